@@ -5,6 +5,11 @@ import (
 	"fmt"
 	handler "github.com/JMURv/simple-s3/internal/hdl/http"
 	cfg "github.com/JMURv/simple-s3/pkg/config"
+	"github.com/JMURv/simple-s3/pkg/index"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/JMURv/simple-s3/pkg/trash"
+	"github.com/JMURv/simple-s3/pkg/versions"
+	"io"
 	"log"
 	"os"
 	"os/signal"
@@ -13,13 +18,18 @@ import (
 
 const configPath = "local.config.yaml"
 
-func gracefulShutdown(cancel context.CancelFunc) {
+func gracefulShutdown(cancel context.CancelFunc, closers ...io.Closer) {
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	<-ch
 
 	log.Println("Shutting down gracefully...")
 	cancel()
+	for _, c := range closers {
+		if err := c.Close(); err != nil {
+			log.Println("Error closing: ", err)
+		}
+	}
 	os.Exit(0)
 }
 
@@ -41,7 +51,48 @@ func main() {
 		}
 	}
 
-	h := handler.New(fmt.Sprintf(":%v", conf.Port), conf.SavePath, conf.HTTP)
-	go gracefulShutdown(cancel)
+	idx, err := index.Open(conf.Index.Path)
+	if err != nil {
+		log.Fatalf("Error opening index: %s\n", err)
+	}
+
+	// `main reindex` rebuilds the index from savePath and exits
+	local := storage.NewLocal(conf.SavePath)
+	reindex := len(os.Args) > 1 && os.Args[1] == "reindex"
+	if reindex || conf.Index.RebuildOnStart || !idx.Built() {
+		n, err := idx.Rebuild(ctx, local)
+		if err != nil {
+			log.Fatalf("Error rebuilding index: %s\n", err)
+		}
+		log.Printf("Indexed %d files\n", n)
+	}
+	if reindex {
+		if err = idx.Close(); err != nil {
+			log.Fatalf("Error closing index: %s\n", err)
+		}
+		return
+	}
+
+	var store storage.Storage = index.Wrap(local, idx)
+	closers := []io.Closer{idx}
+	if conf.Versioning != nil && len(conf.Versioning.Prefixes) > 0 {
+		vs, err := versions.Open(conf.Versioning.Path, conf.Versioning.Prefixes)
+		if err != nil {
+			log.Fatalf("Error opening versions: %s\n", err)
+		}
+		store = versions.Wrap(store, vs)
+		closers = append(closers, vs)
+	}
+	if !conf.Trash.Disabled {
+		bin, err := trash.Open(conf.Trash.Path, store, conf.Trash.Retention)
+		if err != nil {
+			log.Fatalf("Error opening trash: %s\n", err)
+		}
+		store = bin
+		closers = append(closers, bin)
+	}
+
+	h := handler.New(fmt.Sprintf(":%v", conf.Port), conf, store)
+	go gracefulShutdown(cancel, closers...)
 	h.Start(ctx)
 }
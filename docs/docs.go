@@ -15,22 +15,46 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/delete": {
-            "delete": {
-                "description": "Deletes a file from the server",
-                "summary": "Delete a file",
+        "/archive": {
+            "get": {
+                "description": "Streams the given files, and every file below the given directories, as a single archive. Entries are named relative to the parent of the path that selected them, so archiving /uploads/photos yields photos/... Paths whose entries would share a name, such as a/x.txt and b/x.txt, are refused with 409. Nothing is buffered: the archive is written while the files are read.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "summary": "Download an archive",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "File path",
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "File or directory path, may be repeated",
                         "name": "path",
-                        "in": "query",
-                        "required": true
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "zip",
+                        "description": "zip or tar.gz",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Paths and format, for POST requests",
+                        "name": "body",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/model.ArchiveReq"
+                        }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK"
                     },
                     "400": {
                         "description": "Bad Request",
@@ -44,6 +68,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -51,39 +81,63 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/list": {
-            "get": {
-                "description": "Retrieve a list of files from a directory with pagination",
-                "summary": "List files with pagination",
+            },
+            "post": {
+                "description": "Streams the given files, and every file below the given directories, as a single archive. Entries are named relative to the parent of the path that selected them, so archiving /uploads/photos yields photos/... Paths whose entries would share a name, such as a/x.txt and b/x.txt, are refused with 409. Nothing is buffered: the archive is written while the files are read.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "summary": "Download an archive",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Directory path",
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "File or directory path, may be repeated",
                         "name": "path",
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "default": 1,
-                        "description": "Page number",
-                        "name": "page",
+                        "type": "string",
+                        "default": "zip",
+                        "description": "zip or tar.gz",
+                        "name": "format",
                         "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "default": 10,
-                        "description": "Number of items per page",
-                        "name": "size",
-                        "in": "query"
+                        "description": "Paths and format, for POST requests",
+                        "name": "body",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/model.ArchiveReq"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "OK"
+                    },
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/utils.PaginatedResponse"
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
                     "500": {
@@ -95,44 +149,32 @@ const docTemplate = `{
                 }
             }
         },
-        "/search": {
-            "get": {
-                "description": "Retrieve a list of files matching the given name from a directory with pagination",
-                "summary": "Search files",
+        "/copy": {
+            "post": {
+                "description": "Copies the file at src to dst, or every file below the directory src to the same place below dst, without the data leaving the server. overwrite works as for /move.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Copy files",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Search query",
-                        "name": "q",
-                        "in": "query",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Directory path",
-                        "name": "path",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "default": 1,
-                        "description": "Page number",
-                        "name": "page",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "default": 10,
-                        "description": "Number of items per page",
-                        "name": "size",
-                        "in": "query"
+                        "description": "Source, destination and overwrite policy",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.TransferReq"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/utils.PaginatedResponse"
+                            "$ref": "#/definitions/model.TransferRes"
                         }
                     },
                     "400": {
@@ -141,22 +183,30 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.TransferRes"
+                        }
                     }
                 }
             }
         },
-        "/stream/uploads/{path}": {
+        "/cover/{path}": {
             "get": {
-                "description": "Streams a media file for the given path",
+                "description": "Serves the cover art embedded in an MP3, FLAC, Ogg Vorbis or Opus file as stored. With w, h, fit, q or format it is transformed like /img and cached.",
                 "produces": [
-                    "media/*"
+                    "image/jpeg",
+                    "image/png",
+                    "image/gif"
                 ],
-                "summary": "Stream a media file",
+                "summary": "Get the cover art of an audio file",
                 "parameters": [
                     {
                         "type": "string",
@@ -164,20 +214,61 @@ const docTemplate = `{
                         "name": "path",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Width in pixels",
+                        "name": "w",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Height in pixels",
+                        "name": "h",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "contain",
+                        "description": "contain, cover or fill",
+                        "name": "fit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 80,
+                        "description": "JPEG quality",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "jpeg, png or gif",
+                        "name": "format",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK"
                     },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
-                    "415": {
-                        "description": "Unsupported Media Type",
+                    "422": {
+                        "description": "Unprocessable Entity",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
@@ -185,34 +276,22 @@ const docTemplate = `{
                 }
             }
         },
-        "/upload": {
-            "post": {
-                "description": "Uploads a file to a specified path",
-                "consumes": [
-                    "multipart/form-data"
-                ],
-                "summary": "Upload a new file",
+        "/delete": {
+            "delete": {
+                "description": "Deletes a file from the server. Unless the trash is disabled the file is moved to it and can be restored from /trash until its retention passes. A versioned file also leaves a delete marker behind and can be restored from /versions.",
+                "summary": "Delete a file",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Directory path",
+                        "description": "File path",
                         "name": "path",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "file",
-                        "description": "File to upload",
-                        "name": "file",
-                        "in": "formData",
+                        "in": "query",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
-                        "schema": {
-                            "$ref": "#/definitions/model.FileRes"
-                        }
+                    "204": {
+                        "description": "No Content"
                     },
                     "400": {
                         "description": "Bad Request",
@@ -220,8 +299,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
-                    "409": {
-                        "description": "Conflict",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
@@ -234,49 +313,2147 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "model.FileRes": {
-            "type": "object",
-            "properties": {
-                "modTime": {
-                    "type": "integer"
-                },
-                "path": {
-                    "type": "string"
-                }
-            }
         },
-        "utils.ErrorResponse": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string"
+        "/du": {
+            "get": {
+                "description": "Returns the number and size of the files below the directory at path, along with the same totals for each of its direct subdirectories, largest first.",
+                "summary": "Disk usage",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Directory path, the root by default",
+                        "name": "path",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.DirRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
                 }
             }
         },
-        "utils.PaginatedResponse": {
-            "type": "object",
-            "properties": {
-                "count": {
-                    "type": "integer"
-                },
-                "current_page": {
-                    "type": "integer"
-                },
-                "data": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/model.FileRes"
-                    }
-                },
-                "has_next_page": {
-                    "type": "boolean"
-                },
-                "total_pages": {
-                    "type": "integer"
-                }
+        "/img/{path}": {
+            "get": {
+                "description": "Decodes a JPEG, PNG or GIF image and serves it resized to w and h. fit=contain (default) scales the image down to fit the box, fit=cover crops it to fill the box and fit=fill stretches it. q sets the JPEG quality and format converts to jpeg, png or gif. Variants are cached on disk and dropped when the original is deleted; X-Cache reports HIT or MISS.",
+                "produces": [
+                    "image/jpeg",
+                    "image/png",
+                    "image/gif"
+                ],
+                "summary": "Transform an image",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File path",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Width in pixels",
+                        "name": "w",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Height in pixels",
+                        "name": "h",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "contain",
+                        "description": "contain, cover or fill",
+                        "name": "fit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 80,
+                        "description": "JPEG quality",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "jpeg, png or gif",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/lifecycle/archive": {
+            "get": {
+                "description": "Lists the files that lifecycle rules moved to the compressed archive tier below path, ordered by path, with the size they had and the size they take compressed.",
+                "summary": "List archived files",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "/",
+                        "description": "Directory path",
+                        "name": "path",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ArchivedListRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/lifecycle/archive/restore": {
+            "post": {
+                "description": "Decompresses the archived file at path back into storage and takes it out of the archive tier. A file stored at that path since is not replaced and the restore is refused with 409, unless the path is versioned.",
+                "summary": "Restore an archived file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File path",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.FileRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/lifecycle/report": {
+            "get": {
+                "description": "Evaluates the lifecycle rules against every file without applying them and lists the files each rule would expire or archive, ordered by path. at evaluates them at another time, e.g. to see what expires next week.",
+                "summary": "Dry-run the lifecycle rules",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "now",
+                        "description": "RFC 3339 time to evaluate the rules at",
+                        "name": "at",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.LifecycleReportRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/list": {
+            "get": {
+                "description": "Retrieve a list of files from a directory with pagination",
+                "summary": "List files with pagination",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Directory path",
+                        "name": "path",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Number of items per page",
+                        "name": "size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.PaginatedResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/mkdir": {
+            "post": {
+                "description": "Creates the directory at path along with its parents, so it can be listed before anything is uploaded to it. Creating a directory that already exists succeeds. Only backends with real directories, such as the local one, support it.",
+                "summary": "Create a directory",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Directory path",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.DirRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/move": {
+            "post": {
+                "description": "Moves the file at src to dst, or every file below the directory src to the same place below dst. On the local backend a directory is renamed in one step when nothing exists at dst yet, and otherwise every file is renamed atomically and the source directories left empty are removed. overwrite decides what happens to files that already exist at the destination: fail (default) moves nothing and reports them as conflicts with 409, skip leaves them and their sources alone, replace overwrites them. The metadata index follows the files; cached image variants are dropped.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Move or rename files",
+                "parameters": [
+                    {
+                        "description": "Source, destination and overwrite policy",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.TransferReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.TransferRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.TransferRes"
+                        }
+                    }
+                }
+            }
+        },
+        "/multipart": {
+            "post": {
+                "description": "Starts an upload whose parts are sent separately and assembled once complete. The file name is slugified like in /upload.",
+                "summary": "Start a multipart upload",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Directory path",
+                        "name": "path",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "File name",
+                        "name": "filename",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.MultipartUploadRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/multipart/{id}": {
+            "get": {
+                "description": "GET /multipart/{id} lists uploaded parts, PUT /multipart/{id}/{partNumber} uploads a part from the raw request body, POST /multipart/{id}/complete assembles the listed parts and DELETE /multipart/{id} aborts the upload",
+                "summary": "Manage a multipart upload",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Upload ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.PartRes"
+                            }
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.FileRes"
+                        }
+                    },
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/presign": {
+            "post": {
+                "description": "Signs /upload, /uploads/{path} or /stream/uploads/{path} so it can be used without credentials until it expires",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Create a presigned URL",
+                "parameters": [
+                    {
+                        "description": "URL to sign",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.PresignReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.PresignRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/reindex": {
+            "post": {
+                "description": "Rebuilds the index that serves /list and /search from the files currently in savePath, picking up files changed outside the server",
+                "summary": "Rebuild the metadata index",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/rmdir": {
+            "delete": {
+                "description": "Deletes the directory at path. Without recursive only an empty directory is deleted and anything else is refused with 409; with recursive every file below it is deleted first, together with its cached image variants, followed by the directories themselves.",
+                "summary": "Delete a directory",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Directory path",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Delete the content too",
+                        "name": "recursive",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.RmDirRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/search": {
+            "get": {
+                "description": "Retrieve a list of files matching the given name from a directory with pagination",
+                "summary": "Search files",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Search query",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Directory path",
+                        "name": "path",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Number of items per page",
+                        "name": "size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.PaginatedResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stat": {
+            "get": {
+                "description": "Returns the size, checksums and content type of a file. Images also report their dimensions, orientation, camera, capture date, whether they carry a GPS location and their placeholder. Videos report their container, duration, resolution, codecs and, for MP4, whether they are faststart. Audio files report their tags, duration and cover art URL. All of these are computed on the first request if the upload did not.",
+                "summary": "Describe a file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File path",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.StatRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stream/uploads/{path}": {
+            "get": {
+                "description": "Streams a media file for the given path. The media type is detected from the file contents and has to match http.streamableTypes. Single and multiple byte ranges are served as 206 Partial Content, and If-None-Match, If-Modified-Since and If-Range are honoured.",
+                "produces": [
+                    "media/*"
+                ],
+                "summary": "Stream a media file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File path",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Byte ranges, e.g. bytes=0-1023",
+                        "name": "Range",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "206": {
+                        "description": "Partial Content"
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "416": {
+                        "description": "Requested Range Not Satisfiable",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/thumbnails": {
+            "get": {
+                "description": "Lists the thumbnail jobs that are pending, running or have failed, optionally for a single file. Finished thumbnails are not listed, and only the latest failed jobs are kept.",
+                "summary": "Thumbnail status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File path",
+                        "name": "path",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ThumbnailStatusRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/trash": {
+            "get": {
+                "description": "Lists the files moved to the trash by deletes, most recently deleted first, with the path they are restored to and when they are purged for good.",
+                "summary": "List the trash",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Number of items per page",
+                        "name": "size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.TrashListRes"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/trash/empty": {
+            "delete": {
+                "description": "Permanently deletes the trash item id, or every item in the trash when id is omitted. Items are also purged automatically once the configured retention has passed.",
+                "summary": "Empty the trash",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Trash item ID",
+                        "name": "id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.PurgeRes"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/trash/restore": {
+            "post": {
+                "description": "Moves the trash item id back to the path it was deleted from, recreating its directories. A file stored at that path since is not replaced and the restore is refused with 409, unless the path is versioned.",
+                "summary": "Restore from the trash",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Trash item ID",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.FileRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tree": {
+            "get": {
+                "description": "Returns the directory at path with its subdirectories nested inside it, sorted by name, each with the number and size of the files directly in it and of everything below it. Empty directories are included on backends that have them. depth limits how many levels are nested; the totals always cover everything.",
+                "summary": "Directory tree",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Directory path, the root by default",
+                        "name": "path",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Levels of subdirectories to include, 0 for all",
+                        "name": "depth",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.DirRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tus/{id}": {
+            "patch": {
+                "description": "Implements the tus 1.0 core protocol with the creation, termination and expiration extensions. POST /tus creates an upload from Upload-Length and Upload-Metadata (filename, optional path), HEAD /tus/{id} reports the Upload-Offset, PATCH /tus/{id} appends bytes at Upload-Offset and DELETE /tus/{id} discards the upload. Finished uploads are stored at the same slugified path as /upload.",
+                "summary": "Resumable upload (tus 1.0)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Upload ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Protocol version, 1.0.0",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset of the PATCH body",
+                        "name": "Upload-Offset",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK"
+                    },
+                    "201": {
+                        "description": "Created"
+                    },
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Gone",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/upload": {
+            "post": {
+                "description": "Uploads a file to a specified path. MP4 files whose moov atom comes after the media data are stored with a warning. With extract=true a ZIP, TAR or tar.gz file is unpacked into the path instead and the outcome of every entry is reported as a model.ExtractRes. An existing file is refused with 409 unless its path is versioned, in which case it becomes a previous version.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "summary": "Upload a new file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Directory path",
+                        "name": "path",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "file",
+                        "description": "File to upload",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Remove EXIF, XMP and IPTC metadata from JPEG and PNG images",
+                        "name": "stripMetadata",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Rotate JPEG images upright according to their EXIF orientation",
+                        "name": "autoOrient",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Unpack the uploaded archive into path",
+                        "name": "extract",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tags for lifecycle rules, URL-encoded as k1=v1\u0026k2=v2",
+                        "name": "tags",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "extract=true: no entry could be stored; otherwise 201 with the same body",
+                        "schema": {
+                            "$ref": "#/definitions/model.ExtractRes"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.FileRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/uploads/{path}": {
+            "get": {
+                "description": "Serves the file stored at the given path, or one of its previous versions with versionId. A path of the form archive.zip!/dir/file.txt serves a file inside a stored ZIP archive without extracting it, with Range support for entries stored uncompressed. archive.zip!/ and archive.zip!/dir/ list the files of the archive below that directory.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "summary": "Download a file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File path",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Version ID, on versioned paths",
+                        "name": "versionId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number, for archive listings",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Number of items per page, for archive listings",
+                        "name": "size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "File content, or the listing of an archive directory",
+                        "schema": {
+                            "$ref": "#/definitions/model.ArchiveListRes"
+                        }
+                    },
+                    "206": {
+                        "description": "Partial Content"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/versions": {
+            "get": {
+                "description": "Lists the versions of the file at path, newest first, including its delete markers. A directory lists the versions of every versioned file below it, ordered by path. Files stored without versioning report a single version with the ID \"null\".",
+                "summary": "List versions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "/",
+                        "description": "File or directory path",
+                        "name": "path",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.VersionsRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/versions/purge": {
+            "delete": {
+                "description": "Permanently deletes the version id of the file at path, or every previous version and delete marker when id is omitted. The current version is never purged; delete the file first.",
+                "summary": "Purge versions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File path",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Version ID",
+                        "name": "id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.PurgeRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/versions/restore": {
+            "post": {
+                "description": "Stores a copy of the version id of the file at path as its current version. The version it replaces, or the delete marker hiding the file, stays in the history, so a restore can itself be undone.",
+                "summary": "Restore a version",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "File path",
+                        "name": "path",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Version ID",
+                        "name": "id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/model.FileRes"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "model.ArchiveEntryRes": {
+            "type": "object",
+            "properties": {
+                "compressedSize": {
+                    "type": "integer"
+                },
+                "modTime": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "path": {
+                    "description": "Path is the virtual path of the entry: the path of the archive, !\nand the name of the entry",
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "stored": {
+                    "description": "Stored entries are not compressed, so they can be read in ranges",
+                    "type": "boolean"
+                }
+            }
+        },
+        "model.ArchiveListRes": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "current_page": {
+                    "type": "integer"
+                },
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ArchiveEntryRes"
+                    }
+                },
+                "has_next_page": {
+                    "type": "boolean"
+                },
+                "total_pages": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.ArchiveReq": {
+            "type": "object",
+            "properties": {
+                "format": {
+                    "type": "string"
+                },
+                "paths": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "model.ArchivedListRes": {
+            "type": "object",
+            "properties": {
+                "files": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ArchivedRes"
+                    }
+                }
+            }
+        },
+        "model.ArchivedRes": {
+            "type": "object",
+            "properties": {
+                "archivedAt": {
+                    "type": "integer"
+                },
+                "compressedSize": {
+                    "type": "integer"
+                },
+                "contentType": {
+                    "type": "string"
+                },
+                "etag": {
+                    "type": "string"
+                },
+                "modTime": {
+                    "type": "integer"
+                },
+                "originalName": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "sha256": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "tags": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "model.AudioMetaRes": {
+            "type": "object",
+            "properties": {
+                "album": {
+                    "type": "string"
+                },
+                "artist": {
+                    "type": "string"
+                },
+                "cover": {
+                    "type": "string"
+                },
+                "duration": {
+                    "type": "number"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "genre": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "track": {
+                    "type": "string"
+                },
+                "year": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.DirRes": {
+            "type": "object",
+            "properties": {
+                "dirs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.DirRes"
+                    }
+                },
+                "files": {
+                    "type": "integer"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "totalFiles": {
+                    "type": "integer"
+                },
+                "totalSize": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.ExtractEntryRes": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "file": {
+                    "$ref": "#/definitions/model.FileRes"
+                },
+                "name": {
+                    "description": "Name is the path of the entry inside the archive",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status is extracted, rejected for entries that are unsafe or not\nregular files, or failed when storing the file did not work",
+                    "type": "string"
+                }
+            }
+        },
+        "model.ExtractRes": {
+            "type": "object",
+            "properties": {
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ExtractEntryRes"
+                    }
+                },
+                "extracted": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "rejected": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.FileRes": {
+            "type": "object",
+            "properties": {
+                "audio": {
+                    "description": "Audio is set for MP3, FLAC, Ogg Vorbis and Opus files once their tags\nhave been read, in the same way as Placeholder",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.AudioMetaRes"
+                        }
+                    ]
+                },
+                "contentType": {
+                    "type": "string"
+                },
+                "etag": {
+                    "type": "string"
+                },
+                "modTime": {
+                    "type": "integer"
+                },
+                "originalName": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "placeholder": {
+                    "description": "Placeholder is set for images once it has been computed, which\nhappens on upload or the first time the image is described by /stat",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.PlaceholderRes"
+                        }
+                    ]
+                },
+                "sha256": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "tags": {
+                    "description": "Tags label the file for lifecycle rules, as sent with its upload",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "thumbnails": {
+                    "description": "Thumbnails maps preset names to their URLs. Only upload responses\ncarry them; they are rendered in the background.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "video": {
+                    "description": "Video is set for MP4, MOV, WebM and Matroska files once they have\nbeen probed, in the same way as Placeholder",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.VideoMetaRes"
+                        }
+                    ]
+                },
+                "warnings": {
+                    "description": "Warnings point out problems with an upload that didn't stop it from\nbeing stored. Only upload responses carry them.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "model.ImageMetaRes": {
+            "type": "object",
+            "properties": {
+                "format": {
+                    "type": "string"
+                },
+                "hasLocation": {
+                    "type": "boolean"
+                },
+                "height": {
+                    "type": "integer"
+                },
+                "lensModel": {
+                    "type": "string"
+                },
+                "make": {
+                    "type": "string"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "orientation": {
+                    "type": "integer"
+                },
+                "software": {
+                    "type": "string"
+                },
+                "takenAt": {
+                    "type": "string"
+                },
+                "width": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.LifecycleFileRes": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "modTime": {
+                    "type": "integer"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "rule": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.LifecycleReportRes": {
+            "type": "object",
+            "properties": {
+                "archive": {
+                    "type": "integer"
+                },
+                "at": {
+                    "type": "integer"
+                },
+                "expire": {
+                    "type": "integer"
+                },
+                "files": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.LifecycleFileRes"
+                    }
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.MultipartUploadRes": {
+            "type": "object",
+            "properties": {
+                "path": {
+                    "type": "string"
+                },
+                "uploadId": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.PartRes": {
+            "type": "object",
+            "properties": {
+                "etag": {
+                    "type": "string"
+                },
+                "modTime": {
+                    "type": "integer"
+                },
+                "partNumber": {
+                    "type": "integer"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.PlaceholderRes": {
+            "type": "object",
+            "properties": {
+                "blurHash": {
+                    "type": "string"
+                },
+                "color": {
+                    "description": "Color is the dominant colour as #rrggbb",
+                    "type": "string"
+                },
+                "preview": {
+                    "description": "Preview is a data URI of the image scaled down to 16 pixels",
+                    "type": "string"
+                }
+            }
+        },
+        "model.PresignReq": {
+            "type": "object",
+            "properties": {
+                "expiresIn": {
+                    "type": "integer"
+                },
+                "maxLength": {
+                    "type": "integer"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.PresignRes": {
+            "type": "object",
+            "properties": {
+                "expiresAt": {
+                    "type": "integer"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.PurgeRes": {
+            "type": "object",
+            "properties": {
+                "purged": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.RmDirRes": {
+            "type": "object",
+            "properties": {
+                "dirs": {
+                    "type": "integer"
+                },
+                "files": {
+                    "type": "integer"
+                },
+                "path": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.StatRes": {
+            "type": "object",
+            "properties": {
+                "audio": {
+                    "description": "Audio is set for MP3, FLAC, Ogg Vorbis and Opus files once their tags\nhave been read, in the same way as Placeholder",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.AudioMetaRes"
+                        }
+                    ]
+                },
+                "contentType": {
+                    "type": "string"
+                },
+                "etag": {
+                    "type": "string"
+                },
+                "image": {
+                    "$ref": "#/definitions/model.ImageMetaRes"
+                },
+                "modTime": {
+                    "type": "integer"
+                },
+                "originalName": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "placeholder": {
+                    "description": "Placeholder is set for images once it has been computed, which\nhappens on upload or the first time the image is described by /stat",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.PlaceholderRes"
+                        }
+                    ]
+                },
+                "sha256": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "tags": {
+                    "description": "Tags label the file for lifecycle rules, as sent with its upload",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "thumbnails": {
+                    "description": "Thumbnails maps preset names to their URLs. Only upload responses\ncarry them; they are rendered in the background.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "video": {
+                    "description": "Video is set for MP4, MOV, WebM and Matroska files once they have\nbeen probed, in the same way as Placeholder",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.VideoMetaRes"
+                        }
+                    ]
+                },
+                "warnings": {
+                    "description": "Warnings point out problems with an upload that didn't stop it from\nbeing stored. Only upload responses carry them.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "model.ThumbnailJobRes": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "preset": {
+                    "type": "string"
+                },
+                "queued": {
+                    "type": "integer"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "updated": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.ThumbnailStatusRes": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "type": "integer"
+                },
+                "jobs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ThumbnailJobRes"
+                    }
+                },
+                "pending": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.TransferEntryRes": {
+            "type": "object",
+            "properties": {
+                "dst": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "file": {
+                    "$ref": "#/definitions/model.FileRes"
+                },
+                "src": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status is moved or copied, skipped when the destination exists and\noverwrite is skip, conflict when it exists and overwrite is fail, or\nfailed",
+                    "type": "string"
+                }
+            }
+        },
+        "model.TransferReq": {
+            "type": "object",
+            "properties": {
+                "dst": {
+                    "type": "string"
+                },
+                "overwrite": {
+                    "description": "Overwrite is fail (default), skip or replace",
+                    "type": "string"
+                },
+                "src": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.TransferRes": {
+            "type": "object",
+            "properties": {
+                "done": {
+                    "type": "integer"
+                },
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.TransferEntryRes"
+                    }
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "skipped": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.TrashItemRes": {
+            "type": "object",
+            "properties": {
+                "contentType": {
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "type": "integer"
+                },
+                "etag": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "modTime": {
+                    "type": "integer"
+                },
+                "originalName": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "sha256": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.TrashListRes": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "current_page": {
+                    "type": "integer"
+                },
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.TrashItemRes"
+                    }
+                },
+                "has_next_page": {
+                    "type": "boolean"
+                },
+                "total_pages": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.VersionRes": {
+            "type": "object",
+            "properties": {
+                "contentType": {
+                    "type": "string"
+                },
+                "deleteMarker": {
+                    "type": "boolean"
+                },
+                "etag": {
+                    "type": "string"
+                },
+                "latest": {
+                    "type": "boolean"
+                },
+                "modTime": {
+                    "type": "integer"
+                },
+                "originalName": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "sha256": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "versionId": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.VersionsRes": {
+            "type": "object",
+            "properties": {
+                "versions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.VersionRes"
+                    }
+                }
+            }
+        },
+        "model.VideoMetaRes": {
+            "type": "object",
+            "properties": {
+                "audioCodec": {
+                    "type": "string"
+                },
+                "container": {
+                    "type": "string"
+                },
+                "duration": {
+                    "type": "number"
+                },
+                "faststart": {
+                    "type": "boolean"
+                },
+                "height": {
+                    "type": "integer"
+                },
+                "videoCodec": {
+                    "type": "string"
+                },
+                "width": {
+                    "type": "integer"
+                }
+            }
+        },
+        "utils.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.PaginatedResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "current_page": {
+                    "type": "integer"
+                },
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.FileRes"
+                    }
+                },
+                "has_next_page": {
+                    "type": "boolean"
+                },
+                "total_pages": {
+                    "type": "integer"
+                }
+            }
+        },
+        "utils.Response": {
+            "type": "object",
+            "properties": {
+                "msg": {}
             }
         }
     }
@@ -0,0 +1,438 @@
+package http
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/JMURv/simple-s3/pkg/unpack"
+	utils "github.com/JMURv/simple-s3/pkg/utils/http"
+	"github.com/JMURv/simple-s3/pkg/utils/mediatype"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	archiveZip   = "zip"
+	archiveTarGz = "tar.gz"
+)
+
+// archiveEntry is an object together with its name inside the archive
+type archiveEntry struct {
+	name string
+	info storage.ObjectInfo
+}
+
+// archive streams a ZIP or gzipped TAR of files and directories
+// @Summary Download an archive
+// @Description Streams the given files, and every file below the given directories, as a single archive. Entries are named relative to the parent of the path that selected them, so archiving /uploads/photos yields photos/... Paths whose entries would share a name, such as a/x.txt and b/x.txt, are refused with 409. Nothing is buffered: the archive is written while the files are read.
+// @Accept json
+// @Produce octet-stream
+// @Param path query []string false "File or directory path, may be repeated" collectionFormat(multi)
+// @Param format query string false "zip or tar.gz" default(zip)
+// @Param body body model.ArchiveReq false "Paths and format, for POST requests"
+// @Success 200
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Router /archive [get]
+// @Router /archive [post]
+func (h *Handler) archive(w http.ResponseWriter, r *http.Request) {
+	req := &model.ArchiveReq{}
+	switch r.Method {
+	case http.MethodGet:
+		req.Paths = r.URL.Query()["path"]
+		req.Format = r.URL.Query().Get("format")
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidBody)
+			return
+		}
+	default:
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+
+	format := strings.ToLower(req.Format)
+	switch format {
+	case "":
+		format = archiveZip
+	case archiveZip, archiveTarGz:
+	case "tgz":
+		format = archiveTarGz
+	default:
+		utils.ErrResponse(w, http.StatusBadRequest, ErrArchiveFormat)
+		return
+	}
+
+	if len(req.Paths) == 0 {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrPathNotProvided)
+		return
+	}
+
+	// Everything that can fail with a status code is checked before the
+	// first byte of the archive is written
+	entries, err := h.archiveEntries(r.Context(), req.Paths)
+	if errors.Is(err, ErrInvalidPath) {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	} else if errors.Is(err, storage.ErrNotFound) {
+		utils.ErrResponse(w, http.StatusNotFound, ErrRetrievingFile)
+		return
+	} else if errors.Is(err, ErrArchiveNameTaken) {
+		utils.ErrResponse(w, http.StatusConflict, err)
+		return
+	} else if err != nil {
+		log.Println("Error reading directory: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrReadingDir)
+		return
+	}
+
+	name := "archive"
+	if len(req.Paths) == 1 {
+		if key, ok := h.objectKey(req.Paths[0]); ok {
+			name = path.Base(key)
+		}
+	}
+
+	w.Header().Set(
+		"Content-Disposition",
+		mime.FormatMediaType("attachment", map[string]string{"filename": name + "." + format}),
+	)
+	if format == archiveZip {
+		w.Header().Set("Content-Type", "application/zip")
+		err = h.writeZip(r.Context(), w, entries)
+	} else {
+		w.Header().Set("Content-Type", "application/gzip")
+		err = h.writeTarGz(r.Context(), w, entries)
+	}
+
+	// The status is already sent, so a failure can only cut the archive
+	// short. It is left without its trailer, which clients report as
+	// corrupt instead of quietly extracting part of it.
+	if err != nil {
+		log.Println("Error writing archive: ", err)
+	}
+}
+
+// archiveEntries resolves the requested paths to the objects they select,
+// in the order they were requested and without duplicates. A path naming
+// an object selects it; otherwise it selects every object below it, just
+// like /list does. Two objects that would get the same name inside the
+// archive, such as a/x.txt and b/x.txt, are refused with ErrArchiveNameTaken
+// rather than letting one overwrite the other on extraction.
+func (h *Handler) archiveEntries(ctx context.Context, paths []string) ([]archiveEntry, error) {
+	entries := make([]archiveEntry, 0, len(paths))
+	seen := make(map[string]bool, len(paths))
+	names := make(map[string]bool, len(paths))
+	add := func(name string, info storage.ObjectInfo) error {
+		if seen[info.Key] {
+			return nil
+		}
+		if names[name] {
+			return fmt.Errorf("%w: %s", ErrArchiveNameTaken, name)
+		}
+		seen[info.Key], names[name] = true, true
+		entries = append(entries, archiveEntry{name: name, info: info})
+		return nil
+	}
+
+	for _, p := range paths {
+		key, ok := h.dirKey(p)
+		if !ok {
+			return nil, ErrInvalidPath
+		}
+
+		if key != "" {
+			if info, err := h.storage.Stat(ctx, key); err == nil {
+				if err = add(path.Base(key), *info); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+
+		prefix, parent := "", ""
+		if key != "" {
+			prefix, parent = key+"/", path.Dir(key)
+		}
+		objects, _, err := h.storage.List(ctx, prefix, "", 0)
+		if errors.Is(err, storage.ErrInvalidKey) {
+			return nil, ErrInvalidPath
+		} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if len(objects) == 0 {
+			return nil, storage.ErrNotFound
+		}
+
+		for _, obj := range objects {
+			name := obj.Key
+			if parent != "." && parent != "" {
+				name = strings.TrimPrefix(obj.Key, parent+"/")
+			}
+			if err = add(name, obj); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entries, nil
+}
+
+// writeZip writes entries as a ZIP archive. Media and archives are already
+// compressed, so they are stored rather than deflated again.
+func (h *Handler) writeZip(ctx context.Context, w io.Writer, entries []archiveEntry) error {
+	zw := zip.NewWriter(w)
+	for _, e := range entries {
+		method := zip.Deflate
+		if compressed(e.info.ContentType) {
+			method = zip.Store
+		}
+
+		dst, err := zw.CreateHeader(
+			&zip.FileHeader{
+				Name:     e.name,
+				Method:   method,
+				Modified: e.info.ModTime,
+			},
+		)
+		if err != nil {
+			return err
+		}
+		if err = h.copyObject(ctx, dst, e.info); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// writeTarGz writes entries as a gzipped TAR archive
+func (h *Handler) writeTarGz(ctx context.Context, w io.Writer, entries []archiveEntry) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		err := tw.WriteHeader(
+			&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     e.name,
+				Size:     e.info.Size,
+				Mode:     0644,
+				ModTime:  e.info.ModTime,
+			},
+		)
+		if err != nil {
+			return err
+		}
+		if err = h.copyObject(ctx, tw, e.info); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// copyObject streams the content of an object to w
+func (h *Handler) copyObject(ctx context.Context, w io.Writer, info storage.ObjectInfo) error {
+	file, _, err := h.storage.Get(ctx, info.Key, 0, info.Size)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// compressed reports whether a media type is already compressed
+func compressed(contentType string) bool {
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	switch mediaType {
+	case "image/svg+xml", "image/bmp", "image/tiff", "audio/wav", "audio/x-wav":
+		return false
+	case "application/zip", "application/gzip", "application/x-gzip", "application/x-7z-compressed",
+		"application/x-bzip2", "application/x-xz", "application/zstd", "application/x-rar-compressed":
+		return true
+	}
+	return strings.HasPrefix(mediaType, "image/") ||
+		strings.HasPrefix(mediaType, "video/") ||
+		strings.HasPrefix(mediaType, "audio/")
+}
+
+// archiveSeparator splits a virtual path into the key of a stored archive
+// and the name of an entry inside it
+const archiveSeparator = "!/"
+
+// splitArchivePath splits a virtual path such as bundle.zip!/dir/file.txt.
+// bundle.zip! alone stands for the root of the archive.
+func splitArchivePath(key string) (string, string, bool) {
+	if archiveKey, name, ok := strings.Cut(key, archiveSeparator); ok {
+		return archiveKey, strings.Trim(name, "/"), archiveKey != ""
+	}
+	if archiveKey, ok := strings.CutSuffix(key, "!"); ok && archiveKey != "" {
+		return archiveKey, "", true
+	}
+	return "", "", false
+}
+
+// archiveEntry serves the file called name inside the ZIP archive stored at
+// archiveKey, or lists the files below name when it is a directory. The
+// archive is read in place through its central directory.
+func (h *Handler) archiveEntry(w http.ResponseWriter, r *http.Request, archiveKey, name string) {
+	info, err := h.storage.Stat(r.Context(), archiveKey)
+	if err != nil {
+		utils.ErrResponse(w, http.StatusNotFound, ErrRetrievingFile)
+		return
+	}
+
+	content := storage.NewReadSeeker(r.Context(), h.storage, info)
+	defer content.Close()
+
+	archive, err := unpack.Open(content, info.Size)
+	if errors.Is(err, unpack.ErrUnsupported) {
+		utils.ErrResponse(w, http.StatusUnsupportedMediaType, ErrUnsupportedMediaType)
+		return
+	} else if err != nil {
+		utils.ErrResponse(w, http.StatusUnprocessableEntity, ErrInvalidArchive)
+		return
+	}
+
+	// Only ZIP archives can be read without going through all of them
+	files, err := archive.Files()
+	if err != nil {
+		utils.ErrResponse(w, http.StatusUnsupportedMediaType, ErrUnsupportedMediaType)
+		return
+	}
+
+	prefix := ""
+	if name != "" {
+		prefix = name + "/"
+	}
+
+	listed := make([]model.ArchiveEntryRes, 0, len(files))
+	for i := range files {
+		f := &files[i]
+		if f.Name == name {
+			h.serveArchiveFile(w, r, info, f)
+			return
+		}
+		if strings.HasPrefix(f.Name, prefix) {
+			listed = append(
+				listed, model.ArchiveEntryRes{
+					Path:           h.publicPath(archiveKey) + archiveSeparator + f.Name,
+					Name:           f.Name,
+					Size:           f.Size,
+					CompressedSize: f.CompressedSize,
+					ModTime:        f.ModTime.Unix(),
+					Stored:         f.Stored,
+				},
+			)
+		}
+	}
+	if name != "" && len(listed) == 0 {
+		utils.ErrResponse(w, http.StatusNotFound, ErrRetrievingFile)
+		return
+	}
+
+	page, size := utils.ParsePaginationParams(r, h.config.DefaultPage, h.config.DefaultSize)
+	count := len(listed)
+	start := min((page-1)*size, count)
+	end := min(start+size, count)
+
+	totalPages := (count + size - 1) / size
+	utils.SuccessDataResponse(
+		w, http.StatusOK, model.ArchiveListRes{
+			Data:        listed[start:end],
+			Count:       count,
+			TotalPages:  totalPages,
+			CurrentPage: page,
+			HasNextPage: page < totalPages,
+		},
+	)
+}
+
+// serveArchiveFile serves a file of a ZIP archive. Stored files are served
+// like any other file, ranges included; compressed ones can only be
+// decompressed from the start, so they are always sent whole.
+func (h *Handler) serveArchiveFile(w http.ResponseWriter, r *http.Request, archive *storage.ObjectInfo, f *unpack.File) {
+	modTime := f.ModTime
+	if modTime.IsZero() {
+		modTime = archive.ModTime
+	}
+
+	contentType, err := sniffArchiveFile(f)
+	if err != nil {
+		log.Println("Error reading archive entry: ", err)
+		utils.ErrResponse(w, http.StatusUnprocessableEntity, ErrInvalidArchive)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", fmt.Sprintf(`W/"%08x-%x"`, f.CRC32, f.Size))
+
+	if f.Stored {
+		section, err := f.Section()
+		if err != nil {
+			utils.ErrResponse(w, http.StatusUnprocessableEntity, ErrInvalidArchive)
+			return
+		}
+		http.ServeContent(w, r, f.Name, modTime, section)
+		return
+	}
+
+	w.Header().Set("Accept-Ranges", "none")
+	w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	if status := utils.CheckPreconditions(r, w.Header().Get("ETag"), modTime); status != 0 {
+		if status == http.StatusNotModified {
+			w.Header().Del("Content-Type")
+			w.WriteHeader(status)
+			return
+		}
+		utils.ErrResponse(w, status, ErrPrecondition)
+		return
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		utils.ErrResponse(w, http.StatusUnprocessableEntity, ErrInvalidArchive)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Length", strconv.FormatInt(f.Size, 10))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err = io.Copy(w, rc); err != nil {
+		log.Println("Error streaming archive entry: ", err)
+	}
+}
+
+// sniffArchiveFile detects the media type of a file inside an archive from
+// its leading bytes
+func sniffArchiveFile(f *unpack.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	head := make([]byte, min(f.Size, mediatype.SniffLen))
+	if _, err = io.ReadFull(rc, head); err != nil {
+		return "", err
+	}
+	return mediatype.Detect(head), nil
+}
@@ -0,0 +1,282 @@
+package http
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+)
+
+// readZip returns the entries of a ZIP archive by name
+func readZip(t *testing.T, data []byte) map[string]string {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := make(map[string]string, len(zr.File))
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+// readTarGz returns the entries of a gzipped TAR archive by name
+func readTarGz(t *testing.T, data []byte) map[string]string {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	files := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = string(content)
+	}
+	return files
+}
+
+func TestArchive(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupTestHandler()
+
+	photos := path.Dir(uploadFile(t, hdl, "photos", "a.txt", []byte("first")).Path)
+	uploadFile(t, hdl, "photos/2024", "b.txt", []byte("second"))
+	docs := path.Dir(uploadFile(t, hdl, "docs", "c.txt", []byte("third")).Path)
+	uploadFile(t, hdl, "other", "c.txt", []byte("other third"))
+	uploadFile(t, hdl, "other/2024", "b.txt", []byte("other second"))
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		hdl.archive(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	t.Run(
+		"Directory As Zip", func(t *testing.T) {
+			rec := get("/archive?path=" + photos)
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "application/zip", rec.Header().Get("Content-Type"))
+			assert.Equal(t, `attachment; filename=photos.zip`, rec.Header().Get("Content-Disposition"))
+			assert.Equal(
+				t, map[string]string{
+					"photos/a.txt":      "first",
+					"photos/2024/b.txt": "second",
+				}, readZip(t, rec.Body.Bytes()),
+			)
+		},
+	)
+
+	t.Run(
+		"Nested Directory", func(t *testing.T) {
+			rec := get("/archive?path=photos/2024")
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, map[string]string{"2024/b.txt": "second"}, readZip(t, rec.Body.Bytes()))
+		},
+	)
+
+	t.Run(
+		"Selection As Tar", func(t *testing.T) {
+			rec := get("/archive?format=tar.gz&path=docs/c.txt&path=photos/2024&path=photos/2024/b.txt")
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "application/gzip", rec.Header().Get("Content-Type"))
+			assert.Equal(t, `attachment; filename=archive.tar.gz`, rec.Header().Get("Content-Disposition"))
+			assert.Equal(
+				t, map[string]string{
+					"c.txt":      "third",
+					"2024/b.txt": "second",
+				}, readTarGz(t, rec.Body.Bytes()),
+			)
+		},
+	)
+
+	t.Run(
+		"Post", func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			body := strings.NewReader(`{"paths":["` + docs + `"],"format":"zip"}`)
+			hdl.archive(rec, httptest.NewRequest(http.MethodPost, "/archive", body))
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, map[string]string{"docs/c.txt": "third"}, readZip(t, rec.Body.Bytes()))
+		},
+	)
+
+	t.Run(
+		"Everything", func(t *testing.T) {
+			rec := get("/archive?path=" + path.Dir(docs))
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Len(t, readZip(t, rec.Body.Bytes()), 5)
+		},
+	)
+
+	t.Run(
+		"Errors", func(t *testing.T) {
+			tests := []struct {
+				name   string
+				target string
+				status int
+			}{
+				{"No Path", "/archive", http.StatusBadRequest},
+				{"Unknown Format", "/archive?path=docs&format=rar", http.StatusBadRequest},
+				{"Invalid Path", "/archive?path=docs/c?.txt", http.StatusBadRequest},
+				{"Traversal", "/archive?path=docs/../../secret", http.StatusBadRequest},
+				{"Missing", "/archive?path=missing", http.StatusNotFound},
+				{"One Missing", "/archive?path=docs&path=missing.txt", http.StatusNotFound},
+				{"Same File Name", "/archive?path=docs/c.txt&path=other/c.txt", http.StatusConflict},
+				{"Same Directory Name", "/archive?path=photos/2024&path=other/2024", http.StatusConflict},
+			}
+
+			for _, tt := range tests {
+				t.Run(
+					tt.name, func(t *testing.T) {
+						rec := get(tt.target)
+						assert.Equal(t, tt.status, rec.Code)
+						assert.Empty(t, rec.Header().Get("Content-Disposition"))
+					},
+				)
+			}
+		},
+	)
+}
+
+// testBundle builds a ZIP with a stored and a deflated file below dir, a
+// file at its root and an entry escaping it
+func testBundle(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range []struct {
+		name, content string
+		method        uint16
+	}{
+		{"dir/stored.txt", "stored content", zip.Store},
+		{"dir/deflated.txt", strings.Repeat("deflated ", 100), zip.Deflate},
+		{"readme.md", "# Bundle", zip.Deflate},
+		{"../evil.txt", "evil", zip.Store},
+	} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: e.name, Method: e.method})
+		require.NoError(t, err)
+		w.Write([]byte(e.content))
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestArchivePaths(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupTestHandler()
+
+	uploadFile(t, hdl, "bundles", "bundle.zip", testBundle(t))
+	uploadFile(t, hdl, "bundles", "notes.txt", []byte("not an archive"))
+
+	get := func(target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		hdl.download(rec, req)
+		return rec
+	}
+
+	list := func(t *testing.T, target string) model.ArchiveListRes {
+		rec := get(target, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		res := model.ArchiveListRes{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return res
+	}
+
+	t.Run(
+		"List", func(t *testing.T) {
+			res := list(t, "/uploads/bundles/bundle.zip!/")
+			assert.Equal(t, 3, res.Count)
+			require.Len(t, res.Data, 3)
+			assert.Equal(t, "dir/stored.txt", res.Data[0].Name)
+			assert.True(t, strings.HasSuffix(res.Data[0].Path, "/bundles/bundle.zip!/dir/stored.txt"))
+			assert.True(t, res.Data[0].Stored)
+			assert.False(t, res.Data[1].Stored)
+			assert.Less(t, res.Data[1].CompressedSize, res.Data[1].Size)
+
+			assert.Equal(t, 3, list(t, "/uploads/bundles/bundle.zip!").Count)
+			assert.Equal(t, 2, list(t, "/uploads/bundles/bundle.zip!/dir/").Count)
+
+			page := list(t, "/uploads/bundles/bundle.zip!/?size=1&page=2")
+			assert.Equal(t, 3, page.TotalPages)
+			require.Len(t, page.Data, 1)
+			assert.Equal(t, "dir/deflated.txt", page.Data[0].Name)
+		},
+	)
+
+	t.Run(
+		"Stored Range", func(t *testing.T) {
+			rec := get("/uploads/bundles/bundle.zip!/dir/stored.txt", nil)
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "stored content", rec.Body.String())
+			assert.Equal(t, "bytes", rec.Header().Get("Accept-Ranges"))
+			assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain"))
+
+			rec = get("/uploads/bundles/bundle.zip!/dir/stored.txt", http.Header{"Range": {"bytes=7-13"}})
+			require.Equal(t, http.StatusPartialContent, rec.Code)
+			assert.Equal(t, "content", rec.Body.String())
+			assert.Equal(t, "bytes 7-13/14", rec.Header().Get("Content-Range"))
+		},
+	)
+
+	t.Run(
+		"Deflated", func(t *testing.T) {
+			rec := get("/uploads/bundles/bundle.zip!/dir/deflated.txt", http.Header{"Range": {"bytes=0-7"}})
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, strings.Repeat("deflated ", 100), rec.Body.String())
+			assert.Equal(t, "none", rec.Header().Get("Accept-Ranges"))
+			assert.Equal(t, "900", rec.Header().Get("Content-Length"))
+
+			etag := rec.Header().Get("ETag")
+			require.NotEmpty(t, etag)
+			rec = get("/uploads/bundles/bundle.zip!/dir/deflated.txt", http.Header{"If-None-Match": {etag}})
+			assert.Equal(t, http.StatusNotModified, rec.Code)
+		},
+	)
+
+	t.Run(
+		"Errors", func(t *testing.T) {
+			tests := []struct {
+				name   string
+				target string
+				status int
+			}{
+				{"Missing Entry", "/uploads/bundles/bundle.zip!/dir/missing.txt", http.StatusNotFound},
+				{"Escaping Entry", "/uploads/bundles/bundle.zip!/../evil.txt", http.StatusBadRequest},
+				{"Missing Archive", "/uploads/bundles/missing.zip!/readme.md", http.StatusNotFound},
+				{"Not An Archive", "/uploads/bundles/notes.txt!/", http.StatusUnsupportedMediaType},
+			}
+
+			for _, tt := range tests {
+				t.Run(
+					tt.name, func(t *testing.T) {
+						assert.Equal(t, tt.status, get(tt.target, nil).Code)
+					},
+				)
+			}
+		},
+	)
+}
@@ -0,0 +1,240 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"github.com/JMURv/simple-s3/pkg/audiotag"
+	"github.com/JMURv/simple-s3/pkg/imgproc"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	utils "github.com/JMURv/simple-s3/pkg/utils/http"
+	"github.com/JMURv/simple-s3/pkg/utils/mediatype"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+const (
+	// coverName is where the cover art of an audio file is kept in the
+	// image cache, next to its variants
+	coverName = "cover"
+	// coverVariantPrefix keeps resized covers apart from image variants
+	coverVariantPrefix = "cover-"
+)
+
+// cover serves the cover art embedded in an audio file
+// @Summary Get the cover art of an audio file
+// @Description Serves the cover art embedded in an MP3, FLAC, Ogg Vorbis or Opus file as stored. With w, h, fit, q or format it is transformed like /img and cached.
+// @Param path path string true "File path"
+// @Param w query int false "Width in pixels"
+// @Param h query int false "Height in pixels"
+// @Param fit query string false "contain, cover or fill" default(contain)
+// @Param q query int false "JPEG quality" default(80)
+// @Param format query string false "jpeg, png or gif"
+// @Produce  image/jpeg,image/png,image/gif
+// @Success 200
+// @Success 304
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Router /cover/{path} [get]
+func (h *Handler) cover(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+
+	key, ok := urlKey(r, "/cover/")
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	info, err := h.storage.Stat(r.Context(), key)
+	if err != nil {
+		utils.ErrResponse(w, http.StatusNotFound, ErrRetrievingFile)
+		return
+	}
+	if meta := h.audioMeta(r.Context(), info); meta == nil || meta.Cover == "" {
+		utils.ErrResponse(w, http.StatusNotFound, ErrNoCover)
+		return
+	}
+
+	file, stat, err := h.images.OpenFile(key, coverName, info.ModTime)
+	if errors.Is(err, imgproc.ErrCacheMiss) {
+		// The cache was cleared since the tags were read
+		if _, err = h.readAudio(r.Context(), info); err == nil {
+			file, stat, err = h.images.OpenFile(key, coverName, info.ModTime)
+		}
+	}
+	if err != nil {
+		log.Println("Error opening cover: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+		return
+	}
+	defer file.Close()
+
+	head := make([]byte, mediatype.SniffLen)
+	n, _ := io.ReadFull(file, head)
+	contentType := mediatype.Detect(head[:n])
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+		return
+	}
+
+	if !transformRequested(r.URL.Query()) {
+		w.Header().Set("Content-Type", contentType)
+		http.ServeContent(w, r, coverName, stat.ModTime(), file)
+		return
+	}
+
+	opts, err := imgproc.ParseOptions(r.URL.Query(), h.imageLimits)
+	if err != nil {
+		utils.ErrResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if opts.Format == "" {
+		opts.Format = imgproc.FormatOf(contentType)
+	}
+	if opts.Format == "" {
+		utils.ErrResponse(w, http.StatusUnprocessableEntity, imgproc.ErrUnsupportedFormat)
+		return
+	}
+
+	variant, stat, hit, err := h.coverVariant(key, file, opts, info)
+	if errors.Is(err, imgproc.ErrUnsupportedFormat) || errors.Is(err, imgproc.ErrTooLarge) {
+		utils.ErrResponse(w, http.StatusUnprocessableEntity, err)
+		return
+	} else if err != nil {
+		log.Println("Error rendering cover: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+		return
+	}
+	defer variant.Close()
+
+	cache := "MISS"
+	if hit {
+		cache = "HIT"
+	}
+	w.Header().Set("Content-Type", imgproc.ContentType(opts.Format))
+	w.Header().Set("X-Cache", cache)
+	http.ServeContent(w, r, opts.Name(), stat.ModTime(), variant)
+}
+
+// transformRequested reports whether q holds any /img option, as opposed
+// to nothing or only the signature of a presigned URL
+func transformRequested(q url.Values) bool {
+	for _, name := range []string{"w", "h", "fit", "q", "format"} {
+		if q.Has(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// coverVariant opens a transformed cover, rendering it from src on a miss
+func (h *Handler) coverVariant(key string, src io.Reader, opts imgproc.Options, info *storage.ObjectInfo) (*os.File, os.FileInfo, bool, error) {
+	name := coverVariantPrefix + opts.Name()
+	file, stat, err := h.images.OpenFile(key, name, info.ModTime)
+	if !errors.Is(err, imgproc.ErrCacheMiss) {
+		return file, stat, err == nil, err
+	}
+
+	file, stat, err = h.images.StoreFile(
+		key, name, func(dst io.Writer) error {
+			return imgproc.Transform(dst, src, opts, h.imageLimits.MaxPixels)
+		},
+	)
+	return file, stat, false, err
+}
+
+// audioMeta returns the tags of an audio file, reading them and recording
+// them in its metadata when they are missing. Anything audiotag doesn't
+// understand has none.
+func (h *Handler) audioMeta(ctx context.Context, info *storage.ObjectInfo) *model.AudioMetaRes {
+	if res := cachedAudioMeta(info); res != nil {
+		return res
+	}
+
+	contentType, err := h.sniff(ctx, info.Key, info.Size)
+	if err != nil || !audiotag.Supports(contentType) {
+		return nil
+	}
+
+	info.Audio, err = h.readAudio(ctx, info)
+	if err != nil {
+		if !errors.Is(err, audiotag.ErrMalformed) && !errors.Is(err, audiotag.ErrUnsupported) {
+			log.Printf("Error reading tags of %s: %v\n", info.Key, err)
+		}
+		return nil
+	}
+
+	if annotator, ok := storage.As[storage.Annotator](h.storage); ok {
+		if err = annotator.Annotate(ctx, info); err != nil {
+			log.Printf("Error recording tags of %s: %v\n", info.Key, err)
+		}
+	}
+	return cachedAudioMeta(info)
+}
+
+// readAudio reads the tags of an audio file and caches its cover art
+func (h *Handler) readAudio(ctx context.Context, info *storage.ObjectInfo) (*storage.Audio, error) {
+	src := storage.NewReadSeeker(ctx, h.storage, info)
+	defer src.Close()
+
+	tags, err := audiotag.Read(src, info.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	audio := &storage.Audio{
+		Format:   tags.Format,
+		Title:    tags.Title,
+		Artist:   tags.Artist,
+		Album:    tags.Album,
+		Genre:    tags.Genre,
+		Year:     tags.Year,
+		Track:    tags.Track,
+		Duration: tags.Duration,
+	}
+	if tags.Cover != nil && imgproc.FormatOf(tags.Cover.MIMEType) != "" {
+		audio.Cover = true
+		cover, _, err := h.images.StoreFile(
+			info.Key, coverName, func(w io.Writer) error {
+				_, err := w.Write(tags.Cover.Data)
+				return err
+			},
+		)
+		if err != nil {
+			log.Printf("Error caching cover of %s: %v\n", info.Key, err)
+		} else {
+			cover.Close()
+		}
+	}
+	return audio, nil
+}
+
+// cachedAudioMeta returns the tags of an audio file if they have already
+// been recorded in its metadata
+func cachedAudioMeta(info *storage.ObjectInfo) *model.AudioMetaRes {
+	if info.Audio == nil {
+		return nil
+	}
+
+	res := &model.AudioMetaRes{
+		Format:   info.Audio.Format,
+		Title:    info.Audio.Title,
+		Artist:   info.Audio.Artist,
+		Album:    info.Audio.Album,
+		Genre:    info.Audio.Genre,
+		Year:     info.Audio.Year,
+		Track:    info.Audio.Track,
+		Duration: info.Audio.Duration.Seconds(),
+	}
+	if info.Audio.Cover {
+		res.Cover = (&url.URL{Path: "/cover/" + info.Key}).String()
+	}
+	return res
+}
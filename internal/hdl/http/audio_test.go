@@ -0,0 +1,135 @@
+package http
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testMP3 builds an MP3 file with an ID3v2.3 tag, optionally carrying
+// cover as its front cover, and one second of 128 kbit/s frames
+func testMP3(title string, cover []byte) []byte {
+	frame := func(id string, data []byte) []byte {
+		return append(append(binary.BigEndian.AppendUint32([]byte(id), uint32(len(data))), 0, 0), data...)
+	}
+
+	body := frame("TIT2", append([]byte{0}, title...))
+	body = append(body, frame("TPE1", []byte("\x00The Hosts"))...)
+	body = append(body, frame("TALB", []byte("\x00Season 1"))...)
+	if cover != nil {
+		body = append(body, frame("APIC", append([]byte("\x00image/png\x00\x03\x00"), cover...))...)
+	}
+	n := len(body)
+	tag := append([]byte{'I', 'D', '3', 3, 0, 0, byte(n >> 21 & 0x7F), byte(n >> 14 & 0x7F), byte(n >> 7 & 0x7F), byte(n & 0x7F)}, body...)
+
+	// 16000 bytes at 128 kbit/s
+	audio := make([]byte, 0, 16000)
+	for len(audio)+417 <= 16000 {
+		audio = append(audio, 0xFF, 0xFB, 0x90, 0x00)
+		audio = append(audio, make([]byte, 413)...)
+	}
+	audio = append(audio, make([]byte, 16000-len(audio))...)
+	return append(tag, audio...)
+}
+
+func TestAudioMeta(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupIndexedHandler(t)
+	cover := encodeTestImage(t, 40, 40)
+
+	fetchCover := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		hdl.cover(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	t.Run(
+		"Upload", func(t *testing.T) {
+			res := uploadFile(t, hdl, "podcasts", "episode.mp3", testMP3("Episode 1", cover))
+			require.NotNil(t, res.Audio)
+			assert.Equal(t, "mp3", res.Audio.Format)
+			assert.Equal(t, "Episode 1", res.Audio.Title)
+			assert.Equal(t, "The Hosts", res.Audio.Artist)
+			assert.Equal(t, "Season 1", res.Audio.Album)
+			assert.Equal(t, 1.0, res.Audio.Duration)
+			assert.Equal(t, "/cover/podcasts/episode.mp3", res.Audio.Cover)
+			assert.Nil(t, res.Video)
+
+			files := listByName(t, hdl, "podcasts")
+			assert.Equal(t, res.Audio, files["episode.mp3"].Audio)
+		},
+	)
+
+	t.Run(
+		"Cover As Stored", func(t *testing.T) {
+			rec := fetchCover("/cover/podcasts/episode.mp3")
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+			assert.Equal(t, cover, rec.Body.Bytes())
+		},
+	)
+
+	t.Run(
+		"Transformed Cover", func(t *testing.T) {
+			rec := fetchCover("/cover/podcasts/episode.mp3?w=10&format=jpeg")
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "image/jpeg", rec.Header().Get("Content-Type"))
+			assert.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+
+			rec = fetchCover("/cover/podcasts/episode.mp3?w=10&format=png")
+			require.Equal(t, http.StatusOK, rec.Code)
+			conf, err := png.DecodeConfig(bytes.NewReader(rec.Body.Bytes()))
+			require.NoError(t, err)
+			assert.Equal(t, 10, conf.Width)
+
+			rec = fetchCover("/cover/podcasts/episode.mp3?w=10&format=png")
+			assert.Equal(t, "HIT", rec.Header().Get("X-Cache"))
+
+			assert.Equal(t, http.StatusBadRequest, fetchCover("/cover/podcasts/episode.mp3?fit=sideways").Code)
+		},
+	)
+
+	t.Run(
+		"Cache Cleared", func(t *testing.T) {
+			require.NoError(t, os.RemoveAll(filepath.Join(testTmpDir, "img")))
+
+			files := listByName(t, hdl, "podcasts")
+			require.NotNil(t, files["episode.mp3"].Audio)
+			assert.Equal(t, "Episode 1", files["episode.mp3"].Audio.Title)
+
+			rec := fetchCover("/cover/podcasts/episode.mp3")
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, cover, rec.Body.Bytes())
+		},
+	)
+
+	t.Run(
+		"No Cover", func(t *testing.T) {
+			res := uploadFile(t, hdl, "podcasts", "bare.mp3", testMP3("Bare", nil))
+			require.NotNil(t, res.Audio)
+			assert.Empty(t, res.Audio.Cover)
+			assert.Equal(t, http.StatusNotFound, fetchCover("/cover/podcasts/bare.mp3").Code)
+
+			uploadFile(t, hdl, "podcasts", "notes.txt", []byte("not audio"))
+			assert.Equal(t, http.StatusNotFound, fetchCover("/cover/podcasts/notes.txt").Code)
+			assert.Equal(t, http.StatusNotFound, fetchCover("/cover/podcasts/missing.mp3").Code)
+		},
+	)
+
+	t.Run(
+		"Dropped On Delete", func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			hdl.deleteFile(rec, httptest.NewRequest(http.MethodDelete, "/delete?path=podcasts/episode.mp3", nil))
+			require.Equal(t, http.StatusNoContent, rec.Code)
+			assert.Equal(t, http.StatusNotFound, fetchCover("/cover/podcasts/episode.mp3").Code)
+		},
+	)
+}
@@ -0,0 +1,179 @@
+package http
+
+import (
+	"fmt"
+	"github.com/JMURv/simple-s3/pkg/config"
+	"github.com/JMURv/simple-s3/pkg/sigv4"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAccessKey = "test-access-key"
+const testSecretKey = "test-secret-key"
+
+func setupAuthHandler() *Handler {
+	return New(port, setupAuthConfig(), storage.NewLocal(testDir))
+}
+
+func setupAuthConfig() *config.Config {
+	return &config.Config{
+		SavePath: testDir,
+		HTTP: &config.HTTPConfig{
+			MaxUploadSize:   10 * 1024 * 1024,
+			MaxStreamBuffer: 1024,
+			DefaultPage:     1,
+			DefaultSize:     10,
+		},
+		S3: &config.S3Config{
+			Bucket: testBucket,
+			Region: "us-east-1",
+		},
+		Auth: &config.AuthConfig{
+			Credentials: []config.Credential{
+				{AccessKey: testAccessKey, SecretKey: testSecretKey},
+			},
+		},
+	}
+}
+
+func TestAuth(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupAuthHandler()
+
+	t.Run(
+		"Anonymous Request", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, listEndpoint, nil)
+			rec := httptest.NewRecorder()
+			hdl.auth(hdl.listFiles)(rec, req)
+
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+			assert.Contains(t, rec.Body.String(), "AccessDenied")
+		},
+	)
+
+	t.Run(
+		"Signed Request", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, listEndpoint, nil)
+			sigv4.Sign(req, testAccessKey, testSecretKey, "us-east-1", time.Now())
+			rec := httptest.NewRecorder()
+			hdl.auth(hdl.listFiles)(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+		},
+	)
+
+	t.Run(
+		"Wrong Secret", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/"+testBucket+"?list-type=2", nil)
+			sigv4.Sign(req, testAccessKey, "wrong-secret", "us-east-1", time.Now())
+			rec := httptest.NewRecorder()
+			hdl.s3Auth(hdl.s3API)(rec, req)
+
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+			assert.Contains(t, rec.Body.String(), "<Code>SignatureDoesNotMatch</Code>")
+		},
+	)
+
+	t.Run(
+		"Skewed Clock", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/delete?path=file.txt", nil)
+			sigv4.Sign(req, testAccessKey, testSecretKey, "us-east-1", time.Now().Add(-time.Hour))
+			rec := httptest.NewRecorder()
+			hdl.auth(hdl.deleteFile)(rec, req)
+
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+			assert.Contains(t, rec.Body.String(), "RequestTimeTooSkewed")
+		},
+	)
+
+	t.Run(
+		"Presigned Get", func(t *testing.T) {
+			err := os.WriteFile(filepath.Join(testDir, "presigned.txt"), []byte("presigned"), 0644)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/"+testBucket+"/presigned.txt", nil)
+			sigv4.Presign(req, testAccessKey, testSecretKey, "us-east-1", time.Now(), time.Minute)
+			rec := httptest.NewRecorder()
+			hdl.s3Auth(hdl.s3API)(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "presigned", rec.Body.String())
+		},
+	)
+
+	t.Run(
+		"Presigned Put With Unsigned Tags", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/"+testBucket+"/tagged.txt", strings.NewReader("tagged"))
+			sigv4.Presign(req, testAccessKey, testSecretKey, "us-east-1", time.Now(), time.Minute)
+			req.Header.Set("X-Amz-Tagging", "class=cold")
+			rec := httptest.NewRecorder()
+			hdl.s3Auth(hdl.s3API)(rec, req)
+
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+			assert.Contains(t, rec.Body.String(), "<Code>AccessDenied</Code>")
+			assert.NoFileExists(t, filepath.Join(testDir, "tagged.txt"))
+		},
+	)
+}
+
+func TestAuthStreamingPayload(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupAuthHandler()
+
+	now := time.Now().UTC()
+	date := now.Format(sigv4.DateFormat)
+	key := sigv4.SigningKey(testSecretKey, date, "us-east-1")
+	scope := sigv4.Scope(date, "us-east-1")
+
+	newRequest := func(chunks []string, tamper bool) *http.Request {
+		req := httptest.NewRequest(http.MethodPut, "/"+testBucket+"/streamed.txt", nil)
+		req.Header.Set("X-Amz-Content-Sha256", sigv4.StreamingPayload)
+		req.Header.Set("X-Amz-Decoded-Content-Length", fmt.Sprint(len(strings.Join(chunks, ""))))
+		req.Header.Set("Content-Encoding", "aws-chunked")
+		sigv4.Sign(req, testAccessKey, testSecretKey, "us-east-1", now)
+
+		_, sig, _ := strings.Cut(req.Header.Get("Authorization"), "Signature=")
+		var body strings.Builder
+		for _, chunk := range append(chunks, "") {
+			sig = sigv4.ChunkSignature(key, now, scope, sig, []byte(chunk))
+			if tamper && chunk != "" {
+				chunk = strings.ToUpper(chunk)
+			}
+			fmt.Fprintf(&body, "%x;chunk-signature=%s\r\n%s\r\n", len(chunk), sig, chunk)
+		}
+		req.Body = io.NopCloser(strings.NewReader(body.String()))
+		return req
+	}
+
+	t.Run(
+		"Valid Chunks", func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			hdl.s3Auth(hdl.s3API)(rec, newRequest([]string{"hello ", "world"}, false))
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			data, err := os.ReadFile(filepath.Join(testDir, "streamed.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, "hello world", string(data))
+		},
+	)
+
+	t.Run(
+		"Tampered Chunk", func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			hdl.s3Auth(hdl.s3API)(rec, newRequest([]string{"evil"}, true))
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+			assert.Contains(t, rec.Body.String(), "<Code>SignatureDoesNotMatch</Code>")
+		},
+	)
+}
@@ -0,0 +1,360 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	utils "github.com/JMURv/simple-s3/pkg/utils/http"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// makeDir creates a directory
+// @Summary Create a directory
+// @Description Creates the directory at path along with its parents, so it can be listed before anything is uploaded to it. Creating a directory that already exists succeeds. Only backends with real directories, such as the local one, support it.
+// @Param path query string true "Directory path"
+// @Success 201 {object} model.DirRes
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 501 {object} utils.ErrorResponse
+// @Router /mkdir [post]
+func (h *Handler) makeDir(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+
+	dirs, ok := storage.As[storage.Dirs](h.storage)
+	if !ok {
+		utils.ErrResponse(w, http.StatusNotImplemented, ErrDirsUnsupported)
+		return
+	}
+
+	key, ok := h.objectKey(r.URL.Query().Get("path"))
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	if _, err := h.storage.Stat(r.Context(), key); err == nil {
+		utils.ErrResponse(w, http.StatusConflict, ErrAlreadyExists)
+		return
+	}
+
+	err := dirs.MkDir(r.Context(), key)
+	if errors.Is(err, storage.ErrInvalidKey) {
+		// A file is in the way of one of the parents
+		utils.ErrResponse(w, http.StatusConflict, ErrAlreadyExists)
+		return
+	} else if err != nil {
+		log.Println("Error creating directory: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrCreatingDir)
+		return
+	}
+
+	utils.SuccessDataResponse(w, http.StatusCreated, &model.DirRes{Path: h.publicPath(key)})
+}
+
+// removeDir deletes a directory
+// @Summary Delete a directory
+// @Description Deletes the directory at path. Without recursive only an empty directory is deleted and anything else is refused with 409; with recursive every file below it is deleted first, together with its cached image variants, followed by the directories themselves.
+// @Param path query string true "Directory path"
+// @Param recursive query bool false "Delete the content too" default(false)
+// @Success 200 {object} model.RmDirRes
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Router /rmdir [delete]
+func (h *Handler) removeDir(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+
+	key, ok := h.objectKey(r.URL.Query().Get("path"))
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	recursive := false
+	if v := r.URL.Query().Get("recursive"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidOption)
+			return
+		}
+		recursive = b
+	}
+
+	if _, err := h.storage.Stat(r.Context(), key); err == nil {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	objects, _, err := h.storage.List(r.Context(), key+"/", "", 0)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		log.Println("Error reading directory: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrReadingDir)
+		return
+	}
+	if len(objects) > 0 && !recursive {
+		utils.ErrResponse(w, http.StatusConflict, storage.ErrNotEmpty)
+		return
+	}
+
+	res := model.RmDirRes{Path: h.publicPath(key)}
+	for _, obj := range objects {
+		err := h.storage.Delete(r.Context(), obj.Key)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Error deleting %s: %v\n", obj.Key, err)
+			utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+			return
+		}
+		h.dropVariants(obj.Key)
+		res.Files++
+	}
+
+	dirs, ok := storage.As[storage.Dirs](h.storage)
+	if !ok {
+		// Without real directories one is gone with its last file
+		if res.Files == 0 {
+			utils.ErrResponse(w, http.StatusNotFound, storage.ErrNotFound)
+			return
+		}
+		utils.SuccessDataResponse(w, http.StatusOK, &res)
+		return
+	}
+
+	below, err := dirs.ListDirs(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		utils.ErrResponse(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		log.Println("Error reading directory: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrReadingDir)
+		return
+	}
+	if len(below) > 0 && !recursive {
+		utils.ErrResponse(w, http.StatusConflict, storage.ErrNotEmpty)
+		return
+	}
+
+	// Children sort after their parents, so going backwards empties every
+	// directory before it is removed
+	for i := len(below) - 1; i >= 0; i-- {
+		if err = h.removeEmptyDir(w, r.Context(), dirs, below[i]); err != nil {
+			return
+		}
+		res.Dirs++
+	}
+	if err = h.removeEmptyDir(w, r.Context(), dirs, key); err != nil {
+		return
+	}
+	res.Dirs++
+
+	log.Printf("Directory %s deleted successfully\n", key)
+	utils.SuccessDataResponse(w, http.StatusOK, &res)
+}
+
+// removeEmptyDir removes dir, writing the error response if it can't
+func (h *Handler) removeEmptyDir(w http.ResponseWriter, ctx context.Context, dirs storage.Dirs, dir string) error {
+	err := dirs.RemoveDir(ctx, dir)
+	switch {
+	case err == nil:
+	case errors.Is(err, storage.ErrNotEmpty):
+		// Left behind by an upload that is still running
+		utils.ErrResponse(w, http.StatusConflict, err)
+	case errors.Is(err, storage.ErrNotFound):
+		utils.ErrResponse(w, http.StatusNotFound, err)
+	default:
+		log.Printf("Error removing directory %s: %v\n", dir, err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+	}
+	return err
+}
+
+// dirTree describes a directory and the directories below it
+// @Summary Directory tree
+// @Description Returns the directory at path with its subdirectories nested inside it, sorted by name, each with the number and size of the files directly in it and of everything below it. Empty directories are included on backends that have them. depth limits how many levels are nested; the totals always cover everything.
+// @Param path query string false "Directory path, the root by default"
+// @Param depth query int false "Levels of subdirectories to include, 0 for all" default(0)
+// @Success 200 {object} model.DirRes
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /tree [get]
+func (h *Handler) dirTree(w http.ResponseWriter, r *http.Request) {
+	key, ok := h.dirKey(r.URL.Query().Get("path"))
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	depth := 0
+	if v := r.URL.Query().Get("depth"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidOption)
+			return
+		}
+		depth = n
+	}
+
+	tree, err := h.buildTree(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		utils.ErrResponse(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		log.Println("Error reading directory: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrReadingDir)
+		return
+	}
+
+	res := tree.res(key, depth, byName)
+	utils.SuccessDataResponse(w, http.StatusOK, &res)
+}
+
+// diskUsage summarises the space taken below a directory
+// @Summary Disk usage
+// @Description Returns the number and size of the files below the directory at path, along with the same totals for each of its direct subdirectories, largest first.
+// @Param path query string false "Directory path, the root by default"
+// @Success 200 {object} model.DirRes
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /du [get]
+func (h *Handler) diskUsage(w http.ResponseWriter, r *http.Request) {
+	key, ok := h.dirKey(r.URL.Query().Get("path"))
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	tree, err := h.buildTree(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		utils.ErrResponse(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		log.Println("Error reading directory: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrReadingDir)
+		return
+	}
+
+	res := tree.res(key, 1, bySize)
+	utils.SuccessDataResponse(w, http.StatusOK, &res)
+}
+
+// tree holds a directory and the directories below it, keyed by their keys
+type tree struct {
+	dirs     map[string]*model.DirRes
+	children map[string][]string
+}
+
+// buildTree counts the files below dir into every directory holding them
+func (h *Handler) buildTree(ctx context.Context, dir string) (*tree, error) {
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+
+	objects, _, err := h.storage.List(ctx, prefix, "", 0)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+
+	var below []string
+	if dirs, ok := storage.As[storage.Dirs](h.storage); ok {
+		if below, err = dirs.ListDirs(ctx, dir); err != nil {
+			return nil, err
+		}
+	} else if dir != "" && len(objects) == 0 {
+		return nil, storage.ErrNotFound
+	}
+
+	t := &tree{dirs: make(map[string]*model.DirRes), children: make(map[string][]string)}
+	// add adds the directory key along with the parents it is missing
+	add := func(key string) {
+		for {
+			if _, ok := t.dirs[key]; ok {
+				return
+			}
+			t.dirs[key] = &model.DirRes{Path: h.publicPath(key)}
+			if key == dir {
+				return
+			}
+			parent := parentDir(key)
+			t.children[parent] = append(t.children[parent], key)
+			key = parent
+		}
+	}
+
+	add(dir)
+	for _, d := range below {
+		add(d)
+	}
+
+	for _, obj := range objects {
+		parent := parentDir(obj.Key)
+		add(parent)
+
+		t.dirs[parent].Files++
+		t.dirs[parent].Size += obj.Size
+		for key := parent; ; key = parentDir(key) {
+			t.dirs[key].TotalFiles++
+			t.dirs[key].TotalSize += obj.Size
+			if key == dir {
+				break
+			}
+		}
+	}
+	return t, nil
+}
+
+func parentDir(key string) string {
+	if i := strings.LastIndexByte(key, '/'); i >= 0 {
+		return key[:i]
+	}
+	return ""
+}
+
+// res nests the directories below key up to depth levels, 0 meaning all,
+// ordering the ones at each level by less
+func (t *tree) res(key string, depth int, less func(a, b *model.DirRes) bool) model.DirRes {
+	res := *t.dirs[key]
+	if depth < 0 {
+		return res
+	}
+
+	// Below the last level to include nothing is nested any more
+	next := depth - 1
+	if depth == 0 {
+		next = 0
+	} else if next == 0 {
+		next = -1
+	}
+
+	for _, child := range t.children[key] {
+		res.Dirs = append(res.Dirs, t.res(child, next, less))
+	}
+	sort.Slice(
+		res.Dirs, func(i, j int) bool {
+			return less(&res.Dirs[i], &res.Dirs[j])
+		},
+	)
+	return res
+}
+
+func byName(a, b *model.DirRes) bool {
+	return a.Path < b.Path
+}
+
+// bySize puts the largest directories first
+func bySize(a, b *model.DirRes) bool {
+	if a.TotalSize != b.TotalSize {
+		return a.TotalSize > b.TotalSize
+	}
+	return a.Path < b.Path
+}
@@ -0,0 +1,206 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func dirRequest(t *testing.T, handler http.HandlerFunc, method, query string, status int, res any) {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(method, "/?"+query, nil))
+	require.Equal(t, status, rec.Code, rec.Body.String())
+	if res != nil {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), res))
+	}
+}
+
+func TestMakeDir(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupIndexedHandler(t)
+
+	uploadFile(t, hdl, "docs", "a.txt", []byte("a"))
+
+	t.Run(
+		"Create", func(t *testing.T) {
+			res := model.DirRes{}
+			dirRequest(t, hdl.makeDir, http.MethodPost, "path=projects/2024", http.StatusCreated, &res)
+			assert.Equal(t, "/test_uploads/projects/2024", res.Path)
+			assert.DirExists(t, filepath.Join(testDir, "projects", "2024"))
+
+			dirRequest(t, hdl.makeDir, http.MethodPost, "path=projects/2024", http.StatusCreated, nil)
+		},
+	)
+
+	t.Run(
+		"Errors", func(t *testing.T) {
+			dirRequest(t, hdl.makeDir, http.MethodPost, "path=docs/a.txt", http.StatusConflict, nil)
+			dirRequest(t, hdl.makeDir, http.MethodPost, "path=docs/a.txt/sub", http.StatusConflict, nil)
+			dirRequest(t, hdl.makeDir, http.MethodPost, "path=../outside", http.StatusBadRequest, nil)
+			dirRequest(t, hdl.makeDir, http.MethodPost, "", http.StatusBadRequest, nil)
+			dirRequest(t, hdl.makeDir, http.MethodGet, "path=other", http.StatusMethodNotAllowed, nil)
+			assert.NoDirExists(t, filepath.Join(testDir, "..", "outside"))
+		},
+	)
+
+	t.Run(
+		"Unsupported", func(t *testing.T) {
+			memory := setupTestHandler()
+			memory.storage = storage.NewMemory()
+			dirRequest(t, memory.makeDir, http.MethodPost, "path=projects", http.StatusNotImplemented, nil)
+		},
+	)
+}
+
+func TestRemoveDir(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupIndexedHandler(t)
+
+	uploadFile(t, hdl, "docs", "a.txt", []byte("a"))
+	uploadFile(t, hdl, "docs/2024", "b.txt", []byte("b"))
+	dirRequest(t, hdl.makeDir, http.MethodPost, "path=docs/empty", http.StatusCreated, nil)
+	dirRequest(t, hdl.makeDir, http.MethodPost, "path=empty", http.StatusCreated, nil)
+
+	t.Run(
+		"Empty", func(t *testing.T) {
+			res := model.RmDirRes{}
+			dirRequest(t, hdl.removeDir, http.MethodDelete, "path=empty", http.StatusOK, &res)
+			assert.Equal(t, 0, res.Files)
+			assert.Equal(t, 1, res.Dirs)
+			assert.NoDirExists(t, filepath.Join(testDir, "empty"))
+		},
+	)
+
+	t.Run(
+		"Not Empty", func(t *testing.T) {
+			dirRequest(t, hdl.removeDir, http.MethodDelete, "path=docs", http.StatusConflict, nil)
+			assert.FileExists(t, filepath.Join(testDir, "docs", "a.txt"))
+		},
+	)
+
+	t.Run(
+		"Recursive", func(t *testing.T) {
+			res := model.RmDirRes{}
+			dirRequest(t, hdl.removeDir, http.MethodDelete, "path=docs&recursive=true", http.StatusOK, &res)
+			assert.Equal(t, 2, res.Files)
+			assert.Equal(t, 3, res.Dirs)
+			assert.NoDirExists(t, filepath.Join(testDir, "docs"))
+
+			_, err := hdl.storage.Stat(context.Background(), "docs/2024/b.txt")
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+		},
+	)
+
+	t.Run(
+		"Errors", func(t *testing.T) {
+			dirRequest(t, hdl.removeDir, http.MethodDelete, "path=docs", http.StatusNotFound, nil)
+			dirRequest(t, hdl.removeDir, http.MethodDelete, "path=../outside&recursive=true", http.StatusBadRequest, nil)
+			dirRequest(t, hdl.removeDir, http.MethodDelete, "path=/&recursive=true", http.StatusBadRequest, nil)
+			dirRequest(t, hdl.removeDir, http.MethodDelete, "path=docs&recursive=maybe", http.StatusBadRequest, nil)
+			dirRequest(t, hdl.removeDir, http.MethodPost, "path=docs", http.StatusMethodNotAllowed, nil)
+
+			uploadFile(t, hdl, "", "file.txt", []byte("file"))
+			dirRequest(t, hdl.removeDir, http.MethodDelete, "path=file.txt", http.StatusBadRequest, nil)
+		},
+	)
+}
+
+func TestDirTree(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupIndexedHandler(t)
+
+	uploadFile(t, hdl, "", "root.txt", []byte("root"))
+	uploadFile(t, hdl, "photos", "a.txt", []byte("aaaa"))
+	uploadFile(t, hdl, "photos/2024/summer", "b.txt", []byte("bb"))
+	uploadFile(t, hdl, "docs", "c.txt", []byte("cccccccc"))
+	dirRequest(t, hdl.makeDir, http.MethodPost, "path=photos/2025", http.StatusCreated, nil)
+
+	t.Run(
+		"Tree", func(t *testing.T) {
+			res := model.DirRes{}
+			dirRequest(t, hdl.dirTree, http.MethodGet, "", http.StatusOK, &res)
+			assert.Equal(t, "/test_uploads", res.Path)
+			assert.Equal(t, 1, res.Files)
+			assert.Equal(t, 4, res.TotalFiles)
+			assert.Equal(t, int64(18), res.TotalSize)
+
+			require.Len(t, res.Dirs, 2)
+			assert.Equal(t, "/test_uploads/docs", res.Dirs[0].Path)
+			photos := res.Dirs[1]
+			assert.Equal(t, 1, photos.Files)
+			assert.Equal(t, 2, photos.TotalFiles)
+			assert.Equal(t, int64(6), photos.TotalSize)
+
+			require.Len(t, photos.Dirs, 2)
+			assert.Equal(t, "/test_uploads/photos/2024", photos.Dirs[0].Path)
+			assert.Equal(t, 0, photos.Dirs[0].Files)
+			assert.Equal(t, 1, photos.Dirs[0].TotalFiles)
+			require.Len(t, photos.Dirs[0].Dirs, 1)
+			assert.Equal(t, "/test_uploads/photos/2024/summer", photos.Dirs[0].Dirs[0].Path)
+			assert.Equal(t, "/test_uploads/photos/2025", photos.Dirs[1].Path)
+			assert.Equal(t, 0, photos.Dirs[1].TotalFiles)
+		},
+	)
+
+	t.Run(
+		"Depth", func(t *testing.T) {
+			res := model.DirRes{}
+			dirRequest(t, hdl.dirTree, http.MethodGet, "path=/test_uploads/photos&depth=1", http.StatusOK, &res)
+			assert.Equal(t, "/test_uploads/photos", res.Path)
+			assert.Equal(t, 2, res.TotalFiles)
+			require.Len(t, res.Dirs, 2)
+			assert.Empty(t, res.Dirs[0].Dirs)
+			assert.Equal(t, 1, res.Dirs[0].TotalFiles)
+		},
+	)
+
+	t.Run(
+		"Disk Usage", func(t *testing.T) {
+			res := model.DirRes{}
+			dirRequest(t, hdl.diskUsage, http.MethodGet, "", http.StatusOK, &res)
+			assert.Equal(t, int64(18), res.TotalSize)
+			require.Len(t, res.Dirs, 2)
+			assert.Equal(t, "/test_uploads/docs", res.Dirs[0].Path)
+			assert.Equal(t, int64(8), res.Dirs[0].TotalSize)
+			assert.Equal(t, "/test_uploads/photos", res.Dirs[1].Path)
+			assert.Empty(t, res.Dirs[1].Dirs)
+		},
+	)
+
+	t.Run(
+		"Errors", func(t *testing.T) {
+			dirRequest(t, hdl.dirTree, http.MethodGet, "path=missing", http.StatusNotFound, nil)
+			dirRequest(t, hdl.dirTree, http.MethodGet, "path=root.txt", http.StatusNotFound, nil)
+			dirRequest(t, hdl.dirTree, http.MethodGet, "path=../outside", http.StatusBadRequest, nil)
+			dirRequest(t, hdl.dirTree, http.MethodGet, "depth=-1", http.StatusBadRequest, nil)
+			dirRequest(t, hdl.diskUsage, http.MethodGet, "path=missing", http.StatusNotFound, nil)
+		},
+	)
+
+	t.Run(
+		"Without Directories", func(t *testing.T) {
+			memory := setupTestHandler()
+			memory.storage = storage.NewMemory()
+			uploadFile(t, memory, "a/b", "c.txt", []byte("c"))
+
+			res := model.DirRes{}
+			dirRequest(t, memory.dirTree, http.MethodGet, "", http.StatusOK, &res)
+			assert.Equal(t, 1, res.TotalFiles)
+			require.Len(t, res.Dirs, 1)
+			require.Len(t, res.Dirs[0].Dirs, 1)
+			assert.Equal(t, 1, res.Dirs[0].Dirs[0].Files)
+
+			dirRequest(t, memory.dirTree, http.MethodGet, "path=x", http.StatusNotFound, nil)
+		},
+	)
+}
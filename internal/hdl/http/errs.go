@@ -15,3 +15,31 @@ var ErrRetrievingFile = errors.New("error retrieving file")
 var ErrParsingForm = errors.New("error parsing form")
 var ErrReadingDir = errors.New("error reading directory")
 var ErrUnsupportedMediaType = errors.New("unsupported media type")
+var ErrPrecondition = errors.New("precondition failed")
+var ErrInvalidOption = errors.New("invalid option")
+var ErrInvalidTags = errors.New("invalid tags")
+var ErrInvalidTime = errors.New("invalid time")
+var ErrInvalidImage = errors.New("invalid image")
+var ErrNoCover = errors.New("file has no cover art")
+
+var ErrInvalidBody = errors.New("invalid request body")
+var ErrPresignDisabled = errors.New("presigned urls are disabled")
+var ErrPresignTarget = errors.New("url can't be presigned")
+var ErrPresignExpiry = errors.New("invalid expiry")
+
+var ErrTusVersion = errors.New("unsupported tus version")
+var ErrTusLength = errors.New("invalid upload length")
+var ErrTusOffset = errors.New("invalid upload offset")
+
+var ErrIndexDisabled = errors.New("metadata index is disabled")
+var ErrVersioningDisabled = errors.New("versioning is disabled")
+var ErrTrashDisabled = errors.New("trash is disabled")
+var ErrLifecycleDisabled = errors.New("no lifecycle rules are configured")
+var ErrThumbnailsDisabled = errors.New("no thumbnail presets are configured")
+
+var ErrArchiveFormat = errors.New("unsupported archive format")
+var ErrInvalidArchive = errors.New("invalid archive")
+var ErrArchiveNameTaken = errors.New("two paths would share a name in the archive")
+var ErrSameTarget = errors.New("destination is the source or lies inside it")
+
+var ErrDirsUnsupported = errors.New("storage backend has no directories")
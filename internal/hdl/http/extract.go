@@ -0,0 +1,156 @@
+package http
+
+import (
+	"errors"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/JMURv/simple-s3/pkg/unpack"
+	u "github.com/JMURv/simple-s3/pkg/utils"
+	utils "github.com/JMURv/simple-s3/pkg/utils/http"
+	"github.com/JMURv/simple-s3/pkg/utils/slugify"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const (
+	extractStatusExtracted = "extracted"
+	extractStatusRejected  = "rejected"
+	extractStatusFailed    = "failed"
+)
+
+// extractUpload unpacks an uploaded archive into dir. The archive is
+// checked against the limits as a whole before anything is stored; after
+// that every entry is stored, or refused, on its own.
+func (h *Handler) extractUpload(w http.ResponseWriter, r *http.Request, dir string, file multipart.File, size int64) {
+	archive, err := unpack.Open(file, size)
+	if errors.Is(err, unpack.ErrUnsupported) {
+		utils.ErrResponse(w, http.StatusUnsupportedMediaType, ErrUnsupportedMediaType)
+		return
+	} else if err != nil {
+		utils.ErrResponse(w, http.StatusUnprocessableEntity, ErrInvalidArchive)
+		return
+	}
+
+	if _, err = h.sanitizeOptions(r, dir); err != nil {
+		utils.ErrResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	entries, err := archive.Check(h.extractLimits)
+	if errors.Is(err, unpack.ErrTooManyEntries) || errors.Is(err, unpack.ErrTooLarge) {
+		utils.ErrResponse(w, http.StatusRequestEntityTooLarge, err)
+		return
+	} else if err != nil {
+		utils.ErrResponse(w, http.StatusUnprocessableEntity, ErrInvalidArchive)
+		return
+	}
+
+	res := model.ExtractRes{Entries: make([]model.ExtractEntryRes, 0, len(entries))}
+	err = archive.Walk(
+		func(e *unpack.Entry, content io.Reader) error {
+			entry := h.extractEntry(r, dir, e, content)
+			switch entry.Status {
+			case extractStatusExtracted:
+				res.Extracted++
+			case extractStatusRejected:
+				res.Rejected++
+			default:
+				res.Failed++
+			}
+			res.Entries = append(res.Entries, entry)
+			return r.Context().Err()
+		},
+	)
+	if err != nil {
+		log.Println("Error extracting archive: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+		return
+	}
+
+	status := http.StatusOK
+	if res.Extracted > 0 {
+		status = http.StatusCreated
+	}
+	utils.SuccessDataResponse(w, status, &res)
+}
+
+// extractEntry stores one entry of an archive below dir the way a single
+// upload would be stored
+func (h *Handler) extractEntry(r *http.Request, dir string, e *unpack.Entry, content io.Reader) model.ExtractEntryRes {
+	res := model.ExtractEntryRes{Name: e.RawName}
+	if e.Err != nil {
+		res.Status, res.Error = extractStatusFailed, e.Err.Error()
+		if isRefusal(e.Err) {
+			res.Status = extractStatusRejected
+		}
+		return res
+	}
+
+	name, ok := slugifyPath(e.Name)
+	key := path.Join(dir, name)
+	if !ok || !storage.ValidKey(key) {
+		res.Status, res.Error = extractStatusRejected, ErrInvalidPath.Error()
+		return res
+	}
+
+	failed := func(err error) model.ExtractEntryRes {
+		res.Status, res.Error = extractStatusFailed, err.Error()
+		return res
+	}
+
+	ctx := r.Context()
+	_, err := h.storage.Stat(ctx, key)
+	if err == nil && !storage.Overwritable(h.storage, key) {
+		return failed(ErrAlreadyExists)
+	}
+	overwrite := err == nil
+
+	sanitize, _ := h.sanitizeOptions(r, key)
+	body, err := h.sanitizeUpload(content, sanitize)
+	if err != nil {
+		return failed(err)
+	}
+
+	info, err := h.storage.Put(storage.WithOriginalName(ctx, path.Base(e.Name)), key, body)
+	if err != nil {
+		log.Println("Error storing extracted file: ", err)
+		return failed(ErrInternal)
+	}
+	if overwrite {
+		h.dropVariants(key)
+	}
+
+	file := h.uploadRes(ctx, info)
+	res.Status, res.File = extractStatusExtracted, &file
+	return res
+}
+
+// isRefusal reports whether err is one of the reasons unpack refuses an
+// entry, rather than an error opening it
+func isRefusal(err error) bool {
+	for _, reason := range []error{unpack.ErrUnsafePath, unpack.ErrLink, unpack.ErrSpecial, unpack.ErrEncrypted} {
+		if errors.Is(err, reason) {
+			return true
+		}
+	}
+	return false
+}
+
+// slugifyPath applies slugify.Filename to every segment of a cleaned entry
+// path. It fails when a segment has nothing left.
+func slugifyPath(name string) (string, bool) {
+	segments := strings.Split(name, "/")
+	for i, s := range segments {
+		segments[i] = slugify.Filename(s)
+		if segments[i] == "" || strings.Trim(segments[i], ".") == "" {
+			return "", false
+		}
+	}
+
+	name = strings.Join(segments, "/")
+	return name, u.IsValidPath(name)
+}
@@ -0,0 +1,168 @@
+package http
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// extractUpload uploads data with extract set to extract
+func extractUpload(hdl *Handler, dir, name, extract string, data []byte) *httptest.ResponseRecorder {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("path", dir)
+	writer.WriteField("extract", extract)
+	file, _ := writer.CreateFormFile("file", name)
+	file.Write(data)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, createEndpoint, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	hdl.createFile(rec, req)
+	return rec
+}
+
+// testAssetZip builds a ZIP with two files, an entry escaping the archive
+// and a symlink
+func testAssetZip(t *testing.T) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range []struct {
+		name, content string
+		mode          fs.FileMode
+	}{
+		{"Asset Pack/", "", fs.ModeDir | 0755},
+		{"Asset Pack/Logo Big.txt", "logo", 0644},
+		{"Asset Pack/readme.txt", "read me", 0644},
+		{"../../evil.txt", "evil", 0644},
+		{"Asset Pack/passwd", "/etc/passwd", fs.ModeSymlink | 0777},
+	} {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		hdr.SetMode(e.mode)
+		w, err := zw.CreateHeader(hdr)
+		require.NoError(t, err)
+		w.Write([]byte(e.content))
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestExtractUpload(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupTestHandler()
+
+	t.Run(
+		"Zip", func(t *testing.T) {
+			rec := extractUpload(hdl, "assets", "pack.zip", "true", testAssetZip(t))
+			require.Equal(t, http.StatusCreated, rec.Code)
+
+			res := model.ExtractRes{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			assert.Equal(t, 2, res.Extracted)
+			assert.Equal(t, 2, res.Rejected)
+			assert.Equal(t, 0, res.Failed)
+			require.Len(t, res.Entries, 4)
+
+			assert.Equal(t, "Asset Pack/Logo Big.txt", res.Entries[0].Name)
+			assert.Equal(t, "extracted", res.Entries[0].Status)
+			require.NotNil(t, res.Entries[0].File)
+			assert.Equal(t, "Logo Big.txt", res.Entries[0].File.OriginalName)
+
+			assert.Equal(t, "rejected", res.Entries[2].Status)
+			assert.Equal(t, "entry path escapes the archive", res.Entries[2].Error)
+			assert.Equal(t, "rejected", res.Entries[3].Status)
+			assert.Equal(t, "entry is a link", res.Entries[3].Error)
+
+			data, err := os.ReadFile(filepath.Join(testDir, "assets", "asset-pack", "logo-big.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, "logo", string(data))
+			assert.NoFileExists(t, filepath.Join(testDir, "..", "evil.txt"))
+			assert.NoFileExists(t, filepath.Join(testDir, "evil.txt"))
+			assert.NoFileExists(t, filepath.Join(testDir, "assets", "asset-pack", "passwd"))
+		},
+	)
+
+	t.Run(
+		"Existing Files", func(t *testing.T) {
+			rec := extractUpload(hdl, "assets", "pack.zip", "true", testAssetZip(t))
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			res := model.ExtractRes{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			assert.Equal(t, 0, res.Extracted)
+			assert.Equal(t, 2, res.Failed)
+			assert.Equal(t, ErrAlreadyExists.Error(), res.Entries[0].Error)
+		},
+	)
+
+	t.Run(
+		"Tar Gz", func(t *testing.T) {
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			tw := tar.NewWriter(gz)
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: "docs/Report 2024.txt", Mode: 0644, Size: 6}))
+			tw.Write([]byte("report"))
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: "/etc/cron.d/job", Mode: 0644, Size: 3}))
+			tw.Write([]byte("job"))
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: "docs/hard", Typeflag: tar.TypeLink, Linkname: "docs/Report 2024.txt"}))
+			require.NoError(t, tw.Close())
+			require.NoError(t, gz.Close())
+
+			rec := extractUpload(hdl, "", "docs.tar.gz", "true", buf.Bytes())
+			require.Equal(t, http.StatusCreated, rec.Code)
+
+			res := model.ExtractRes{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			assert.Equal(t, 1, res.Extracted)
+			assert.Equal(t, 2, res.Rejected)
+			assert.FileExists(t, filepath.Join(testDir, "docs", "report-2024.txt"))
+		},
+	)
+
+	t.Run(
+		"Limits", func(t *testing.T) {
+			limited := setupTestHandler()
+			limited.extractLimits.MaxEntries = 3
+			rec := extractUpload(limited, "limited", "pack.zip", "true", testAssetZip(t))
+			assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+			limited = setupTestHandler()
+			limited.extractLimits.MaxSize = 8
+			rec = extractUpload(limited, "limited", "pack.zip", "true", testAssetZip(t))
+			assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+			assert.NoDirExists(t, filepath.Join(testDir, "limited"))
+		},
+	)
+
+	t.Run(
+		"Errors", func(t *testing.T) {
+			rec := extractUpload(hdl, "plain", "notes.txt", "true", []byte("plain text"))
+			assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+
+			zipped := testAssetZip(t)
+			rec = extractUpload(hdl, "broken", "pack.zip", "true", zipped[:len(zipped)-10])
+			assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+			rec = extractUpload(hdl, "plain", "notes.txt", "maybe", []byte("plain text"))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			rec = extractUpload(hdl, "plain", "pack.zip", "false", testAssetZip(t))
+			assert.Equal(t, http.StatusCreated, rec.Code)
+			assert.FileExists(t, filepath.Join(testDir, "plain", "pack.zip"))
+		},
+	)
+}
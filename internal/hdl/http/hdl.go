@@ -2,53 +2,234 @@ package http
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	_ "github.com/JMURv/simple-s3/docs"
 	"github.com/JMURv/simple-s3/pkg/config"
+	"github.com/JMURv/simple-s3/pkg/imgproc"
+	"github.com/JMURv/simple-s3/pkg/lifecycle"
 	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/multipart"
+	"github.com/JMURv/simple-s3/pkg/presign"
+	"github.com/JMURv/simple-s3/pkg/sigv4"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/JMURv/simple-s3/pkg/thumbnail"
+	"github.com/JMURv/simple-s3/pkg/trash"
+	"github.com/JMURv/simple-s3/pkg/tus"
+	"github.com/JMURv/simple-s3/pkg/unpack"
 	u "github.com/JMURv/simple-s3/pkg/utils"
 	utils "github.com/JMURv/simple-s3/pkg/utils/http"
 	"github.com/JMURv/simple-s3/pkg/utils/slugify"
 	swag "github.com/swaggo/http-swagger"
-	"io"
 	"log"
 	"net/http"
-	"os"
+	"net/url"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+// maxTags, maxTagKey and maxTagValue are the limits S3 puts on the tags of
+// an object, in characters
+const maxTags = 10
+const maxTagKey = 128
+const maxTagValue = 256
+
 type Handler struct {
 	port     string
 	server   *http.Server
 	savePath string
 	config   *config.HTTPConfig
+	s3       *config.S3Config
+	storage  storage.Storage
+	verifier *sigv4.Verifier
+
+	streamable []string
+
+	presigner        *presign.Signer
+	presignMaxExpiry time.Duration
+
+	multipart   *multipart.Manager
+	maxPartSize int64
+
+	tus *tus.Store
+
+	images      *imgproc.Cache
+	imageLimits imgproc.Limits
+
+	presets    map[string]url.Values
+	thumbnails *thumbnail.Pool
+
+	uploadPolicies []config.ImageUploadPolicy
+
+	extractLimits unpack.Limits
+
+	lifecycle *lifecycle.Worker
 }
 
-func New(port string, savePath string, config *config.HTTPConfig) *Handler {
-	return &Handler{
+func New(port string, conf *config.Config, store storage.Storage) *Handler {
+	h := &Handler{
 		port:     port,
-		savePath: savePath,
-		config:   config,
+		savePath: conf.SavePath,
+		config:   conf.HTTP,
+		s3:       conf.S3,
+		storage:  store,
+	}
+
+	h.streamable = config.DefaultStreamableTypes
+	if conf.HTTP != nil && len(conf.HTTP.StreamableTypes) > 0 {
+		h.streamable = conf.HTTP.StreamableTypes
+	}
+
+	if conf.Auth != nil && len(conf.Auth.Credentials) > 0 {
+		secrets := make(map[string]string, len(conf.Auth.Credentials))
+		for _, c := range conf.Auth.Credentials {
+			secrets[c.AccessKey] = c.SecretKey
+		}
+
+		region := ""
+		if conf.S3 != nil {
+			region = conf.S3.Region
+		}
+
+		skew := conf.Auth.MaxSkew
+		if skew <= 0 {
+			skew = config.DefaultMaxSkew
+		}
+		h.verifier = sigv4.NewVerifier(secrets, region, skew)
+	}
+
+	tmpPath := conf.TmpPath
+	if tmpPath == "" {
+		tmpPath = config.DefaultTmpPath(conf.SavePath)
+	}
+
+	minPartSize, maxPartSize := int64(config.DefaultMinPartSize), int64(config.DefaultMaxPartSize)
+	multipartExpiry := config.DefaultMultipartExpiry
+	if conf.Multipart != nil {
+		if conf.Multipart.MinPartSize > 0 {
+			minPartSize = conf.Multipart.MinPartSize
+		}
+		if conf.Multipart.MaxPartSize > 0 {
+			maxPartSize = conf.Multipart.MaxPartSize
+		}
+		if conf.Multipart.Expiry > 0 {
+			multipartExpiry = conf.Multipart.Expiry
+		}
 	}
+	h.maxPartSize = maxPartSize
+	h.multipart = multipart.New(filepath.Join(tmpPath, "multipart"), store, minPartSize, maxPartSize, multipartExpiry)
+
+	tusExpiry := config.DefaultTusExpiry
+	if conf.Tus != nil && conf.Tus.Expiry > 0 {
+		tusExpiry = conf.Tus.Expiry
+	}
+	h.tus = tus.New(filepath.Join(tmpPath, "tus"), store, tusExpiry)
+
+	imageCache := config.DefaultImageCachePath(conf.SavePath)
+	h.imageLimits = imgproc.Limits{
+		MaxDimension: config.DefaultImageMaxDimension,
+		MaxPixels:    config.DefaultImageMaxPixels,
+		Quality:      config.DefaultImageQuality,
+	}
+	if conf.Images != nil {
+		if conf.Images.CachePath != "" {
+			imageCache = conf.Images.CachePath
+		}
+		if conf.Images.MaxDimension > 0 {
+			h.imageLimits.MaxDimension = conf.Images.MaxDimension
+		}
+		if conf.Images.MaxPixels > 0 {
+			h.imageLimits.MaxPixels = conf.Images.MaxPixels
+		}
+		if conf.Images.Quality > 0 {
+			h.imageLimits.Quality = conf.Images.Quality
+		}
+		h.setupUploadPolicies(conf.Images.Uploads)
+	}
+	h.images = imgproc.NewCache(imageCache)
+	h.setupThumbnails(conf.Thumbnails)
+
+	h.extractLimits = unpack.Limits{
+		MaxEntries: config.DefaultExtractMaxEntries,
+		MaxSize:    config.DefaultExtractMaxSize,
+	}
+	if conf.Extract != nil {
+		if conf.Extract.MaxEntries > 0 {
+			h.extractLimits.MaxEntries = conf.Extract.MaxEntries
+		}
+		if conf.Extract.MaxSize > 0 {
+			h.extractLimits.MaxSize = conf.Extract.MaxSize
+		}
+	}
+
+	if conf.Presign != nil && conf.Presign.Secret != "" {
+		h.presigner = presign.New(conf.Presign.Secret)
+		h.presignMaxExpiry = conf.Presign.MaxExpiry
+		if h.presignMaxExpiry <= 0 {
+			h.presignMaxExpiry = config.DefaultPresignExpiry
+		}
+	}
+	h.setupLifecycle(conf.Lifecycle, store)
+	return h
 }
 
 func (h *Handler) Start(ctx context.Context) {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/swagger/", swag.WrapHandler)
 
-	mux.HandleFunc("/list", h.listFiles)
-	mux.HandleFunc("/search", h.searchFiles)
-	mux.HandleFunc("/upload", h.createFile)
-	mux.HandleFunc("/delete", h.deleteFile)
-	mux.HandleFunc("/stream/uploads/", h.stream)
-	mux.Handle("/uploads/", http.StripPrefix("/uploads", http.FileServer(http.Dir(h.savePath))))
+	mux.HandleFunc("/list", h.auth(h.listFiles))
+	mux.HandleFunc("/search", h.auth(h.searchFiles))
+	mux.HandleFunc("/upload", h.presigned(h.createFile))
+	mux.HandleFunc("/delete", h.auth(h.deleteFile))
+	mux.HandleFunc("/archive", h.auth(h.archive))
+	mux.HandleFunc("/move", h.auth(h.moveFiles))
+	mux.HandleFunc("/copy", h.auth(h.copyFiles))
+	mux.HandleFunc("/mkdir", h.auth(h.makeDir))
+	mux.HandleFunc("/rmdir", h.auth(h.removeDir))
+	mux.HandleFunc("/tree", h.auth(h.dirTree))
+	mux.HandleFunc("/du", h.auth(h.diskUsage))
+	mux.HandleFunc("/stat", h.auth(h.stat))
+	mux.HandleFunc("/presign", h.auth(h.presignURL))
+	mux.HandleFunc("/reindex", h.auth(h.reindex))
+	mux.HandleFunc("/versions", h.auth(h.listVersions))
+	mux.HandleFunc("/versions/restore", h.auth(h.restoreVersion))
+	mux.HandleFunc("/versions/purge", h.auth(h.purgeVersions))
+	mux.HandleFunc("/trash", h.auth(h.listTrash))
+	mux.HandleFunc("/trash/restore", h.auth(h.restoreTrash))
+	mux.HandleFunc("/trash/empty", h.auth(h.emptyTrash))
+	mux.HandleFunc("/lifecycle/report", h.auth(h.lifecycleReport))
+	mux.HandleFunc("/lifecycle/archive", h.auth(h.listArchived))
+	mux.HandleFunc("/lifecycle/archive/restore", h.auth(h.restoreArchived))
+	mux.HandleFunc("/multipart", h.auth(h.initiateMultipart))
+	mux.HandleFunc("/multipart/", h.auth(h.multipartUpload))
+	mux.HandleFunc("/tus", h.auth(h.tusUpload))
+	mux.HandleFunc("/tus/", h.auth(h.tusUpload))
+	mux.HandleFunc("/stream/uploads/", h.presigned(h.stream))
+	mux.HandleFunc("/uploads/", h.presigned(h.download))
+	mux.HandleFunc("/img/", h.presigned(h.image))
+	mux.HandleFunc("/cover/", h.presigned(h.cover))
+	mux.HandleFunc("/thumbnails", h.auth(h.thumbnailStatus))
+	mux.HandleFunc("/", h.s3Auth(h.s3API))
 
 	h.server = &http.Server{
 		Addr:    h.port,
 		Handler: mux,
 	}
 
+	go h.purgeUploads(ctx)
+	if bin, ok := storage.As[*trash.Storage](h.storage); ok {
+		go h.purgeTrash(ctx, bin)
+	}
+	if h.thumbnails != nil {
+		go h.thumbnails.Run(ctx)
+	}
+	if h.lifecycle != nil {
+		go h.lifecycle.Run(ctx)
+	}
 	go func() {
 		<-ctx.Done()
 		if err := h.server.Shutdown(ctx); err != nil {
@@ -62,65 +243,6 @@ func (h *Handler) Start(ctx context.Context) {
 	}
 }
 
-// stream streams a media file based on the given path
-// @Summary Stream a media file
-// @Description Streams a media file for the given path
-// @Param path path string true "File path"
-// @Produce  media/*
-// @Success 200
-// @Failure 404 {object} utils.ErrorResponse
-// @Failure 415 {object} utils.ErrorResponse
-// @Router /stream/uploads/{path} [get]
-func (h *Handler) stream(w http.ResponseWriter, r *http.Request) {
-	name := r.URL.Path[len("/stream/uploads/"):]
-	path := filepath.Join(h.savePath, name)
-
-	file, err := os.Open(path)
-	if err != nil {
-		utils.ErrResponse(w, http.StatusNotFound, ErrRetrievingFile)
-		return
-	}
-	defer file.Close()
-
-	switch filepath.Ext(name) {
-	case ".jpg", ".jpeg":
-		w.Header().Set("Content-Type", "image/jpeg")
-	case ".png":
-		w.Header().Set("Content-Type", "image/png")
-	case ".gif":
-		w.Header().Set("Content-Type", "image/gif")
-	case ".mp4":
-		w.Header().Set("Content-Type", "video/mp4")
-	case ".webm":
-		w.Header().Set("Content-Type", "video/webm")
-	default:
-		utils.ErrResponse(w, http.StatusUnsupportedMediaType, ErrUnsupportedMediaType)
-	}
-	w.Header().Set("Transfer-Encoding", "chunked")
-
-	log.Println("Streaming mediafile: ", name)
-	buffer := make([]byte, h.config.MaxStreamBuffer)
-	for {
-		n, err := file.Read(buffer)
-		if err != nil && err != io.EOF {
-			utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
-			return
-		}
-		if n == 0 {
-			break
-		}
-
-		if _, err := w.Write(buffer[:n]); err != nil {
-			log.Println("Error writing chunk:", err)
-			return
-		}
-
-		if flusher, ok := w.(http.Flusher); ok {
-			flusher.Flush()
-		}
-	}
-}
-
 // searchFiles search files by name in a directory with pagination
 // @Summary Search files
 // @Description Retrieve a list of files matching the given name from a directory with pagination
@@ -139,42 +261,31 @@ func (h *Handler) searchFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	path := strings.Trim(r.URL.Query().Get("path"), " /\\")
-	if !u.IsValidPath(path) {
+	dir, ok := h.dirKey(r.URL.Query().Get("path"))
+	if !ok {
 		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
 		return
 	}
 
-	paths, err := u.ListFilesRecursive(
-		filepath.Join(h.savePath, path),
-	)
-	if err != nil {
-		log.Println("Error reading directory: ", err)
-		utils.ErrResponse(w, http.StatusInternalServerError, ErrReadingDir)
-		return
-	}
-
-	paths = u.SearchBySubStr(paths, q)
 	page, size := utils.ParsePaginationParams(
 		r, h.config.DefaultPage,
 		h.config.DefaultSize,
 	)
 
-	count := len(paths)
-	start := (page - 1) * size
-	if start > count {
-		start = count
-	}
-
-	end := start + size
-	if end > count {
-		end = count
+	paths, count, err := h.pageDir(r.Context(), dir, q, page, size)
+	if errors.Is(err, storage.ErrInvalidKey) {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	} else if err != nil {
+		log.Println("Error reading directory: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrReadingDir)
+		return
 	}
 
 	totalPages := (count + size - 1) / size
 	utils.SuccessDataResponse(
 		w, http.StatusOK, utils.PaginatedResponse{
-			Data:        paths[start:end],
+			Data:        paths,
 			Count:       count,
 			TotalPages:  totalPages,
 			CurrentPage: page,
@@ -190,6 +301,7 @@ func (h *Handler) searchFiles(w http.ResponseWriter, r *http.Request) {
 // @Param page query int false "Page number" default(1)
 // @Param size query int false "Number of items per page" default(10)
 // @Success 200 {object} utils.PaginatedResponse
+// @Failure 400 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Router /list [get]
 func (h *Handler) listFiles(w http.ResponseWriter, r *http.Request) {
@@ -198,36 +310,26 @@ func (h *Handler) listFiles(w http.ResponseWriter, r *http.Request) {
 		h.config.DefaultSize,
 	)
 
-	path := strings.Trim(r.URL.Query().Get("path"), " /\\")
-	if !u.IsValidPath(path) {
+	dir, ok := h.dirKey(r.URL.Query().Get("path"))
+	if !ok {
 		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
 		return
 	}
 
-	files, err := u.ListFilesRecursive(
-		filepath.Join(h.savePath, path),
-	)
-	if err != nil {
+	files, count, err := h.pageDir(r.Context(), dir, "", page, size)
+	if errors.Is(err, storage.ErrInvalidKey) {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	} else if err != nil {
 		log.Println("Error reading directory: ", err)
 		utils.ErrResponse(w, http.StatusInternalServerError, ErrReadingDir)
 		return
 	}
 
-	count := len(files)
-	start := (page - 1) * size
-	if start > count {
-		start = count
-	}
-
-	end := start + size
-	if end > count {
-		end = count
-	}
-
 	totalPages := (count + size - 1) / size
 	utils.SuccessDataResponse(
 		w, http.StatusOK, utils.PaginatedResponse{
-			Data:        files[start:end],
+			Data:        files,
 			Count:       count,
 			TotalPages:  totalPages,
 			CurrentPage: page,
@@ -238,13 +340,21 @@ func (h *Handler) listFiles(w http.ResponseWriter, r *http.Request) {
 
 // createFile uploads a new file to the server
 // @Summary Upload a new file
-// @Description Uploads a file to a specified path
+// @Description Uploads a file to a specified path. MP4 files whose moov atom comes after the media data are stored with a warning. With extract=true a ZIP, TAR or tar.gz file is unpacked into the path instead and the outcome of every entry is reported as a model.ExtractRes. An existing file is refused with 409 unless its path is versioned, in which case it becomes a previous version.
 // @Accept multipart/form-data
 // @Param path formData string false "Directory path"
 // @Param file formData file true "File to upload"
+// @Param stripMetadata formData bool false "Remove EXIF, XMP and IPTC metadata from JPEG and PNG images"
+// @Param autoOrient formData bool false "Rotate JPEG images upright according to their EXIF orientation"
+// @Param extract formData bool false "Unpack the uploaded archive into path"
+// @Param tags formData string false "Tags for lifecycle rules, URL-encoded as k1=v1&k2=v2"
 // @Success 201 {object} model.FileRes
+// @Success 200 {object} model.ExtractRes "extract=true: no entry could be stored; otherwise 201 with the same body"
 // @Failure 400 {object} utils.ErrorResponse
 // @Failure 409 {object} utils.ErrorResponse
+// @Failure 413 {object} utils.ErrorResponse
+// @Failure 415 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
 // @Failure 500 {object} utils.ErrorResponse
 // @Router /upload [post]
 func (h *Handler) createFile(w http.ResponseWriter, r *http.Request) {
@@ -259,17 +369,15 @@ func (h *Handler) createFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	path := h.savePath
-	if reqPath := r.FormValue("path"); reqPath != "" {
-		if !u.IsValidPath(reqPath) {
-			utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
-			return
-		}
-		path = filepath.Join(h.savePath, strings.Trim(reqPath, " /\\"))
+	// A presigned upload may only target the directory it was signed for
+	reqPath := r.FormValue("path")
+	if presignGrant(r) != nil {
+		reqPath = r.URL.Query().Get("path")
 	}
 
-	if err := os.MkdirAll(path, os.ModePerm); err != nil {
-		utils.ErrResponse(w, http.StatusBadRequest, ErrCreatingDir)
+	dir, ok := h.dirKey(reqPath)
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
 		return
 	}
 
@@ -280,35 +388,101 @@ func (h *Handler) createFile(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	dstPath := filepath.Join(path, slugify.Filename(handler.Filename))
-	if _, err = os.Stat(dstPath); err == nil {
+	tags, err := parseTags(r.FormValue("tags"))
+	if err != nil {
+		utils.ErrResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	r = r.WithContext(storage.WithTags(r.Context(), tags))
+
+	if v := r.FormValue("extract"); v != "" {
+		extract, err := strconv.ParseBool(v)
+		if err != nil {
+			utils.ErrResponse(w, http.StatusBadRequest, fmt.Errorf("%w: extract", ErrInvalidOption))
+			return
+		}
+		if extract {
+			h.extractUpload(w, r, dir, file, handler.Size)
+			return
+		}
+	}
+
+	key := path.Join(dir, slugify.Filename(handler.Filename))
+	// Versioned keys keep what an upload replaces, so they may be written over
+	_, err = h.storage.Stat(r.Context(), key)
+	if err == nil && !storage.Overwritable(h.storage, key) {
 		utils.ErrResponse(w, http.StatusConflict, ErrAlreadyExists)
 		return
 	}
+	overwrite := err == nil
 
-	dst, err := os.Create(dstPath)
+	sanitize, err := h.sanitizeOptions(r, key)
 	if err != nil {
-		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+		utils.ErrResponse(w, http.StatusBadRequest, err)
 		return
 	}
-	defer dst.Close()
 
-	if _, err = io.Copy(dst, file); err != nil {
+	body, err := h.sanitizeUpload(file, sanitize)
+	if err != nil {
+		utils.ErrResponse(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	ctx := storage.WithOriginalName(r.Context(), handler.Filename)
+	info, err := h.storage.Put(ctx, key, body)
+	if errors.Is(err, storage.ErrInvalidKey) {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	} else if err != nil {
+		log.Println("Error storing file: ", err)
 		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
 		return
 	}
+	if overwrite {
+		h.dropVariants(key)
+	}
 
-	utils.SuccessDataResponse(
-		w, http.StatusCreated, &model.FileRes{
-			Path:    "/" + dstPath,
-			ModTime: time.Now().Unix(),
-		},
-	)
+	res := h.uploadRes(r.Context(), info)
+	utils.SuccessDataResponse(w, http.StatusCreated, &res)
+}
+
+// uploadRes describes a file that was just stored, starting its thumbnails
+// and computing its placeholder, video details and audio tags
+func (h *Handler) uploadRes(ctx context.Context, info *storage.ObjectInfo) model.FileRes {
+	res := h.fileRes(info)
+	res.Thumbnails = h.enqueueThumbnails(info)
+	res.Placeholder = h.placeholder(ctx, info)
+	res.Video = h.videoMeta(ctx, info)
+	res.Audio = h.audioMeta(ctx, info)
+	res.Warnings = uploadWarnings(info.Key, res.Video)
+	return res
+}
+
+// parseTags reads tags sent as a URL-encoded query, k1=v1&k2=v2, the way
+// S3 takes them in x-amz-tagging. An empty string is no tags.
+func parseTags(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	q, err := url.ParseQuery(s)
+	if err != nil || len(q) > maxTags {
+		return nil, ErrInvalidTags
+	}
+
+	tags := make(map[string]string, len(q))
+	for k, v := range q {
+		if k == "" || len(v) != 1 || utf8.RuneCountInString(k) > maxTagKey || utf8.RuneCountInString(v[0]) > maxTagValue {
+			return nil, ErrInvalidTags
+		}
+		tags[k] = v[0]
+	}
+	return tags, nil
 }
 
 // deleteFile deletes a specified file
 // @Summary Delete a file
-// @Description Deletes a file from the server
+// @Description Deletes a file from the server. Unless the trash is disabled the file is moved to it and can be restored from /trash until its retention passes. A versioned file also leaves a delete marker behind and can be restored from /versions.
 // @Param path query string true "File path"
 // @Success 204
 // @Failure 400 {object} utils.ErrorResponse
@@ -321,19 +495,165 @@ func (h *Handler) deleteFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	path := strings.Trim(r.URL.Query().Get("path"), " /\\")
-	if path == "" {
+	path := r.URL.Query().Get("path")
+	if strings.Trim(path, " /\\") == "" {
 		utils.ErrResponse(w, http.StatusBadRequest, ErrPathNotProvided)
 		return
 	}
-	if err := os.Remove(path); err != nil && os.IsNotExist(err) {
+
+	key, ok := h.objectKey(path)
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	err := h.storage.Delete(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
 		utils.ErrResponse(w, http.StatusNotFound, err)
 		return
+	} else if errors.Is(err, storage.ErrInvalidKey) {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
 	} else if err != nil {
 		utils.ErrResponse(w, http.StatusInternalServerError, err)
 		return
 	}
+	h.dropVariants(key)
 
-	log.Printf("File %s deleted successfully\n", path)
+	log.Printf("File %s deleted successfully\n", key)
 	utils.SuccessResponse(w, http.StatusNoContent, "OK")
 }
+
+// download serves a stored file, honouring Range and conditional headers
+// @Summary Download a file
+// @Description Serves the file stored at the given path, or one of its previous versions with versionId. A path of the form archive.zip!/dir/file.txt serves a file inside a stored ZIP archive without extracting it, with Range support for entries stored uncompressed. archive.zip!/ and archive.zip!/dir/ list the files of the archive below that directory.
+// @Param path path string true "File path"
+// @Param versionId query string false "Version ID, on versioned paths"
+// @Param page query int false "Page number, for archive listings" default(1)
+// @Param size query int false "Number of items per page, for archive listings" default(10)
+// @Produce octet-stream
+// @Success 200 {object} model.ArchiveListRes "File content, or the listing of an archive directory"
+// @Success 206
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 415 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Failure 501 {object} utils.ErrorResponse
+// @Router /uploads/{path} [get]
+func (h *Handler) download(w http.ResponseWriter, r *http.Request) {
+	key, ok := urlKey(r, "/uploads/")
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	if id := r.URL.Query().Get("versionId"); id != "" {
+		h.downloadVersion(w, r, key, id)
+		return
+	}
+
+	info, err := h.storage.Stat(r.Context(), key)
+	if err != nil {
+		// A file whose name looks like a virtual path still wins
+		if archiveKey, name, ok := splitArchivePath(key); ok {
+			h.archiveEntry(w, r, archiveKey, name)
+			return
+		}
+		utils.ErrResponse(w, http.StatusNotFound, ErrRetrievingFile)
+		return
+	}
+
+	content := storage.NewReadSeeker(r.Context(), h.storage, info)
+	defer content.Close()
+
+	if info.ContentType != "" {
+		w.Header().Set("Content-Type", info.ContentType)
+	}
+	http.ServeContent(w, r, key, info.ModTime, content)
+}
+
+// listDir lists every file below dir, which is relative to savePath
+func (h *Handler) listDir(ctx context.Context, dir string) ([]model.FileRes, error) {
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+
+	objects, _, err := h.storage.List(ctx, prefix, "", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]model.FileRes, 0, len(objects))
+	for i := range objects {
+		files = append(files, h.fileRes(&objects[i]))
+	}
+	return files, nil
+}
+
+// pageDir returns one page of the files below dir whose path contains q,
+// together with the number of matching files. Backends that implement
+// storage.Pager, such as the metadata index, answer without a full listing.
+func (h *Handler) pageDir(ctx context.Context, dir, q string, page, size int) ([]model.FileRes, int, error) {
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+
+	if pager, ok := storage.As[storage.Pager](h.storage); ok {
+		objects, count, err := pager.Page(ctx, prefix, q, (page-1)*size, size)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		files := make([]model.FileRes, 0, len(objects))
+		for i := range objects {
+			files = append(files, h.fileRes(&objects[i]))
+		}
+		return files, count, nil
+	}
+
+	files, err := h.listDir(ctx, dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	if q != "" {
+		files = u.SearchBySubStr(files, q)
+	}
+
+	count := len(files)
+	start := (page - 1) * size
+	if start > count {
+		start = count
+	}
+
+	end := start + size
+	if end > count {
+		end = count
+	}
+	return files[start:end], count, nil
+}
+
+// fileRes describes an object the way the JSON endpoints always have:
+// with its path prefixed by savePath. Images carry their placeholder,
+// videos their container details and audio files their tags once they
+// have been computed.
+func (h *Handler) fileRes(info *storage.ObjectInfo) model.FileRes {
+	return model.FileRes{
+		Path:         h.publicPath(info.Key),
+		ModTime:      info.ModTime.Unix(),
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		ETag:         info.ETag,
+		SHA256:       info.SHA256,
+		OriginalName: info.OriginalName,
+		Tags:         info.Tags,
+		Placeholder:  h.cachedPlaceholder(info),
+		Video:        h.cachedVideoMeta(info),
+		Audio:        cachedAudioMeta(info),
+	}
+}
+
+// publicPath is the path of an object as reported by the JSON endpoints
+func (h *Handler) publicPath(key string) string {
+	return filepath.Join("/", h.savePath, filepath.FromSlash(key))
+}
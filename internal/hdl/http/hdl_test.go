@@ -5,6 +5,8 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/JMURv/simple-s3/pkg/config"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
 	utils "github.com/JMURv/simple-s3/pkg/utils/http"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -22,21 +24,38 @@ import (
 
 const port = ":8080"
 const testDir = "./test_uploads"
+const testTmpDir = "./test_tmp"
+const testBucket = "test-bucket"
 
 const createEndpoint = "/upload"
 const listEndpoint = "/list"
 
 func setupTestHandler() *Handler {
-	return New(
-		port,
-		testDir,
-		&config.HTTPConfig{
+	return New(port, setupTestConfig(), storage.NewLocal(testDir))
+}
+
+func setupTestConfig() *config.Config {
+	return &config.Config{
+		SavePath: testDir,
+		TmpPath:  testTmpDir,
+		HTTP: &config.HTTPConfig{
 			MaxUploadSize:   10 * 1024 * 1024, // 10 MB
 			MaxStreamBuffer: 1024,
 			DefaultPage:     1,
 			DefaultSize:     10,
 		},
-	)
+		S3: &config.S3Config{
+			Bucket: testBucket,
+			Region: "us-east-1",
+		},
+		Multipart: &config.MultipartConfig{
+			MinPartSize: 5,
+			MaxPartSize: 1024,
+		},
+		Images: &config.ImagesConfig{
+			CachePath: filepath.Join(testTmpDir, "img"),
+		},
+	}
 }
 
 func setupTestDir() {
@@ -49,6 +68,9 @@ func teardownTestDir() {
 	if err := os.RemoveAll(testDir); err != nil {
 		log.Println("Error removing test directory: ", err)
 	}
+	if err := os.RemoveAll(testTmpDir); err != nil {
+		log.Println("Error removing test directory: ", err)
+	}
 }
 
 func TestStart(t *testing.T) {
@@ -56,13 +78,16 @@ func TestStart(t *testing.T) {
 	defer teardownTestDir()
 	hdl := New(
 		":8083",
-		testDir,
-		&config.HTTPConfig{
-			MaxUploadSize:   10 * 1024 * 1024,
-			MaxStreamBuffer: 1024,
-			DefaultPage:     1,
-			DefaultSize:     10,
+		&config.Config{
+			SavePath: testDir,
+			HTTP: &config.HTTPConfig{
+				MaxUploadSize:   10 * 1024 * 1024,
+				MaxStreamBuffer: 1024,
+				DefaultPage:     1,
+				DefaultSize:     10,
+			},
 		},
+		storage.NewLocal(testDir),
 	)
 
 	go func() {
@@ -371,6 +396,14 @@ func TestCreateFile(t *testing.T) {
 			assert.Contains(t, string(res), "test_uploads")
 			assert.Contains(t, string(res), fileName)
 
+			fileRes := model.FileRes{}
+			require.NoError(t, json.Unmarshal(res, &fileRes))
+			assert.Equal(t, int64(len("This is a test file.")), fileRes.Size)
+			assert.Equal(t, "text/plain; charset=utf-8", fileRes.ContentType)
+			assert.NotEmpty(t, fileRes.ETag)
+			assert.Len(t, fileRes.SHA256, 64)
+			assert.Equal(t, fileName, fileRes.OriginalName)
+
 			_, err := os.Stat(path)
 			assert.NoError(t, err)
 
@@ -564,7 +597,7 @@ func TestStream(t *testing.T) {
 		"Success MP4 Streaming", func(t *testing.T) {
 			path := filepath.Join(testDir, "testfile.mp4")
 			expType := "video/mp4"
-			expText := "This is a test video file."
+			expText := "\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom"
 
 			err := os.WriteFile(path, []byte(expText), 0644)
 			assert.Nil(t, err)
@@ -589,7 +622,7 @@ func TestStream(t *testing.T) {
 		"Success WEBM Streaming", func(t *testing.T) {
 			path := filepath.Join(testDir, "testfile.webm")
 			expType := "video/webm"
-			expText := "This is a test video file."
+			expText := "\x1A\x45\xDF\xA3\x9F\x42\x82\x84webm"
 
 			err := os.WriteFile(path, []byte(expText), 0644)
 			assert.Nil(t, err)
@@ -614,7 +647,7 @@ func TestStream(t *testing.T) {
 		"Success JPEG Streaming", func(t *testing.T) {
 			path := filepath.Join(testDir, "testfile.jpeg")
 			expType := "image/jpeg"
-			expText := "This is a test video file."
+			expText := "\xFF\xD8\xFF\xE0\x00\x10JFIF\x00"
 
 			err := os.WriteFile(path, []byte(expText), 0644)
 			assert.Nil(t, err)
@@ -639,7 +672,7 @@ func TestStream(t *testing.T) {
 		"Success PNG Streaming", func(t *testing.T) {
 			path := filepath.Join(testDir, "testfile.png")
 			expType := "image/png"
-			expText := "This is a test video file."
+			expText := "\x89PNG\r\n\x1A\n\x00\x00\x00\x0DIHDR"
 
 			err := os.WriteFile(path, []byte(expText), 0644)
 			assert.Nil(t, err)
@@ -664,7 +697,7 @@ func TestStream(t *testing.T) {
 		"Success GIF Streaming", func(t *testing.T) {
 			path := filepath.Join(testDir, "testfile.gif")
 			expType := "image/gif"
-			expText := "This is a test video file."
+			expText := "GIF89a\x01\x00\x01\x00"
 
 			err := os.WriteFile(path, []byte(expText), 0644)
 			assert.Nil(t, err)
@@ -702,6 +735,78 @@ func TestStream(t *testing.T) {
 		},
 	)
 
+	t.Run(
+		"Disguised Text", func(t *testing.T) {
+			path := filepath.Join(testDir, "disguised.mp4")
+			err := os.WriteFile(path, []byte("This is not a video file."), 0644)
+			assert.Nil(t, err)
+			defer os.Remove(path)
+
+			req := httptest.NewRequest(http.MethodGet, "/stream/uploads/disguised.mp4", nil)
+			rec := httptest.NewRecorder()
+
+			handler.stream(rec, req)
+
+			res := rec.Result()
+			assert.Equal(t, http.StatusUnsupportedMediaType, res.StatusCode)
+			assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+			body, err := io.ReadAll(res.Body)
+			assert.Nil(t, err)
+			assert.NotContains(t, string(body), "This is not a video file.")
+		},
+	)
+
+	t.Run(
+		"Sniffed Without Extension", func(t *testing.T) {
+			path := filepath.Join(testDir, "recording")
+			expText := "ID3\x04\x00\x00\x00\x00\x00\x00"
+			err := os.WriteFile(path, []byte(expText), 0644)
+			assert.Nil(t, err)
+			defer os.Remove(path)
+
+			req := httptest.NewRequest(http.MethodGet, "/stream/uploads/recording", nil)
+			rec := httptest.NewRecorder()
+
+			handler.stream(rec, req)
+
+			res := rec.Result()
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, "audio/mpeg", res.Header.Get("Content-Type"))
+		},
+	)
+
+	t.Run(
+		"Configured Allow-List", func(t *testing.T) {
+			path := filepath.Join(testDir, "document.pdf")
+			err := os.WriteFile(path, []byte("%PDF-1.7\n"), 0644)
+			assert.Nil(t, err)
+			defer os.Remove(path)
+
+			rec := httptest.NewRecorder()
+			handler.stream(rec, httptest.NewRequest(http.MethodGet, "/stream/uploads/document.pdf", nil))
+			assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+
+			conf := setupTestConfig()
+			conf.HTTP.StreamableTypes = []string{"application/pdf", "video/mp4"}
+			hdl := New(port, conf, handler.storage)
+
+			rec = httptest.NewRecorder()
+			hdl.stream(rec, httptest.NewRequest(http.MethodGet, "/stream/uploads/document.pdf", nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+
+			gif := filepath.Join(testDir, "animation.gif")
+			err = os.WriteFile(gif, []byte("GIF89a\x01\x00\x01\x00"), 0644)
+			assert.Nil(t, err)
+			defer os.Remove(gif)
+
+			rec = httptest.NewRecorder()
+			hdl.stream(rec, httptest.NewRequest(http.MethodGet, "/stream/uploads/animation.gif", nil))
+			assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+		},
+	)
+
 	t.Run(
 		"File Not Found", func(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "/stream/uploads/nonexistent.mp4", nil)
@@ -714,3 +819,59 @@ func TestStream(t *testing.T) {
 		},
 	)
 }
+
+func TestMemoryStorage(t *testing.T) {
+	const memoryVideo = "\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00in-memory video"
+	hdl := New(
+		port,
+		&config.Config{
+			SavePath: "uploads",
+			HTTP: &config.HTTPConfig{
+				MaxUploadSize:   1024,
+				MaxStreamBuffer: 16,
+				DefaultPage:     1,
+				DefaultSize:     10,
+			},
+		},
+		storage.NewMemory(),
+	)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("path", "videos")
+	file, _ := writer.CreateFormFile("file", "Clip One.mp4")
+	file.Write([]byte(memoryVideo))
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, createEndpoint, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	hdl.createFile(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), "/uploads/videos/clip-one.mp4")
+
+	rec = httptest.NewRecorder()
+	hdl.listFiles(rec, httptest.NewRequest(http.MethodGet, "/list?path=videos", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "clip-one.mp4")
+
+	rec = httptest.NewRecorder()
+	hdl.stream(rec, httptest.NewRequest(http.MethodGet, "/stream/uploads/videos/clip-one.mp4", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, memoryVideo, rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/uploads/videos/clip-one.mp4", nil)
+	req.Header.Set("Range", "bytes=19-24")
+	rec = httptest.NewRecorder()
+	hdl.download(rec, req)
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "memory", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	hdl.deleteFile(rec, httptest.NewRequest(http.MethodDelete, "/delete?path=/uploads/videos/clip-one.mp4", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = httptest.NewRecorder()
+	hdl.stream(rec, httptest.NewRequest(http.MethodGet, "/stream/uploads/videos/clip-one.mp4", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,270 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/JMURv/simple-s3/pkg/config"
+	"github.com/JMURv/simple-s3/pkg/imgproc"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	utils "github.com/JMURv/simple-s3/pkg/utils/http"
+	"github.com/JMURv/simple-s3/pkg/utils/mediatype"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// image serves a resized, cropped or converted variant of a stored image
+// @Summary Transform an image
+// @Description Decodes a JPEG, PNG or GIF image and serves it resized to w and h. fit=contain (default) scales the image down to fit the box, fit=cover crops it to fill the box and fit=fill stretches it. q sets the JPEG quality and format converts to jpeg, png or gif. Variants are cached on disk and dropped when the original is deleted; X-Cache reports HIT or MISS.
+// @Param path path string true "File path"
+// @Param w query int false "Width in pixels"
+// @Param h query int false "Height in pixels"
+// @Param fit query string false "contain, cover or fill" default(contain)
+// @Param q query int false "JPEG quality" default(80)
+// @Param format query string false "jpeg, png or gif"
+// @Produce  image/jpeg,image/png,image/gif
+// @Success 200
+// @Success 304
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 415 {object} utils.ErrorResponse
+// @Failure 422 {object} utils.ErrorResponse
+// @Router /img/{path} [get]
+func (h *Handler) image(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+
+	key, ok := urlKey(r, "/img/")
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	info, err := h.storage.Stat(r.Context(), key)
+	if err != nil {
+		utils.ErrResponse(w, http.StatusNotFound, ErrRetrievingFile)
+		return
+	}
+
+	source, err := h.imageFormat(r.Context(), info)
+	if errors.Is(err, ErrUnsupportedMediaType) {
+		utils.ErrResponse(w, http.StatusUnsupportedMediaType, err)
+		return
+	} else if err != nil {
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrRetrievingFile)
+		return
+	}
+
+	opts, err := imgproc.ParseOptions(r.URL.Query(), h.imageLimits)
+	if err != nil {
+		utils.ErrResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if opts.Format == "" {
+		opts.Format = source
+	}
+
+	file, stat, hit, err := h.variant(r.Context(), info, opts)
+	if errors.Is(err, imgproc.ErrUnsupportedFormat) || errors.Is(err, imgproc.ErrTooLarge) {
+		utils.ErrResponse(w, http.StatusUnprocessableEntity, err)
+		return
+	} else if err != nil {
+		log.Println("Error rendering image: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+		return
+	}
+	defer file.Close()
+
+	cache := "MISS"
+	if hit {
+		cache = "HIT"
+	}
+	w.Header().Set("Content-Type", imgproc.ContentType(opts.Format))
+	w.Header().Set("X-Cache", cache)
+	http.ServeContent(w, r, opts.Name(), stat.ModTime(), file)
+}
+
+// imageFormat detects the format of a stored image, failing with
+// ErrUnsupportedMediaType for anything that can't be transformed
+func (h *Handler) imageFormat(ctx context.Context, info *storage.ObjectInfo) (string, error) {
+	contentType, err := h.sniff(ctx, info.Key, info.Size)
+	if err != nil {
+		return "", err
+	}
+	if format := imgproc.FormatOf(contentType); format != "" {
+		return format, nil
+	}
+	return "", ErrUnsupportedMediaType
+}
+
+// variant opens the variant of an image described by opts, rendering it on
+// a cache miss. It reports whether the variant was served from the cache.
+func (h *Handler) variant(ctx context.Context, info *storage.ObjectInfo, opts imgproc.Options) (*os.File, os.FileInfo, bool, error) {
+	file, stat, err := h.images.Open(info.Key, opts, info.ModTime)
+	if !errors.Is(err, imgproc.ErrCacheMiss) {
+		return file, stat, err == nil, err
+	}
+
+	file, stat, err = h.images.Store(
+		info.Key, opts, func(dst io.Writer) error {
+			src, _, err := h.storage.Get(ctx, info.Key, 0, -1)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+			return imgproc.Transform(dst, src, opts, h.imageLimits.MaxPixels)
+		},
+	)
+	return file, stat, false, err
+}
+
+// setupUploadPolicies orders the per-prefix upload defaults so the longest
+// prefix is tried first
+func (h *Handler) setupUploadPolicies(policies []config.ImageUploadPolicy) {
+	h.uploadPolicies = make([]config.ImageUploadPolicy, 0, len(policies))
+	for _, p := range policies {
+		p.Prefix = strings.Trim(p.Prefix, " /\\")
+		h.uploadPolicies = append(h.uploadPolicies, p)
+	}
+	sort.SliceStable(
+		h.uploadPolicies, func(i, j int) bool {
+			return len(h.uploadPolicies[i].Prefix) > len(h.uploadPolicies[j].Prefix)
+		},
+	)
+}
+
+// sanitizeOptions combines the upload policy of key with the stripMetadata
+// and autoOrient form values, which take precedence
+func (h *Handler) sanitizeOptions(r *http.Request, key string) (imgproc.SanitizeOptions, error) {
+	o := imgproc.SanitizeOptions{Quality: imgproc.OriginalQuality, MaxPixels: h.imageLimits.MaxPixels}
+	for _, p := range h.uploadPolicies {
+		if p.Prefix == "" || key == p.Prefix || strings.HasPrefix(key, p.Prefix+"/") {
+			o.StripMetadata, o.AutoOrient = p.StripMetadata, p.AutoOrient
+			break
+		}
+	}
+
+	for name, dst := range map[string]*bool{"stripMetadata": &o.StripMetadata, "autoOrient": &o.AutoOrient} {
+		v := r.FormValue(name)
+		if v == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return o, fmt.Errorf("%w: %s", ErrInvalidOption, name)
+		}
+		*dst = b
+	}
+	return o, nil
+}
+
+// maxSanitizeSize bounds the images read into memory to be sanitized when
+// uploads aren't limited in size
+const maxSanitizeSize = 256 << 20 // 256 MB
+
+// sanitizeUpload applies o to an uploaded JPEG or PNG. Anything else, or an
+// upload that needs no processing, is passed through untouched. Images are
+// processed in memory, so those above the upload limit are refused.
+func (h *Handler) sanitizeUpload(file io.Reader, o imgproc.SanitizeOptions) (io.Reader, error) {
+	if !o.StripMetadata && !o.AutoOrient {
+		return file, nil
+	}
+
+	buffered := bufio.NewReaderSize(file, mediatype.SniffLen)
+	head, err := buffered.Peek(mediatype.SniffLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if format := imgproc.FormatOf(mediatype.Detect(head)); format != imgproc.FormatJPEG && format != imgproc.FormatPNG {
+		return buffered, nil
+	}
+
+	limit := h.config.MaxUploadSize
+	if limit <= 0 {
+		limit = maxSanitizeSize
+	}
+	data, err := io.ReadAll(io.LimitReader(buffered, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, imgproc.ErrTooLarge)
+	}
+	data, err = imgproc.Sanitize(data, o)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	return bytes.NewReader(data), nil
+}
+
+// placeholder returns the placeholder of an image, computing and caching it
+// when it is missing or stale. Anything that isn't a JPEG, PNG or GIF, or
+// can't be decoded, has none.
+func (h *Handler) placeholder(ctx context.Context, info *storage.ObjectInfo) *model.PlaceholderRes {
+	if res := h.cachedPlaceholder(info); res != nil {
+		return res
+	}
+	if _, err := h.imageFormat(ctx, info); err != nil {
+		return nil
+	}
+
+	src, _, err := h.storage.Get(ctx, info.Key, 0, -1)
+	if err != nil {
+		log.Println("Error opening image: ", err)
+		return nil
+	}
+	defer src.Close()
+
+	img, err := imgproc.Decode(src, h.imageLimits.MaxPixels)
+	if err != nil {
+		return nil
+	}
+	p, err := imgproc.NewPlaceholder(img)
+	if err != nil {
+		return nil
+	}
+	if err = h.images.StorePlaceholder(info.Key, p); err != nil {
+		log.Printf("Error caching placeholder of %s: %v\n", info.Key, err)
+	}
+	return placeholderRes(p)
+}
+
+// cachedPlaceholder returns the placeholder of an image if it has already
+// been computed. Objects known not to be images are skipped without
+// touching the cache.
+func (h *Handler) cachedPlaceholder(info *storage.ObjectInfo) *model.PlaceholderRes {
+	if info.ContentType != "" && imgproc.FormatOf(info.ContentType) == "" {
+		return nil
+	}
+
+	p, err := h.images.Placeholder(info.Key, info.ModTime)
+	if err != nil {
+		return nil
+	}
+	return placeholderRes(p)
+}
+
+func placeholderRes(p *imgproc.Placeholder) *model.PlaceholderRes {
+	return &model.PlaceholderRes{BlurHash: p.BlurHash, Preview: p.Preview, Color: p.Color}
+}
+
+// dropVariants removes the cached image variants of a deleted file and
+// cancels its pending thumbnails
+func (h *Handler) dropVariants(key string) {
+	if h.thumbnails != nil {
+		h.thumbnails.Forget(key)
+	}
+	if err := h.images.Invalidate(key); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Error dropping variants of %s: %v\n", key, err)
+	}
+}
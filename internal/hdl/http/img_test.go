@@ -0,0 +1,218 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/JMURv/simple-s3/pkg/model"
+	utils "github.com/JMURv/simple-s3/pkg/utils/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func encodeTestImage(t *testing.T, width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 4), G: uint8(y * 4), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func writeTestImage(t *testing.T, name string, width, height int) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(testDir, name)), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, name), encodeTestImage(t, width, height), 0644))
+}
+
+func TestImage(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupTestHandler()
+
+	writeTestImage(t, "avatars/photo.png", 60, 30)
+
+	transform := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		hdl.image(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	t.Run(
+		"Resize And Convert", func(t *testing.T) {
+			rec := transform("/img/avatars/photo.png?w=20&h=20&fit=cover&format=jpeg&q=70")
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "image/jpeg", rec.Header().Get("Content-Type"))
+			assert.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+
+			conf, format, err := image.DecodeConfig(rec.Body)
+			require.NoError(t, err)
+			assert.Equal(t, "jpeg", format)
+			assert.Equal(t, 20, conf.Width)
+			assert.Equal(t, 20, conf.Height)
+
+			rec = transform("/img/avatars/photo.png?w=20&h=20&fit=cover&format=jpeg&q=70")
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "HIT", rec.Header().Get("X-Cache"))
+			assert.FileExists(t, filepath.Join(testTmpDir, "img", "avatars", "photo.png", "20x20-cover-q70.jpeg"))
+		},
+	)
+
+	t.Run(
+		"Keeps Format", func(t *testing.T) {
+			rec := transform("/img/avatars/photo.png?w=30")
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+
+			conf, err := png.DecodeConfig(rec.Body)
+			require.NoError(t, err)
+			assert.Equal(t, 30, conf.Width)
+			assert.Equal(t, 15, conf.Height)
+		},
+	)
+
+	t.Run(
+		"Replaced Original", func(t *testing.T) {
+			require.Equal(t, "HIT", transform("/img/avatars/photo.png?w=30").Header().Get("X-Cache"))
+
+			writeTestImage(t, "avatars/photo.png", 40, 40)
+			future := time.Now().Add(time.Minute)
+			require.NoError(t, os.Chtimes(filepath.Join(testDir, "avatars", "photo.png"), future, future))
+
+			rec := transform("/img/avatars/photo.png?w=30")
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+
+			conf, err := png.DecodeConfig(rec.Body)
+			require.NoError(t, err)
+			assert.Equal(t, 30, conf.Height)
+		},
+	)
+
+	t.Run(
+		"Invalid Options", func(t *testing.T) {
+			assert.Equal(t, http.StatusBadRequest, transform("/img/avatars/photo.png?w=abc").Code)
+			assert.Equal(t, http.StatusBadRequest, transform("/img/avatars/photo.png?w=10&fit=cover").Code)
+			assert.Equal(t, http.StatusBadRequest, transform("/img/avatars/photo.png?w=99999").Code)
+			assert.Equal(t, http.StatusBadRequest, transform("/img/avatars/photo.png?format=bmp").Code)
+		},
+	)
+
+	t.Run(
+		"Not An Image", func(t *testing.T) {
+			require.NoError(t, os.WriteFile(filepath.Join(testDir, "notes.png"), []byte("just text"), 0644))
+			assert.Equal(t, http.StatusUnsupportedMediaType, transform("/img/notes.png?w=10").Code)
+		},
+	)
+
+	t.Run(
+		"Too Many Pixels", func(t *testing.T) {
+			conf := setupTestConfig()
+			conf.Images.MaxPixels = 100
+			rec := httptest.NewRecorder()
+			New(port, conf, hdl.storage).image(rec, httptest.NewRequest(http.MethodGet, "/img/avatars/photo.png?w=5", nil))
+			assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		},
+	)
+
+	t.Run(
+		"Not Found", func(t *testing.T) {
+			assert.Equal(t, http.StatusNotFound, transform("/img/avatars/missing.png?w=10").Code)
+		},
+	)
+
+	t.Run(
+		"Delete Drops Variants", func(t *testing.T) {
+			variants := filepath.Join(testTmpDir, "img", "avatars", "photo.png")
+			assert.DirExists(t, variants)
+
+			rec := httptest.NewRecorder()
+			hdl.deleteFile(rec, httptest.NewRequest(http.MethodDelete, "/delete?path=avatars/photo.png", nil))
+			require.Equal(t, http.StatusNoContent, rec.Code)
+
+			_, err := os.Stat(variants)
+			assert.True(t, os.IsNotExist(err))
+			assert.Equal(t, http.StatusNotFound, transform("/img/avatars/photo.png?w=30").Code)
+		},
+	)
+}
+
+// listByName lists dir and returns its files by name
+func listByName(t *testing.T, hdl *Handler, dir string) map[string]model.FileRes {
+	rec := httptest.NewRecorder()
+	hdl.listFiles(rec, httptest.NewRequest(http.MethodGet, "/list?path="+dir, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	res := utils.PaginatedResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	files := make(map[string]model.FileRes, len(res.Data))
+	for _, f := range res.Data {
+		files[filepath.Base(f.Path)] = f
+	}
+	return files
+}
+
+func TestPlaceholders(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupTestHandler()
+
+	t.Run(
+		"Upload", func(t *testing.T) {
+			res := uploadFile(t, hdl, "gallery", "wide.png", encodeTestImage(t, 60, 30))
+			require.NotNil(t, res.Placeholder)
+			assert.Len(t, res.Placeholder.BlurHash, 28)
+			assert.Equal(t, "L", res.Placeholder.BlurHash[:1])
+			assert.Regexp(t, `^#[0-9a-f]{6}$`, res.Placeholder.Color)
+			assert.True(t, strings.HasPrefix(res.Placeholder.Preview, "data:image/jpeg;base64,"))
+
+			notes := uploadFile(t, hdl, "gallery", "notes.txt", []byte("not an image"))
+			assert.Nil(t, notes.Placeholder)
+
+			files := listByName(t, hdl, "gallery")
+			assert.Equal(t, res.Placeholder, files["wide.png"].Placeholder)
+			assert.Nil(t, files["notes.txt"].Placeholder)
+		},
+	)
+
+	t.Run(
+		"Computed By Stat", func(t *testing.T) {
+			writeTestImage(t, "legacy/tall.png", 20, 40)
+			assert.Nil(t, listByName(t, hdl, "legacy")["tall.png"].Placeholder)
+
+			res := statFile(t, hdl, "legacy/tall.png")
+			require.NotNil(t, res.Placeholder)
+			assert.Equal(t, "T", res.Placeholder.BlurHash[:1])
+			assert.Equal(t, res.Placeholder, listByName(t, hdl, "legacy")["tall.png"].Placeholder)
+		},
+	)
+
+	t.Run(
+		"Replaced Behind The Server", func(t *testing.T) {
+			future := time.Now().Add(time.Hour)
+			require.NoError(t, os.Chtimes(filepath.Join(testDir, "legacy", "tall.png"), future, future))
+			assert.Nil(t, listByName(t, hdl, "legacy")["tall.png"].Placeholder)
+		},
+	)
+
+	t.Run(
+		"Dropped On Delete", func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			hdl.deleteFile(rec, httptest.NewRequest(http.MethodDelete, "/delete?path=gallery/wide.png", nil))
+			require.Equal(t, http.StatusNoContent, rec.Code)
+			assert.NoFileExists(t, filepath.Join(testTmpDir, "img", "gallery", "wide.png", "placeholder.json"))
+		},
+	)
+}
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"context"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	utils "github.com/JMURv/simple-s3/pkg/utils/http"
+	"log"
+	"net/http"
+)
+
+// reindexer is implemented by storage backed by the metadata index
+type reindexer interface {
+	Reindex(ctx context.Context) (int, error)
+}
+
+// reindex rebuilds the metadata index from the files in savePath
+// @Summary Rebuild the metadata index
+// @Description Rebuilds the index that serves /list and /search from the files currently in savePath, picking up files changed outside the server
+// @Success 200 {object} utils.Response
+// @Failure 500 {object} utils.ErrorResponse
+// @Failure 501 {object} utils.ErrorResponse
+// @Router /reindex [post]
+func (h *Handler) reindex(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+
+	idx, ok := storage.As[reindexer](h.storage)
+	if !ok {
+		utils.ErrResponse(w, http.StatusNotImplemented, ErrIndexDisabled)
+		return
+	}
+
+	n, err := idx.Reindex(r.Context())
+	if err != nil {
+		log.Println("Error rebuilding index: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+		return
+	}
+
+	log.Printf("Indexed %d files\n", n)
+	utils.SuccessResponse(w, http.StatusOK, n)
+}
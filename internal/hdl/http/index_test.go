@@ -0,0 +1,152 @@
+package http
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/JMURv/simple-s3/pkg/index"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	utils "github.com/JMURv/simple-s3/pkg/utils/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func setupIndexedHandler(t *testing.T) *Handler {
+	idx, err := index.Open(filepath.Join(testTmpDir, "index"))
+	require.NoError(t, err)
+	t.Cleanup(func() { idx.Close() })
+
+	return New(port, setupTestConfig(), index.Wrap(storage.NewLocal(testDir), idx))
+}
+
+func TestIndexedListing(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupIndexedHandler(t)
+
+	upload := func(t *testing.T, dir, name string) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		writer.WriteField("path", dir)
+		file, _ := writer.CreateFormFile("file", name)
+		file.Write([]byte(name))
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, createEndpoint, body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+		hdl.createFile(rec, req)
+		require.Equal(t, http.StatusCreated, rec.Code)
+	}
+	list := func(t *testing.T, handler http.HandlerFunc, target string) (*httptest.ResponseRecorder, *utils.PaginatedResponse) {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+		res := &utils.PaginatedResponse{}
+		json.NewDecoder(bytes.NewReader(rec.Body.Bytes())).Decode(res)
+		return rec, res
+	}
+
+	for _, name := range []string{"c.txt", "a.txt", "b.txt"} {
+		upload(t, "docs", name)
+	}
+	upload(t, "photos", "cat.jpg")
+
+	t.Run(
+		"Ordered Pages", func(t *testing.T) {
+			rec, res := list(t, hdl.listFiles, "/list?path=docs&page=1&size=2")
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, 3, res.Count)
+			assert.Equal(t, 2, res.TotalPages)
+			assert.True(t, res.HasNextPage)
+			require.Len(t, res.Data, 2)
+			assert.Equal(t, "/test_uploads/docs/a.txt", res.Data[0].Path)
+			assert.Equal(t, "/test_uploads/docs/b.txt", res.Data[1].Path)
+
+			_, res = list(t, hdl.listFiles, "/list?path=docs&page=2&size=2")
+			require.Len(t, res.Data, 1)
+			assert.Equal(t, "/test_uploads/docs/c.txt", res.Data[0].Path)
+		},
+	)
+
+	t.Run(
+		"Metadata", func(t *testing.T) {
+			_, res := list(t, hdl.searchFiles, "/search?q=cat")
+			require.Len(t, res.Data, 1)
+
+			file := res.Data[0]
+			assert.Equal(t, int64(len("cat.jpg")), file.Size)
+			assert.Equal(t, "image/jpeg", file.ContentType)
+			assert.Equal(t, fmt.Sprintf(`"%x"`, md5.Sum([]byte("cat.jpg"))), file.ETag)
+			assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte("cat.jpg"))), file.SHA256)
+			assert.Equal(t, "cat.jpg", file.OriginalName)
+		},
+	)
+
+	t.Run(
+		"Search", func(t *testing.T) {
+			rec, res := list(t, hdl.searchFiles, "/search?q=CAT")
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, 1, res.Count)
+			assert.Equal(t, "/test_uploads/photos/cat.jpg", res.Data[0].Path)
+
+			_, res = list(t, hdl.searchFiles, "/search?q=cat&path=docs")
+			assert.Equal(t, 0, res.Count)
+		},
+	)
+
+	t.Run(
+		"Delete", func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			hdl.deleteFile(rec, httptest.NewRequest(http.MethodDelete, "/delete?path=docs/b.txt", nil))
+			require.Equal(t, http.StatusNoContent, rec.Code)
+
+			_, res := list(t, hdl.listFiles, "/list?path=docs")
+			assert.Equal(t, 2, res.Count)
+		},
+	)
+
+	t.Run(
+		"Missing Directory", func(t *testing.T) {
+			rec, _ := list(t, hdl.listFiles, "/list?path=missing")
+			assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		},
+	)
+
+	t.Run(
+		"Reindex", func(t *testing.T) {
+			require.NoError(t, os.WriteFile(filepath.Join(testDir, "docs", "manual.txt"), []byte("x"), 0o644))
+
+			_, res := list(t, hdl.searchFiles, "/search?q=manual")
+			assert.Equal(t, 0, res.Count)
+
+			rec := httptest.NewRecorder()
+			hdl.reindex(rec, httptest.NewRequest(http.MethodGet, "/reindex", nil))
+			assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+			rec = httptest.NewRecorder()
+			hdl.reindex(rec, httptest.NewRequest(http.MethodPost, "/reindex", nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.JSONEq(t, `{"msg": 4}`, rec.Body.String())
+
+			_, res = list(t, hdl.searchFiles, "/search?q=manual")
+			assert.Equal(t, 1, res.Count)
+		},
+	)
+
+	t.Run(
+		"Reindex Without Index", func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			setupTestHandler().reindex(rec, httptest.NewRequest(http.MethodPost, "/reindex", nil))
+			assert.Equal(t, http.StatusNotImplemented, rec.Code)
+		},
+	)
+}
@@ -0,0 +1,216 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"github.com/JMURv/simple-s3/pkg/config"
+	"github.com/JMURv/simple-s3/pkg/lifecycle"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	utils "github.com/JMURv/simple-s3/pkg/utils/http"
+	"log"
+	"net/http"
+	"time"
+)
+
+// setupLifecycle compiles the lifecycle rules, ignoring the invalid ones,
+// and creates the worker applying them
+func (h *Handler) setupLifecycle(conf *config.LifecycleConfig, store storage.Storage) {
+	if conf == nil || len(conf.Rules) == 0 {
+		return
+	}
+
+	rules := make([]lifecycle.Rule, 0, len(conf.Rules))
+	for i, r := range conf.Rules {
+		rule := lifecycle.Rule{
+			Name:    r.Name,
+			Prefix:  r.Prefix,
+			MinAge:  r.MinAge,
+			MinSize: r.MinSize,
+			MaxSize: r.MaxSize,
+			Tags:    r.Tags,
+			Action:  lifecycle.Action(r.Action),
+		}
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("#%d", i+1)
+		}
+		if err := rule.Validate(); err != nil {
+			log.Printf("Ignoring lifecycle rule %s: %v\n", rule.Name, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	interval := conf.Interval
+	if interval <= 0 {
+		interval = config.DefaultLifecycleInterval
+	}
+	archivePath := conf.ArchivePath
+	if archivePath == "" {
+		archivePath = config.DefaultArchivePath(h.savePath)
+	}
+	h.lifecycle = lifecycle.New(store, lifecycle.NewTier(archivePath), rules, interval, h.dropVariants)
+}
+
+// lifecycleReport reports what the lifecycle rules would do
+// @Summary Dry-run the lifecycle rules
+// @Description Evaluates the lifecycle rules against every file without applying them and lists the files each rule would expire or archive, ordered by path. at evaluates them at another time, e.g. to see what expires next week.
+// @Param at query string false "RFC 3339 time to evaluate the rules at" default(now)
+// @Success 200 {object} model.LifecycleReportRes
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Failure 501 {object} utils.ErrorResponse
+// @Router /lifecycle/report [get]
+func (h *Handler) lifecycleReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+
+	if h.lifecycle == nil {
+		utils.ErrResponse(w, http.StatusNotImplemented, ErrLifecycleDisabled)
+		return
+	}
+
+	at := time.Now()
+	if v := r.URL.Query().Get("at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidTime)
+			return
+		}
+		at = t
+	}
+
+	decisions, err := h.lifecycle.Plan(r.Context(), at)
+	if err != nil {
+		log.Println("Error evaluating lifecycle rules: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+		return
+	}
+
+	res := model.LifecycleReportRes{At: at.Unix(), Files: make([]model.LifecycleFileRes, 0, len(decisions))}
+	for _, d := range decisions {
+		if d.Action == lifecycle.Expire {
+			res.Expire++
+		} else {
+			res.Archive++
+		}
+		res.Size += d.Size
+		res.Files = append(
+			res.Files, model.LifecycleFileRes{
+				Path:    h.publicPath(d.Key),
+				Rule:    d.Rule,
+				Action:  string(d.Action),
+				Size:    d.Size,
+				ModTime: d.ModTime.Unix(),
+			},
+		)
+	}
+	utils.SuccessDataResponse(w, http.StatusOK, &res)
+}
+
+// listArchived lists the files moved to the archive tier
+// @Summary List archived files
+// @Description Lists the files that lifecycle rules moved to the compressed archive tier below path, ordered by path, with the size they had and the size they take compressed.
+// @Param path query string false "Directory path" default(/)
+// @Success 200 {object} model.ArchivedListRes
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Failure 501 {object} utils.ErrorResponse
+// @Router /lifecycle/archive [get]
+func (h *Handler) listArchived(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+
+	if h.lifecycle == nil {
+		utils.ErrResponse(w, http.StatusNotImplemented, ErrLifecycleDisabled)
+		return
+	}
+
+	dir, ok := h.dirKey(r.URL.Query().Get("path"))
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	archived, err := h.lifecycle.Tier().List(r.Context(), dir)
+	if errors.Is(err, storage.ErrInvalidKey) {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	} else if err != nil {
+		log.Println("Error listing archive: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+		return
+	}
+
+	res := model.ArchivedListRes{Files: make([]model.ArchivedRes, 0, len(archived))}
+	for _, a := range archived {
+		res.Files = append(
+			res.Files, model.ArchivedRes{
+				Path:           h.publicPath(a.Key),
+				Size:           a.Size,
+				CompressedSize: a.CompressedSize,
+				ModTime:        a.ModTime.Unix(),
+				ArchivedAt:     a.ArchivedAt.Unix(),
+				ContentType:    a.ContentType,
+				ETag:           a.ETag,
+				SHA256:         a.SHA256,
+				OriginalName:   a.OriginalName,
+				Tags:           a.Tags,
+			},
+		)
+	}
+	utils.SuccessDataResponse(w, http.StatusOK, &res)
+}
+
+// restoreArchived moves an archived file back to where it was
+// @Summary Restore an archived file
+// @Description Decompresses the archived file at path back into storage and takes it out of the archive tier. A file stored at that path since is not replaced and the restore is refused with 409, unless the path is versioned.
+// @Param path query string true "File path"
+// @Success 201 {object} model.FileRes
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 501 {object} utils.ErrorResponse
+// @Router /lifecycle/archive/restore [post]
+func (h *Handler) restoreArchived(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+
+	if h.lifecycle == nil {
+		utils.ErrResponse(w, http.StatusNotImplemented, ErrLifecycleDisabled)
+		return
+	}
+
+	key, ok := h.objectKey(r.URL.Query().Get("path"))
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	info, err := h.lifecycle.Tier().Restore(r.Context(), key, h.storage)
+	if errors.Is(err, storage.ErrNotFound) {
+		utils.ErrResponse(w, http.StatusNotFound, err)
+		return
+	} else if errors.Is(err, lifecycle.ErrConflict) {
+		utils.ErrResponse(w, http.StatusConflict, err)
+		return
+	} else if err != nil {
+		log.Println("Error restoring archived file: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+		return
+	}
+	h.dropVariants(key)
+
+	log.Printf("File %s restored from archive\n", key)
+	res := h.uploadRes(r.Context(), info)
+	utils.SuccessDataResponse(w, http.StatusCreated, &res)
+}
@@ -0,0 +1,132 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"github.com/JMURv/simple-s3/pkg/config"
+	"github.com/JMURv/simple-s3/pkg/index"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func uploadTagged(t *testing.T, hdl *Handler, dir, name, tags string, content []byte, status int) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("path", dir)
+	writer.WriteField("tags", tags)
+	file, _ := writer.CreateFormFile("file", name)
+	file.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, createEndpoint, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	hdl.createFile(rec, req)
+	require.Equal(t, status, rec.Code)
+}
+
+func TestLifecycle(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+
+	conf := setupTestConfig()
+	conf.Lifecycle = &config.LifecycleConfig{
+		ArchivePath: t.TempDir(),
+		Rules: []config.LifecycleRule{
+			{Name: "stale", Prefix: "tmp", MinAge: 24 * time.Hour, Action: "expire"},
+			{Name: "cold", Tags: map[string]string{"class": "cold"}, Action: "archive"},
+			{Name: "invalid", Prefix: "docs", Action: "move"},
+		},
+	}
+	idx, err := index.Open(filepath.Join(testTmpDir, "index"))
+	require.NoError(t, err)
+	defer idx.Close()
+	hdl := New(port, conf, index.Wrap(storage.NewLocal(testDir), idx))
+
+	uploadFile(t, hdl, "tmp", "a.txt", []byte("a"))
+	uploadTagged(t, hdl, "docs", "b.txt", "class=cold&owner=me", []byte("bb"), http.StatusCreated)
+	uploadFile(t, hdl, "docs", "c.txt", []byte("c"))
+
+	t.Run(
+		"Report", func(t *testing.T) {
+			res := model.LifecycleReportRes{}
+			dirRequest(t, hdl.lifecycleReport, http.MethodGet, "", http.StatusOK, &res)
+			assert.Equal(t, 0, res.Expire)
+			assert.Equal(t, 1, res.Archive)
+			require.Len(t, res.Files, 1)
+			assert.Equal(t, "/test_uploads/docs/b.txt", res.Files[0].Path)
+			assert.Equal(t, "cold", res.Files[0].Rule)
+
+			at := time.Now().Add(48 * time.Hour).Format(time.RFC3339)
+			dirRequest(t, hdl.lifecycleReport, http.MethodGet, "at="+at, http.StatusOK, &res)
+			assert.Equal(t, 1, res.Expire)
+			assert.Equal(t, int64(3), res.Size)
+			require.Len(t, res.Files, 2)
+			assert.Equal(t, "/test_uploads/tmp/a.txt", res.Files[1].Path)
+			assert.Equal(t, "expire", res.Files[1].Action)
+
+			assert.FileExists(t, filepath.Join(testDir, "tmp", "a.txt"))
+			assert.FileExists(t, filepath.Join(testDir, "docs", "b.txt"))
+		},
+	)
+
+	t.Run(
+		"Apply", func(t *testing.T) {
+			res, err := hdl.lifecycle.Apply(context.Background(), time.Now())
+			require.NoError(t, err)
+			assert.Equal(t, 1, res.Archived)
+			assert.NoFileExists(t, filepath.Join(testDir, "docs", "b.txt"))
+			assert.FileExists(t, filepath.Join(testDir, "docs", "c.txt"))
+
+			archived := model.ArchivedListRes{}
+			dirRequest(t, hdl.listArchived, http.MethodGet, "path=docs", http.StatusOK, &archived)
+			require.Len(t, archived.Files, 1)
+			assert.Equal(t, "/test_uploads/docs/b.txt", archived.Files[0].Path)
+			assert.Equal(t, int64(2), archived.Files[0].Size)
+			assert.Equal(t, map[string]string{"class": "cold", "owner": "me"}, archived.Files[0].Tags)
+		},
+	)
+
+	t.Run(
+		"Restore", func(t *testing.T) {
+			file := model.FileRes{}
+			dirRequest(t, hdl.restoreArchived, http.MethodPost, "path=docs/b.txt", http.StatusCreated, &file)
+			assert.Equal(t, "/test_uploads/docs/b.txt", file.Path)
+			assert.Equal(t, "cold", file.Tags["class"])
+			assert.FileExists(t, filepath.Join(testDir, "docs", "b.txt"))
+			dirRequest(t, hdl.restoreArchived, http.MethodPost, "path=docs/b.txt", http.StatusNotFound, nil)
+
+			archived := model.ArchivedListRes{}
+			dirRequest(t, hdl.listArchived, http.MethodGet, "", http.StatusOK, &archived)
+			assert.Empty(t, archived.Files)
+		},
+	)
+
+	t.Run(
+		"Errors", func(t *testing.T) {
+			dirRequest(t, hdl.lifecycleReport, http.MethodGet, "at=tomorrow", http.StatusBadRequest, nil)
+			dirRequest(t, hdl.lifecycleReport, http.MethodPost, "", http.StatusMethodNotAllowed, nil)
+			dirRequest(t, hdl.restoreArchived, http.MethodPost, "path=../x", http.StatusBadRequest, nil)
+			dirRequest(t, hdl.restoreArchived, http.MethodGet, "path=docs/b.txt", http.StatusMethodNotAllowed, nil)
+
+			uploadTagged(t, hdl, "docs", "d.txt", "class=%zz", []byte("d"), http.StatusBadRequest)
+		},
+	)
+
+	t.Run(
+		"Disabled", func(t *testing.T) {
+			plain := setupTestHandler()
+			dirRequest(t, plain.lifecycleReport, http.MethodGet, "", http.StatusNotImplemented, nil)
+			dirRequest(t, plain.listArchived, http.MethodGet, "", http.StatusNotImplemented, nil)
+			dirRequest(t, plain.restoreArchived, http.MethodPost, "path=a.txt", http.StatusNotImplemented, nil)
+		},
+	)
+}
@@ -0,0 +1,320 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	utils "github.com/JMURv/simple-s3/pkg/utils/http"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	overwriteFail    = "fail"
+	overwriteSkip    = "skip"
+	overwriteReplace = "replace"
+)
+
+const (
+	transferStatusMoved    = "moved"
+	transferStatusCopied   = "copied"
+	transferStatusSkipped  = "skipped"
+	transferStatusConflict = "conflict"
+	transferStatusFailed   = "failed"
+)
+
+// transfer is one file of a move or copy
+type transfer struct {
+	src, dst string
+	info     storage.ObjectInfo
+	exists   bool
+}
+
+// moveFiles moves or renames a file or a directory
+// @Summary Move or rename files
+// @Description Moves the file at src to dst, or every file below the directory src to the same place below dst. On the local backend a directory is renamed in one step when nothing exists at dst yet, and otherwise every file is renamed atomically and the source directories left empty are removed. overwrite decides what happens to files that already exist at the destination: fail (default) moves nothing and reports them as conflicts with 409, skip leaves them and their sources alone, replace overwrites them. The metadata index follows the files; cached image variants are dropped.
+// @Accept json
+// @Produce json
+// @Param body body model.TransferReq true "Source, destination and overwrite policy"
+// @Success 200 {object} model.TransferRes
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} model.TransferRes
+// @Router /move [post]
+func (h *Handler) moveFiles(w http.ResponseWriter, r *http.Request) {
+	h.transferFiles(w, r, false)
+}
+
+// copyFiles copies a file or a directory
+// @Summary Copy files
+// @Description Copies the file at src to dst, or every file below the directory src to the same place below dst, without the data leaving the server. overwrite works as for /move.
+// @Accept json
+// @Produce json
+// @Param body body model.TransferReq true "Source, destination and overwrite policy"
+// @Success 200 {object} model.TransferRes
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} model.TransferRes
+// @Router /copy [post]
+func (h *Handler) copyFiles(w http.ResponseWriter, r *http.Request) {
+	h.transferFiles(w, r, true)
+}
+
+func (h *Handler) transferFiles(w http.ResponseWriter, r *http.Request, keepSource bool) {
+	if r.Method != http.MethodPost {
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+
+	req := &model.TransferReq{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidBody)
+		return
+	}
+
+	switch req.Overwrite {
+	case "":
+		req.Overwrite = overwriteFail
+	case overwriteFail, overwriteSkip, overwriteReplace:
+	default:
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidOption)
+		return
+	}
+
+	src, ok := h.objectKey(req.Src)
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+	dst, ok := h.objectKey(req.Dst)
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	transfers, err := h.planTransfers(r.Context(), src, dst)
+	if errors.Is(err, ErrSameTarget) {
+		utils.ErrResponse(w, http.StatusBadRequest, err)
+		return
+	} else if errors.Is(err, storage.ErrNotFound) {
+		utils.ErrResponse(w, http.StatusNotFound, ErrRetrievingFile)
+		return
+	} else if err != nil {
+		log.Println("Error reading directory: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrReadingDir)
+		return
+	}
+
+	// Conflicts are reported before anything is touched, so a failed
+	// request can simply be repeated with another policy
+	if req.Overwrite == overwriteFail {
+		res := model.TransferRes{}
+		for _, t := range transfers {
+			if t.exists {
+				res.Failed++
+				res.Entries = append(res.Entries, h.transferRes(t, transferStatusConflict, ErrAlreadyExists))
+			}
+		}
+		if res.Failed > 0 {
+			utils.SuccessDataResponse(w, http.StatusConflict, &res)
+			return
+		}
+	}
+
+	done := transferStatusMoved
+	if keepSource {
+		done = transferStatusCopied
+	}
+
+	dir := transfers[0].src != src
+	renamed := !keepSource && dir && h.moveDir(r.Context(), src, dst, transfers)
+
+	res := model.TransferRes{Entries: make([]model.TransferEntryRes, 0, len(transfers))}
+	for _, t := range transfers {
+		if t.exists && req.Overwrite == overwriteSkip {
+			res.Skipped++
+			res.Entries = append(res.Entries, h.transferRes(t, transferStatusSkipped, ErrAlreadyExists))
+			continue
+		}
+
+		var info *storage.ObjectInfo
+		if renamed {
+			info, err = h.movedFile(r.Context(), t)
+		} else {
+			info, err = h.transferFile(r.Context(), t, keepSource)
+		}
+		if err != nil {
+			log.Printf("Error transferring %s to %s: %v\n", t.src, t.dst, err)
+			switch {
+			case errors.Is(err, storage.ErrInvalidKey):
+				err = ErrInvalidPath
+			case errors.Is(err, storage.ErrNotFound):
+				err = ErrRetrievingFile
+			default:
+				err = ErrInternal
+			}
+			res.Failed++
+			res.Entries = append(res.Entries, h.transferRes(t, transferStatusFailed, err))
+			continue
+		}
+
+		entry := h.transferRes(t, done, nil)
+		file := h.fileRes(info)
+		file.Thumbnails = h.enqueueThumbnails(info)
+		entry.File = &file
+		res.Done++
+		res.Entries = append(res.Entries, entry)
+	}
+
+	if !keepSource && dir && !renamed {
+		h.moveEmptyDirs(r.Context(), src, dst)
+	}
+	utils.SuccessDataResponse(w, http.StatusOK, &res)
+}
+
+// planTransfers pairs the files selected by src with their destinations.
+// A src naming a file selects it alone; otherwise it selects every file
+// below it.
+func (h *Handler) planTransfers(ctx context.Context, src, dst string) ([]transfer, error) {
+	if src == dst {
+		return nil, ErrSameTarget
+	}
+
+	var transfers []transfer
+	if info, err := h.storage.Stat(ctx, src); err == nil {
+		transfers = []transfer{{src: src, dst: dst, info: *info}}
+	} else {
+		if strings.HasPrefix(dst, src+"/") {
+			return nil, ErrSameTarget
+		}
+
+		objects, _, err := h.storage.List(ctx, src+"/", "", 0)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
+		if len(objects) == 0 {
+			return nil, storage.ErrNotFound
+		}
+
+		transfers = make([]transfer, 0, len(objects))
+		for _, obj := range objects {
+			transfers = append(
+				transfers, transfer{
+					src:  obj.Key,
+					dst:  dst + strings.TrimPrefix(obj.Key, src),
+					info: obj,
+				},
+			)
+		}
+	}
+
+	for i := range transfers {
+		_, err := h.storage.Stat(ctx, transfers[i].dst)
+		transfers[i].exists = err == nil
+	}
+	return transfers, nil
+}
+
+// transferFile moves or copies one file, replacing whatever is at its
+// destination, and drops the derived data that no longer applies
+func (h *Handler) transferFile(ctx context.Context, t transfer, keepSource bool) (*storage.ObjectInfo, error) {
+	if keepSource {
+		rc, _, err := h.storage.Get(ctx, t.src, 0, -1)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		info, err := h.storage.Put(storage.WithMetaOf(ctx, t.info.Meta), t.dst, rc)
+		if err != nil {
+			return nil, err
+		}
+		h.dropVariants(t.dst)
+		return info, nil
+	}
+
+	if err := h.storage.Move(ctx, t.src, t.dst); err != nil {
+		return nil, err
+	}
+	return h.movedFile(ctx, t)
+}
+
+// movedFile drops the derived data of a file once it has been moved. A
+// moved file keeps its modification time, which could make the variants
+// of a replaced file look fresh.
+func (h *Handler) movedFile(ctx context.Context, t transfer) (*storage.ObjectInfo, error) {
+	h.dropVariants(t.src)
+	h.dropVariants(t.dst)
+	return h.storage.Stat(ctx, t.dst)
+}
+
+// moveDir renames the directory src to dst in one step, which only works
+// when nothing is there yet and the backend can. Reports whether it did;
+// otherwise the files are moved one by one.
+func (h *Handler) moveDir(ctx context.Context, src, dst string, transfers []transfer) bool {
+	for _, t := range transfers {
+		if t.exists {
+			return false
+		}
+	}
+
+	mover, ok := storage.As[storage.DirMover](h.storage)
+	if !ok {
+		return false
+	}
+	err := mover.MoveDir(ctx, src, dst)
+	if err != nil && !errors.Is(err, storage.ErrExists) && !errors.Is(err, errors.ErrUnsupported) {
+		log.Printf("Error moving directory %s to %s: %v\n", src, dst, err)
+	}
+	return err == nil
+}
+
+// moveEmptyDirs recreates the directories below src below dst and removes
+// those of src that moving their files one by one left empty. Directories
+// still holding skipped or failed files stay.
+func (h *Handler) moveEmptyDirs(ctx context.Context, src, dst string) {
+	dirs, ok := storage.As[storage.Dirs](h.storage)
+	if !ok {
+		return
+	}
+
+	below, err := dirs.ListDirs(ctx, src)
+	if err != nil {
+		log.Println("Error reading directory: ", err)
+		return
+	}
+
+	// Children sort after their parents, so going backwards empties every
+	// directory before it is removed
+	for i := len(below) - 1; i >= 0; i-- {
+		if err = dirs.MkDir(ctx, dst+strings.TrimPrefix(below[i], src)); err != nil {
+			log.Printf("Error creating directory: %v\n", err)
+			continue
+		}
+		h.removeMovedDir(ctx, dirs, below[i])
+	}
+	h.removeMovedDir(ctx, dirs, src)
+}
+
+// removeMovedDir removes dir unless something is left in it
+func (h *Handler) removeMovedDir(ctx context.Context, dirs storage.Dirs, dir string) {
+	err := dirs.RemoveDir(ctx, dir)
+	if err != nil && !errors.Is(err, storage.ErrNotEmpty) && !errors.Is(err, storage.ErrNotFound) {
+		log.Printf("Error removing directory %s: %v\n", dir, err)
+	}
+}
+
+func (h *Handler) transferRes(t transfer, status string, err error) model.TransferEntryRes {
+	res := model.TransferEntryRes{
+		Src:    h.publicPath(t.src),
+		Dst:    h.publicPath(t.dst),
+		Status: status,
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
@@ -0,0 +1,186 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func transferFiles(t *testing.T, handler http.HandlerFunc, body string, status int) model.TransferRes {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/move", strings.NewReader(body)))
+	require.Equal(t, status, rec.Code, rec.Body.String())
+
+	res := model.TransferRes{}
+	if status == http.StatusOK || status == http.StatusConflict {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	}
+	return res
+}
+
+func readTestFile(t *testing.T, key string) string {
+	data, err := os.ReadFile(filepath.Join(testDir, filepath.FromSlash(key)))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestMoveFiles(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupIndexedHandler(t)
+
+	uploadFile(t, hdl, "inbox", "Report.txt", []byte("report"))
+	uploadFile(t, hdl, "inbox/2024", "a.txt", []byte("a"))
+	uploadFile(t, hdl, "archive", "report.txt", []byte("old report"))
+
+	t.Run(
+		"Rename", func(t *testing.T) {
+			res := transferFiles(t, hdl.moveFiles, `{"src": "inbox/report.txt", "dst": "inbox/renamed.txt"}`, http.StatusOK)
+			assert.Equal(t, 1, res.Done)
+			require.Len(t, res.Entries, 1)
+			assert.Equal(t, "moved", res.Entries[0].Status)
+			require.NotNil(t, res.Entries[0].File)
+			assert.Equal(t, "Report.txt", res.Entries[0].File.OriginalName)
+			assert.NotEmpty(t, res.Entries[0].File.SHA256)
+
+			assert.Equal(t, "report", readTestFile(t, "inbox/renamed.txt"))
+			assert.NoFileExists(t, filepath.Join(testDir, "inbox", "report.txt"))
+			assert.Equal(t, "Report.txt", statFile(t, hdl, "inbox/renamed.txt").OriginalName)
+		},
+	)
+
+	t.Run(
+		"Conflict", func(t *testing.T) {
+			res := transferFiles(t, hdl.moveFiles, `{"src": "inbox/renamed.txt", "dst": "archive/report.txt"}`, http.StatusConflict)
+			assert.Equal(t, 1, res.Failed)
+			require.Len(t, res.Entries, 1)
+			assert.Equal(t, "conflict", res.Entries[0].Status)
+			assert.Equal(t, "old report", readTestFile(t, "archive/report.txt"))
+			assert.FileExists(t, filepath.Join(testDir, "inbox", "renamed.txt"))
+		},
+	)
+
+	t.Run(
+		"Skip", func(t *testing.T) {
+			res := transferFiles(t, hdl.moveFiles, `{"src": "inbox/renamed.txt", "dst": "archive/report.txt", "overwrite": "skip"}`, http.StatusOK)
+			assert.Equal(t, 1, res.Skipped)
+			assert.Equal(t, "old report", readTestFile(t, "archive/report.txt"))
+		},
+	)
+
+	t.Run(
+		"Replace", func(t *testing.T) {
+			res := transferFiles(t, hdl.moveFiles, `{"src": "inbox/renamed.txt", "dst": "archive/report.txt", "overwrite": "replace"}`, http.StatusOK)
+			assert.Equal(t, 1, res.Done)
+			assert.Equal(t, "report", readTestFile(t, "archive/report.txt"))
+			assert.Equal(t, int64(len("report")), statFile(t, hdl, "archive/report.txt").Size)
+		},
+	)
+
+	t.Run(
+		"Directory", func(t *testing.T) {
+			uploadFile(t, hdl, "inbox", "b.txt", []byte("b"))
+
+			res := transferFiles(t, hdl.moveFiles, `{"src": "/`+strings.TrimPrefix(testDir, "./")+`/inbox", "dst": "done"}`, http.StatusOK)
+			assert.Equal(t, 2, res.Done)
+			assert.Equal(t, "a", readTestFile(t, "done/2024/a.txt"))
+			assert.Equal(t, "b", readTestFile(t, "done/b.txt"))
+
+			files := listByName(t, hdl, "done")
+			assert.Len(t, files, 2)
+			assert.Equal(t, "b.txt", statFile(t, hdl, "done/b.txt").OriginalName)
+			assert.NoDirExists(t, filepath.Join(testDir, "inbox"))
+		},
+	)
+
+	t.Run(
+		"Into Existing Directory", func(t *testing.T) {
+			uploadFile(t, hdl, "outbox/2025", "c.txt", []byte("c"))
+			uploadFile(t, hdl, "outbox", "b.txt", []byte("other b"))
+			require.NoError(t, os.MkdirAll(filepath.Join(testDir, "outbox", "empty"), os.ModePerm))
+
+			res := transferFiles(t, hdl.moveFiles, `{"src": "outbox", "dst": "done", "overwrite": "skip"}`, http.StatusOK)
+			assert.Equal(t, 1, res.Done)
+			assert.Equal(t, 1, res.Skipped)
+			assert.Equal(t, "c", readTestFile(t, "done/2025/c.txt"))
+			assert.DirExists(t, filepath.Join(testDir, "done", "empty"))
+
+			// Only the skipped file is left behind
+			assert.NoDirExists(t, filepath.Join(testDir, "outbox", "2025"))
+			assert.NoDirExists(t, filepath.Join(testDir, "outbox", "empty"))
+			assert.Equal(t, "other b", readTestFile(t, "outbox/b.txt"))
+
+			res = transferFiles(t, hdl.moveFiles, `{"src": "outbox", "dst": "done", "overwrite": "replace"}`, http.StatusOK)
+			assert.Equal(t, 1, res.Done)
+			assert.Equal(t, "other b", readTestFile(t, "done/b.txt"))
+			assert.NoDirExists(t, filepath.Join(testDir, "outbox"))
+		},
+	)
+
+	t.Run(
+		"Errors", func(t *testing.T) {
+			tests := []struct {
+				name   string
+				body   string
+				status int
+			}{
+				{"Invalid Body", `{`, http.StatusBadRequest},
+				{"Unknown Policy", `{"src": "done", "dst": "elsewhere", "overwrite": "merge"}`, http.StatusBadRequest},
+				{"No Source", `{"dst": "elsewhere"}`, http.StatusBadRequest},
+				{"Traversal", `{"src": "done", "dst": "../outside"}`, http.StatusBadRequest},
+				{"Same Path", `{"src": "done", "dst": "done/"}`, http.StatusBadRequest},
+				{"Into Itself", `{"src": "done", "dst": "done/sub"}`, http.StatusBadRequest},
+				{"Missing", `{"src": "missing", "dst": "elsewhere"}`, http.StatusNotFound},
+			}
+
+			for _, tt := range tests {
+				t.Run(
+					tt.name, func(t *testing.T) {
+						transferFiles(t, hdl.moveFiles, tt.body, tt.status)
+					},
+				)
+			}
+		},
+	)
+}
+
+func TestCopyFiles(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupIndexedHandler(t)
+
+	uploadFile(t, hdl, "templates", "Invoice.txt", []byte("invoice"))
+	uploadFile(t, hdl, "templates/mail", "welcome.txt", []byte("welcome"))
+
+	t.Run(
+		"File", func(t *testing.T) {
+			res := transferFiles(t, hdl.copyFiles, `{"src": "templates/invoice.txt", "dst": "drafts/invoice-1.txt"}`, http.StatusOK)
+			require.Len(t, res.Entries, 1)
+			assert.Equal(t, "copied", res.Entries[0].Status)
+			assert.Equal(t, "Invoice.txt", res.Entries[0].File.OriginalName)
+
+			assert.Equal(t, "invoice", readTestFile(t, "drafts/invoice-1.txt"))
+			assert.Equal(t, "invoice", readTestFile(t, "templates/invoice.txt"))
+		},
+	)
+
+	t.Run(
+		"Directory", func(t *testing.T) {
+			res := transferFiles(t, hdl.copyFiles, `{"src": "templates", "dst": "drafts"}`, http.StatusOK)
+			assert.Equal(t, 2, res.Done)
+			assert.Equal(t, "welcome", readTestFile(t, "drafts/mail/welcome.txt"))
+			assert.Len(t, listByName(t, hdl, "templates"), 2)
+			assert.Len(t, listByName(t, hdl, "drafts"), 3)
+
+			res = transferFiles(t, hdl.copyFiles, `{"src": "templates", "dst": "drafts"}`, http.StatusConflict)
+			assert.Equal(t, 2, res.Failed)
+		},
+	)
+}
@@ -0,0 +1,347 @@
+package http
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/multipart"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	utils "github.com/JMURv/simple-s3/pkg/utils/http"
+	"github.com/JMURv/simple-s3/pkg/utils/s3"
+	"github.com/JMURv/simple-s3/pkg/utils/slugify"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// maxManifestSize bounds the body of a complete request: 10000 parts with
+// their etags fit comfortably
+const maxManifestSize = 1 << 20
+
+func (h *Handler) createMultipartUpload(w http.ResponseWriter, r *http.Request, key string) {
+	upload, err := h.multipart.Initiate(key, "")
+	if errors.Is(err, storage.ErrInvalidKey) {
+		s3.ErrResponse(w, r, s3.ErrInvalidObjectName)
+		return
+	} else if err != nil {
+		log.Println("Error initiating multipart upload: ", err)
+		s3.ErrResponse(w, r, s3.ErrInternalError)
+		return
+	}
+
+	s3.XMLResponse(
+		w, http.StatusOK, &s3.InitiateMultipartUploadResult{
+			Bucket:   h.s3.Bucket,
+			Key:      key,
+			UploadID: upload.ID,
+		},
+	)
+}
+
+// s3MultipartAPI serves the operations addressing an existing upload
+func (h *Handler) s3MultipartAPI(w http.ResponseWriter, r *http.Request, key, id string) {
+	upload, err := h.multipart.Get(id)
+	if err != nil || upload.Key != key {
+		s3.ErrResponse(w, r, s3.ErrNoSuchUpload)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		h.uploadPart(w, r, upload)
+	case http.MethodGet:
+		h.listParts(w, r, upload)
+	case http.MethodPost:
+		h.completeMultipartUpload(w, r, upload)
+	case http.MethodDelete:
+		if err = h.multipart.Abort(upload.ID); err != nil {
+			s3.ErrResponse(w, r, multipartS3Error(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s3.ErrResponse(w, r, s3.ErrMethodNotAllowed)
+	}
+}
+
+func (h *Handler) uploadPart(w http.ResponseWriter, r *http.Request, upload *multipart.Upload) {
+	n, err := multipart.PartNumber(r.URL.Query().Get("partNumber"))
+	if err != nil {
+		s3.ErrResponse(w, r, s3.ErrInvalidArgument)
+		return
+	}
+
+	payload, s3err := newS3Payload(r, h.maxPartSize)
+	if s3err != nil {
+		s3.ErrResponse(w, r, s3err)
+		return
+	}
+
+	part, err := h.multipart.PutPart(upload.ID, n, payload)
+	if err != nil {
+		s3.ErrResponse(w, r, multipartS3Error(err))
+		return
+	}
+
+	w.Header().Set("ETag", part.ETag)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) listParts(w http.ResponseWriter, r *http.Request, upload *multipart.Upload) {
+	parts, err := h.multipart.ListParts(upload.ID)
+	if err != nil {
+		s3.ErrResponse(w, r, multipartS3Error(err))
+		return
+	}
+
+	res := &s3.ListPartsResult{
+		Bucket:   h.s3.Bucket,
+		Key:      upload.Key,
+		UploadID: upload.ID,
+		Parts:    make([]s3.Part, 0, len(parts)),
+	}
+	for _, part := range parts {
+		res.Parts = append(
+			res.Parts, s3.Part{
+				PartNumber:   part.Number,
+				LastModified: s3.FormatTime(part.ModTime),
+				ETag:         part.ETag,
+				Size:         part.Size,
+			},
+		)
+	}
+	s3.XMLResponse(w, http.StatusOK, res)
+}
+
+func (h *Handler) completeMultipartUpload(w http.ResponseWriter, r *http.Request, upload *multipart.Upload) {
+	req := &s3.CompleteMultipartUpload{}
+	if err := xml.NewDecoder(io.LimitReader(r.Body, maxManifestSize)).Decode(req); err != nil {
+		s3.ErrResponse(w, r, s3.ErrMalformedXML)
+		return
+	}
+
+	manifest := make([]multipart.CompletedPart, 0, len(req.Parts))
+	for _, part := range req.Parts {
+		manifest = append(manifest, multipart.CompletedPart{Number: part.PartNumber, ETag: part.ETag})
+	}
+
+	_, err := h.storage.Stat(r.Context(), upload.Key)
+	overwrite := err == nil
+
+	info, etag, err := h.multipart.Complete(r.Context(), upload.ID, manifest)
+	if err != nil {
+		s3.ErrResponse(w, r, multipartS3Error(err))
+		return
+	}
+	if overwrite {
+		h.dropVariants(upload.Key)
+	}
+	h.uploadRes(r.Context(), info)
+
+	log.Printf("Object %s assembled from %d parts\n", upload.Key, len(manifest))
+	s3.XMLResponse(
+		w, http.StatusOK, &s3.CompleteMultipartUploadResult{
+			Location: "/" + h.s3.Bucket + "/" + upload.Key,
+			Bucket:   h.s3.Bucket,
+			Key:      upload.Key,
+			ETag:     etag,
+		},
+	)
+}
+
+func multipartS3Error(err error) *s3.Error {
+	switch {
+	case errors.Is(err, multipart.ErrNoSuchUpload):
+		return s3.ErrNoSuchUpload
+	case errors.Is(err, multipart.ErrInvalidPartNumber):
+		return s3.ErrInvalidArgument
+	case errors.Is(err, multipart.ErrInvalidPart):
+		return s3.ErrInvalidPart
+	case errors.Is(err, multipart.ErrInvalidPartOrder):
+		return s3.ErrInvalidPartOrder
+	case errors.Is(err, multipart.ErrPartTooSmall):
+		return s3.ErrEntityTooSmall
+	case errors.Is(err, multipart.ErrPartTooLarge):
+		return s3.ErrEntityTooLarge
+	default:
+		return payloadError(err)
+	}
+}
+
+// initiateMultipart starts a multipart upload
+// @Summary Start a multipart upload
+// @Description Starts an upload whose parts are sent separately and assembled once complete. The file name is slugified like in /upload.
+// @Param path query string false "Directory path"
+// @Param filename query string true "File name"
+// @Success 201 {object} model.MultipartUploadRes
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Router /multipart [post]
+func (h *Handler) initiateMultipart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+
+	dir, ok := h.dirKey(r.URL.Query().Get("path"))
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	filename := r.URL.Query().Get("filename")
+	if filename == "" {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrMissingQuery)
+		return
+	}
+
+	key := path.Join(dir, slugify.Filename(filename))
+	if _, err := h.storage.Stat(r.Context(), key); err == nil && !storage.Overwritable(h.storage, key) {
+		utils.ErrResponse(w, http.StatusConflict, ErrAlreadyExists)
+		return
+	}
+
+	upload, err := h.multipart.Initiate(key, filename)
+	if err != nil {
+		utils.ErrResponse(w, multipartStatus(err), multipartErr(err))
+		return
+	}
+
+	utils.SuccessDataResponse(
+		w, http.StatusCreated, &model.MultipartUploadRes{
+			UploadID: upload.ID,
+			Path:     h.publicPath(key),
+		},
+	)
+}
+
+// multipartUpload serves an in-progress multipart upload
+// @Summary Manage a multipart upload
+// @Description GET /multipart/{id} lists uploaded parts, PUT /multipart/{id}/{partNumber} uploads a part from the raw request body, POST /multipart/{id}/complete assembles the listed parts and DELETE /multipart/{id} aborts the upload
+// @Param id path string true "Upload ID"
+// @Success 200 {array} model.PartRes
+// @Success 201 {object} model.FileRes
+// @Success 204
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Router /multipart/{id} [get]
+func (h *Handler) multipartUpload(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/multipart/"), "/")
+	upload, err := h.multipart.Get(id)
+	if err != nil {
+		utils.ErrResponse(w, http.StatusNotFound, multipartErr(err))
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && action == "":
+		parts, err := h.multipart.ListParts(upload.ID)
+		if err != nil {
+			utils.ErrResponse(w, multipartStatus(err), multipartErr(err))
+			return
+		}
+
+		res := make([]model.PartRes, 0, len(parts))
+		for _, part := range parts {
+			res = append(
+				res, model.PartRes{
+					PartNumber: part.Number,
+					ETag:       part.ETag,
+					Size:       part.Size,
+					ModTime:    part.ModTime.Unix(),
+				},
+			)
+		}
+		utils.SuccessDataResponse(w, http.StatusOK, res)
+	case r.Method == http.MethodPut && action != "":
+		n, err := multipart.PartNumber(action)
+		if err != nil {
+			utils.ErrResponse(w, http.StatusBadRequest, err)
+			return
+		}
+
+		part, err := h.multipart.PutPart(upload.ID, n, r.Body)
+		if err != nil {
+			utils.ErrResponse(w, multipartStatus(err), multipartErr(err))
+			return
+		}
+
+		utils.SuccessDataResponse(
+			w, http.StatusOK, &model.PartRes{
+				PartNumber: part.Number,
+				ETag:       part.ETag,
+				Size:       part.Size,
+				ModTime:    part.ModTime.Unix(),
+			},
+		)
+	case r.Method == http.MethodPost && action == "complete":
+		req := &model.CompleteMultipartReq{}
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxManifestSize)).Decode(req); err != nil {
+			utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidBody)
+			return
+		}
+
+		_, err := h.storage.Stat(r.Context(), upload.Key)
+		if err == nil && !storage.Overwritable(h.storage, upload.Key) {
+			utils.ErrResponse(w, http.StatusConflict, ErrAlreadyExists)
+			return
+		}
+		overwrite := err == nil
+
+		manifest := make([]multipart.CompletedPart, 0, len(req.Parts))
+		for _, part := range req.Parts {
+			manifest = append(manifest, multipart.CompletedPart{Number: part.PartNumber, ETag: part.ETag})
+		}
+
+		info, _, err := h.multipart.Complete(r.Context(), upload.ID, manifest)
+		if err != nil {
+			utils.ErrResponse(w, multipartStatus(err), multipartErr(err))
+			return
+		}
+		if overwrite {
+			h.dropVariants(upload.Key)
+		}
+
+		log.Printf("File %s assembled from %d parts\n", upload.Key, len(manifest))
+		res := h.uploadRes(r.Context(), info)
+		utils.SuccessDataResponse(w, http.StatusCreated, &res)
+	case r.Method == http.MethodDelete && action == "":
+		if err := h.multipart.Abort(upload.ID); err != nil {
+			utils.ErrResponse(w, multipartStatus(err), multipartErr(err))
+			return
+		}
+		utils.SuccessResponse(w, http.StatusNoContent, "OK")
+	default:
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+	}
+}
+
+func multipartStatus(err error) int {
+	switch {
+	case errors.Is(err, multipart.ErrNoSuchUpload):
+		return http.StatusNotFound
+	case errors.Is(err, multipart.ErrPartTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, multipart.ErrInvalidPartNumber),
+		errors.Is(err, multipart.ErrInvalidPart),
+		errors.Is(err, multipart.ErrInvalidPartOrder),
+		errors.Is(err, multipart.ErrPartTooSmall),
+		errors.Is(err, storage.ErrInvalidKey):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// multipartErr hides unexpected errors behind ErrInternal
+func multipartErr(err error) error {
+	if multipartStatus(err) == http.StatusInternalServerError {
+		log.Println("Multipart upload error: ", err)
+		return ErrInternal
+	}
+	return err
+}
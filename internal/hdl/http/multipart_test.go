@@ -0,0 +1,229 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/utils/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestS3Multipart(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupTestHandler()
+
+	object := "/" + testBucket + "/videos/movie.mp4"
+	initiate := func(t *testing.T) string {
+		rec := s3Request(hdl, http.MethodPost, object+"?uploads", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var res s3.InitiateMultipartUploadResult
+		require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &res))
+		assert.Equal(t, "videos/movie.mp4", res.Key)
+		return res.UploadID
+	}
+	uploadPart := func(id string, n int, body string) *httptest.ResponseRecorder {
+		target := fmt.Sprintf("%s?partNumber=%d&uploadId=%s", object, n, id)
+		return s3Request(hdl, http.MethodPut, target, strings.NewReader(body))
+	}
+	complete := func(id string, parts ...s3.Part) *httptest.ResponseRecorder {
+		body, _ := xml.Marshal(&s3.CompleteMultipartUpload{Parts: parts})
+		return s3Request(hdl, http.MethodPost, object+"?uploadId="+id, bytes.NewReader(body))
+	}
+
+	t.Run(
+		"Upload And Complete", func(t *testing.T) {
+			id := initiate(t)
+
+			rec := uploadPart(id, 2, "world")
+			require.Equal(t, http.StatusOK, rec.Code)
+			second := rec.Header().Get("ETag")
+
+			rec = uploadPart(id, 1, "broken")
+			require.Equal(t, http.StatusOK, rec.Code)
+			rec = uploadPart(id, 1, "hello ")
+			require.Equal(t, http.StatusOK, rec.Code)
+			first := rec.Header().Get("ETag")
+
+			rec = s3Request(hdl, http.MethodGet, object+"?uploadId="+id, nil)
+			require.Equal(t, http.StatusOK, rec.Code)
+			var list s3.ListPartsResult
+			require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &list))
+			require.Len(t, list.Parts, 2)
+			assert.Equal(t, 1, list.Parts[0].PartNumber)
+			assert.Equal(t, first, list.Parts[0].ETag)
+			assert.Equal(t, int64(6), list.Parts[0].Size)
+
+			rec = complete(id, s3.Part{PartNumber: 1, ETag: first}, s3.Part{PartNumber: 2, ETag: second})
+			require.Equal(t, http.StatusOK, rec.Code)
+			var res s3.CompleteMultipartUploadResult
+			require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &res))
+			assert.True(t, strings.HasSuffix(res.ETag, `-2"`))
+
+			data, err := os.ReadFile(filepath.Join(testDir, "videos", "movie.mp4"))
+			require.NoError(t, err)
+			assert.Equal(t, "hello world", string(data))
+
+			rec = uploadPart(id, 3, "again")
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+			assert.Contains(t, rec.Body.String(), "NoSuchUpload")
+		},
+	)
+
+	t.Run(
+		"Invalid Manifest", func(t *testing.T) {
+			id := initiate(t)
+			first := uploadPart(id, 1, "ab").Header().Get("ETag")
+			second := uploadPart(id, 2, "cdefgh").Header().Get("ETag")
+
+			rec := complete(id, s3.Part{PartNumber: 2, ETag: second}, s3.Part{PartNumber: 1, ETag: first})
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), "InvalidPartOrder")
+
+			rec = complete(id, s3.Part{PartNumber: 1, ETag: `"0123"`})
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), "InvalidPart")
+
+			rec = complete(id, s3.Part{PartNumber: 1, ETag: first}, s3.Part{PartNumber: 2, ETag: second})
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), "EntityTooSmall")
+
+			rec = s3Request(hdl, http.MethodPost, object+"?uploadId="+id, strings.NewReader("<broken"))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), "MalformedXML")
+		},
+	)
+
+	t.Run(
+		"Invalid Part", func(t *testing.T) {
+			id := initiate(t)
+
+			rec := uploadPart(id, 0, "data")
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			rec = uploadPart(id, 1, strings.Repeat("x", 2048))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), "EntityTooLarge")
+
+			rec = s3Request(hdl, http.MethodGet, "/"+testBucket+"/other.mp4?uploadId="+id, nil)
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		},
+	)
+
+	t.Run(
+		"Abort", func(t *testing.T) {
+			id := initiate(t)
+			require.Equal(t, http.StatusOK, uploadPart(id, 1, "data").Code)
+
+			rec := s3Request(hdl, http.MethodDelete, object+"?uploadId="+id, nil)
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+
+			rec = s3Request(hdl, http.MethodGet, object+"?uploadId="+id, nil)
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		},
+	)
+}
+
+func TestMultipart(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupTestHandler()
+
+	initiate := func(t *testing.T, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/multipart?"+query, nil)
+		rec := httptest.NewRecorder()
+		hdl.initiateMultipart(rec, req)
+		return rec
+	}
+	do := func(method, target string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		hdl.multipartUpload(rec, req)
+		return rec
+	}
+
+	t.Run(
+		"Upload And Complete", func(t *testing.T) {
+			rec := initiate(t, "path=music&filename=My%20Song.mp3")
+			require.Equal(t, http.StatusCreated, rec.Code)
+
+			var upload model.MultipartUploadRes
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&upload))
+			assert.True(t, strings.HasSuffix(upload.Path, "/music/my-song.mp3"))
+
+			parts := make([]model.PartRes, 0, 2)
+			for i, chunk := range []string{"first-", "second"} {
+				rec = do(http.MethodPut, fmt.Sprintf("/multipart/%s/%d", upload.UploadID, i+1), chunk)
+				require.Equal(t, http.StatusOK, rec.Code)
+
+				var part model.PartRes
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&part))
+				parts = append(parts, part)
+			}
+
+			rec = do(http.MethodGet, "/multipart/"+upload.UploadID, "")
+			require.Equal(t, http.StatusOK, rec.Code)
+			var listed []model.PartRes
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&listed))
+			assert.Len(t, listed, 2)
+
+			body, _ := json.Marshal(&model.CompleteMultipartReq{Parts: parts})
+			rec = do(http.MethodPost, "/multipart/"+upload.UploadID+"/complete", string(body))
+			require.Equal(t, http.StatusCreated, rec.Code)
+
+			var res model.FileRes
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+			assert.Equal(t, upload.Path, res.Path)
+
+			data, err := os.ReadFile(filepath.Join(testDir, "music", "my-song.mp3"))
+			require.NoError(t, err)
+			assert.Equal(t, "first-second", string(data))
+
+			rec = initiate(t, "path=music&filename=My%20Song.mp3")
+			assert.Equal(t, http.StatusConflict, rec.Code)
+		},
+	)
+
+	t.Run(
+		"Invalid Requests", func(t *testing.T) {
+			assert.Equal(t, http.StatusBadRequest, initiate(t, "path=music").Code)
+			assert.Equal(t, http.StatusBadRequest, initiate(t, "path=../..&filename=a.txt").Code)
+
+			rec := do(http.MethodGet, "/multipart/0123456789abcdef0123456789abcdef", "")
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+
+			rec = initiate(t, "filename=a.txt")
+			require.Equal(t, http.StatusCreated, rec.Code)
+			var upload model.MultipartUploadRes
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&upload))
+
+			rec = do(http.MethodPut, "/multipart/"+upload.UploadID+"/abc", "data")
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			rec = do(http.MethodPost, "/multipart/"+upload.UploadID+"/complete", "{")
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			rec = do(http.MethodPost, "/multipart/"+upload.UploadID+"/complete", `{"parts":[]}`)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			rec = do(http.MethodPatch, "/multipart/"+upload.UploadID, "")
+			assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+			rec = do(http.MethodDelete, "/multipart/"+upload.UploadID, "")
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+
+			rec = do(http.MethodGet, "/multipart/"+upload.UploadID, "")
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		},
+	)
+}
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"github.com/JMURv/simple-s3/pkg/storage"
+	u "github.com/JMURv/simple-s3/pkg/utils"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// resolvePath turns a path sent by a client into a key relative to
+// savePath, "" being savePath itself. Every handler goes through it, or
+// through urlKey, before touching storage. Paths may start with a slash
+// and with savePath, the way the JSON endpoints report them; anything
+// that could lead outside savePath is refused with ErrInvalidPath. The
+// local backend also refuses keys that a symlink takes outside of it.
+func (h *Handler) resolvePath(p string) (string, error) {
+	p = strings.TrimSpace(p)
+	if !u.IsValidPath(p) {
+		return "", ErrInvalidPath
+	}
+
+	// A single leading slash is the one the JSON endpoints add. Any more
+	// would make a network path on Windows.
+	key, err := storage.CleanKey(strings.TrimPrefix(p, "/"))
+	if err != nil {
+		return "", ErrInvalidPath
+	}
+
+	root := strings.Trim(filepath.ToSlash(filepath.Clean(h.savePath)), "/")
+	if key == root {
+		return "", nil
+	}
+	return strings.TrimPrefix(key, root+"/"), nil
+}
+
+// objectKey resolves a path that has to name something below savePath
+func (h *Handler) objectKey(p string) (string, bool) {
+	key, err := h.resolvePath(p)
+	return key, err == nil && key != ""
+}
+
+// dirKey resolves a path that may also name savePath itself, as ""
+func (h *Handler) dirKey(p string) (string, bool) {
+	key, err := h.resolvePath(p)
+	return key, err == nil
+}
+
+// urlKey resolves the key of the object served at the URL path following
+// prefix. The URL names it relative to savePath already.
+func urlKey(r *http.Request, prefix string) (string, bool) {
+	key, err := storage.CleanKey(strings.TrimPrefix(r.URL.Path, prefix))
+	return key, err == nil && key != ""
+}
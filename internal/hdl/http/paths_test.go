@@ -0,0 +1,176 @@
+package http
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolvePath(t *testing.T) {
+	hdl := setupTestHandler()
+	tests := []struct {
+		path     string
+		expected string
+		err      error
+	}{
+		{"", "", nil},
+		{"/", "", nil},
+		{"docs/a.txt", "docs/a.txt", nil},
+		{"/docs/a.txt", "docs/a.txt", nil},
+		{"docs/", "docs", nil},
+		{" docs ", "docs", nil},
+		{"./docs//./a.txt", "docs/a.txt", nil},
+		{"docs\\a.txt", "docs/a.txt", nil},
+		{"/test_uploads/docs/a.txt", "docs/a.txt", nil},
+		{"test_uploads/docs", "docs", nil},
+		{"/test_uploads", "", nil},
+		{"..", "", ErrInvalidPath},
+		{"../etc/passwd", "", ErrInvalidPath},
+		{"docs/../../etc/passwd", "", ErrInvalidPath},
+		{"docs/../a.txt", "", ErrInvalidPath},
+		{"..\\..\\etc\\passwd", "", ErrInvalidPath},
+		{"/test_uploads/../etc/passwd", "", ErrInvalidPath},
+		{"//etc/passwd", "", ErrInvalidPath},
+		{"\\\\server\\share", "", ErrInvalidPath},
+		{"C:\\Windows\\win.ini", "", ErrInvalidPath},
+		{"docs/a\x00.txt", "", ErrInvalidPath},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.path, func(t *testing.T) {
+				key, err := hdl.resolvePath(tt.path)
+				assert.ErrorIs(t, err, tt.err)
+				assert.Equal(t, tt.expected, key)
+			},
+		)
+	}
+}
+
+func TestTraversal(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupTestHandler()
+
+	// A secret next to savePath, and links inside savePath leading to it
+	outside := t.TempDir()
+	secret := filepath.Join(outside, "secret.txt")
+	require.NoError(t, os.WriteFile(secret, []byte("secret"), 0644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(testDir, "leak")))
+	require.NoError(t, os.Symlink(secret, filepath.Join(testDir, "leak.txt")))
+	uploadFile(t, hdl, "docs", "a.txt", []byte("a"))
+
+	query := func(p string) string {
+		return url.Values{"path": {p}}.Encode()
+	}
+	upload := func(target string) *http.Request {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		writer.WriteField("path", target)
+		file, _ := writer.CreateFormFile("file", "evil.txt")
+		file.Write([]byte("evil"))
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, createEndpoint, body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req
+	}
+
+	attacks := []string{
+		"../",
+		"../../etc/passwd",
+		"docs/../../etc/passwd",
+		"..\\..\\etc\\passwd",
+		"//etc/passwd",
+		"C:\\Windows\\win.ini",
+		"docs/a\x00.txt",
+	}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		req     func(p string) *http.Request
+	}{
+		{
+			"List", hdl.listFiles, func(p string) *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/list?"+query(p), nil)
+			},
+		},
+		{
+			"Search", hdl.searchFiles, func(p string) *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/search?q=a&"+query(p), nil)
+			},
+		},
+		{"Upload", hdl.createFile, upload},
+		{
+			"Delete", hdl.deleteFile, func(p string) *http.Request {
+				return httptest.NewRequest(http.MethodDelete, "/delete?"+query(p), nil)
+			},
+		},
+		{
+			"Stat", hdl.stat, func(p string) *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/stat?"+query(p), nil)
+			},
+		},
+		{
+			"Stream", hdl.stream, func(p string) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/stream/uploads/", nil)
+				req.URL.Path += p
+				return req
+			},
+		},
+		{
+			"Download", hdl.download, func(p string) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/uploads/", nil)
+				req.URL.Path += p
+				return req
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				for _, p := range attacks {
+					rec := httptest.NewRecorder()
+					tt.handler(rec, tt.req(p))
+					assert.Equal(t, http.StatusBadRequest, rec.Code, p)
+				}
+			},
+		)
+	}
+
+	t.Run(
+		"Symlinks", func(t *testing.T) {
+			for _, tt := range tests {
+				for _, p := range []string{"leak/secret.txt", "leak.txt"} {
+					rec := httptest.NewRecorder()
+					tt.handler(rec, tt.req(p))
+					assert.NotContains(t, []int{http.StatusOK, http.StatusCreated, http.StatusNoContent}, rec.Code, tt.name+" "+p)
+					assert.NotContains(t, rec.Body.String(), "secret.txt", tt.name+" "+p)
+				}
+			}
+
+			rec := httptest.NewRecorder()
+			hdl.createFile(rec, upload("leak"))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.NoFileExists(t, filepath.Join(outside, "evil.txt"))
+
+			files := listByName(t, hdl, "")
+			assert.NotContains(t, files, "leak.txt")
+			assert.Contains(t, files, "a.txt")
+		},
+	)
+
+	data, err := os.ReadFile(secret)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(data))
+	assert.NoFileExists(t, filepath.Join(outside, "..", "evil.txt"))
+}
@@ -0,0 +1,123 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/JMURv/simple-s3/pkg/config"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setupPresignHandler() *Handler {
+	conf := setupAuthConfig()
+	conf.Presign = &config.PresignConfig{Secret: "presign-secret"}
+	return New(port, conf, storage.NewLocal(testDir))
+}
+
+func TestPresign(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupPresignHandler()
+
+	mint := func(t *testing.T, req model.PresignReq) (*model.PresignRes, int) {
+		body, _ := json.Marshal(req)
+		rec := httptest.NewRecorder()
+		hdl.presignURL(rec, httptest.NewRequest(http.MethodPost, "/presign", bytes.NewReader(body)))
+
+		res := &model.PresignRes{}
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(res))
+		}
+		return res, rec.Code
+	}
+
+	upload := func(target, dir string, content []byte) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		if dir != "" {
+			writer.WriteField("path", dir)
+		}
+		file, _ := writer.CreateFormFile("file", "avatar.png")
+		file.Write(content)
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, target, body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+		hdl.presigned(hdl.createFile)(rec, req)
+		return rec
+	}
+
+	t.Run(
+		"Presigned Upload", func(t *testing.T) {
+			res, code := mint(t, model.PresignReq{URL: "/upload?path=avatars", Method: http.MethodPost, MaxLength: 4096})
+			require.Equal(t, http.StatusOK, code)
+
+			rec := upload(res.URL, "elsewhere", []byte("png bytes"))
+			assert.Equal(t, http.StatusCreated, rec.Code)
+
+			_, err := os.Stat(filepath.Join(testDir, "avatars", "avatar.png"))
+			assert.NoError(t, err)
+		},
+	)
+
+	t.Run(
+		"Content Length Limit", func(t *testing.T) {
+			res, code := mint(t, model.PresignReq{URL: "/upload", Method: http.MethodPost, MaxLength: 64})
+			require.Equal(t, http.StatusOK, code)
+
+			rec := upload(res.URL, "", bytes.Repeat([]byte("A"), 1024))
+			assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+		},
+	)
+
+	t.Run(
+		"Presigned Stream", func(t *testing.T) {
+			video := "\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00video"
+			err := os.WriteFile(filepath.Join(testDir, "clip.mp4"), []byte(video), 0644)
+			require.NoError(t, err)
+
+			res, code := mint(t, model.PresignReq{URL: "/stream/uploads/clip.mp4"})
+			require.Equal(t, http.StatusOK, code)
+
+			rec := httptest.NewRecorder()
+			hdl.presigned(hdl.stream)(rec, httptest.NewRequest(http.MethodGet, res.URL, nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, video, rec.Body.String())
+
+			rec = httptest.NewRecorder()
+			hdl.presigned(hdl.stream)(rec, httptest.NewRequest(http.MethodGet, strings.Replace(res.URL, "clip", "other", 1), nil))
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		},
+	)
+
+	t.Run(
+		"Unsigned Request Needs Credentials", func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			hdl.presigned(hdl.stream)(rec, httptest.NewRequest(http.MethodGet, "/stream/uploads/clip.mp4", nil))
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+		},
+	)
+
+	t.Run(
+		"Invalid Targets", func(t *testing.T) {
+			_, code := mint(t, model.PresignReq{URL: "/delete?path=clip.mp4", Method: http.MethodDelete})
+			assert.Equal(t, http.StatusBadRequest, code)
+
+			_, code = mint(t, model.PresignReq{URL: "/upload", Method: http.MethodGet})
+			assert.Equal(t, http.StatusBadRequest, code)
+
+			_, code = mint(t, model.PresignReq{URL: "/uploads/clip.mp4", ExpiresIn: 365 * 24 * 3600})
+			assert.Equal(t, http.StatusBadRequest, code)
+		},
+	)
+}
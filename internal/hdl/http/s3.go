@@ -0,0 +1,447 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/JMURv/simple-s3/pkg/sigv4"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/JMURv/simple-s3/pkg/utils/s3"
+	"hash"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const s3MaxKeys = 1000
+
+// s3API serves the S3 wire protocol using path-style addressing:
+// "/" lists buckets, "/{bucket}" addresses the bucket and
+// "/{bucket}/{key}" addresses an object inside it.
+func (h *Handler) s3API(w http.ResponseWriter, r *http.Request) {
+	if h.s3 == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket == "" {
+		if r.Method != http.MethodGet {
+			s3.ErrResponse(w, r, s3.ErrMethodNotAllowed)
+			return
+		}
+		h.listBuckets(w, r)
+		return
+	}
+
+	if bucket != h.s3.Bucket {
+		s3.ErrResponse(w, r, s3.ErrNoSuchBucket)
+		return
+	}
+
+	if key == "" {
+		switch r.Method {
+		case http.MethodHead:
+			w.Header().Set("x-amz-bucket-region", h.s3.Region)
+			w.WriteHeader(http.StatusOK)
+		case http.MethodGet:
+			if r.URL.Query().Has("location") {
+				s3.XMLResponse(w, http.StatusOK, &s3.LocationConstraint{Location: h.s3.Region})
+				return
+			}
+			h.listObjectsV2(w, r)
+		default:
+			s3.ErrResponse(w, r, s3.ErrMethodNotAllowed)
+		}
+		return
+	}
+
+	q := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		h.createMultipartUpload(w, r, key)
+		return
+	case q.Has("uploadId"):
+		h.s3MultipartAPI(w, r, key, q.Get("uploadId"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		h.getObject(w, r, key)
+	case http.MethodPut:
+		h.putObject(w, r, key)
+	case http.MethodDelete:
+		h.deleteObject(w, r, key)
+	default:
+		s3.ErrResponse(w, r, s3.ErrMethodNotAllowed)
+	}
+}
+
+// validKey reports whether key can be stored. A trailing slash is allowed
+// for folder placeholders.
+func validKey(key string) bool {
+	return storage.ValidKey(strings.TrimSuffix(key, "/"))
+}
+
+func (h *Handler) listBuckets(w http.ResponseWriter, r *http.Request) {
+	created := time.Unix(0, 0)
+	s3.XMLResponse(
+		w, http.StatusOK, &s3.ListAllMyBucketsResult{
+			Owner: s3.Owner{ID: "simple-s3", DisplayName: "simple-s3"},
+			Buckets: []s3.Bucket{
+				{Name: h.s3.Bucket, CreationDate: s3.FormatTime(created)},
+			},
+		},
+	)
+}
+
+func (h *Handler) listObjectsV2(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	prefix := q.Get("prefix")
+	delimiter := q.Get("delimiter")
+	startAfter := q.Get("start-after")
+	encodeURL := q.Get("encoding-type") == "url"
+
+	maxKeys := s3MaxKeys
+	if v := q.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			s3.ErrResponse(w, r, s3.ErrInvalidArgument)
+			return
+		}
+		maxKeys = min(n, s3MaxKeys)
+	}
+
+	marker := startAfter
+	token := q.Get("continuation-token")
+	if token != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			s3.ErrResponse(w, r, s3.ErrInvalidArgument)
+			return
+		}
+		marker = string(decoded)
+	}
+
+	encode := func(s string) string {
+		if encodeURL {
+			return strings.ReplaceAll(url.QueryEscape(s), "%2F", "/")
+		}
+		return s
+	}
+
+	res := &s3.ListBucketResult{
+		Name:              h.s3.Bucket,
+		Prefix:            encode(prefix),
+		Delimiter:         encode(delimiter),
+		MaxKeys:           maxKeys,
+		ContinuationToken: token,
+		StartAfter:        encode(startAfter),
+		Contents:          []s3.Object{},
+		CommonPrefixes:    []s3.CommonPrefix{},
+	}
+	if encodeURL {
+		res.EncodingType = "url"
+	}
+
+	// Pages are read from the backend in batches starting after the marker,
+	// skipping over every key rolled up into a common prefix
+	cursor := marker
+	if marker != "" && delimiter != "" && strings.HasSuffix(marker, delimiter) {
+		cursor = skipPrefix(marker)
+	}
+
+	last := ""
+	for {
+		objects, next, err := h.storage.List(r.Context(), prefix, cursor, maxKeys+1)
+		if errors.Is(err, storage.ErrNotFound) {
+			objects, next, err = nil, "", nil
+		}
+		if errors.Is(err, storage.ErrInvalidKey) {
+			s3.ErrResponse(w, r, s3.ErrInvalidArgument)
+			return
+		} else if err != nil {
+			log.Println("Error listing objects: ", err)
+			s3.ErrResponse(w, r, s3.ErrInternalError)
+			return
+		}
+
+		for _, obj := range objects {
+			entry := obj.Key
+			if delimiter != "" {
+				if i := strings.Index(obj.Key[len(prefix):], delimiter); i >= 0 {
+					entry = obj.Key[:len(prefix)+i+len(delimiter)]
+				}
+			}
+			if entry == last {
+				continue
+			}
+
+			if res.KeyCount == maxKeys {
+				res.IsTruncated = true
+				res.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(last))
+				s3.XMLResponse(w, http.StatusOK, res)
+				return
+			}
+
+			last = entry
+			res.KeyCount++
+			if entry != obj.Key {
+				res.CommonPrefixes = append(res.CommonPrefixes, s3.CommonPrefix{Prefix: encode(entry)})
+				continue
+			}
+
+			etag, err := h.objectETag(r.Context(), &obj)
+			if err != nil {
+				log.Println("Error computing etag: ", err)
+				s3.ErrResponse(w, r, s3.ErrInternalError)
+				return
+			}
+			res.Contents = append(
+				res.Contents, s3.Object{
+					Key:          encode(obj.Key),
+					LastModified: s3.FormatTime(obj.ModTime),
+					ETag:         etag,
+					Size:         obj.Size,
+					StorageClass: "STANDARD",
+				},
+			)
+		}
+
+		if next == "" {
+			break
+		}
+		cursor = next
+		if delimiter != "" && strings.HasSuffix(last, delimiter) && strings.HasPrefix(cursor, last) {
+			cursor = skipPrefix(last)
+		}
+	}
+
+	s3.XMLResponse(w, http.StatusOK, res)
+}
+
+// skipPrefix returns a cursor that sorts after every key starting with
+// prefix. Keys are UTF-8, so none has a byte as high as 0xff.
+func skipPrefix(prefix string) string {
+	return prefix + "\xff"
+}
+
+func (h *Handler) getObject(w http.ResponseWriter, r *http.Request, key string) {
+	if !storage.ValidKey(key) {
+		s3.ErrResponse(w, r, s3.ErrInvalidObjectName)
+		return
+	}
+
+	info, err := h.storage.Stat(r.Context(), key)
+	if err != nil {
+		s3.ErrResponse(w, r, s3.ErrNoSuchKey)
+		return
+	}
+
+	etag, err := h.objectETag(r.Context(), info)
+	if err != nil {
+		log.Println("Error computing etag: ", err)
+		s3.ErrResponse(w, r, s3.ErrInternalError)
+		return
+	}
+
+	content := storage.NewReadSeeker(r.Context(), h.storage, info)
+	defer content.Close()
+
+	w.Header().Set("ETag", etag)
+	ct := info.ContentType
+	if ct == "" {
+		ct = contentType(key)
+	}
+	w.Header().Set("Content-Type", ct)
+	w.Header().Set("x-amz-request-id", s3.RequestID())
+	http.ServeContent(w, r, "", info.ModTime, content)
+}
+
+func (h *Handler) putObject(w http.ResponseWriter, r *http.Request, key string) {
+	if r.Header.Get("x-amz-copy-source") != "" {
+		s3.ErrResponse(w, r, s3.ErrNotImplemented)
+		return
+	}
+
+	if !validKey(key) {
+		s3.ErrResponse(w, r, s3.ErrInvalidObjectName)
+		return
+	}
+
+	// Directories are implicit, so folder placeholders created by S3
+	// consoles have nothing to store
+	if strings.HasSuffix(key, "/") {
+		w.Header().Set("ETag", `"`+hex.EncodeToString(md5.New().Sum(nil))+`"`)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	payload, s3err := newS3Payload(r, h.config.MaxUploadSize)
+	if s3err != nil {
+		s3.ErrResponse(w, r, s3err)
+		return
+	}
+
+	tags, err := parseTags(r.Header.Get("x-amz-tagging"))
+	if err != nil {
+		s3.ErrResponse(w, r, s3.ErrInvalidTag)
+		return
+	}
+
+	// S3 overwrites, so anything derived from the replaced content goes
+	_, err = h.storage.Stat(r.Context(), key)
+	overwrite := err == nil
+
+	info, err := h.storage.Put(storage.WithTags(r.Context(), tags), key, payload)
+	if err != nil {
+		s3.ErrResponse(w, r, payloadError(err))
+		return
+	}
+	if overwrite {
+		h.dropVariants(key)
+	}
+	// S3 clients get no file details, but computing them caches them for /stat
+	h.uploadRes(r.Context(), info)
+
+	log.Printf("Object %s stored successfully\n", key)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(payload.hash.Sum(nil))+`"`)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) deleteObject(w http.ResponseWriter, r *http.Request, key string) {
+	if !validKey(key) {
+		s3.ErrResponse(w, r, s3.ErrInvalidObjectName)
+		return
+	}
+
+	// S3 answers 204 whether or not the key existed
+	err := h.storage.Delete(r.Context(), key)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		log.Println("Error deleting object: ", err)
+		s3.ErrResponse(w, r, s3.ErrInternalError)
+		return
+	}
+	h.dropVariants(key)
+
+	log.Printf("Object %s deleted successfully\n", key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// objectETag returns the recorded ETag of an object, hashing its contents
+// when the backend doesn't keep metadata
+func (h *Handler) objectETag(ctx context.Context, info *storage.ObjectInfo) (string, error) {
+	if info.ETag != "" {
+		return info.ETag, nil
+	}
+
+	rc, _, err := h.storage.Get(ctx, info.Key, 0, -1)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	hash := md5.New()
+	if _, err = io.Copy(hash, rc); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`, nil
+}
+
+func contentType(name string) string {
+	if ct := mime.TypeByExtension(filepath.Ext(name)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// newS3Payload prepares the body of r for storing, decoding aws-chunked
+// framing and checking Content-MD5 and the size limit on the way
+func newS3Payload(r *http.Request, max int64) (*s3Payload, *s3.Error) {
+	payload := &s3Payload{
+		hash:     md5.New(),
+		max:      max,
+		expected: r.ContentLength,
+		r:        r.Body,
+	}
+	if s3.IsChunked(r) {
+		payload.expected = -1
+		if v := r.Header.Get("x-amz-decoded-content-length"); v != "" {
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				payload.expected = n
+			}
+		}
+		payload.r = s3.NewChunkedReader(r.Body, chunkVerifier(r))
+	}
+
+	if payload.expected > payload.max {
+		return nil, s3.ErrEntityTooLarge
+	}
+
+	if v := r.Header.Get("Content-MD5"); v != "" {
+		sum, err := base64.StdEncoding.DecodeString(v)
+		if err != nil || len(sum) != md5.Size {
+			return nil, s3.ErrInvalidDigest
+		}
+		payload.md5 = sum
+	}
+	return payload, nil
+}
+
+// payloadError maps a failure while consuming an s3Payload to an S3 error
+func payloadError(err error) *s3.Error {
+	var s3err *s3.Error
+	switch {
+	case errors.As(err, &s3err):
+		return s3err
+	case errors.Is(err, sigv4.ErrSignatureMismatch), errors.Is(err, s3.ErrMissingChunkSignature):
+		return s3.ErrSignatureDoesNotMatch
+	case errors.Is(err, sigv4.ErrContentSHA256Mismatch):
+		return s3.ErrContentSHA256Mismatch
+	case errors.Is(err, storage.ErrInvalidKey):
+		return s3.ErrInvalidObjectName
+	default:
+		log.Println("Error reading payload: ", err)
+		return s3.ErrIncompleteBody
+	}
+}
+
+// s3Payload validates a PutObject body while it is being stored. Failures
+// surface as read errors, so the storage backend never commits the object.
+type s3Payload struct {
+	r        io.Reader
+	hash     hash.Hash
+	md5      []byte
+	n        int64
+	max      int64
+	expected int64
+}
+
+func (p *s3Payload) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	p.hash.Write(b[:n])
+
+	if p.n > p.max {
+		return n, s3.ErrEntityTooLarge
+	}
+	if err == io.EOF {
+		if p.expected >= 0 && p.n != p.expected {
+			return n, s3.ErrIncompleteBody
+		}
+		if p.md5 != nil && !bytes.Equal(p.md5, p.hash.Sum(nil)) {
+			return n, s3.ErrBadDigest
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,278 @@
+package http
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"github.com/JMURv/simple-s3/pkg/imgproc"
+	"github.com/JMURv/simple-s3/pkg/utils/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func s3Request(h *Handler, method, target string, body io.Reader) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	rec := httptest.NewRecorder()
+	h.s3API(rec, req)
+	return rec
+}
+
+func TestS3Objects(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupTestHandler()
+
+	content := []byte("hello from s3")
+	sum := md5.Sum(content)
+	etag := fmt.Sprintf(`"%x"`, sum)
+
+	t.Run(
+		"Put Object", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/"+testBucket+"/docs/hello.txt", bytes.NewReader(content))
+			req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+			rec := httptest.NewRecorder()
+			hdl.s3API(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, etag, rec.Header().Get("ETag"))
+
+			data, err := os.ReadFile(filepath.Join(testDir, "docs", "hello.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, content, data)
+		},
+	)
+
+	t.Run(
+		"Get Object", func(t *testing.T) {
+			rec := s3Request(hdl, http.MethodGet, "/"+testBucket+"/docs/hello.txt", nil)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, etag, rec.Header().Get("ETag"))
+			assert.Equal(t, content, rec.Body.Bytes())
+		},
+	)
+
+	t.Run(
+		"Get Object Range", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/"+testBucket+"/docs/hello.txt", nil)
+			req.Header.Set("Range", "bytes=0-4")
+			rec := httptest.NewRecorder()
+			hdl.s3API(rec, req)
+
+			assert.Equal(t, http.StatusPartialContent, rec.Code)
+			assert.Equal(t, "hello", rec.Body.String())
+		},
+	)
+
+	t.Run(
+		"Head Object", func(t *testing.T) {
+			rec := s3Request(hdl, http.MethodHead, "/"+testBucket+"/docs/hello.txt", nil)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, etag, rec.Header().Get("ETag"))
+			assert.Equal(t, fmt.Sprint(len(content)), rec.Header().Get("Content-Length"))
+			assert.Empty(t, rec.Body.Bytes())
+		},
+	)
+
+	t.Run(
+		"Bad Digest", func(t *testing.T) {
+			other := md5.Sum([]byte("something else"))
+			req := httptest.NewRequest(http.MethodPut, "/"+testBucket+"/bad.txt", bytes.NewReader(content))
+			req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(other[:]))
+			rec := httptest.NewRecorder()
+			hdl.s3API(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), "<Code>BadDigest</Code>")
+
+			_, err := os.Stat(filepath.Join(testDir, "bad.txt"))
+			assert.True(t, os.IsNotExist(err))
+		},
+	)
+
+	t.Run(
+		"Chunked Put", func(t *testing.T) {
+			body := "5;chunk-signature=abc\r\nhello\r\n6;chunk-signature=def\r\n world\r\n0;chunk-signature=ghi\r\n\r\n"
+			req := httptest.NewRequest(http.MethodPut, "/"+testBucket+"/chunked.txt", strings.NewReader(body))
+			req.Header.Set("x-amz-content-sha256", "STREAMING-AWS4-HMAC-SHA256-PAYLOAD")
+			req.Header.Set("x-amz-decoded-content-length", "11")
+			rec := httptest.NewRecorder()
+			hdl.s3API(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			data, err := os.ReadFile(filepath.Join(testDir, "chunked.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, "hello world", string(data))
+		},
+	)
+
+	t.Run(
+		"Invalid Key", func(t *testing.T) {
+			rec := s3Request(hdl, http.MethodPut, "/"+testBucket+"/a/../../escape.txt", strings.NewReader("x"))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), "<Code>InvalidObjectName</Code>")
+		},
+	)
+
+	t.Run(
+		"No Such Bucket", func(t *testing.T) {
+			rec := s3Request(hdl, http.MethodGet, "/other-bucket/docs/hello.txt", nil)
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+			assert.Contains(t, rec.Body.String(), "<Code>NoSuchBucket</Code>")
+		},
+	)
+
+	t.Run(
+		"Delete Object", func(t *testing.T) {
+			rec := s3Request(hdl, http.MethodDelete, "/"+testBucket+"/docs/hello.txt", nil)
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+
+			rec = s3Request(hdl, http.MethodGet, "/"+testBucket+"/docs/hello.txt", nil)
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+			assert.Contains(t, rec.Body.String(), "<Code>NoSuchKey</Code>")
+
+			rec = s3Request(hdl, http.MethodDelete, "/"+testBucket+"/docs/hello.txt", nil)
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+		},
+	)
+
+	t.Run(
+		"Overwrite Drops Variants", func(t *testing.T) {
+			rec := s3Request(hdl, http.MethodPut, "/"+testBucket+"/gallery/photo.png", bytes.NewReader(encodeTestImage(t, 40, 20)))
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			rec = httptest.NewRecorder()
+			hdl.image(rec, httptest.NewRequest(http.MethodGet, "/img/gallery/photo.png?w=10", nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			opts, err := imgproc.ParseOptions(url.Values{"w": {"10"}, "format": {"png"}}, hdl.imageLimits)
+			require.NoError(t, err)
+			file, _, err := hdl.images.Open("gallery/photo.png", opts, time.Time{})
+			require.NoError(t, err)
+			file.Close()
+
+			rec = s3Request(hdl, http.MethodPut, "/"+testBucket+"/gallery/photo.png", bytes.NewReader(encodeTestImage(t, 20, 40)))
+			require.Equal(t, http.StatusOK, rec.Code)
+			_, _, err = hdl.images.Open("gallery/photo.png", opts, time.Time{})
+			assert.ErrorIs(t, err, imgproc.ErrCacheMiss)
+		},
+	)
+}
+
+func TestS3ListObjectsV2(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupTestHandler()
+
+	for _, key := range []string{"a.txt", "dir/b.txt", "dir/c.txt", "dir/sub/d.txt", "e.txt"} {
+		p := filepath.Join(testDir, filepath.FromSlash(key))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), os.ModePerm))
+		require.NoError(t, os.WriteFile(p, []byte(key), 0644))
+	}
+
+	list := func(t *testing.T, query string) *s3.ListBucketResult {
+		rec := s3Request(hdl, http.MethodGet, "/"+testBucket+"?list-type=2&"+query, nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		res := &s3.ListBucketResult{}
+		require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), res))
+		return res
+	}
+
+	keys := func(res *s3.ListBucketResult) []string {
+		out := make([]string, 0, len(res.Contents)+len(res.CommonPrefixes))
+		for _, obj := range res.Contents {
+			out = append(out, obj.Key)
+		}
+		for _, p := range res.CommonPrefixes {
+			out = append(out, p.Prefix)
+		}
+		return out
+	}
+
+	t.Run(
+		"All Keys", func(t *testing.T) {
+			res := list(t, "")
+			assert.Equal(t, []string{"a.txt", "dir/b.txt", "dir/c.txt", "dir/sub/d.txt", "e.txt"}, keys(res))
+			assert.False(t, res.IsTruncated)
+		},
+	)
+
+	t.Run(
+		"Delimiter", func(t *testing.T) {
+			res := list(t, "delimiter=/")
+			assert.Equal(t, []string{"a.txt", "e.txt", "dir/"}, keys(res))
+
+			res = list(t, "delimiter=/&prefix=dir/")
+			assert.Equal(t, []string{"dir/b.txt", "dir/c.txt", "dir/sub/"}, keys(res))
+		},
+	)
+
+	t.Run(
+		"Prefix", func(t *testing.T) {
+			res := list(t, "prefix=dir/s")
+			assert.Equal(t, []string{"dir/sub/d.txt"}, keys(res))
+
+			res = list(t, "prefix=missing/")
+			assert.Empty(t, keys(res))
+		},
+	)
+
+	t.Run(
+		"Continuation", func(t *testing.T) {
+			res := list(t, "delimiter=/&max-keys=2")
+			assert.Equal(t, []string{"a.txt", "dir/"}, keys(res))
+			assert.True(t, res.IsTruncated)
+			require.NotEmpty(t, res.NextContinuationToken)
+
+			res = list(t, "delimiter=/&max-keys=2&continuation-token="+res.NextContinuationToken)
+			assert.Equal(t, []string{"e.txt"}, keys(res))
+			assert.False(t, res.IsTruncated)
+		},
+	)
+
+	t.Run(
+		"Page By Page", func(t *testing.T) {
+			for _, query := range []string{"delimiter=/", "", "prefix=dir/&delimiter=/"} {
+				all := list(t, query)
+				listed := make([]string, 0)
+				token := ""
+				for {
+					res := list(t, query+"&max-keys=1&continuation-token="+token)
+					require.LessOrEqual(t, res.KeyCount, 1)
+					listed = append(listed, keys(res)...)
+					if !res.IsTruncated {
+						break
+					}
+					token = res.NextContinuationToken
+				}
+				assert.ElementsMatch(t, keys(all), listed, query)
+			}
+		},
+	)
+
+	t.Run(
+		"Invalid Max Keys", func(t *testing.T) {
+			rec := s3Request(hdl, http.MethodGet, "/"+testBucket+"?list-type=2&max-keys=abc", nil)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		},
+	)
+
+	t.Run(
+		"Invalid Prefix", func(t *testing.T) {
+			rec := s3Request(hdl, http.MethodGet, "/"+testBucket+"?list-type=2&prefix=../", nil)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), "<Code>InvalidArgument</Code>")
+		},
+	)
+}
@@ -0,0 +1,101 @@
+package http
+
+import (
+	"context"
+	"github.com/JMURv/simple-s3/pkg/imgproc"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	utils "github.com/JMURv/simple-s3/pkg/utils/http"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// stat describes a single file
+// @Summary Describe a file
+// @Description Returns the size, checksums and content type of a file. Images also report their dimensions, orientation, camera, capture date, whether they carry a GPS location and their placeholder. Videos report their container, duration, resolution, codecs and, for MP4, whether they are faststart. Audio files report their tags, duration and cover art URL. All of these are computed on the first request if the upload did not.
+// @Param path query string true "File path"
+// @Success 200 {object} model.StatRes
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Router /stat [get]
+func (h *Handler) stat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+
+	path := r.URL.Query().Get("path")
+	if strings.Trim(path, " /\\") == "" {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrPathNotProvided)
+		return
+	}
+	key, ok := h.objectKey(path)
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	info, err := h.storage.Stat(r.Context(), key)
+	if err != nil {
+		utils.ErrResponse(w, http.StatusNotFound, ErrRetrievingFile)
+		return
+	}
+
+	res := model.StatRes{FileRes: h.fileRes(info)}
+	res.Image = h.imageMeta(r.Context(), info)
+	if res.Image != nil && res.Placeholder == nil {
+		res.Placeholder = h.placeholder(r.Context(), info)
+	}
+	if res.Video == nil {
+		res.Video = h.videoMeta(r.Context(), info)
+	}
+	if res.Audio == nil {
+		res.Audio = h.audioMeta(r.Context(), info)
+	}
+	utils.SuccessDataResponse(w, http.StatusOK, &res)
+}
+
+// imageMeta reads the dimensions and EXIF details of an image, returning
+// nil for anything else
+func (h *Handler) imageMeta(ctx context.Context, info *storage.ObjectInfo) *model.ImageMetaRes {
+	if _, err := h.imageFormat(ctx, info); err != nil {
+		return nil
+	}
+
+	file, _, err := h.storage.Get(ctx, info.Key, 0, min(info.Size, imgproc.MetadataScanLen))
+	if err != nil {
+		log.Println("Error opening image: ", err)
+		return nil
+	}
+	defer file.Close()
+
+	head, err := io.ReadAll(file)
+	if err != nil {
+		log.Println("Error reading image: ", err)
+		return nil
+	}
+
+	meta, err := imgproc.ReadMetadata(head)
+	if err != nil {
+		return nil
+	}
+
+	res := &model.ImageMetaRes{
+		Format:      meta.Format,
+		Width:       meta.Width,
+		Height:      meta.Height,
+		Orientation: meta.Orientation,
+		Make:        meta.Make,
+		Model:       meta.Model,
+		LensModel:   meta.LensModel,
+		Software:    meta.Software,
+		HasLocation: meta.HasLocation,
+	}
+	if !meta.Taken.IsZero() {
+		res.TakenAt = meta.Taken.Format(time.RFC3339)
+	}
+	return res
+}
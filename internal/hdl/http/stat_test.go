@@ -0,0 +1,230 @@
+package http
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/JMURv/simple-s3/pkg/config"
+	"github.com/JMURv/simple-s3/pkg/imgproc"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testPhoto encodes a 40x20 JPEG carrying the EXIF block of a phone photo:
+// camera, capture date, orientation 6 and a GPS position
+func testPhoto(t *testing.T) []byte {
+	be := binary.BigEndian
+	entry := func(buf []byte, tag, typ uint16, count, value uint32) []byte {
+		buf = be.AppendUint16(buf, tag)
+		buf = be.AppendUint16(buf, typ)
+		buf = be.AppendUint32(buf, count)
+		return be.AppendUint32(buf, value)
+	}
+
+	pad := func(buf []byte, n int) []byte {
+		return append(buf, make([]byte, n-len(buf))...)
+	}
+
+	// IFD0 at 8 with its strings at 74, the Exif IFD at 96 with the date at
+	// 114, and the GPS IFD at 134 with the latitude rationals at 152
+	tiff := []byte("MM\x00\x2A\x00\x00\x00\x08\x00\x05")
+	tiff = entry(tiff, 0x010F, 2, 5, 74)
+	tiff = entry(tiff, 0x0110, 2, 8, 80)
+	tiff = entry(tiff, 0x0112, 3, 1, 6<<16)
+	tiff = entry(tiff, 0x8769, 4, 1, 96)
+	tiff = entry(tiff, 0x8825, 4, 1, 134)
+	tiff = append(pad(tiff, 74), "Acme\x00"...)
+	tiff = append(pad(tiff, 80), "Phone X\x00"...)
+	tiff = append(pad(tiff, 96), 0x00, 0x01)
+	tiff = entry(tiff, 0x9003, 2, 20, 114)
+	tiff = append(pad(tiff, 114), "2023:06:15 14:30:00\x00"...)
+	tiff = append(tiff, 0x00, 0x01)
+	tiff = entry(tiff, 0x0002, 5, 3, 152)
+	tiff = pad(tiff, 152)
+	for _, v := range []uint32{52, 1, 30, 1, 0, 1} {
+		tiff = be.AppendUint32(tiff, v)
+	}
+
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	segment := be.AppendUint16([]byte{0xFF, 0xE1}, uint16(len(app1)+2))
+
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 40, 20)), nil))
+	out := append([]byte{0xFF, 0xD8}, segment...)
+	out = append(out, app1...)
+	return append(out, buf.Bytes()[2:]...)
+}
+
+func statFile(t *testing.T, hdl *Handler, path string) model.StatRes {
+	rec := httptest.NewRecorder()
+	hdl.stat(rec, httptest.NewRequest(http.MethodGet, "/stat?path="+path, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	res := model.StatRes{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	return res
+}
+
+func TestStat(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupTestHandler()
+
+	uploadFile(t, hdl, "", "photo.jpg", testPhoto(t))
+	uploadFile(t, hdl, "", "notes.txt", []byte("not an image"))
+
+	t.Run(
+		"Photo", func(t *testing.T) {
+			res := statFile(t, hdl, "/test_uploads/photo.jpg")
+			assert.Equal(t, "/test_uploads/photo.jpg", res.Path)
+			require.NotNil(t, res.Image)
+			assert.Equal(t, "jpeg", res.Image.Format)
+			assert.Equal(t, 20, res.Image.Width)
+			assert.Equal(t, 40, res.Image.Height)
+			assert.Equal(t, 6, res.Image.Orientation)
+			assert.Equal(t, "Acme", res.Image.Make)
+			assert.Equal(t, "Phone X", res.Image.Model)
+			assert.Contains(t, res.Image.TakenAt, "2023-06-15T14:30:00")
+			assert.True(t, res.Image.HasLocation)
+		},
+	)
+
+	t.Run(
+		"Not An Image", func(t *testing.T) {
+			res := statFile(t, hdl, "notes.txt")
+			assert.Equal(t, int64(len("not an image")), res.Size)
+			assert.Nil(t, res.Image)
+		},
+	)
+
+	t.Run(
+		"Errors", func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			hdl.stat(rec, httptest.NewRequest(http.MethodGet, "/stat?path=missing.jpg", nil))
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+
+			rec = httptest.NewRecorder()
+			hdl.stat(rec, httptest.NewRequest(http.MethodGet, "/stat", nil))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		},
+	)
+}
+
+func TestUploadSanitizing(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+
+	conf := setupTestConfig()
+	conf.Images.Uploads = []config.ImageUploadPolicy{
+		{Prefix: "", AutoOrient: true},
+		{Prefix: "/avatars/", StripMetadata: true, AutoOrient: true},
+		{Prefix: "avatars/raw", StripMetadata: false},
+	}
+	hdl := New(port, conf, setupTestHandler().storage)
+	photo := testPhoto(t)
+
+	upload := func(dir string, fields map[string]string, content []byte) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		writer.WriteField("path", dir)
+		for k, v := range fields {
+			writer.WriteField(k, v)
+		}
+		file, _ := writer.CreateFormFile("file", "photo.jpg")
+		file.Write(content)
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, createEndpoint, body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+		hdl.createFile(rec, req)
+		return rec
+	}
+
+	t.Run(
+		"Prefix Default", func(t *testing.T) {
+			require.Equal(t, http.StatusCreated, upload("avatars", nil, photo).Code)
+
+			res := statFile(t, hdl, "avatars/photo.jpg")
+			require.NotNil(t, res.Image)
+			assert.Equal(t, 1, res.Image.Orientation)
+			assert.Equal(t, 20, res.Image.Width)
+			assert.Empty(t, res.Image.Make)
+			assert.False(t, res.Image.HasLocation)
+		},
+	)
+
+	t.Run(
+		"Global Default", func(t *testing.T) {
+			require.Equal(t, http.StatusCreated, upload("gallery", nil, photo).Code)
+
+			res := statFile(t, hdl, "gallery/photo.jpg")
+			require.NotNil(t, res.Image)
+			assert.Equal(t, 1, res.Image.Orientation)
+			assert.Equal(t, "Acme", res.Image.Make)
+			assert.True(t, res.Image.HasLocation)
+		},
+	)
+
+	t.Run(
+		"Longest Prefix", func(t *testing.T) {
+			require.Equal(t, http.StatusCreated, upload("avatars/raw", nil, photo).Code)
+
+			res := statFile(t, hdl, "avatars/raw/photo.jpg")
+			require.NotNil(t, res.Image)
+			assert.Equal(t, 6, res.Image.Orientation)
+			assert.True(t, res.Image.HasLocation)
+		},
+	)
+
+	t.Run(
+		"Form Override", func(t *testing.T) {
+			require.Equal(t, http.StatusCreated, upload("private", map[string]string{"stripMetadata": "true", "autoOrient": "false"}, photo).Code)
+
+			res := statFile(t, hdl, "private/photo.jpg")
+			require.NotNil(t, res.Image)
+			assert.Equal(t, 6, res.Image.Orientation)
+			assert.Empty(t, res.Image.Make)
+			assert.False(t, res.Image.HasLocation)
+
+			stored, err := os.ReadFile(filepath.Join(testDir, "private", "photo.jpg"))
+			require.NoError(t, err)
+			assert.Less(t, len(stored), len(photo))
+		},
+	)
+
+	t.Run(
+		"Invalid Option", func(t *testing.T) {
+			assert.Equal(t, http.StatusBadRequest, upload("other", map[string]string{"stripMetadata": "maybe"}, photo).Code)
+		},
+	)
+
+	t.Run(
+		"Malformed Image", func(t *testing.T) {
+			assert.Equal(t, http.StatusUnprocessableEntity, upload("avatars/broken", nil, photo[:64]).Code)
+			_, err := os.Stat(filepath.Join(testDir, "avatars", "broken"))
+			assert.True(t, os.IsNotExist(err))
+		},
+	)
+
+	t.Run(
+		"Too Many Pixels", func(t *testing.T) {
+			hdl.imageLimits.MaxPixels = 10
+			assert.Equal(t, http.StatusUnprocessableEntity, upload("huge", nil, photo).Code)
+			assert.NoDirExists(t, filepath.Join(testDir, "huge"))
+
+			hdl.config.MaxUploadSize = 64
+			_, err := hdl.sanitizeUpload(bytes.NewReader(photo), imgproc.SanitizeOptions{StripMetadata: true})
+			assert.ErrorIs(t, err, ErrInvalidImage)
+		},
+	)
+}
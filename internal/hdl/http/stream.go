@@ -0,0 +1,179 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	utils "github.com/JMURv/simple-s3/pkg/utils/http"
+	"github.com/JMURv/simple-s3/pkg/utils/mediatype"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+)
+
+// stream streams a media file based on the given path
+// @Summary Stream a media file
+// @Description Streams a media file for the given path. The media type is detected from the file contents and has to match http.streamableTypes. Single and multiple byte ranges are served as 206 Partial Content, and If-None-Match, If-Modified-Since and If-Range are honoured.
+// @Param path path string true "File path"
+// @Param Range header string false "Byte ranges, e.g. bytes=0-1023"
+// @Produce  media/*
+// @Success 200
+// @Success 206
+// @Success 304
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 412 {object} utils.ErrorResponse
+// @Failure 415 {object} utils.ErrorResponse
+// @Failure 416 {object} utils.ErrorResponse
+// @Router /stream/uploads/{path} [get]
+func (h *Handler) stream(w http.ResponseWriter, r *http.Request) {
+	name, ok := urlKey(r, "/stream/uploads/")
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	info, err := h.storage.Stat(r.Context(), name)
+	if err != nil {
+		utils.ErrResponse(w, http.StatusNotFound, ErrRetrievingFile)
+		return
+	}
+
+	contentType, err := h.sniff(r.Context(), name, info.Size)
+	if err != nil {
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrRetrievingFile)
+		return
+	}
+	if !mediatype.Allowed(contentType, h.streamable) {
+		utils.ErrResponse(w, http.StatusUnsupportedMediaType, ErrUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+
+	etag := info.ETag
+	if etag == "" {
+		etag = weakETag(info)
+	}
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+
+	if status := utils.CheckPreconditions(r, etag, info.ModTime); status != 0 {
+		if status == http.StatusNotModified {
+			w.Header().Del("Content-Type")
+			w.WriteHeader(status)
+			return
+		}
+		utils.ErrResponse(w, status, ErrPrecondition)
+		return
+	}
+
+	var ranges []utils.ByteRange
+	if r.Header.Get("Range") != "" && utils.RangeApplies(r, etag, info.ModTime) {
+		ranges, err = utils.ParseRange(r.Header.Get("Range"), info.Size)
+		if err != nil {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+			utils.ErrResponse(w, http.StatusRequestedRangeNotSatisfiable, err)
+			return
+		}
+	}
+
+	log.Println("Streaming mediafile: ", name)
+	switch len(ranges) {
+	case 0:
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+		w.WriteHeader(http.StatusOK)
+		if r.Method != http.MethodHead {
+			h.streamRange(w, w, r, name, utils.ByteRange{Start: 0, Length: info.Size})
+		}
+	case 1:
+		w.Header().Set("Content-Range", ranges[0].ContentRange(info.Size))
+		w.Header().Set("Content-Length", strconv.FormatInt(ranges[0].Length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		if r.Method != http.MethodHead {
+			h.streamRange(w, w, r, name, ranges[0])
+		}
+	default:
+		mw := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+		w.WriteHeader(http.StatusPartialContent)
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		for _, rng := range ranges {
+			part, err := mw.CreatePart(
+				textproto.MIMEHeader{
+					"Content-Type":  {contentType},
+					"Content-Range": {rng.ContentRange(info.Size)},
+				},
+			)
+			if err != nil || !h.streamRange(w, part, r, name, rng) {
+				return
+			}
+		}
+		mw.Close()
+	}
+}
+
+// streamRange copies a range of a file to dst in chunks of MaxStreamBuffer,
+// flushing w after every chunk. It reports whether the whole range was sent.
+func (h *Handler) streamRange(w http.ResponseWriter, dst io.Writer, r *http.Request, name string, rng utils.ByteRange) bool {
+	if rng.Length == 0 {
+		return true
+	}
+
+	file, _, err := h.storage.Get(r.Context(), name, rng.Start, rng.Length)
+	if err != nil {
+		log.Println("Error opening file: ", err)
+		return false
+	}
+	defer file.Close()
+
+	flusher, _ := w.(http.Flusher)
+
+	buffer := make([]byte, max(h.config.MaxStreamBuffer, 1))
+	for {
+		n, err := file.Read(buffer)
+		if n > 0 {
+			if _, err := dst.Write(buffer[:n]); err != nil {
+				log.Println("Error writing chunk:", err)
+				return false
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err == io.EOF {
+			return true
+		}
+		if err != nil {
+			log.Println("Error reading chunk:", err)
+			return false
+		}
+	}
+}
+
+// sniff detects the media type of a file from its leading bytes, so a file
+// is never streamed on the strength of its extension alone
+func (h *Handler) sniff(ctx context.Context, name string, size int64) (string, error) {
+	file, _, err := h.storage.Get(ctx, name, 0, min(size, mediatype.SniffLen))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	head, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	return mediatype.Detect(head), nil
+}
+
+// weakETag identifies a version of a file without reading it, for backends
+// that don't record content hashes
+func weakETag(info *storage.ObjectInfo) string {
+	return fmt.Sprintf(`W/"%x-%x"`, info.ModTime.UnixNano(), info.Size)
+}
@@ -0,0 +1,151 @@
+package http
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamRanges(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupTestHandler()
+
+	// A bare ftyp box, just enough to be sniffed as MP4
+	content := "\x00\x00\x00\x14ftypisom\x00\x00\x02\x00mp41"
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "clip.mp4"), []byte(content), 0644))
+
+	stream := func(method string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/stream/uploads/clip.mp4", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		hdl.stream(rec, req)
+		return rec
+	}
+
+	full := stream(http.MethodGet, nil)
+	require.Equal(t, http.StatusOK, full.Code)
+	etag := full.Header().Get("ETag")
+	lastModified := full.Header().Get("Last-Modified")
+
+	t.Run(
+		"Validators", func(t *testing.T) {
+			assert.Equal(t, "bytes", full.Header().Get("Accept-Ranges"))
+			assert.NotEmpty(t, etag)
+			assert.NotEmpty(t, lastModified)
+			assert.Equal(t, "20", full.Header().Get("Content-Length"))
+			assert.Equal(t, content, full.Body.String())
+		},
+	)
+
+	t.Run(
+		"Single Range", func(t *testing.T) {
+			rec := stream(http.MethodGet, map[string]string{"Range": "bytes=5-9"})
+			assert.Equal(t, http.StatusPartialContent, rec.Code)
+			assert.Equal(t, "bytes 5-9/20", rec.Header().Get("Content-Range"))
+			assert.Equal(t, "5", rec.Header().Get("Content-Length"))
+			assert.Equal(t, "video/mp4", rec.Header().Get("Content-Type"))
+			assert.Equal(t, content[5:10], rec.Body.String())
+
+			rec = stream(http.MethodGet, map[string]string{"Range": "bytes=-3"})
+			assert.Equal(t, http.StatusPartialContent, rec.Code)
+			assert.Equal(t, content[17:], rec.Body.String())
+		},
+	)
+
+	t.Run(
+		"Multiple Ranges", func(t *testing.T) {
+			rec := stream(http.MethodGet, map[string]string{"Range": "bytes=0-1,10-12"})
+			require.Equal(t, http.StatusPartialContent, rec.Code)
+
+			mediaType, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+			require.NoError(t, err)
+			assert.Equal(t, "multipart/byteranges", mediaType)
+
+			reader := multipart.NewReader(rec.Body, params["boundary"])
+			expected := []struct{ contentRange, body string }{
+				{"bytes 0-1/20", content[0:2]},
+				{"bytes 10-12/20", content[10:13]},
+			}
+			for _, exp := range expected {
+				part, err := reader.NextPart()
+				require.NoError(t, err)
+				assert.Equal(t, "video/mp4", part.Header.Get("Content-Type"))
+				assert.Equal(t, exp.contentRange, part.Header.Get("Content-Range"))
+
+				body, err := io.ReadAll(part)
+				require.NoError(t, err)
+				assert.Equal(t, exp.body, string(body))
+			}
+			_, err = reader.NextPart()
+			assert.Equal(t, io.EOF, err)
+		},
+	)
+
+	t.Run(
+		"Unsatisfiable Range", func(t *testing.T) {
+			rec := stream(http.MethodGet, map[string]string{"Range": "bytes=50-60"})
+			assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, rec.Code)
+			assert.Equal(t, "bytes */20", rec.Header().Get("Content-Range"))
+		},
+	)
+
+	t.Run(
+		"Conditional", func(t *testing.T) {
+			rec := stream(http.MethodGet, map[string]string{"If-None-Match": etag})
+			assert.Equal(t, http.StatusNotModified, rec.Code)
+			assert.Empty(t, rec.Body.String())
+
+			rec = stream(http.MethodGet, map[string]string{"If-Modified-Since": lastModified})
+			assert.Equal(t, http.StatusNotModified, rec.Code)
+
+			older := time.Now().Add(-24 * time.Hour).UTC().Format(http.TimeFormat)
+			rec = stream(http.MethodGet, map[string]string{"If-Modified-Since": older})
+			assert.Equal(t, http.StatusOK, rec.Code)
+		},
+	)
+
+	t.Run(
+		"If-Range", func(t *testing.T) {
+			rec := stream(http.MethodGet, map[string]string{"Range": "bytes=0-3", "If-Range": lastModified})
+			assert.Equal(t, http.StatusPartialContent, rec.Code)
+			assert.Equal(t, content[0:4], rec.Body.String())
+
+			rec = stream(http.MethodGet, map[string]string{"Range": "bytes=0-3", "If-Range": `"stale"`})
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, content, rec.Body.String())
+		},
+	)
+
+	t.Run(
+		"Head", func(t *testing.T) {
+			rec := stream(http.MethodHead, map[string]string{"Range": "bytes=0-3"})
+			assert.Equal(t, http.StatusPartialContent, rec.Code)
+			assert.Equal(t, "4", rec.Header().Get("Content-Length"))
+			assert.Empty(t, rec.Body.String())
+		},
+	)
+
+	t.Run(
+		"Small Buffer", func(t *testing.T) {
+			conf := setupTestConfig()
+			conf.HTTP.MaxStreamBuffer = 3
+			rec := httptest.NewRecorder()
+			New(port, conf, hdl.storage).stream(rec, httptest.NewRequest(http.MethodGet, "/stream/uploads/clip.mp4", nil))
+			assert.Equal(t, content, rec.Body.String())
+			assert.True(t, rec.Flushed)
+			assert.False(t, strings.Contains(rec.Header().Get("Transfer-Encoding"), "chunked"))
+		},
+	)
+}
@@ -0,0 +1,171 @@
+package http
+
+import (
+	"context"
+	"github.com/JMURv/simple-s3/pkg/config"
+	"github.com/JMURv/simple-s3/pkg/imgproc"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/JMURv/simple-s3/pkg/thumbnail"
+	utils "github.com/JMURv/simple-s3/pkg/utils/http"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// presetQuery turns a thumbnail preset into the /img query that renders it
+func presetQuery(p config.ThumbnailPreset) url.Values {
+	q := url.Values{}
+	if p.Width > 0 {
+		q.Set("w", strconv.Itoa(p.Width))
+	}
+	if p.Height > 0 {
+		q.Set("h", strconv.Itoa(p.Height))
+	}
+	if p.Fit != "" {
+		q.Set("fit", p.Fit)
+	}
+	if p.Quality > 0 {
+		q.Set("q", strconv.Itoa(p.Quality))
+	}
+	if p.Format != "" {
+		q.Set("format", p.Format)
+	}
+	return q
+}
+
+// setupThumbnails validates the configured presets and creates the worker
+// pool that renders them. Invalid presets are logged and skipped.
+func (h *Handler) setupThumbnails(conf *config.ThumbnailsConfig) {
+	if conf == nil || len(conf.Presets) == 0 {
+		return
+	}
+
+	h.presets = make(map[string]url.Values, len(conf.Presets))
+	for name, preset := range conf.Presets {
+		q := presetQuery(preset)
+		if _, err := imgproc.ParseOptions(q, h.imageLimits); err != nil {
+			log.Printf("Ignoring thumbnail preset %s: %v\n", name, err)
+			continue
+		}
+		h.presets[name] = q
+	}
+
+	workers, queueSize := config.DefaultThumbnailWorkers, config.DefaultThumbnailQueue
+	if conf.Workers > 0 {
+		workers = conf.Workers
+	}
+	if conf.QueueSize > 0 {
+		queueSize = conf.QueueSize
+	}
+	h.thumbnails = thumbnail.NewPool(workers, queueSize, h.renderThumbnail)
+}
+
+// enqueueThumbnails schedules every preset of a freshly uploaded image and
+// returns their URLs, presigned when presigning is configured. Files that
+// aren't images get no thumbnails.
+func (h *Handler) enqueueThumbnails(info *storage.ObjectInfo) map[string]string {
+	if h.thumbnails == nil || imgproc.FormatOf(info.ContentType) == "" {
+		return nil
+	}
+
+	names := make([]string, 0, len(h.presets))
+	urls := make(map[string]string, len(h.presets))
+	for name, q := range h.presets {
+		names = append(names, name)
+		urls[name] = h.thumbnailURL(&url.URL{Path: "/img/" + info.Key, RawQuery: q.Encode()})
+	}
+	sort.Strings(names)
+
+	h.thumbnails.Enqueue(info.Key, names)
+	return urls
+}
+
+// thumbnailURL signs target for GET the way /presign does with its default
+// expiry, so the URL works where /img needs credentials
+func (h *Handler) thumbnailURL(target *url.URL) string {
+	if h.presigner == nil {
+		return target.String()
+	}
+
+	signed, _, err := h.presigner.URL(http.MethodGet, target.RequestURI(), min(defaultPresignTTL, h.presignMaxExpiry), 0)
+	if err != nil {
+		log.Printf("Error while signing thumbnail URL %s: %v\n", target, err)
+		return target.String()
+	}
+	return signed
+}
+
+// renderThumbnail renders one preset of key into the variant cache, which
+// is where /img finds it
+func (h *Handler) renderThumbnail(ctx context.Context, key, preset string) error {
+	opts, err := imgproc.ParseOptions(h.presets[preset], h.imageLimits)
+	if err != nil {
+		return err
+	}
+
+	info, err := h.storage.Stat(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if opts.Format == "" {
+		if opts.Format, err = h.imageFormat(ctx, info); err != nil {
+			return err
+		}
+	}
+
+	file, _, _, err := h.variant(ctx, info, opts)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// thumbnailStatus reports thumbnail jobs that are still pending or failed
+// @Summary Thumbnail status
+// @Description Lists the thumbnail jobs that are pending, running or have failed, optionally for a single file. Finished thumbnails are not listed, and only the latest failed jobs are kept.
+// @Param path query string false "File path"
+// @Success 200 {object} model.ThumbnailStatusRes
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 501 {object} utils.ErrorResponse
+// @Router /thumbnails [get]
+func (h *Handler) thumbnailStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+	if h.thumbnails == nil {
+		utils.ErrResponse(w, http.StatusNotImplemented, ErrThumbnailsDisabled)
+		return
+	}
+
+	key, ok := h.dirKey(r.URL.Query().Get("path"))
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	jobs := h.thumbnails.Jobs(key)
+	res := model.ThumbnailStatusRes{Jobs: make([]model.ThumbnailJobRes, 0, len(jobs))}
+	for _, job := range jobs {
+		if job.State == thumbnail.Failed {
+			res.Failed++
+		} else {
+			res.Pending++
+		}
+		res.Jobs = append(
+			res.Jobs, model.ThumbnailJobRes{
+				Path:    h.publicPath(job.Key),
+				Preset:  job.Preset,
+				State:   string(job.State),
+				Error:   job.Error,
+				Queued:  job.Queued.Unix(),
+				Updated: job.Updated.Unix(),
+			},
+		)
+	}
+	utils.SuccessDataResponse(w, http.StatusOK, &res)
+}
@@ -0,0 +1,251 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/JMURv/simple-s3/pkg/config"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"image/jpeg"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func uploadRequest(dir, name string, content []byte) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("path", dir)
+	file, _ := writer.CreateFormFile("file", name)
+	file.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, createEndpoint, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func uploadFile(t *testing.T, hdl *Handler, dir, name string, content []byte) model.FileRes {
+	rec := httptest.NewRecorder()
+	hdl.createFile(rec, uploadRequest(dir, name, content))
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	res := model.FileRes{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	return res
+}
+
+func thumbnailStatus(t *testing.T, hdl *Handler, target string) model.ThumbnailStatusRes {
+	rec := httptest.NewRecorder()
+	hdl.thumbnailStatus(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	res := model.ThumbnailStatusRes{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	return res
+}
+
+// failingDelete is a backend whose deletes fail
+type failingDelete struct {
+	storage.Storage
+}
+
+func (failingDelete) Delete(context.Context, string) error {
+	return errors.New("disk failure")
+}
+
+func TestThumbnails(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+
+	conf := setupTestConfig()
+	conf.Thumbnails = &config.ThumbnailsConfig{
+		Workers: 2,
+		Presets: map[string]config.ThumbnailPreset{
+			"square": {Width: 16, Height: 16, Fit: "cover", Format: "jpeg", Quality: 75},
+			"small":  {Width: 20},
+			"broken": {Width: 10, Fit: "cover"},
+		},
+	}
+	hdl := New(port, conf, setupTestHandler().storage)
+
+	res := uploadFile(t, hdl, "gallery", "Photo.png", encodeTestImage(t, 40, 20))
+
+	t.Run(
+		"Upload Response", func(t *testing.T) {
+			require.Len(t, res.Thumbnails, 2, "invalid presets are skipped")
+			assert.Equal(t, "/img/gallery/photo.png?fit=cover&format=jpeg&h=16&q=75&w=16", res.Thumbnails["square"])
+			assert.Equal(t, "/img/gallery/photo.png?w=20", res.Thumbnails["small"])
+		},
+	)
+
+	t.Run(
+		"Pending", func(t *testing.T) {
+			status := thumbnailStatus(t, hdl, "/thumbnails?path="+url.QueryEscape(res.Path))
+			assert.Equal(t, 2, status.Pending)
+			assert.Zero(t, status.Failed)
+			require.Len(t, status.Jobs, 2)
+			assert.Equal(t, res.Path, status.Jobs[0].Path)
+			assert.Equal(t, "pending", status.Jobs[0].State)
+
+			assert.Empty(t, thumbnailStatus(t, hdl, "/thumbnails?path=gallery/other.png").Jobs)
+		},
+	)
+
+	t.Run(
+		"Not An Image", func(t *testing.T) {
+			text := uploadFile(t, hdl, "gallery", "notes.txt", []byte("no thumbnails here"))
+			assert.Empty(t, text.Thumbnails)
+		},
+	)
+
+	t.Run(
+		"Rendered", func(t *testing.T) {
+			broken := uploadFile(t, hdl, "gallery", "broken.png", []byte("\x89PNG\r\n\x1a\nnot really a png"))
+			require.Len(t, broken.Thumbnails, 2)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go hdl.thumbnails.Run(ctx)
+
+			require.Eventually(
+				t, func() bool {
+					status := thumbnailStatus(t, hdl, "/thumbnails")
+					return status.Pending == 0 && status.Failed == 2
+				}, 5*time.Second, 10*time.Millisecond,
+			)
+
+			status := thumbnailStatus(t, hdl, "/thumbnails")
+			for _, job := range status.Jobs {
+				assert.Equal(t, broken.Path, job.Path)
+				assert.Equal(t, "failed", job.State)
+				assert.NotEmpty(t, job.Error)
+			}
+
+			rec := httptest.NewRecorder()
+			hdl.image(rec, httptest.NewRequest(http.MethodGet, res.Thumbnails["square"], nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "HIT", rec.Header().Get("X-Cache"))
+
+			conf, err := jpeg.DecodeConfig(rec.Body)
+			require.NoError(t, err)
+			assert.Equal(t, 16, conf.Width)
+			assert.Equal(t, 16, conf.Height)
+		},
+	)
+
+	t.Run(
+		"Delete Forgets Jobs", func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			hdl.deleteFile(rec, httptest.NewRequest(http.MethodDelete, "/delete?path=gallery/broken.png", nil))
+			require.Equal(t, http.StatusNoContent, rec.Code)
+			assert.Empty(t, thumbnailStatus(t, hdl, "/thumbnails").Jobs)
+		},
+	)
+
+	t.Run(
+		"Other Uploads", func(t *testing.T) {
+			img := encodeTestImage(t, 40, 20)
+
+			rec := s3Request(hdl, http.MethodPut, "/"+testBucket+"/other/s3.png", bytes.NewReader(img))
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			rec = tusRequest(
+				hdl, http.MethodPost, "/tus", nil, map[string]string{
+					"Upload-Length":   strconv.Itoa(len(img)),
+					"Upload-Metadata": tusMetadata("tus.png", "other"),
+				},
+			)
+			require.Equal(t, http.StatusCreated, rec.Code)
+			rec = tusRequest(
+				hdl, http.MethodPatch, rec.Header().Get("Location"), bytes.NewReader(img), map[string]string{
+					"Content-Type":  tusContentType,
+					"Upload-Offset": "0",
+				},
+			)
+			require.Equal(t, http.StatusNoContent, rec.Code)
+
+			rec = httptest.NewRecorder()
+			hdl.initiateMultipart(rec, httptest.NewRequest(http.MethodPost, "/multipart?path=other&filename=multipart.png", nil))
+			require.Equal(t, http.StatusCreated, rec.Code)
+			upload := model.MultipartUploadRes{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &upload))
+
+			rec = httptest.NewRecorder()
+			hdl.multipartUpload(rec, httptest.NewRequest(http.MethodPut, "/multipart/"+upload.UploadID+"/1", bytes.NewReader(img)))
+			require.Equal(t, http.StatusOK, rec.Code)
+			part := model.PartRes{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &part))
+
+			body, _ := json.Marshal(&model.CompleteMultipartReq{Parts: []model.PartRes{part}})
+			rec = httptest.NewRecorder()
+			hdl.multipartUpload(rec, httptest.NewRequest(http.MethodPost, "/multipart/"+upload.UploadID+"/complete", bytes.NewReader(body)))
+			require.Equal(t, http.StatusCreated, rec.Code)
+			res := model.FileRes{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			assert.Len(t, res.Thumbnails, 2)
+
+			for _, name := range []string{"s3.png", "tus.png", "multipart.png"} {
+				status := thumbnailStatus(t, hdl, "/thumbnails?path=other/"+name)
+				assert.Equal(t, 2, status.Pending, name)
+			}
+		},
+	)
+
+	t.Run(
+		"Failed Delete Keeps Jobs", func(t *testing.T) {
+			store := hdl.storage
+			hdl.storage = failingDelete{store}
+			defer func() { hdl.storage = store }()
+
+			rec := httptest.NewRecorder()
+			hdl.deleteFile(rec, httptest.NewRequest(http.MethodDelete, "/delete?path=other/s3.png", nil))
+			require.Equal(t, http.StatusInternalServerError, rec.Code)
+			assert.Equal(t, 2, thumbnailStatus(t, hdl, "/thumbnails?path=other/s3.png").Pending)
+		},
+	)
+
+	t.Run(
+		"Disabled", func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			setupTestHandler().thumbnailStatus(rec, httptest.NewRequest(http.MethodGet, "/thumbnails", nil))
+			assert.Equal(t, http.StatusNotImplemented, rec.Code)
+		},
+	)
+}
+
+func TestThumbnailsPresigned(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+
+	conf := setupAuthConfig()
+	conf.Presign = &config.PresignConfig{Secret: "presign-secret"}
+	conf.Thumbnails = &config.ThumbnailsConfig{
+		Presets: map[string]config.ThumbnailPreset{"small": {Width: 20}},
+	}
+	hdl := New(port, conf, storage.NewLocal(testDir))
+
+	res := uploadFile(t, hdl, "gallery", "photo.png", encodeTestImage(t, 40, 20))
+	require.Len(t, res.Thumbnails, 1)
+
+	target, err := url.Parse(res.Thumbnails["small"])
+	require.NoError(t, err)
+	assert.Equal(t, "/img/gallery/photo.png", target.Path)
+	assert.Equal(t, "20", target.Query().Get("w"))
+
+	rec := httptest.NewRecorder()
+	hdl.presigned(hdl.image)(rec, httptest.NewRequest(http.MethodGet, res.Thumbnails["small"], nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	hdl.presigned(hdl.image)(rec, httptest.NewRequest(http.MethodGet, "/img/gallery/photo.png?w=20", nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
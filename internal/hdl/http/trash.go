@@ -0,0 +1,193 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/JMURv/simple-s3/pkg/trash"
+	utils "github.com/JMURv/simple-s3/pkg/utils/http"
+	"log"
+	"net/http"
+	"time"
+)
+
+// trashPurgeInterval is how often expired files are removed from the trash
+const trashPurgeInterval = time.Hour
+
+// listTrash lists the deleted files waiting in the trash
+// @Summary List the trash
+// @Description Lists the files moved to the trash by deletes, most recently deleted first, with the path they are restored to and when they are purged for good.
+// @Param page query int false "Page number" default(1)
+// @Param size query int false "Number of items per page" default(10)
+// @Success 200 {object} model.TrashListRes
+// @Failure 500 {object} utils.ErrorResponse
+// @Failure 501 {object} utils.ErrorResponse
+// @Router /trash [get]
+func (h *Handler) listTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+
+	bin, ok := storage.As[*trash.Storage](h.storage)
+	if !ok {
+		utils.ErrResponse(w, http.StatusNotImplemented, ErrTrashDisabled)
+		return
+	}
+
+	page, size := utils.ParsePaginationParams(
+		r, h.config.DefaultPage,
+		h.config.DefaultSize,
+	)
+
+	items, count, err := bin.Items((page-1)*size, size)
+	if err != nil {
+		log.Println("Error listing trash: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+		return
+	}
+
+	res := make([]model.TrashItemRes, 0, len(items))
+	for _, item := range items {
+		res = append(res, h.trashItemRes(item))
+	}
+
+	totalPages := (count + size - 1) / size
+	utils.SuccessDataResponse(
+		w, http.StatusOK, model.TrashListRes{
+			Data:        res,
+			Count:       count,
+			TotalPages:  totalPages,
+			CurrentPage: page,
+			HasNextPage: page < totalPages,
+		},
+	)
+}
+
+// restoreTrash puts a deleted file back where it was
+// @Summary Restore from the trash
+// @Description Moves the trash item id back to the path it was deleted from, recreating its directories. A file stored at that path since is not replaced and the restore is refused with 409, unless the path is versioned.
+// @Param id query string true "Trash item ID"
+// @Success 201 {object} model.FileRes
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 501 {object} utils.ErrorResponse
+// @Router /trash/restore [post]
+func (h *Handler) restoreTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+
+	bin, ok := storage.As[*trash.Storage](h.storage)
+	if !ok {
+		utils.ErrResponse(w, http.StatusNotImplemented, ErrTrashDisabled)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrMissingQuery)
+		return
+	}
+
+	info, err := bin.Restore(r.Context(), id)
+	if errors.Is(err, storage.ErrNotFound) {
+		utils.ErrResponse(w, http.StatusNotFound, err)
+		return
+	} else if errors.Is(err, trash.ErrConflict) {
+		utils.ErrResponse(w, http.StatusConflict, err)
+		return
+	} else if err != nil {
+		log.Println("Error restoring from trash: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+		return
+	}
+	h.dropVariants(info.Key)
+
+	log.Printf("File %s restored from trash\n", info.Key)
+	res := h.uploadRes(r.Context(), info)
+	utils.SuccessDataResponse(w, http.StatusCreated, &res)
+}
+
+// emptyTrash permanently deletes files from the trash
+// @Summary Empty the trash
+// @Description Permanently deletes the trash item id, or every item in the trash when id is omitted. Items are also purged automatically once the configured retention has passed.
+// @Param id query string false "Trash item ID"
+// @Success 200 {object} model.PurgeRes
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 500 {object} utils.ErrorResponse
+// @Failure 501 {object} utils.ErrorResponse
+// @Router /trash/empty [delete]
+func (h *Handler) emptyTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+
+	bin, ok := storage.As[*trash.Storage](h.storage)
+	if !ok {
+		utils.ErrResponse(w, http.StatusNotImplemented, ErrTrashDisabled)
+		return
+	}
+
+	n := 1
+	var err error
+	if id := r.URL.Query().Get("id"); id != "" {
+		err = bin.Remove(r.Context(), id)
+	} else {
+		n, err = bin.Empty(r.Context())
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		utils.ErrResponse(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		log.Println("Error emptying trash: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+		return
+	}
+
+	log.Printf("Deleted %d files from trash\n", n)
+	utils.SuccessDataResponse(w, http.StatusOK, &model.PurgeRes{Purged: n})
+}
+
+// purgeTrash removes the files whose retention has passed from the trash
+// until ctx is cancelled
+func (h *Handler) purgeTrash(ctx context.Context, bin *trash.Storage) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			n, err := bin.Purge(ctx, now)
+			if err != nil {
+				log.Println("Error purging trash: ", err)
+			}
+			if n > 0 {
+				log.Printf("Purged %d files from trash\n", n)
+			}
+		}
+	}
+}
+
+// trashItemRes describes a trash item with the path it is restored to,
+// prefixed by savePath like the other JSON endpoints
+func (h *Handler) trashItemRes(item trash.Item) model.TrashItemRes {
+	return model.TrashItemRes{
+		ID:           item.ID,
+		Path:         h.publicPath(item.Key),
+		DeletedAt:    item.DeletedAt.Unix(),
+		ExpiresAt:    item.Expires.Unix(),
+		Size:         item.Size,
+		ModTime:      item.ModTime.Unix(),
+		ContentType:  item.ContentType,
+		ETag:         item.ETag,
+		SHA256:       item.SHA256,
+		OriginalName: item.OriginalName,
+	}
+}
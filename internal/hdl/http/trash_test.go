@@ -0,0 +1,114 @@
+package http
+
+import (
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/JMURv/simple-s3/pkg/trash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func setupTrashHandler(t *testing.T) *Handler {
+	bin, err := trash.Open(t.TempDir(), storage.NewLocal(testDir), time.Hour)
+	require.NoError(t, err)
+	t.Cleanup(func() { bin.Close() })
+
+	return New(port, setupTestConfig(), bin)
+}
+
+func TestTrash(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupTrashHandler(t)
+
+	uploadFile(t, hdl, "docs", "a.txt", []byte("a"))
+	uploadFile(t, hdl, "docs/2024", "b.txt", []byte("bb"))
+
+	t.Run(
+		"Delete", func(t *testing.T) {
+			dirRequest(t, hdl.deleteFile, http.MethodDelete, "path=docs/a.txt", http.StatusNoContent, nil)
+			assert.NoFileExists(t, filepath.Join(testDir, "docs", "a.txt"))
+
+			res := model.TrashListRes{}
+			dirRequest(t, hdl.listTrash, http.MethodGet, "", http.StatusOK, &res)
+			assert.Equal(t, 1, res.Count)
+			require.Len(t, res.Data, 1)
+			assert.Equal(t, "/test_uploads/docs/a.txt", res.Data[0].Path)
+			assert.Equal(t, int64(1), res.Data[0].Size)
+			assert.Equal(t, res.Data[0].DeletedAt+3600, res.Data[0].ExpiresAt)
+		},
+	)
+
+	t.Run(
+		"Recursive Rmdir", func(t *testing.T) {
+			dirRequest(t, hdl.removeDir, http.MethodDelete, "path=docs&recursive=true", http.StatusOK, nil)
+			assert.NoDirExists(t, filepath.Join(testDir, "docs"))
+
+			res := model.TrashListRes{}
+			dirRequest(t, hdl.listTrash, http.MethodGet, "page=1&size=1", http.StatusOK, &res)
+			assert.Equal(t, 2, res.Count)
+			assert.True(t, res.HasNextPage)
+			require.Len(t, res.Data, 1)
+			assert.Equal(t, "/test_uploads/docs/2024/b.txt", res.Data[0].Path)
+		},
+	)
+
+	t.Run(
+		"Restore", func(t *testing.T) {
+			res := model.TrashListRes{}
+			dirRequest(t, hdl.listTrash, http.MethodGet, "", http.StatusOK, &res)
+			require.Len(t, res.Data, 2)
+
+			file := model.FileRes{}
+			dirRequest(t, hdl.restoreTrash, http.MethodPost, "id="+res.Data[0].ID, http.StatusCreated, &file)
+			assert.Equal(t, "/test_uploads/docs/2024/b.txt", file.Path)
+			assert.FileExists(t, filepath.Join(testDir, "docs", "2024", "b.txt"))
+			dirRequest(t, hdl.restoreTrash, http.MethodPost, "id="+res.Data[0].ID, http.StatusNotFound, nil)
+
+			uploadFile(t, hdl, "docs", "a.txt", []byte("replacement"))
+			dirRequest(t, hdl.restoreTrash, http.MethodPost, "id="+res.Data[1].ID, http.StatusConflict, nil)
+		},
+	)
+
+	t.Run(
+		"Empty", func(t *testing.T) {
+			res := model.TrashListRes{}
+			dirRequest(t, hdl.listTrash, http.MethodGet, "", http.StatusOK, &res)
+			require.Len(t, res.Data, 1)
+
+			purged := model.PurgeRes{}
+			dirRequest(t, hdl.emptyTrash, http.MethodDelete, "id="+res.Data[0].ID, http.StatusOK, &purged)
+			assert.Equal(t, 1, purged.Purged)
+			dirRequest(t, hdl.emptyTrash, http.MethodDelete, "id="+res.Data[0].ID, http.StatusNotFound, nil)
+
+			dirRequest(t, hdl.deleteFile, http.MethodDelete, "path=docs/a.txt", http.StatusNoContent, nil)
+			dirRequest(t, hdl.deleteFile, http.MethodDelete, "path=docs/2024/b.txt", http.StatusNoContent, nil)
+			dirRequest(t, hdl.emptyTrash, http.MethodDelete, "", http.StatusOK, &purged)
+			assert.Equal(t, 2, purged.Purged)
+
+			dirRequest(t, hdl.listTrash, http.MethodGet, "", http.StatusOK, &res)
+			assert.Equal(t, 0, res.Count)
+		},
+	)
+
+	t.Run(
+		"Errors", func(t *testing.T) {
+			dirRequest(t, hdl.restoreTrash, http.MethodPost, "", http.StatusBadRequest, nil)
+			dirRequest(t, hdl.restoreTrash, http.MethodGet, "id=x", http.StatusMethodNotAllowed, nil)
+			dirRequest(t, hdl.emptyTrash, http.MethodPost, "", http.StatusMethodNotAllowed, nil)
+		},
+	)
+
+	t.Run(
+		"Disabled", func(t *testing.T) {
+			plain := setupTestHandler()
+			dirRequest(t, plain.listTrash, http.MethodGet, "", http.StatusNotImplemented, nil)
+			dirRequest(t, plain.restoreTrash, http.MethodPost, "id=x", http.StatusNotImplemented, nil)
+			dirRequest(t, plain.emptyTrash, http.MethodDelete, "", http.StatusNotImplemented, nil)
+		},
+	)
+}
@@ -0,0 +1,245 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/JMURv/simple-s3/pkg/tus"
+	utils "github.com/JMURv/simple-s3/pkg/utils/http"
+	"github.com/JMURv/simple-s3/pkg/utils/slugify"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const tusContentType = "application/offset+octet-stream"
+
+// uploadPurgeInterval is how often expired resumable and multipart uploads
+// are removed
+const uploadPurgeInterval = time.Hour
+
+// tusUpload implements the tus 1.0 resumable upload protocol
+// @Summary Resumable upload (tus 1.0)
+// @Description Implements the tus 1.0 core protocol with the creation, termination and expiration extensions. POST /tus creates an upload from Upload-Length and Upload-Metadata (filename, optional path), HEAD /tus/{id} reports the Upload-Offset, PATCH /tus/{id} appends bytes at Upload-Offset and DELETE /tus/{id} discards the upload. Finished uploads are stored at the same slugified path as /upload.
+// @Param id path string true "Upload ID"
+// @Param Tus-Resumable header string true "Protocol version, 1.0.0"
+// @Param Upload-Offset header int false "Offset of the PATCH body"
+// @Success 200
+// @Success 201
+// @Success 204
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 410 {object} utils.ErrorResponse
+// @Failure 412 {object} utils.ErrorResponse
+// @Failure 413 {object} utils.ErrorResponse
+// @Failure 415 {object} utils.ErrorResponse
+// @Router /tus/{id} [patch]
+func (h *Handler) tusUpload(w http.ResponseWriter, r *http.Request) {
+	if method := r.Header.Get("X-HTTP-Method-Override"); method != "" {
+		r.Method = method
+	}
+
+	w.Header().Set("Tus-Resumable", tus.Version)
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Tus-Version", tus.Version)
+		w.Header().Set("Tus-Extension", tus.Extensions)
+		if h.config.MaxUploadSize > 0 {
+			w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.config.MaxUploadSize, 10))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Header.Get("Tus-Resumable") != tus.Version {
+		w.Header().Set("Tus-Version", tus.Version)
+		utils.ErrResponse(w, http.StatusPreconditionFailed, ErrTusVersion)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/tus"), "/")
+	switch {
+	case r.Method == http.MethodPost && id == "":
+		h.createTusUpload(w, r)
+	case r.Method == http.MethodHead && id != "":
+		upload, err := h.tus.Get(id)
+		if err != nil {
+			utils.ErrResponse(w, tusStatus(err), tusErr(err))
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+		if len(upload.Metadata) > 0 {
+			w.Header().Set("Upload-Metadata", tus.FormatMetadata(upload.Metadata))
+		}
+		if !upload.Completed {
+			w.Header().Set("Upload-Expires", upload.Expires.UTC().Format(http.TimeFormat))
+		}
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPatch && id != "":
+		h.patchTusUpload(w, r, id)
+	case r.Method == http.MethodDelete && id != "":
+		if err := h.tus.Terminate(id); err != nil {
+			utils.ErrResponse(w, tusStatus(err), tusErr(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+	}
+}
+
+func (h *Handler) createTusUpload(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrTusLength)
+		return
+	}
+	if h.config.MaxUploadSize > 0 && length > h.config.MaxUploadSize {
+		utils.ErrResponse(w, http.StatusRequestEntityTooLarge, ErrFileTooBig)
+		return
+	}
+
+	metadata, err := tus.ParseMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		utils.ErrResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	filename := (&tus.Upload{Metadata: metadata}).Filename()
+	if filename == "" {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrRetrievingFile)
+		return
+	}
+
+	dir, ok := h.dirKey(metadata["path"])
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	key := path.Join(dir, slugify.Filename(filename))
+	if _, err = h.storage.Stat(r.Context(), key); err == nil && !storage.Overwritable(h.storage, key) {
+		utils.ErrResponse(w, http.StatusConflict, ErrAlreadyExists)
+		return
+	}
+
+	upload, err := h.tus.Create(key, length, metadata)
+	if err != nil {
+		utils.ErrResponse(w, tusStatus(err), tusErr(err))
+		return
+	}
+
+	// An empty file is complete as soon as it is created
+	if length == 0 {
+		if upload, err = h.tus.Write(r.Context(), upload.ID, 0, http.NoBody); err != nil {
+			utils.ErrResponse(w, tusStatus(err), tusErr(err))
+			return
+		}
+	}
+
+	w.Header().Set("Location", "/tus/"+upload.ID)
+	w.Header().Set("Upload-Expires", upload.Expires.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *Handler) patchTusUpload(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("Content-Type") != tusContentType {
+		utils.ErrResponse(w, http.StatusUnsupportedMediaType, ErrUnsupportedMediaType)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrTusOffset)
+		return
+	}
+
+	upload, err := h.tus.Write(r.Context(), id, offset, r.Body)
+	if err != nil {
+		utils.ErrResponse(w, tusStatus(err), tusErr(err))
+		return
+	}
+
+	if upload.Completed {
+		// The upload may have replaced a versioned file
+		h.dropVariants(upload.Key)
+		log.Printf("Resumable upload %s finished as %s\n", upload.ID, upload.Key)
+		// The client gets no file details, but computing them now caches them
+		// for the next stat or list
+		h.uploadRes(r.Context(), upload.Info)
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Expires", upload.Expires.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purgeUploads removes expired resumable and multipart uploads until ctx is
+// cancelled
+func (h *Handler) purgeUploads(ctx context.Context) {
+	ticker := time.NewTicker(uploadPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			purged, err := h.tus.Purge(now)
+			if err != nil {
+				log.Println("Error purging expired uploads: ", err)
+			}
+			if len(purged) > 0 {
+				log.Printf("Purged %d expired uploads\n", len(purged))
+			}
+
+			purged, err = h.multipart.Purge(now)
+			if err != nil {
+				log.Println("Error purging expired multipart uploads: ", err)
+			}
+			if len(purged) > 0 {
+				log.Printf("Purged %d expired multipart uploads\n", len(purged))
+			}
+		}
+	}
+}
+
+func tusStatus(err error) int {
+	switch {
+	case errors.Is(err, tus.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, tus.ErrExpired):
+		return http.StatusGone
+	case errors.Is(err, tus.ErrOffsetMismatch):
+		return http.StatusConflict
+	case errors.Is(err, tus.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, tus.ErrLocked):
+		return http.StatusLocked
+	case errors.Is(err, tus.ErrSizeExceeded):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, tus.ErrInvalidMetadata), errors.Is(err, storage.ErrInvalidKey):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// tusErr hides unexpected errors behind ErrInternal
+func tusErr(err error) error {
+	switch {
+	case errors.Is(err, tus.ErrConflict):
+		return ErrAlreadyExists
+	case errors.Is(err, storage.ErrInvalidKey):
+		return ErrInvalidPath
+	case tusStatus(err) == http.StatusInternalServerError:
+		log.Println("Resumable upload error: ", err)
+		return ErrInternal
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+package http
+
+import (
+	"encoding/base64"
+	"github.com/JMURv/simple-s3/pkg/tus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func tusRequest(h *Handler, method, target string, body io.Reader, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set("Tus-Resumable", tus.Version)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	rec := httptest.NewRecorder()
+	h.tusUpload(rec, req)
+	return rec
+}
+
+func tusMetadata(filename, dir string) string {
+	metadata := "filename " + base64.StdEncoding.EncodeToString([]byte(filename))
+	if dir != "" {
+		metadata += ",path " + base64.StdEncoding.EncodeToString([]byte(dir))
+	}
+	return metadata
+}
+
+func TestTus(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupTestHandler()
+
+	patch := func(location, offset, body string) *httptest.ResponseRecorder {
+		return tusRequest(
+			hdl, http.MethodPatch, location, strings.NewReader(body), map[string]string{
+				"Content-Type":  tusContentType,
+				"Upload-Offset": offset,
+			},
+		)
+	}
+
+	t.Run(
+		"Options", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/tus", nil)
+			rec := httptest.NewRecorder()
+			hdl.tusUpload(rec, req)
+
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+			assert.Equal(t, tus.Version, rec.Header().Get("Tus-Version"))
+			assert.Equal(t, tus.Extensions, rec.Header().Get("Tus-Extension"))
+			assert.Equal(t, "10485760", rec.Header().Get("Tus-Max-Size"))
+		},
+	)
+
+	t.Run(
+		"Upload In Chunks", func(t *testing.T) {
+			rec := tusRequest(
+				hdl, http.MethodPost, "/tus", nil, map[string]string{
+					"Upload-Length":   "11",
+					"Upload-Metadata": tusMetadata("My Notes.txt", "docs"),
+				},
+			)
+			require.Equal(t, http.StatusCreated, rec.Code)
+			location := rec.Header().Get("Location")
+			assert.True(t, strings.HasPrefix(location, "/tus/"))
+			assert.NotEmpty(t, rec.Header().Get("Upload-Expires"))
+
+			rec = patch(location, "0", "hello")
+			require.Equal(t, http.StatusNoContent, rec.Code)
+			assert.Equal(t, "5", rec.Header().Get("Upload-Offset"))
+
+			rec = tusRequest(hdl, http.MethodHead, location, nil, nil)
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "5", rec.Header().Get("Upload-Offset"))
+			assert.Equal(t, "11", rec.Header().Get("Upload-Length"))
+			assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+			assert.Contains(t, rec.Header().Get("Upload-Metadata"), "filename ")
+
+			rec = patch(location, "0", "hello")
+			assert.Equal(t, http.StatusConflict, rec.Code)
+
+			rec = patch(location, "5", " world")
+			require.Equal(t, http.StatusNoContent, rec.Code)
+			assert.Equal(t, "11", rec.Header().Get("Upload-Offset"))
+
+			data, err := os.ReadFile(filepath.Join(testDir, "docs", "my-notes.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, "hello world", string(data))
+
+			rec = tusRequest(hdl, http.MethodHead, location, nil, nil)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "11", rec.Header().Get("Upload-Offset"))
+
+			rec = tusRequest(
+				hdl, http.MethodPost, "/tus", nil, map[string]string{
+					"Upload-Length":   "11",
+					"Upload-Metadata": tusMetadata("My Notes.txt", "docs"),
+				},
+			)
+			assert.Equal(t, http.StatusConflict, rec.Code)
+		},
+	)
+
+	t.Run(
+		"Empty File", func(t *testing.T) {
+			rec := tusRequest(
+				hdl, http.MethodPost, "/tus", nil, map[string]string{
+					"Upload-Length":   "0",
+					"Upload-Metadata": tusMetadata("empty.txt", ""),
+				},
+			)
+			require.Equal(t, http.StatusCreated, rec.Code)
+
+			info, err := os.Stat(filepath.Join(testDir, "empty.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), info.Size())
+		},
+	)
+
+	t.Run(
+		"Terminate", func(t *testing.T) {
+			rec := tusRequest(
+				hdl, http.MethodPost, "/tus/", nil, map[string]string{
+					"Upload-Length":   "4",
+					"Upload-Metadata": tusMetadata("gone.txt", ""),
+				},
+			)
+			require.Equal(t, http.StatusCreated, rec.Code)
+			location := rec.Header().Get("Location")
+
+			rec = tusRequest(hdl, http.MethodPost, location, nil, map[string]string{"X-HTTP-Method-Override": "DELETE"})
+			assert.Equal(t, http.StatusNoContent, rec.Code)
+
+			rec = tusRequest(hdl, http.MethodHead, location, nil, nil)
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+
+			rec = patch(location, "0", "data")
+			assert.Equal(t, http.StatusNotFound, rec.Code)
+		},
+	)
+
+	t.Run(
+		"Invalid Requests", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/tus", nil)
+			req.Header.Set("Upload-Length", "4")
+			rec := httptest.NewRecorder()
+			hdl.tusUpload(rec, req)
+			assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+
+			tests := []struct {
+				name     string
+				headers  map[string]string
+				expected int
+			}{
+				{"Missing Length", map[string]string{"Upload-Metadata": tusMetadata("a.txt", "")}, http.StatusBadRequest},
+				{"Too Large", map[string]string{"Upload-Length": "104857600", "Upload-Metadata": tusMetadata("a.txt", "")}, http.StatusRequestEntityTooLarge},
+				{"Missing Filename", map[string]string{"Upload-Length": "4"}, http.StatusBadRequest},
+				{"Invalid Metadata", map[string]string{"Upload-Length": "4", "Upload-Metadata": "filename %%%"}, http.StatusBadRequest},
+				{"Invalid Path", map[string]string{"Upload-Length": "4", "Upload-Metadata": tusMetadata("a.txt", "../..")}, http.StatusBadRequest},
+			}
+			for _, tc := range tests {
+				t.Run(
+					tc.name, func(t *testing.T) {
+						rec := tusRequest(hdl, http.MethodPost, "/tus", nil, tc.headers)
+						assert.Equal(t, tc.expected, rec.Code)
+					},
+				)
+			}
+
+			rec = tusRequest(
+				hdl, http.MethodPost, "/tus", nil, map[string]string{
+					"Upload-Length":   "4",
+					"Upload-Metadata": tusMetadata("a.txt", ""),
+				},
+			)
+			require.Equal(t, http.StatusCreated, rec.Code)
+			location := rec.Header().Get("Location")
+
+			rec = tusRequest(hdl, http.MethodPatch, location, strings.NewReader("data"), map[string]string{"Upload-Offset": "0"})
+			assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+
+			rec = patch(location, "x", "data")
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+			rec = patch(location, "0", "too much data")
+			assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+			rec = tusRequest(hdl, http.MethodGet, location, nil, nil)
+			assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		},
+	)
+}
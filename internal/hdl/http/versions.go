@@ -0,0 +1,227 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	utils "github.com/JMURv/simple-s3/pkg/utils/http"
+	"github.com/JMURv/simple-s3/pkg/versions"
+	"io"
+	"log"
+	"net/http"
+)
+
+// listVersions lists the versions of a file or of every file in a directory
+// @Summary List versions
+// @Description Lists the versions of the file at path, newest first, including its delete markers. A directory lists the versions of every versioned file below it, ordered by path. Files stored without versioning report a single version with the ID "null".
+// @Param path query string false "File or directory path" default(/)
+// @Success 200 {object} model.VersionsRes
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 501 {object} utils.ErrorResponse
+// @Router /versions [get]
+func (h *Handler) listVersions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+
+	vs, ok := storage.As[*versions.Storage](h.storage)
+	if !ok {
+		utils.ErrResponse(w, http.StatusNotImplemented, ErrVersioningDisabled)
+		return
+	}
+
+	key, ok := h.dirKey(r.URL.Query().Get("path"))
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	list, err := vs.Versions(r.Context(), key, key == "")
+	if errors.Is(err, storage.ErrNotFound) {
+		list, err = vs.Versions(r.Context(), key, true)
+		if err == nil && len(list) == 0 {
+			err = storage.ErrNotFound
+		}
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		utils.ErrResponse(w, http.StatusNotFound, ErrRetrievingFile)
+		return
+	} else if err != nil {
+		log.Println("Error listing versions: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+		return
+	}
+
+	res := model.VersionsRes{Versions: make([]model.VersionRes, 0, len(list))}
+	for _, v := range list {
+		res.Versions = append(res.Versions, h.versionRes(v))
+	}
+	utils.SuccessDataResponse(w, http.StatusOK, &res)
+}
+
+// restoreVersion makes a previous version of a file the current one
+// @Summary Restore a version
+// @Description Stores a copy of the version id of the file at path as its current version. The version it replaces, or the delete marker hiding the file, stays in the history, so a restore can itself be undone.
+// @Param path query string true "File path"
+// @Param id query string true "Version ID"
+// @Success 201 {object} model.FileRes
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 501 {object} utils.ErrorResponse
+// @Router /versions/restore [post]
+func (h *Handler) restoreVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+
+	vs, ok := storage.As[*versions.Storage](h.storage)
+	if !ok {
+		utils.ErrResponse(w, http.StatusNotImplemented, ErrVersioningDisabled)
+		return
+	}
+
+	key, ok := h.objectKey(r.URL.Query().Get("path"))
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrMissingQuery)
+		return
+	}
+
+	info, err := vs.Restore(r.Context(), key, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		utils.ErrResponse(w, http.StatusNotFound, err)
+		return
+	} else if errors.Is(err, versions.ErrDeleteMarker) || errors.Is(err, versions.ErrCurrent) {
+		utils.ErrResponse(w, http.StatusConflict, err)
+		return
+	} else if err != nil {
+		log.Println("Error restoring version: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+		return
+	}
+	h.dropVariants(key)
+
+	log.Printf("Version %s of %s restored\n", id, key)
+	res := h.uploadRes(r.Context(), info)
+	utils.SuccessDataResponse(w, http.StatusCreated, &res)
+}
+
+// purgeVersions permanently deletes previous versions of a file
+// @Summary Purge versions
+// @Description Permanently deletes the version id of the file at path, or every previous version and delete marker when id is omitted. The current version is never purged; delete the file first.
+// @Param path query string true "File path"
+// @Param id query string false "Version ID"
+// @Success 200 {object} model.PurgeRes
+// @Failure 400 {object} utils.ErrorResponse
+// @Failure 404 {object} utils.ErrorResponse
+// @Failure 409 {object} utils.ErrorResponse
+// @Failure 501 {object} utils.ErrorResponse
+// @Router /versions/purge [delete]
+func (h *Handler) purgeVersions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.ErrResponse(w, http.StatusMethodNotAllowed, ErrInvalidReqMethod)
+		return
+	}
+
+	vs, ok := storage.As[*versions.Storage](h.storage)
+	if !ok {
+		utils.ErrResponse(w, http.StatusNotImplemented, ErrVersioningDisabled)
+		return
+	}
+
+	key, ok := h.objectKey(r.URL.Query().Get("path"))
+	if !ok {
+		utils.ErrResponse(w, http.StatusBadRequest, ErrInvalidPath)
+		return
+	}
+
+	n, err := vs.Purge(r.Context(), key, r.URL.Query().Get("id"))
+	if errors.Is(err, storage.ErrNotFound) {
+		utils.ErrResponse(w, http.StatusNotFound, err)
+		return
+	} else if errors.Is(err, versions.ErrCurrent) {
+		utils.ErrResponse(w, http.StatusConflict, err)
+		return
+	} else if err != nil {
+		log.Println("Error purging versions: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+		return
+	}
+
+	log.Printf("Purged %d versions of %s\n", n, key)
+	utils.SuccessDataResponse(w, http.StatusOK, &model.PurgeRes{Purged: n})
+}
+
+// downloadVersion serves one version of a file for download
+func (h *Handler) downloadVersion(w http.ResponseWriter, r *http.Request, key, id string) {
+	vs, ok := storage.As[*versions.Storage](h.storage)
+	if !ok {
+		utils.ErrResponse(w, http.StatusNotImplemented, ErrVersioningDisabled)
+		return
+	}
+
+	v, err := vs.Version(r.Context(), key, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		utils.ErrResponse(w, http.StatusNotFound, ErrRetrievingFile)
+		return
+	} else if err != nil {
+		log.Println("Error reading version: ", err)
+		utils.ErrResponse(w, http.StatusInternalServerError, ErrInternal)
+		return
+	}
+	if v.DeleteMarker {
+		utils.ErrResponse(w, http.StatusNotFound, versions.ErrDeleteMarker)
+		return
+	}
+
+	info := &storage.ObjectInfo{Key: key, Size: v.Size, ModTime: v.ModTime, Meta: v.Meta}
+	content := storage.NewReadSeeker(r.Context(), versionContent{Storage: vs, id: id}, info)
+	defer content.Close()
+
+	w.Header().Set("X-Version-Id", v.ID)
+	if v.ContentType != "" {
+		w.Header().Set("Content-Type", v.ContentType)
+	}
+	http.ServeContent(w, r, key, v.ModTime, content)
+}
+
+// versionContent reads one version of every key it is asked for, so a
+// version can be served through storage.NewReadSeeker
+type versionContent struct {
+	*versions.Storage
+	id string
+}
+
+func (c versionContent) Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, *storage.ObjectInfo, error) {
+	rc, v, err := c.GetVersion(ctx, key, c.id, offset, length)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rc, &storage.ObjectInfo{Key: key, Size: v.Size, ModTime: v.ModTime, Meta: v.Meta}, nil
+}
+
+// versionRes describes a version with its path prefixed by savePath, like
+// the other JSON endpoints
+func (h *Handler) versionRes(v versions.Version) model.VersionRes {
+	return model.VersionRes{
+		Path:         h.publicPath(v.Key),
+		VersionID:    v.ID,
+		Latest:       v.Latest,
+		DeleteMarker: v.DeleteMarker,
+		Size:         v.Size,
+		ModTime:      v.ModTime.Unix(),
+		ContentType:  v.ContentType,
+		ETag:         v.ETag,
+		SHA256:       v.SHA256,
+		OriginalName: v.OriginalName,
+	}
+}
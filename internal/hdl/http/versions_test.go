@@ -0,0 +1,153 @@
+package http
+
+import (
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/JMURv/simple-s3/pkg/versions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func setupVersionedHandler(t *testing.T, prefixes ...string) *Handler {
+	vs, err := versions.Open(t.TempDir(), prefixes)
+	require.NoError(t, err)
+	t.Cleanup(func() { vs.Close() })
+
+	return New(port, setupTestConfig(), versions.Wrap(storage.NewLocal(testDir), vs))
+}
+
+func listVersions(t *testing.T, hdl *Handler, p string) []model.VersionRes {
+	res := model.VersionsRes{}
+	dirRequest(t, hdl.listVersions, http.MethodGet, url.Values{"path": {p}}.Encode(), http.StatusOK, &res)
+	return res.Versions
+}
+
+func downloadVersion(hdl *Handler, key, id string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	hdl.download(rec, httptest.NewRequest(http.MethodGet, "/uploads/"+key+"?versionId="+id, nil))
+	return rec
+}
+
+func TestVersions(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupVersionedHandler(t, "docs")
+
+	uploadFile(t, hdl, "docs", "a.txt", []byte("one"))
+	uploadFile(t, hdl, "docs", "a.txt", []byte("two"))
+	uploadFile(t, hdl, "", "plain.txt", []byte("plain"))
+
+	t.Run(
+		"Overwrite Outside Prefix", func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			hdl.createFile(rec, uploadRequest("", "plain.txt", []byte("again")))
+			assert.Equal(t, http.StatusConflict, rec.Code)
+		},
+	)
+
+	t.Run(
+		"List", func(t *testing.T) {
+			list := listVersions(t, hdl, "docs/a.txt")
+			require.Len(t, list, 2)
+			assert.Equal(t, "/test_uploads/docs/a.txt", list[0].Path)
+			assert.True(t, list[0].Latest)
+			assert.False(t, list[1].Latest)
+
+			list = listVersions(t, hdl, "/test_uploads/docs")
+			assert.Len(t, list, 2)
+
+			list = listVersions(t, hdl, "plain.txt")
+			require.Len(t, list, 1)
+			assert.Equal(t, versions.NullID, list[0].VersionID)
+		},
+	)
+
+	t.Run(
+		"Download", func(t *testing.T) {
+			list := listVersions(t, hdl, "docs/a.txt")
+			rec := downloadVersion(hdl, "docs/a.txt", list[1].VersionID)
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "one", rec.Body.String())
+			assert.Equal(t, list[1].VersionID, rec.Header().Get("X-Version-Id"))
+
+			req := httptest.NewRequest(http.MethodGet, "/uploads/docs/a.txt?versionId="+list[1].VersionID, nil)
+			req.Header.Set("Range", "bytes=1-")
+			rec = httptest.NewRecorder()
+			hdl.download(rec, req)
+			require.Equal(t, http.StatusPartialContent, rec.Code)
+			assert.Equal(t, "ne", rec.Body.String())
+
+			assert.Equal(t, http.StatusNotFound, downloadVersion(hdl, "docs/a.txt", "missing").Code)
+		},
+	)
+
+	t.Run(
+		"Delete And Restore", func(t *testing.T) {
+			dirRequest(t, hdl.deleteFile, http.MethodDelete, "path=docs/a.txt", http.StatusNoContent, nil)
+
+			list := listVersions(t, hdl, "docs/a.txt")
+			require.Len(t, list, 3)
+			assert.True(t, list[0].DeleteMarker)
+			assert.Equal(t, http.StatusNotFound, downloadVersion(hdl, "docs/a.txt", list[0].VersionID).Code)
+
+			query := url.Values{"path": {"docs/a.txt"}, "id": {list[0].VersionID}}.Encode()
+			dirRequest(t, hdl.restoreVersion, http.MethodPost, query, http.StatusConflict, nil)
+
+			res := model.FileRes{}
+			query = url.Values{"path": {"docs/a.txt"}, "id": {list[2].VersionID}}.Encode()
+			dirRequest(t, hdl.restoreVersion, http.MethodPost, query, http.StatusCreated, &res)
+			assert.Equal(t, "/test_uploads/docs/a.txt", res.Path)
+			assert.Equal(t, int64(3), res.Size)
+
+			list = listVersions(t, hdl, "docs/a.txt")
+			require.Len(t, list, 4)
+			rec := downloadVersion(hdl, "docs/a.txt", list[0].VersionID)
+			assert.Equal(t, "one", rec.Body.String())
+
+			dirRequest(t, hdl.restoreVersion, http.MethodPost, "path=docs/a.txt", http.StatusBadRequest, nil)
+			dirRequest(t, hdl.restoreVersion, http.MethodPost, "path=docs/a.txt&id=missing", http.StatusNotFound, nil)
+		},
+	)
+
+	t.Run(
+		"Purge", func(t *testing.T) {
+			list := listVersions(t, hdl, "docs/a.txt")
+
+			query := url.Values{"path": {"docs/a.txt"}, "id": {list[0].VersionID}}.Encode()
+			dirRequest(t, hdl.purgeVersions, http.MethodDelete, query, http.StatusConflict, nil)
+
+			res := model.PurgeRes{}
+			query = url.Values{"path": {"docs/a.txt"}, "id": {list[1].VersionID}}.Encode()
+			dirRequest(t, hdl.purgeVersions, http.MethodDelete, query, http.StatusOK, &res)
+			assert.Equal(t, 1, res.Purged)
+			dirRequest(t, hdl.purgeVersions, http.MethodDelete, query, http.StatusNotFound, nil)
+
+			dirRequest(t, hdl.purgeVersions, http.MethodDelete, "path=docs/a.txt", http.StatusOK, &res)
+			assert.Equal(t, 2, res.Purged)
+			assert.Len(t, listVersions(t, hdl, "docs/a.txt"), 1)
+		},
+	)
+
+	t.Run(
+		"Errors", func(t *testing.T) {
+			dirRequest(t, hdl.listVersions, http.MethodGet, "path=missing", http.StatusNotFound, nil)
+			dirRequest(t, hdl.listVersions, http.MethodGet, "path=../outside", http.StatusBadRequest, nil)
+			dirRequest(t, hdl.listVersions, http.MethodPost, "path=docs", http.StatusMethodNotAllowed, nil)
+			dirRequest(t, hdl.purgeVersions, http.MethodDelete, "path=/", http.StatusBadRequest, nil)
+		},
+	)
+
+	t.Run(
+		"Disabled", func(t *testing.T) {
+			plain := setupTestHandler()
+			dirRequest(t, plain.listVersions, http.MethodGet, "path=docs", http.StatusNotImplemented, nil)
+			dirRequest(t, plain.restoreVersion, http.MethodPost, "path=docs/a.txt&id=x", http.StatusNotImplemented, nil)
+			dirRequest(t, plain.purgeVersions, http.MethodDelete, "path=docs/a.txt", http.StatusNotImplemented, nil)
+			assert.Equal(t, http.StatusNotImplemented, downloadVersion(plain, "docs/a.txt", "x").Code)
+		},
+	)
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"github.com/JMURv/simple-s3/pkg/mediaprobe"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"log"
+)
+
+// videoMetaName is where probed video details are kept in the image cache,
+// next to the variants of the file
+const videoMetaName = "video.json"
+
+const warnNoFastStart = "the moov atom is at the end of the file, so playback can't start until it has been downloaded; " +
+	"remux it with `ffmpeg -i in.mp4 -c copy -movflags +faststart out.mp4`"
+
+// videoMeta returns the container details of a video, probing it when they
+// are missing or stale. Anything mediaprobe doesn't understand has none.
+func (h *Handler) videoMeta(ctx context.Context, info *storage.ObjectInfo) *model.VideoMetaRes {
+	if res := h.cachedVideoMeta(info); res != nil {
+		return res
+	}
+
+	contentType, err := h.sniff(ctx, info.Key, info.Size)
+	if err != nil || !mediaprobe.Supports(contentType) {
+		return nil
+	}
+
+	src := storage.NewReadSeeker(ctx, h.storage, info)
+	defer src.Close()
+
+	probed, err := mediaprobe.Probe(src, info.Size)
+	if err != nil {
+		if !errors.Is(err, mediaprobe.ErrMalformed) && !errors.Is(err, mediaprobe.ErrUnsupported) {
+			log.Printf("Error probing %s: %v\n", info.Key, err)
+		}
+		return nil
+	}
+
+	res := &model.VideoMetaRes{
+		Container:  probed.Container,
+		Duration:   probed.Duration.Seconds(),
+		Width:      probed.Width,
+		Height:     probed.Height,
+		VideoCodec: probed.VideoCodec,
+		AudioCodec: probed.AudioCodec,
+	}
+	if probed.HasFastStart() {
+		res.FastStart = &probed.FastStart
+	}
+	if err = h.images.WriteJSON(info.Key, videoMetaName, res); err != nil {
+		log.Printf("Error caching video details of %s: %v\n", info.Key, err)
+	}
+	return res
+}
+
+// cachedVideoMeta returns the container details of a video if it has
+// already been probed
+func (h *Handler) cachedVideoMeta(info *storage.ObjectInfo) *model.VideoMetaRes {
+	if info.ContentType != "" && !mediaprobe.Supports(info.ContentType) {
+		return nil
+	}
+
+	res := &model.VideoMetaRes{}
+	if err := h.images.ReadJSON(info.Key, videoMetaName, info.ModTime, res); err != nil {
+		return nil
+	}
+	return res
+}
+
+// uploadWarnings points out uploads that are stored but won't behave well,
+// such as MP4 files that can't start playing before they are downloaded
+func uploadWarnings(key string, video *model.VideoMetaRes) []string {
+	var warnings []string
+	if video != nil && video.FastStart != nil && !*video.FastStart {
+		log.Printf("Uploaded %s without faststart\n", key)
+		warnings = append(warnings, warnNoFastStart)
+	}
+	return warnings
+}
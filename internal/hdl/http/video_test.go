@@ -0,0 +1,171 @@
+package http
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/JMURv/simple-s3/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func mp4Box(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	return append(append(binary.BigEndian.AppendUint32(nil, uint32(8+len(body))), typ...), body...)
+}
+
+// testMP4 builds a 12.5 second 1280x720 H.264 file with no samples, with
+// moov in front of or behind mdat
+func testMP4(faststart bool) []byte {
+	be := binary.BigEndian
+	version := []byte{0, 0, 0, 0}
+
+	mvhd := append(be.AppendUint32(be.AppendUint32(append(version, make([]byte, 8)...), 1000), 12500), make([]byte, 80)...)
+	matrix := []uint32{0x10000, 0, 0, 0, 0x10000, 0, 0, 0, 0x40000000, 1280 << 16, 720 << 16}
+	tkhd := append(version, make([]byte, 36)...)
+	for _, v := range matrix {
+		tkhd = be.AppendUint32(tkhd, v)
+	}
+	hdlr := append(append(version, make([]byte, 4)...), "vide\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"...)
+	stsd := append(be.AppendUint32(version, 1), mp4Box("avc1", make([]byte, 28))...)
+
+	moov := mp4Box(
+		"moov", mp4Box("mvhd", mvhd), mp4Box(
+			"trak", mp4Box("tkhd", tkhd), mp4Box(
+				"mdia", mp4Box("hdlr", hdlr), mp4Box("minf", mp4Box("stbl", mp4Box("stsd", stsd))),
+			),
+		),
+	)
+	ftyp := mp4Box("ftyp", []byte("isom\x00\x00\x02\x00isomavc1"))
+	mdat := mp4Box("mdat", make([]byte, 256))
+
+	if faststart {
+		return bytes.Join([][]byte{ftyp, moov, mdat}, nil)
+	}
+	return bytes.Join([][]byte{ftyp, mdat, moov}, nil)
+}
+
+func TestVideoMeta(t *testing.T) {
+	setupTestDir()
+	defer teardownTestDir()
+	hdl := setupTestHandler()
+
+	t.Run(
+		"Faststart Upload", func(t *testing.T) {
+			res := uploadFile(t, hdl, "videos", "clip.mp4", testMP4(true))
+			require.NotNil(t, res.Video)
+			assert.Equal(t, "mp4", res.Video.Container)
+			assert.Equal(t, 12.5, res.Video.Duration)
+			assert.Equal(t, 1280, res.Video.Width)
+			assert.Equal(t, 720, res.Video.Height)
+			assert.Equal(t, "h264", res.Video.VideoCodec)
+			require.NotNil(t, res.Video.FastStart)
+			assert.True(t, *res.Video.FastStart)
+			assert.Empty(t, res.Warnings)
+			assert.Nil(t, res.Placeholder)
+		},
+	)
+
+	t.Run(
+		"Upload Without Faststart", func(t *testing.T) {
+			res := uploadFile(t, hdl, "videos", "slow.mp4", testMP4(false))
+			require.NotNil(t, res.Video)
+			require.NotNil(t, res.Video.FastStart)
+			assert.False(t, *res.Video.FastStart)
+			require.Len(t, res.Warnings, 1)
+			assert.Contains(t, res.Warnings[0], "faststart")
+		},
+	)
+
+	t.Run(
+		"Listed", func(t *testing.T) {
+			uploadFile(t, hdl, "videos", "notes.txt", []byte("not a video"))
+
+			files := listByName(t, hdl, "videos")
+			require.NotNil(t, files["clip.mp4"].Video)
+			assert.Equal(t, 12.5, files["clip.mp4"].Video.Duration)
+			require.NotNil(t, files["slow.mp4"].Video)
+			assert.False(t, *files["slow.mp4"].Video.FastStart)
+			assert.Nil(t, files["notes.txt"].Video)
+		},
+	)
+
+	t.Run(
+		"Probed By Stat", func(t *testing.T) {
+			require.NoError(t, os.MkdirAll(filepath.Join(testDir, "legacy"), os.ModePerm))
+			require.NoError(t, os.WriteFile(filepath.Join(testDir, "legacy", "old.mp4"), testMP4(true), 0644))
+			assert.Nil(t, listByName(t, hdl, "legacy")["old.mp4"].Video)
+
+			res := statFile(t, hdl, "legacy/old.mp4")
+			require.NotNil(t, res.Video)
+			assert.Equal(t, "h264", res.Video.VideoCodec)
+			assert.Nil(t, res.Image)
+			assert.NotNil(t, listByName(t, hdl, "legacy")["old.mp4"].Video)
+		},
+	)
+
+	t.Run(
+		"Other Uploads", func(t *testing.T) {
+			video := testMP4(false)
+
+			rec := httptest.NewRecorder()
+			hdl.initiateMultipart(rec, httptest.NewRequest(http.MethodPost, "/multipart?path=large&filename=multipart.mp4", nil))
+			require.Equal(t, http.StatusCreated, rec.Code)
+			upload := model.MultipartUploadRes{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &upload))
+
+			rec = httptest.NewRecorder()
+			hdl.multipartUpload(rec, httptest.NewRequest(http.MethodPut, "/multipart/"+upload.UploadID+"/1", bytes.NewReader(video)))
+			require.Equal(t, http.StatusOK, rec.Code)
+			part := model.PartRes{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &part))
+
+			body, _ := json.Marshal(&model.CompleteMultipartReq{Parts: []model.PartRes{part}})
+			rec = httptest.NewRecorder()
+			hdl.multipartUpload(rec, httptest.NewRequest(http.MethodPost, "/multipart/"+upload.UploadID+"/complete", bytes.NewReader(body)))
+			require.Equal(t, http.StatusCreated, rec.Code)
+			res := model.FileRes{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			require.NotNil(t, res.Video)
+			require.Len(t, res.Warnings, 1)
+
+			rec = tusRequest(
+				hdl, http.MethodPost, "/tus", nil, map[string]string{
+					"Upload-Length":   strconv.Itoa(len(video)),
+					"Upload-Metadata": tusMetadata("tus.mp4", "large"),
+				},
+			)
+			require.Equal(t, http.StatusCreated, rec.Code)
+			rec = tusRequest(
+				hdl, http.MethodPatch, rec.Header().Get("Location"), bytes.NewReader(video), map[string]string{
+					"Content-Type":  tusContentType,
+					"Upload-Offset": "0",
+				},
+			)
+			require.Equal(t, http.StatusNoContent, rec.Code)
+
+			rec = s3Request(hdl, http.MethodPut, "/"+testBucket+"/large/s3.mp4", bytes.NewReader(video))
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			files := listByName(t, hdl, "large")
+			for _, name := range []string{"multipart.mp4", "tus.mp4", "s3.mp4"} {
+				require.NotNil(t, files[name].Video, name)
+				assert.Equal(t, "h264", files[name].Video.VideoCodec)
+			}
+		},
+	)
+
+	t.Run(
+		"Malformed", func(t *testing.T) {
+			res := uploadFile(t, hdl, "videos", "broken.mp4", testMP4(true)[:120])
+			assert.Nil(t, res.Video)
+			assert.Empty(t, res.Warnings)
+		},
+	)
+}
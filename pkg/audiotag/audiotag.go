@@ -0,0 +1,140 @@
+// Package audiotag reads the tags, duration and embedded cover art of audio
+// files: ID3v1 and ID3v2 in MP3 files, and Vorbis comments in FLAC, Ogg
+// Vorbis and Opus files.
+package audiotag
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+var ErrUnsupported = errors.New("unsupported audio format")
+var ErrMalformed = errors.New("malformed audio file")
+
+const (
+	FormatMP3  = "mp3"
+	FormatFLAC = "flac"
+	FormatOgg  = "ogg"
+	FormatOpus = "opus"
+)
+
+// maxBlockSize bounds how much of a file is held in memory at once, which
+// mostly matters for tags carrying large cover art
+const maxBlockSize = 16 << 20
+
+// pictureFrontCover is the picture type of the front cover in ID3 and FLAC
+const pictureFrontCover = 3
+
+// Tags describes an audio file. Fields the file doesn't carry are empty.
+type Tags struct {
+	Format   string
+	Title    string
+	Artist   string
+	Album    string
+	Genre    string
+	Year     string
+	Track    string
+	Duration time.Duration
+	Cover    *Picture
+}
+
+// Picture is an embedded image, preferably the front cover
+type Picture struct {
+	MIMEType string
+	Data     []byte
+	// front reports whether the picture is tagged as the front cover, which
+	// wins over any other picture
+	front bool
+}
+
+// Supports reports whether Read understands files of a detected media type
+func Supports(contentType string) bool {
+	switch strings.TrimSpace(strings.Split(contentType, ";")[0]) {
+	case "audio/mpeg", "audio/flac", "audio/x-flac", "audio/ogg":
+		return true
+	}
+	return false
+}
+
+// Read parses the tags of the audio file in r, which is size bytes long.
+// Dates such as "2021-05-01" are reduced to their year.
+func Read(r io.ReadSeeker, size int64) (*Tags, error) {
+	t, err := read(r, size)
+	if err != nil {
+		return nil, err
+	}
+	if len(t.Year) > 4 && strings.Trim(t.Year[:4], "0123456789") == "" {
+		t.Year = t.Year[:4]
+	}
+	return t, nil
+}
+
+func read(r io.ReadSeeker, size int64) (*Tags, error) {
+	head, err := readAt(r, 0, min(size, 12))
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return readFLAC(r, 0, size)
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return readOgg(r, size)
+	case bytes.HasPrefix(head, []byte("ID3")):
+		// FLAC files are sometimes written with an ID3v2 tag in front
+		tagSize, err := id3Size(head)
+		if err != nil {
+			return nil, err
+		}
+		if magic, _ := readAt(r, tagSize, min(4, size-tagSize)); string(magic) == "fLaC" {
+			return readFLAC(r, tagSize, size)
+		}
+		return readMP3(r, size)
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		return readMP3(r, size)
+	}
+	return nil, ErrUnsupported
+}
+
+// setCover keeps p unless the tags already hold a front cover
+func (t *Tags) setCover(p *Picture) {
+	if p == nil || len(p.Data) == 0 {
+		return
+	}
+	if t.Cover == nil || (p.front && !t.Cover.front) {
+		t.Cover = p
+	}
+}
+
+// fill copies the fields of other that t is missing
+func (t *Tags) fill(other *Tags) {
+	for dst, src := range map[*string]string{
+		&t.Title: other.Title, &t.Artist: other.Artist, &t.Album: other.Album,
+		&t.Genre: other.Genre, &t.Year: other.Year, &t.Track: other.Track,
+	} {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+}
+
+func readAt(r io.ReadSeeker, offset, length int64) ([]byte, error) {
+	if length < 0 || length > maxBlockSize {
+		return nil, ErrMalformed
+	}
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return nil, ErrMalformed
+		}
+		return nil, err
+	}
+	return buf, nil
+}
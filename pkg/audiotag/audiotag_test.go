@@ -0,0 +1,330 @@
+package audiotag
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+var testCover = []byte("\xFF\xD8\xFF\xE0 not really a jpeg")
+
+func readTags(t *testing.T, data []byte) *Tags {
+	tags, err := Read(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	return tags
+}
+
+func syncsafeBytes(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7F), byte(n >> 14 & 0x7F), byte(n >> 7 & 0x7F), byte(n & 0x7F)}
+}
+
+// id3Tag builds an ID3v2 tag of the given major version from frames made
+// by id3Frame
+func id3Tag(version byte, frames ...[]byte) []byte {
+	body := bytes.Join(frames, nil)
+	return append(append([]byte{'I', 'D', '3', version, 0, 0}, syncsafeBytes(len(body))...), body...)
+}
+
+func id3Frame(version byte, id string, data []byte) []byte {
+	switch version {
+	case 2:
+		return append(append([]byte(id), byte(len(data)>>16), byte(len(data)>>8), byte(len(data))), data...)
+	case 3:
+		return append(append(binary.BigEndian.AppendUint32([]byte(id), uint32(len(data))), 0, 0), data...)
+	}
+	return append(append(append([]byte(id), syncsafeBytes(len(data))...), 0, 0), data...)
+}
+
+func utf16Text(s string) []byte {
+	out := []byte{1, 0xFF, 0xFE}
+	for _, r := range s {
+		out = binary.LittleEndian.AppendUint16(out, uint16(r))
+	}
+	return append(out, 0, 0)
+}
+
+// mpegFrames builds count 128 kbit/s 44.1 kHz MPEG-1 layer III frames of
+// 417 bytes. With frames set, the first one is a Xing header claiming
+// that many frames.
+func mpegFrames(count int, frames uint32) []byte {
+	var out []byte
+	for i := 0; i < count; i++ {
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+		if i == 0 && frames > 0 {
+			copy(frame[36:], "Xing\x00\x00\x00\x01")
+			binary.BigEndian.PutUint32(frame[44:], frames)
+		}
+		out = append(out, frame...)
+	}
+	return out
+}
+
+func id3v1(title string, track, genre byte) []byte {
+	tag := make([]byte, 128)
+	copy(tag, "TAG")
+	copy(tag[3:], title)
+	copy(tag[33:], "V1 Artist")
+	copy(tag[63:], "V1 Album")
+	copy(tag[93:], "1999")
+	tag[126] = track
+	tag[127] = genre
+	return tag
+}
+
+func TestReadMP3(t *testing.T) {
+	t.Run(
+		"ID3v2.3", func(t *testing.T) {
+			apic := append([]byte("\x00image/jpeg\x00\x03cover\x00"), testCover...)
+			other := []byte("\x00image/png\x00\x04back\x00\x89PNG")
+			tag := id3Tag(
+				3,
+				id3Frame(3, "TIT2", []byte("\x00Caf\xE9 Song\x00")),
+				id3Frame(3, "TPE1", utf16Text("Тест")),
+				id3Frame(3, "TCON", []byte("\x00(17)")),
+				id3Frame(3, "TRCK", []byte("\x003/12")),
+				id3Frame(3, "APIC", other),
+				id3Frame(3, "APIC", apic),
+			)
+
+			tags := readTags(t, append(append(tag, mpegFrames(100, 0)...), id3v1("V1 Title", 7, 0)...))
+			assert.Equal(t, FormatMP3, tags.Format)
+			assert.Equal(t, "Café Song", tags.Title)
+			assert.Equal(t, "Тест", tags.Artist)
+			assert.Equal(t, "V1 Album", tags.Album)
+			assert.Equal(t, "Rock", tags.Genre)
+			assert.Equal(t, "3/12", tags.Track)
+			assert.Equal(t, "1999", tags.Year)
+			// 100 frames of 417 bytes at 128 kbit/s
+			audio := 41700.0
+			assert.Equal(t, time.Duration(audio*8/128000*float64(time.Second)), tags.Duration)
+
+			require.NotNil(t, tags.Cover)
+			assert.Equal(t, "image/jpeg", tags.Cover.MIMEType)
+			assert.Equal(t, testCover, tags.Cover.Data)
+		},
+	)
+
+	t.Run(
+		"ID3v2.4 And Xing", func(t *testing.T) {
+			tag := id3Tag(
+				4,
+				id3Frame(4, "TIT2", []byte("\x03Ünïcode\x00")),
+				id3Frame(4, "TDRC", []byte("\x032021-05-01")),
+				id3Frame(4, "TCON", []byte("\x03Synthwave")),
+			)
+
+			tags := readTags(t, append(tag, mpegFrames(3, 1000)...))
+			assert.Equal(t, "Ünïcode", tags.Title)
+			assert.Equal(t, "2021", tags.Year)
+			assert.Equal(t, "Synthwave", tags.Genre)
+			frames := 1000.0
+			assert.Equal(t, time.Duration(frames*1152/44100*float64(time.Second)), tags.Duration)
+		},
+	)
+
+	t.Run(
+		"ID3v2.2", func(t *testing.T) {
+			pic := append([]byte("\x00JPG\x03\x00"), testCover...)
+			tag := id3Tag(2, id3Frame(2, "TT2", []byte("\x00Old")), id3Frame(2, "PIC", pic))
+
+			tags := readTags(t, append(tag, mpegFrames(10, 0)...))
+			assert.Equal(t, "Old", tags.Title)
+			require.NotNil(t, tags.Cover)
+			assert.Equal(t, "image/jpeg", tags.Cover.MIMEType)
+		},
+	)
+
+	t.Run(
+		"ID3v1 Only", func(t *testing.T) {
+			tags := readTags(t, append(mpegFrames(10, 0), id3v1("Plain", 5, 8)...))
+			assert.Equal(t, "Plain", tags.Title)
+			assert.Equal(t, "V1 Artist", tags.Artist)
+			assert.Equal(t, "5", tags.Track)
+			assert.Equal(t, "Jazz", tags.Genre)
+			assert.Nil(t, tags.Cover)
+			assert.Greater(t, tags.Duration, time.Duration(0))
+		},
+	)
+
+	t.Run(
+		"No Frames", func(t *testing.T) {
+			data := append([]byte{0xFF, 0xFB}, make([]byte, 64)...)
+			_, err := Read(bytes.NewReader(data), int64(len(data)))
+			assert.ErrorIs(t, err, ErrMalformed)
+		},
+	)
+}
+
+func vorbisComment(comments ...string) []byte {
+	out := binary.LittleEndian.AppendUint32(nil, 6)
+	out = append(out, "vendor"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(comments)))
+	for _, c := range comments {
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(c)))
+		out = append(out, c...)
+	}
+	return out
+}
+
+func pictureBlock(kind uint32, mimeType string, data []byte) []byte {
+	out := binary.BigEndian.AppendUint32(nil, kind)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(mimeType)))
+	out = append(out, mimeType...)
+	out = binary.BigEndian.AppendUint32(out, 0)
+	out = append(out, make([]byte, 16)...)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(data)))
+	return append(out, data...)
+}
+
+func flacBlock(kind byte, last bool, data []byte) []byte {
+	if last {
+		kind |= flacLastBlock
+	}
+	return append([]byte{kind, byte(len(data) >> 16), byte(len(data) >> 8), byte(len(data))}, data...)
+}
+
+func TestReadFLAC(t *testing.T) {
+	// 441000 samples at 44.1 kHz, stereo, 16 bits
+	info := make([]byte, 34)
+	binary.BigEndian.PutUint64(info[10:], 44100<<44|1<<41|15<<36|441000)
+
+	flac := bytes.Join(
+		[][]byte{
+			[]byte("fLaC"),
+			flacBlock(flacStreamInfo, false, info),
+			flacBlock(1, false, make([]byte, 32)),
+			flacBlock(flacVorbisComment, false, vorbisComment("title=Lossless", "ARTIST=Band", "Album=LP", "DATE=2020", "TRACKNUMBER=2", "bogus")),
+			flacBlock(flacPicture, true, pictureBlock(3, "image/jpeg", testCover)),
+			make([]byte, 64),
+		}, nil,
+	)
+
+	t.Run(
+		"Native", func(t *testing.T) {
+			tags := readTags(t, flac)
+			assert.Equal(t, FormatFLAC, tags.Format)
+			assert.Equal(t, "Lossless", tags.Title)
+			assert.Equal(t, "Band", tags.Artist)
+			assert.Equal(t, "LP", tags.Album)
+			assert.Equal(t, "2020", tags.Year)
+			assert.Equal(t, "2", tags.Track)
+			assert.Equal(t, 10*time.Second, tags.Duration)
+			require.NotNil(t, tags.Cover)
+			assert.Equal(t, testCover, tags.Cover.Data)
+		},
+	)
+
+	t.Run(
+		"Behind ID3", func(t *testing.T) {
+			tags := readTags(t, append(id3Tag(3, id3Frame(3, "TIT2", []byte("\x00Ignored"))), flac...))
+			assert.Equal(t, FormatFLAC, tags.Format)
+			assert.Equal(t, "Lossless", tags.Title)
+		},
+	)
+
+	t.Run(
+		"Truncated", func(t *testing.T) {
+			_, err := Read(bytes.NewReader(flac[:20]), 20)
+			assert.ErrorIs(t, err, ErrMalformed)
+		},
+	)
+}
+
+// buildOggPage builds a page holding whole packets of the stream serial
+func buildOggPage(serial uint32, granule uint64, packets ...[]byte) []byte {
+	var lacing, body []byte
+	for _, p := range packets {
+		n := len(p)
+		for ; n >= 255; n -= 255 {
+			lacing = append(lacing, 255)
+		}
+		lacing = append(lacing, byte(n))
+		body = append(body, p...)
+	}
+
+	header := append([]byte("OggS\x00\x00"), binary.LittleEndian.AppendUint64(nil, granule)...)
+	header = binary.LittleEndian.AppendUint32(header, serial)
+	header = append(header, make([]byte, 8)...)
+	header = append(header, byte(len(lacing)))
+	return append(append(header, lacing...), body...)
+}
+
+func TestReadOgg(t *testing.T) {
+	picture := base64.StdEncoding.EncodeToString(pictureBlock(3, "image/jpeg", testCover))
+
+	t.Run(
+		"Vorbis", func(t *testing.T) {
+			ident := append([]byte("\x01vorbis\x00\x00\x00\x00\x02"), binary.LittleEndian.AppendUint32(nil, 44100)...)
+			ident = append(ident, make([]byte, 14)...)
+			// The comment header is long enough to span pages of 255 byte
+			// segments, and another stream is interleaved
+			comment := append([]byte("\x03vorbis"), vorbisComment("TITLE=Ogg", "METADATA_BLOCK_PICTURE="+picture, "GENRE=Folk")...)
+
+			data := bytes.Join(
+				[][]byte{
+					buildOggPage(1, 0, ident),
+					buildOggPage(2, 0, []byte("other stream")),
+					buildOggPage(1, 0, comment),
+					buildOggPage(1, 44100, make([]byte, 100)),
+					buildOggPage(1, 88200, make([]byte, 100)),
+				}, nil,
+			)
+			tags := readTags(t, data)
+			assert.Equal(t, FormatOgg, tags.Format)
+			assert.Equal(t, "Ogg", tags.Title)
+			assert.Equal(t, "Folk", tags.Genre)
+			assert.Equal(t, 2*time.Second, tags.Duration)
+			require.NotNil(t, tags.Cover)
+			assert.Equal(t, testCover, tags.Cover.Data)
+		},
+	)
+
+	t.Run(
+		"Opus", func(t *testing.T) {
+			ident := append([]byte("OpusHead\x01\x02"), binary.LittleEndian.AppendUint16(nil, 312)...)
+			ident = append(ident, make([]byte, 7)...)
+			comment := append([]byte("OpusTags"), vorbisComment("ARTIST=Podcaster")...)
+
+			data := bytes.Join(
+				[][]byte{
+					buildOggPage(7, 0, ident),
+					buildOggPage(7, 0, comment),
+					buildOggPage(7, 3*opusRate+312, make([]byte, 100)),
+					// A page that finishes no packet has no granule position
+					buildOggPage(7, 1<<64-1, make([]byte, 255)),
+				}, nil,
+			)
+			tags := readTags(t, data)
+			assert.Equal(t, FormatOpus, tags.Format)
+			assert.Equal(t, "Podcaster", tags.Artist)
+			assert.Equal(t, 3*time.Second, tags.Duration)
+		},
+	)
+
+	t.Run(
+		"Unsupported Stream", func(t *testing.T) {
+			data := bytes.Join([][]byte{buildOggPage(1, 0, []byte("\x80theora")), buildOggPage(1, 0, []byte("x"))}, nil)
+			_, err := Read(bytes.NewReader(data), int64(len(data)))
+			assert.ErrorIs(t, err, ErrUnsupported)
+		},
+	)
+}
+
+func TestReadUnsupported(t *testing.T) {
+	data := []byte("RIFF....WAVEfmt ")
+	_, err := Read(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestSupports(t *testing.T) {
+	assert.True(t, Supports("audio/mpeg"))
+	assert.True(t, Supports("audio/flac"))
+	assert.True(t, Supports("audio/ogg"))
+	assert.False(t, Supports("audio/wav"))
+	assert.False(t, Supports("video/mp4"))
+}
@@ -0,0 +1,184 @@
+package audiotag
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	flacStreamInfo    = 0
+	flacVorbisComment = 4
+	flacPicture       = 6
+	flacLastBlock     = 0x80
+)
+
+// readFLAC walks the metadata blocks following the fLaC marker at offset
+func readFLAC(r io.ReadSeeker, offset, size int64) (*Tags, error) {
+	t := &Tags{Format: FormatFLAC}
+	seenInfo := false
+
+	for pos := offset + 4; pos+4 <= size; {
+		header, err := readAt(r, pos, 4)
+		if err != nil {
+			return nil, err
+		}
+		kind := header[0] &^ flacLastBlock
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		if pos+4+length > size {
+			return nil, ErrMalformed
+		}
+
+		switch kind {
+		case flacStreamInfo, flacVorbisComment, flacPicture:
+			block, err := readAt(r, pos+4, length)
+			if err != nil {
+				return nil, err
+			}
+			switch kind {
+			case flacStreamInfo:
+				t.Duration, seenInfo = streamInfoDuration(block), true
+			case flacVorbisComment:
+				readVorbisComment(block, t)
+			case flacPicture:
+				t.setCover(flacPictureBlock(block))
+			}
+		}
+
+		if header[0]&flacLastBlock != 0 {
+			break
+		}
+		pos += 4 + length
+	}
+
+	if !seenInfo {
+		return nil, ErrMalformed
+	}
+	return t, nil
+}
+
+// streamInfoDuration reads the sample rate and the total number of samples
+// packed into bits 80 to 99 and 108 to 143 of STREAMINFO
+func streamInfoDuration(block []byte) time.Duration {
+	if len(block) < 18 {
+		return 0
+	}
+	packed := binary.BigEndian.Uint64(block[10:])
+	rate := packed >> 44
+	samples := packed & (1<<36 - 1)
+	if rate == 0 {
+		return 0
+	}
+	return time.Duration(float64(samples) / float64(rate) * float64(time.Second))
+}
+
+// readVorbisComment parses a Vorbis comment block: a little-endian vendor
+// string followed by KEY=value pairs
+func readVorbisComment(block []byte, t *Tags) {
+	next := func() (string, bool) {
+		if len(block) < 4 {
+			return "", false
+		}
+		n := int(binary.LittleEndian.Uint32(block))
+		if n < 0 || 4+n > len(block) {
+			return "", false
+		}
+		s := string(block[4 : 4+n])
+		block = block[4+n:]
+		return s, true
+	}
+
+	if _, ok := next(); !ok {
+		return
+	}
+	if len(block) < 4 {
+		return
+	}
+	count := int(binary.LittleEndian.Uint32(block))
+	block = block[4:]
+
+	for n := 0; n < count; n++ {
+		comment, ok := next()
+		if !ok {
+			return
+		}
+		key, value, ok := strings.Cut(comment, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		var field *string
+		switch strings.ToUpper(key) {
+		case "TITLE":
+			field = &t.Title
+		case "ARTIST":
+			field = &t.Artist
+		case "ALBUM":
+			field = &t.Album
+		case "GENRE":
+			field = &t.Genre
+		case "DATE", "YEAR":
+			field = &t.Year
+		case "TRACKNUMBER":
+			field = &t.Track
+		case "METADATA_BLOCK_PICTURE":
+			// Ogg files embed a FLAC picture block in base64
+			if data, err := base64.StdEncoding.DecodeString(value); err == nil {
+				t.setCover(flacPictureBlock(data))
+			}
+			continue
+		default:
+			continue
+		}
+		if *field == "" {
+			*field = value
+		}
+	}
+}
+
+// flacPictureBlock decodes a FLAC PICTURE block: the picture type, MIME
+// type, description and size of the image in front of its data
+func flacPictureBlock(block []byte) *Picture {
+	u32 := func() (int, bool) {
+		if len(block) < 4 {
+			return 0, false
+		}
+		v := int(binary.BigEndian.Uint32(block))
+		block = block[4:]
+		return v, v >= 0
+	}
+	bytesOf := func() ([]byte, bool) {
+		n, ok := u32()
+		if !ok || n > len(block) {
+			return nil, false
+		}
+		b := block[:n]
+		block = block[n:]
+		return b, true
+	}
+
+	kind, ok := u32()
+	if !ok {
+		return nil
+	}
+	mimeType, ok := bytesOf()
+	if !ok {
+		return nil
+	}
+	if _, ok = bytesOf(); !ok {
+		return nil
+	}
+	// Width, height, colour depth and palette size
+	if len(block) < 16 {
+		return nil
+	}
+	block = block[16:]
+	data, ok := bytesOf()
+	if !ok {
+		return nil
+	}
+	return &Picture{MIMEType: pictureMIMEType(string(mimeType), data), Data: data, front: kind == pictureFrontCover}
+}
@@ -0,0 +1,317 @@
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	id3HeaderSize  = 10
+	id3v1Size      = 128
+	id3Unsync      = 0x80
+	id3Extended    = 0x40
+	id3Footer      = 0x10
+	id3FrameUnsync = 0x0002
+	// id3DataLength marks v2.4 frames whose data starts with its length
+	id3DataLength = 0x0001
+	// id3Unreadable marks compressed or encrypted frames
+	id3Unreadable = 0x000C
+	// id3Grouping marks v2.4 frames with a group byte in front of the data
+	id3Grouping = 0x0040
+)
+
+// id3Frames maps the text frames of ID3v2.2 and ID3v2.3/4 to the fields
+// they fill
+var id3Frames = map[string]func(*Tags) *string{
+	"TT2":  func(t *Tags) *string { return &t.Title },
+	"TIT2": func(t *Tags) *string { return &t.Title },
+	"TP1":  func(t *Tags) *string { return &t.Artist },
+	"TPE1": func(t *Tags) *string { return &t.Artist },
+	"TAL":  func(t *Tags) *string { return &t.Album },
+	"TALB": func(t *Tags) *string { return &t.Album },
+	"TCO":  func(t *Tags) *string { return &t.Genre },
+	"TCON": func(t *Tags) *string { return &t.Genre },
+	"TYE":  func(t *Tags) *string { return &t.Year },
+	"TYER": func(t *Tags) *string { return &t.Year },
+	"TDRC": func(t *Tags) *string { return &t.Year },
+	"TRK":  func(t *Tags) *string { return &t.Track },
+	"TRCK": func(t *Tags) *string { return &t.Track },
+}
+
+// id3Genres are the genres ID3v1 numbers and ID3v2 refers to as "(n)"
+var id3Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge", "Hip-Hop", "Jazz", "Metal",
+	"New Age", "Oldies", "Other", "Pop", "R&B", "Rap", "Reggae", "Rock", "Techno", "Industrial",
+	"Alternative", "Ska", "Death Metal", "Pranks", "Soundtrack", "Euro-Techno", "Ambient", "Trip-Hop", "Vocal", "Jazz+Funk",
+	"Fusion", "Trance", "Classical", "Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"Alternative Rock", "Bass", "Soul", "Punk", "Space", "Meditative", "Instrumental Pop", "Instrumental Rock", "Ethnic", "Gothic",
+	"Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream", "Southern Rock", "Comedy", "Cult", "Gangsta",
+	"Top 40", "Christian Rap", "Pop/Funk", "Jungle", "Native American", "Cabaret", "New Wave", "Psychedelic", "Rave", "Showtunes",
+	"Trailer", "Lo-Fi", "Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll", "Hard Rock",
+}
+
+// syncsafe decodes a 28-bit integer stored in four bytes of seven bits
+func syncsafe(b []byte) int64 {
+	return int64(b[0]&0x7F)<<21 | int64(b[1]&0x7F)<<14 | int64(b[2]&0x7F)<<7 | int64(b[3]&0x7F)
+}
+
+// id3Size returns the size of the ID3v2 tag at the start of head, header
+// and footer included
+func id3Size(head []byte) (int64, error) {
+	if len(head) < id3HeaderSize || !bytes.HasPrefix(head, []byte("ID3")) {
+		return 0, ErrMalformed
+	}
+	size := id3HeaderSize + syncsafe(head[6:10])
+	if head[5]&id3Footer != 0 {
+		size += id3HeaderSize
+	}
+	return size, nil
+}
+
+// removeUnsync undoes the unsynchronisation scheme, which inserts a zero
+// after every 0xFF that could be mistaken for a frame sync
+func removeUnsync(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xFF, 0x00}, []byte{0xFF})
+}
+
+// readID3v2 parses the ID3v2 tag data holds, starting with its header. The
+// TLEN frame, if any, is returned as the duration.
+func readID3v2(data []byte, t *Tags) time.Duration {
+	version, flags := data[3], data[5]
+	body := data[id3HeaderSize:min(len(data), id3HeaderSize+int(syncsafe(data[6:10])))]
+	if flags&id3Unsync != 0 && version < 4 {
+		body = removeUnsync(body)
+	}
+
+	if flags&id3Extended != 0 && len(body) >= 4 {
+		extended := int(binary.BigEndian.Uint32(body))
+		if version >= 4 {
+			extended = int(syncsafe(body))
+		} else {
+			// v2.3 doesn't count the size field itself
+			extended += 4
+		}
+		if extended > len(body) {
+			return 0
+		}
+		body = body[extended:]
+	}
+
+	idLen, headerLen := 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+
+	var duration time.Duration
+	for len(body) >= headerLen && body[0] != 0 {
+		id := string(body[:idLen])
+		var size int
+		var frameFlags uint16
+		switch version {
+		case 2:
+			size = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(body[4:]))
+			// Compression and encryption sit where v2.4 keeps grouping
+			if body[9]&0xC0 != 0 {
+				frameFlags = id3Unreadable
+			}
+		default:
+			size = int(syncsafe(body[4:]))
+			frameFlags = binary.BigEndian.Uint16(body[8:])
+		}
+		if size < 0 || headerLen+size > len(body) {
+			break
+		}
+		frame := body[headerLen : headerLen+size]
+		body = body[headerLen+size:]
+
+		if frameFlags&id3Unreadable != 0 {
+			continue
+		}
+		if version >= 4 {
+			if frameFlags&id3Grouping != 0 && len(frame) >= 1 {
+				frame = frame[1:]
+			}
+			if frameFlags&id3DataLength != 0 && len(frame) >= 4 {
+				frame = frame[4:]
+			}
+			if frameFlags&id3FrameUnsync != 0 {
+				frame = removeUnsync(frame)
+			}
+		}
+
+		switch {
+		case id3Frames[id] != nil:
+			if field := id3Frames[id](t); *field == "" {
+				*field = id3Text(frame)
+			}
+		case id == "TLEN" || id == "TLE":
+			if ms, err := strconv.Atoi(id3Text(frame)); err == nil && ms > 0 {
+				duration = time.Duration(ms) * time.Millisecond
+			}
+		case id == "APIC":
+			t.setCover(apic(frame))
+		case id == "PIC":
+			t.setCover(pic(frame))
+		}
+	}
+
+	t.Genre = id3Genre(t.Genre)
+	return duration
+}
+
+// id3Text decodes a text frame. Only the first of several values is kept.
+func id3Text(frame []byte) string {
+	if len(frame) == 0 {
+		return ""
+	}
+	text, _ := id3String(frame[0], frame[1:])
+	return strings.TrimSpace(text)
+}
+
+// id3String decodes a string in the given text encoding up to its
+// terminator and returns what follows the terminator
+func id3String(encoding byte, b []byte) (string, []byte) {
+	switch encoding {
+	case 1, 2:
+		// UTF-16, with a byte order mark unless it's big-endian UTF-16BE
+		end := len(b) &^ 1
+		for i := 0; i+1 < len(b); i += 2 {
+			if b[i] == 0 && b[i+1] == 0 {
+				end = i
+				break
+			}
+		}
+		rest := b[min(end+2, len(b)):]
+		s := b[:end]
+
+		var order binary.ByteOrder = binary.BigEndian
+		switch {
+		case bytes.HasPrefix(s, []byte{0xFF, 0xFE}):
+			order, s = binary.LittleEndian, s[2:]
+		case bytes.HasPrefix(s, []byte{0xFE, 0xFF}):
+			s = s[2:]
+		}
+		units := make([]uint16, 0, len(s)/2)
+		for i := 0; i+1 < len(s); i += 2 {
+			units = append(units, order.Uint16(s[i:]))
+		}
+		return string(utf16.Decode(units)), rest
+	}
+
+	end := bytes.IndexByte(b, 0)
+	if end < 0 {
+		end = len(b)
+	}
+	rest := b[min(end+1, len(b)):]
+	if encoding == 3 {
+		return string(b[:end]), rest
+	}
+	return latin1(b[:end]), rest
+}
+
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// id3Genre resolves the numeric genre references of ID3v1 and early ID3v2,
+// written as "(17)", "(17)Rock" or "17"
+func id3Genre(genre string) string {
+	ref := genre
+	if strings.HasPrefix(ref, "(") {
+		end := strings.IndexByte(ref, ')')
+		if end < 0 {
+			return genre
+		}
+		if rest := strings.TrimSpace(ref[end+1:]); rest != "" {
+			return rest
+		}
+		ref = ref[1:end]
+	}
+	if n, err := strconv.Atoi(ref); err == nil && n >= 0 && n < len(id3Genres) {
+		return id3Genres[n]
+	}
+	return genre
+}
+
+// apic decodes an ID3v2.3/4 attached picture
+func apic(frame []byte) *Picture {
+	if len(frame) < 4 {
+		return nil
+	}
+	encoding := frame[0]
+	mimeType, rest := id3String(0, frame[1:])
+	if len(rest) < 1 {
+		return nil
+	}
+	kind := rest[0]
+	_, data := id3String(encoding, rest[1:])
+	return &Picture{MIMEType: pictureMIMEType(mimeType, data), Data: data, front: kind == pictureFrontCover}
+}
+
+// pic decodes an ID3v2.2 attached picture, which names its format with
+// three letters instead of a MIME type
+func pic(frame []byte) *Picture {
+	if len(frame) < 6 {
+		return nil
+	}
+	format := strings.ToLower(string(frame[1:4]))
+	_, data := id3String(frame[0], frame[5:])
+	return &Picture{MIMEType: pictureMIMEType("image/"+format, data), Data: data, front: frame[4] == pictureFrontCover}
+}
+
+// pictureMIMEType trusts the magic bytes of a picture over its declared
+// type, which taggers often get wrong or leave out
+func pictureMIMEType(declared string, data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return "image/jpeg"
+	case bytes.HasPrefix(data, []byte("\x89PNG")):
+		return "image/png"
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		return "image/gif"
+	}
+	declared = strings.ToLower(strings.TrimSpace(declared))
+	if declared == "image/jpg" {
+		return "image/jpeg"
+	}
+	return declared
+}
+
+// readID3v1 parses the fixed 128-byte tag at the end of an MP3 file
+func readID3v1(data []byte) *Tags {
+	if len(data) != id3v1Size || !bytes.HasPrefix(data, []byte("TAG")) {
+		return nil
+	}
+
+	field := func(b []byte) string {
+		if end := bytes.IndexByte(b, 0); end >= 0 {
+			b = b[:end]
+		}
+		return strings.TrimSpace(latin1(b))
+	}
+
+	t := &Tags{
+		Title:  field(data[3:33]),
+		Artist: field(data[33:63]),
+		Album:  field(data[63:93]),
+		Year:   field(data[93:97]),
+	}
+	// ID3v1.1 steals the last two bytes of the comment for the track
+	if comment := data[97:127]; comment[28] == 0 && comment[29] != 0 {
+		t.Track = strconv.Itoa(int(comment[29]))
+	}
+	if genre := int(data[127]); genre < len(id3Genres) {
+		t.Genre = id3Genres[genre]
+	}
+	return t
+}
@@ -0,0 +1,196 @@
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// frameScanLen is how far past the ID3v2 tag the first MPEG frame is
+// looked for
+const frameScanLen = 64 << 10
+
+const (
+	mpeg1  = 3
+	mpeg2  = 2
+	mpeg25 = 0
+	layer1 = 3
+	layer2 = 2
+	layer3 = 1
+)
+
+// mpegBitrates holds the bitrates in kbit/s of MPEG-1 layers I to III and
+// of MPEG-2 and 2.5 layer I and layers II and III
+var mpegBitrates = [5][16]int{
+	{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+	{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+var mpegSampleRates = [3]int{44100, 48000, 32000}
+
+type mpegFrame struct {
+	version    int
+	layer      int
+	bitrate    int
+	sampleRate int
+	padding    bool
+	mono       bool
+}
+
+// parseFrameHeader decodes the four byte header of an MPEG audio frame
+func parseFrameHeader(b []byte) (mpegFrame, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return mpegFrame{}, false
+	}
+
+	f := mpegFrame{version: int(b[1] >> 3 & 3), layer: int(b[1] >> 1 & 3), padding: b[2]&2 != 0, mono: b[3]>>6 == 3}
+	bitrateIndex, rateIndex := int(b[2]>>4), int(b[2]>>2&3)
+	if f.version == 1 || f.layer == 0 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return mpegFrame{}, false
+	}
+
+	table := 3 - f.layer
+	if f.version != mpeg1 {
+		table = 3
+		if f.layer != layer1 {
+			table = 4
+		}
+	}
+	f.bitrate = mpegBitrates[table][bitrateIndex] * 1000
+
+	f.sampleRate = mpegSampleRates[rateIndex]
+	switch f.version {
+	case mpeg2:
+		f.sampleRate /= 2
+	case mpeg25:
+		f.sampleRate /= 4
+	}
+	return f, true
+}
+
+func (f mpegFrame) samplesPerFrame() int {
+	switch {
+	case f.layer == layer1:
+		return 384
+	case f.layer == layer3 && f.version != mpeg1:
+		return 576
+	}
+	return 1152
+}
+
+// length is the size of the frame in bytes, header included
+func (f mpegFrame) length() int {
+	if f.layer == layer1 {
+		return (12*f.bitrate/f.sampleRate + btoi(f.padding)) * 4
+	}
+	return f.samplesPerFrame()/8*f.bitrate/f.sampleRate + btoi(f.padding)
+}
+
+// sideInfoLen is the size of the layer III side information following the
+// header, where encoders put the Xing header
+func (f mpegFrame) sideInfoLen() int {
+	switch {
+	case f.version == mpeg1 && f.mono:
+		return 17
+	case f.version == mpeg1:
+		return 32
+	case f.mono:
+		return 9
+	}
+	return 17
+}
+
+// readMP3 reads the ID3v2 tag in front of the audio, the ID3v1 tag behind
+// it and works out the duration from the first frame
+func readMP3(r io.ReadSeeker, size int64) (*Tags, error) {
+	t := &Tags{Format: FormatMP3}
+	start := int64(0)
+	var tagged time.Duration
+
+	head, err := readAt(r, 0, min(size, id3HeaderSize))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(head, []byte("ID3")) {
+		if start, err = id3Size(head); err != nil {
+			return nil, err
+		}
+		data, err := readAt(r, 0, min(start, size))
+		if err != nil {
+			return nil, err
+		}
+		tagged = readID3v2(data, t)
+	}
+
+	end := size
+	if size-start >= id3v1Size {
+		if data, err := readAt(r, size-id3v1Size, id3v1Size); err == nil {
+			if v1 := readID3v1(data); v1 != nil {
+				t.fill(v1)
+				end -= id3v1Size
+			}
+		}
+	}
+
+	t.Duration = mpegDuration(r, start, end)
+	if t.Duration == 0 {
+		t.Duration = tagged
+	}
+	if t.Duration == 0 && t.Title == "" && t.Artist == "" {
+		return nil, ErrMalformed
+	}
+	return t, nil
+}
+
+// mpegDuration finds the first frame after start and computes the duration
+// from its Xing or VBRI header, or from the bitrate for constant bitrate
+// files without one
+func mpegDuration(r io.ReadSeeker, start, end int64) time.Duration {
+	data, err := readAt(r, start, min(frameScanLen, end-start))
+	if err != nil {
+		return 0
+	}
+
+	for i := 0; i+4 <= len(data); i++ {
+		f, ok := parseFrameHeader(data[i:])
+		if !ok {
+			continue
+		}
+		// A real frame is followed by another one, junk rarely is
+		if next := i + f.length(); next+4 <= len(data) {
+			if _, ok = parseFrameHeader(data[next:]); !ok {
+				continue
+			}
+		}
+		frame := data[i:]
+
+		frames := 0
+		if xing := 4 + f.sideInfoLen(); len(frame) >= xing+12 {
+			tag := string(frame[xing : xing+4])
+			if (tag == "Xing" || tag == "Info") && frame[xing+7]&1 != 0 {
+				frames = int(binary.BigEndian.Uint32(frame[xing+8:]))
+			}
+		}
+		if len(frame) >= 36+18 && string(frame[36:40]) == "VBRI" {
+			frames = int(binary.BigEndian.Uint32(frame[36+14:]))
+		}
+
+		if frames > 0 {
+			return time.Duration(float64(frames) * float64(f.samplesPerFrame()) / float64(f.sampleRate) * float64(time.Second))
+		}
+		audio := end - start - int64(i)
+		return time.Duration(float64(audio) * 8 / float64(f.bitrate) * float64(time.Second))
+	}
+	return 0
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,152 @@
+package audiotag
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+const (
+	oggHeaderSize = 27
+	// oggTailLen is how much of the end of a file is searched for the last
+	// page, whose granule position gives the duration
+	oggTailLen = 64 << 10
+	// opusRate is the rate Opus granule positions count in, whatever the
+	// rate of the input was
+	opusRate = 48000
+)
+
+// oggPage is the part of an Ogg page header that matters here
+type oggPage struct {
+	granule  int64
+	serial   uint32
+	segments []byte
+}
+
+func readOggPage(r io.Reader) (*oggPage, []byte, error) {
+	header := make([]byte, oggHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil, ErrMalformed
+	}
+	if string(header[:4]) != "OggS" {
+		return nil, nil, ErrMalformed
+	}
+
+	p := &oggPage{
+		granule:  int64(binary.LittleEndian.Uint64(header[6:])),
+		serial:   binary.LittleEndian.Uint32(header[14:]),
+		segments: make([]byte, header[26]),
+	}
+	if _, err := io.ReadFull(r, p.segments); err != nil {
+		return nil, nil, ErrMalformed
+	}
+
+	size := 0
+	for _, s := range p.segments {
+		size += int(s)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, nil, ErrMalformed
+	}
+	return p, body, nil
+}
+
+// oggPackets reassembles the first n packets of the first logical stream,
+// which hold the identification and comment headers
+func oggPackets(r io.Reader, n int) ([][]byte, error) {
+	var packets [][]byte
+	var current []byte
+	var serial uint32
+	total := 0
+
+	for first := true; len(packets) < n; first = false {
+		page, body, err := readOggPage(r)
+		if err != nil {
+			return nil, err
+		}
+		if first {
+			serial = page.serial
+		} else if page.serial != serial {
+			continue
+		}
+
+		// A segment shorter than 255 bytes ends a packet
+		offset := 0
+		for _, s := range page.segments {
+			current = append(current, body[offset:offset+int(s)]...)
+			offset += int(s)
+			if s < 255 {
+				packets = append(packets, current)
+				current = nil
+			}
+		}
+
+		if total += len(body); total > maxBlockSize {
+			return nil, ErrMalformed
+		}
+	}
+	return packets[:n], nil
+}
+
+// readOgg reads the Vorbis or Opus headers at the start of an Ogg file and
+// the granule position of its last page
+func readOgg(r io.ReadSeeker, size int64) (*Tags, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	packets, err := oggPackets(bufio.NewReader(r), 2)
+	if err != nil {
+		return nil, err
+	}
+	ident, comment := packets[0], packets[1]
+
+	t := &Tags{}
+	var rate, preSkip int64
+	switch {
+	case bytes.HasPrefix(ident, []byte("\x01vorbis")) && len(ident) >= 16:
+		t.Format = FormatOgg
+		rate = int64(binary.LittleEndian.Uint32(ident[12:]))
+		if !bytes.HasPrefix(comment, []byte("\x03vorbis")) {
+			return nil, ErrMalformed
+		}
+		readVorbisComment(comment[7:], t)
+	case bytes.HasPrefix(ident, []byte("OpusHead")) && len(ident) >= 12:
+		t.Format = FormatOpus
+		rate = opusRate
+		preSkip = int64(binary.LittleEndian.Uint16(ident[10:]))
+		if !bytes.HasPrefix(comment, []byte("OpusTags")) {
+			return nil, ErrMalformed
+		}
+		readVorbisComment(comment[8:], t)
+	default:
+		return nil, ErrUnsupported
+	}
+
+	if granule := lastGranule(r, size); granule > preSkip && rate > 0 {
+		t.Duration = time.Duration(float64(granule-preSkip) / float64(rate) * float64(time.Second))
+	}
+	return t, nil
+}
+
+// lastGranule returns the granule position of the last page that finishes
+// a packet, -1 if none is found near the end of the file
+func lastGranule(r io.ReadSeeker, size int64) int64 {
+	start := max(0, size-oggTailLen)
+	tail, err := readAt(r, start, size-start)
+	if err != nil {
+		return -1
+	}
+
+	for i := bytes.LastIndex(tail, []byte("OggS")); i >= 0; i = bytes.LastIndex(tail[:i], []byte("OggS")) {
+		if i+oggHeaderSize > len(tail) {
+			continue
+		}
+		if granule := int64(binary.LittleEndian.Uint64(tail[i+6:])); granule >= 0 {
+			return granule
+		}
+	}
+	return -1
+}
@@ -3,19 +3,219 @@ package config
 import (
 	"gopkg.in/yaml.v3"
 	"os"
+	"path/filepath"
+	"time"
 )
 
+// DefaultPresignExpiry is the longest lifetime of a presigned URL
+// when PresignConfig.MaxExpiry is not set
+const DefaultPresignExpiry = 24 * time.Hour
+
+// DefaultMinPartSize and DefaultMaxPartSize match the part size limits of S3
+const DefaultMinPartSize = 5 << 20
+const DefaultMaxPartSize = 5 << 30
+
+// DefaultMultipartExpiry is how long an uncompleted multipart upload is kept
+// when MultipartConfig.Expiry is not set
+const DefaultMultipartExpiry = 7 * 24 * time.Hour
+
+// DefaultMaxSkew is the clock skew tolerated between clients and the server
+// when AuthConfig.MaxSkew is not set
+const DefaultMaxSkew = 15 * time.Minute
+
+// DefaultTusExpiry is how long an unfinished resumable upload is kept
+// when TusConfig.Expiry is not set
+const DefaultTusExpiry = 24 * time.Hour
+
+// DefaultImageMaxDimension, DefaultImageMaxPixels and DefaultImageQuality
+// apply to image transforms when ImagesConfig leaves them unset
+const DefaultImageMaxDimension = 4096
+const DefaultImageMaxPixels = 50_000_000
+const DefaultImageQuality = 80
+
+// DefaultThumbnailWorkers and DefaultThumbnailQueue size the thumbnail
+// worker pool when ThumbnailsConfig leaves them unset
+const DefaultThumbnailWorkers = 2
+const DefaultThumbnailQueue = 256
+
+// DefaultExtractMaxEntries and DefaultExtractMaxSize bound the archives
+// unpacked by /upload when ExtractConfig leaves them unset
+const DefaultExtractMaxEntries = 1000
+const DefaultExtractMaxSize = 1 << 30
+
+// DefaultTrashRetention is how long deleted files stay in the trash when
+// TrashConfig.Retention is not set
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// DefaultLifecycleInterval is how often lifecycle rules are applied when
+// LifecycleConfig.Interval is not set
+const DefaultLifecycleInterval = time.Hour
+
 type Config struct {
-	Port     int         `yaml:"port" env-default:"8080"`
-	SavePath string      `yaml:"savePath" env-default:"uploads"`
-	HTTP     *HTTPConfig `yaml:"http"`
+	Port       int               `yaml:"port" env-default:"8080"`
+	SavePath   string            `yaml:"savePath" env-default:"uploads"`
+	TmpPath    string            `yaml:"tmpPath"`
+	HTTP       *HTTPConfig       `yaml:"http"`
+	S3         *S3Config         `yaml:"s3"`
+	Auth       *AuthConfig       `yaml:"auth"`
+	Presign    *PresignConfig    `yaml:"presign"`
+	Multipart  *MultipartConfig  `yaml:"multipart"`
+	Tus        *TusConfig        `yaml:"tus"`
+	Index      *IndexConfig      `yaml:"index"`
+	Images     *ImagesConfig     `yaml:"images"`
+	Thumbnails *ThumbnailsConfig `yaml:"thumbnails"`
+	Extract    *ExtractConfig    `yaml:"extract"`
+	Versioning *VersioningConfig `yaml:"versioning"`
+	Trash      *TrashConfig      `yaml:"trash"`
+	Lifecycle  *LifecycleConfig  `yaml:"lifecycle"`
 }
 
+// DefaultStreamableTypes are the media types served by the stream endpoint
+// when HTTPConfig.StreamableTypes is not set
+var DefaultStreamableTypes = []string{"image/*", "audio/*", "video/*"}
+
+// HTTPConfig tunes the HTTP API. StreamableTypes lists the media types the
+// stream endpoint serves, detected from the content of a file. Entries are
+// exact types, "type/*" wildcards or "*/*".
 type HTTPConfig struct {
-	MaxStreamBuffer int   `yaml:"maxStreamBuffer"`
-	MaxUploadSize   int64 `yaml:"maxUploadSize"`
-	DefaultPage     int   `yaml:"defaultPage"`
-	DefaultSize     int   `yaml:"defaultSize"`
+	MaxStreamBuffer int      `yaml:"maxStreamBuffer"`
+	MaxUploadSize   int64    `yaml:"maxUploadSize"`
+	DefaultPage     int      `yaml:"defaultPage"`
+	DefaultSize     int      `yaml:"defaultSize"`
+	StreamableTypes []string `yaml:"streamableTypes"`
+}
+
+// S3Config configures the S3-compatible API. The whole savePath is exposed
+// as a single bucket with the given name.
+type S3Config struct {
+	Bucket string `yaml:"bucket" env-default:"simple-s3"`
+	Region string `yaml:"region" env-default:"us-east-1"`
+}
+
+// AuthConfig enables AWS Signature V4 authentication. Requests stay
+// anonymous while no credentials are configured.
+type AuthConfig struct {
+	MaxSkew     time.Duration `yaml:"maxSkew"`
+	Credentials []Credential  `yaml:"credentials"`
+}
+
+type Credential struct {
+	AccessKey string `yaml:"accessKey"`
+	SecretKey string `yaml:"secretKey"`
+}
+
+// PresignConfig enables HMAC-signed URLs for uploads, downloads and streams
+type PresignConfig struct {
+	Secret    string        `yaml:"secret"`
+	MaxExpiry time.Duration `yaml:"maxExpiry"`
+}
+
+// MultipartConfig bounds the size of the parts of a multipart upload.
+// Every part except the last one has to be at least MinPartSize. Uploads
+// not completed within Expiry of being initiated are removed.
+type MultipartConfig struct {
+	MinPartSize int64         `yaml:"minPartSize"`
+	MaxPartSize int64         `yaml:"maxPartSize"`
+	Expiry      time.Duration `yaml:"expiry"`
+}
+
+// TusConfig controls resumable uploads. Unfinished uploads are removed
+// once they have not been touched for Expiry.
+type TusConfig struct {
+	Expiry time.Duration `yaml:"expiry"`
+}
+
+// IndexConfig locates the metadata index that serves listings and searches.
+// The index is always rebuilt on startup when it is new; RebuildOnStart
+// also rebuilds an existing one, picking up files changed while the server
+// was down.
+type IndexConfig struct {
+	Path           string `yaml:"path"`
+	RebuildOnStart bool   `yaml:"rebuildOnStart"`
+}
+
+// ImagesConfig controls image processing. Rendered variants are cached under
+// CachePath and dropped when their original is deleted. MaxPixels protects
+// the server from decoding huge originals.
+type ImagesConfig struct {
+	CachePath    string              `yaml:"cachePath"`
+	MaxDimension int                 `yaml:"maxDimension"`
+	MaxPixels    int                 `yaml:"maxPixels"`
+	Quality      int                 `yaml:"quality"`
+	Uploads      []ImageUploadPolicy `yaml:"uploads"`
+}
+
+// ImageUploadPolicy sets how JPEG and PNG uploads below Prefix are processed
+// unless the upload overrides it. The longest matching prefix applies and
+// an empty prefix matches every upload.
+type ImageUploadPolicy struct {
+	Prefix        string `yaml:"prefix"`
+	StripMetadata bool   `yaml:"stripMetadata"`
+	AutoOrient    bool   `yaml:"autoOrient"`
+}
+
+// ThumbnailsConfig names the thumbnail presets rendered in the background
+// after every image upload. Presets take the same options as /img.
+type ThumbnailsConfig struct {
+	Workers   int                        `yaml:"workers"`
+	QueueSize int                        `yaml:"queueSize"`
+	Presets   map[string]ThumbnailPreset `yaml:"presets"`
+}
+
+type ThumbnailPreset struct {
+	Width   int    `yaml:"width"`
+	Height  int    `yaml:"height"`
+	Fit     string `yaml:"fit"`
+	Quality int    `yaml:"quality"`
+	Format  string `yaml:"format"`
+}
+
+// ExtractConfig limits the archives unpacked by /upload?extract=true.
+// MaxEntries counts every file in an archive and MaxSize the total size of
+// the files once unpacked; archives beyond either are refused as a whole.
+type ExtractConfig struct {
+	MaxEntries int   `yaml:"maxEntries"`
+	MaxSize    int64 `yaml:"maxSize"`
+}
+
+// VersioningConfig keeps the previous versions of the objects below
+// Prefixes whenever they are overwritten or deleted. An empty prefix
+// versions every object; no prefixes leaves versioning off. Versions are
+// stored under Path.
+type VersioningConfig struct {
+	Path     string   `yaml:"path"`
+	Prefixes []string `yaml:"prefixes"`
+}
+
+// TrashConfig controls the trash that deleted files are moved to. Files
+// are kept under Path for Retention before they are purged for good.
+// Disabled makes deletes permanent again.
+type TrashConfig struct {
+	Path      string        `yaml:"path"`
+	Retention time.Duration `yaml:"retention"`
+	Disabled  bool          `yaml:"disabled"`
+}
+
+// LifecycleConfig lists the rules applied to stored files every Interval.
+// Archived files are compressed into ArchivePath.
+type LifecycleConfig struct {
+	Interval    time.Duration   `yaml:"interval"`
+	ArchivePath string          `yaml:"archivePath"`
+	Rules       []LifecycleRule `yaml:"rules"`
+}
+
+// LifecycleRule expires or archives the files below Prefix that are at
+// least MinAge old, between MinSize and MaxSize bytes and tagged with all
+// of Tags. Unset conditions match any file, but a rule needs at least one.
+// The first rule matching a file applies. Action is "expire" or "archive".
+type LifecycleRule struct {
+	Name    string            `yaml:"name"`
+	Prefix  string            `yaml:"prefix"`
+	MinAge  time.Duration     `yaml:"minAge"`
+	MinSize int64             `yaml:"minSize"`
+	MaxSize int64             `yaml:"maxSize"`
+	Tags    map[string]string `yaml:"tags"`
+	Action  string            `yaml:"action"`
 }
 
 func MustLoad(configPath string) *Config {
@@ -30,5 +230,84 @@ func MustLoad(configPath string) *Config {
 		panic("failed to unmarshal config: " + err.Error())
 	}
 
+	if conf.TmpPath == "" {
+		conf.TmpPath = DefaultTmpPath(conf.SavePath)
+	}
+
+	if conf.Index == nil {
+		conf.Index = &IndexConfig{}
+	}
+	if conf.Index.Path == "" {
+		conf.Index.Path = DefaultIndexPath(conf.SavePath)
+	}
+
+	if conf.Images == nil {
+		conf.Images = &ImagesConfig{}
+	}
+	if conf.Images.CachePath == "" {
+		conf.Images.CachePath = DefaultImageCachePath(conf.SavePath)
+	}
+
+	if conf.Versioning != nil && conf.Versioning.Path == "" {
+		conf.Versioning.Path = DefaultVersionsPath(conf.SavePath)
+	}
+
+	if conf.Trash == nil {
+		conf.Trash = &TrashConfig{}
+	}
+	if conf.Trash.Path == "" {
+		conf.Trash.Path = DefaultTrashPath(conf.SavePath)
+	}
+	if conf.Trash.Retention <= 0 {
+		conf.Trash.Retention = DefaultTrashRetention
+	}
+
+	if conf.Lifecycle != nil && conf.Lifecycle.ArchivePath == "" {
+		conf.Lifecycle.ArchivePath = DefaultArchivePath(conf.SavePath)
+	}
+
+	if conf.S3 == nil {
+		conf.S3 = &S3Config{}
+	}
+	if conf.S3.Bucket == "" {
+		conf.S3.Bucket = "simple-s3"
+	}
+	if conf.S3.Region == "" {
+		conf.S3.Region = "us-east-1"
+	}
+
 	return &conf
 }
+
+// DefaultTmpPath places the staging area for unfinished uploads beside
+// savePath, so it is never served and stays on the same filesystem
+func DefaultTmpPath(savePath string) string {
+	return filepath.Clean(savePath) + ".tmp"
+}
+
+// DefaultIndexPath keeps the metadata index beside savePath
+func DefaultIndexPath(savePath string) string {
+	return filepath.Clean(savePath) + ".index"
+}
+
+// DefaultImageCachePath keeps rendered image variants beside savePath, in a
+// tree that mirrors it
+func DefaultImageCachePath(savePath string) string {
+	return filepath.Clean(savePath) + ".cache"
+}
+
+// DefaultVersionsPath keeps previous versions of objects beside savePath
+func DefaultVersionsPath(savePath string) string {
+	return filepath.Clean(savePath) + ".versions"
+}
+
+// DefaultTrashPath keeps deleted files beside savePath
+func DefaultTrashPath(savePath string) string {
+	return filepath.Clean(savePath) + ".trash"
+}
+
+// DefaultArchivePath keeps the files archived by lifecycle rules beside
+// savePath
+func DefaultArchivePath(savePath string) string {
+	return filepath.Clean(savePath) + ".archive"
+}
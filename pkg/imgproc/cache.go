@@ -0,0 +1,188 @@
+package imgproc
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var ErrCacheMiss = errors.New("variant is not cached")
+
+// placeholderName can't clash with a variant, whose names start with the size
+const placeholderName = "placeholder.json"
+
+// Cache keeps rendered variants on disk. The variants of an object live in a
+// directory named after its key, mirroring the layout of savePath.
+type Cache struct {
+	dir string
+}
+
+func NewCache(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+func (c *Cache) variantDir(key string) (string, error) {
+	if !storage.ValidKey(key) {
+		return "", storage.ErrInvalidKey
+	}
+	return filepath.Join(c.dir, filepath.FromSlash(key)), nil
+}
+
+// Open returns a cached variant of key. Variants rendered before the
+// original was last modified are stale and reported as ErrCacheMiss.
+func (c *Cache) Open(key string, o Options, modTime time.Time) (*os.File, os.FileInfo, error) {
+	return c.OpenFile(key, o.Name(), modTime)
+}
+
+// Store renders a variant of key into the cache and opens it. The variant
+// is written to a temporary file first, so concurrent renders never expose
+// a partial file.
+func (c *Cache) Store(key string, o Options, render func(io.Writer) error) (*os.File, os.FileInfo, error) {
+	return c.StoreFile(key, o.Name(), render)
+}
+
+// OpenFile opens any other file derived from key that StoreFile cached
+// under name, such as embedded cover art. Staleness works as in Open.
+func (c *Cache) OpenFile(key, name string, modTime time.Time) (*os.File, os.FileInfo, error) {
+	dir, err := c.variantDir(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrCacheMiss
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if info.ModTime().Before(modTime) {
+		file.Close()
+		return nil, nil, ErrCacheMiss
+	}
+	return file, info, nil
+}
+
+// StoreFile renders a file derived from key into the cache under name,
+// which must not look like a variant name, and opens it
+func (c *Cache) StoreFile(key, name string, render func(io.Writer) error) (*os.File, os.FileInfo, error) {
+	name, err := c.write(key, name, render)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, info, nil
+}
+
+// Placeholder returns the cached placeholder of key, with the same
+// staleness rules as Open
+func (c *Cache) Placeholder(key string, modTime time.Time) (*Placeholder, error) {
+	p := &Placeholder{}
+	if err := c.ReadJSON(key, placeholderName, modTime, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// StorePlaceholder caches the placeholder of key next to its variants
+func (c *Cache) StorePlaceholder(key string, p *Placeholder) error {
+	return c.WriteJSON(key, placeholderName, p)
+}
+
+// ReadJSON decodes other data derived from key, such as probed media
+// details, that WriteJSON cached under name. Staleness works as in Open.
+func (c *Cache) ReadJSON(key, name string, modTime time.Time, v any) error {
+	file, _, err := c.OpenFile(key, name, modTime)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return json.NewDecoder(file).Decode(v)
+}
+
+// WriteJSON caches v as data derived from key under name, which must not
+// look like a variant name. It is dropped by Invalidate like a variant.
+func (c *Cache) WriteJSON(key, name string, v any) error {
+	_, err := c.write(
+		key, name, func(w io.Writer) error {
+			return json.NewEncoder(w).Encode(v)
+		},
+	)
+	return err
+}
+
+// write renders name into the directory of key through a temporary file
+// and returns its path
+func (c *Cache) write(key, name string, render func(io.Writer) error) (string, error) {
+	dir, err := c.variantDir(key)
+	if err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".render-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = render(tmp); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err = tmp.Close(); err != nil {
+		return "", err
+	}
+
+	name = filepath.Join(dir, name)
+	return name, os.Rename(tmp.Name(), name)
+}
+
+// Invalidate drops every cached variant of key. Variants of keys nested
+// below it, which share the directory, are left alone.
+func (c *Cache) Invalidate(key string) error {
+	dir, err := c.variantDir(key)
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if err = os.Remove(filepath.Join(dir, entry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	// Only succeeds once nothing nested is cached any more
+	_ = os.Remove(dir)
+	return nil
+}
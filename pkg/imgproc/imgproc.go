@@ -0,0 +1,223 @@
+// Package imgproc processes stored images: it derives resized, cropped and
+// converted variants, kept in a disk cache so every distinct set of options
+// is only rendered once, computes placeholders, reads image metadata and
+// strips it from uploads.
+package imgproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/disintegration/imaging"
+	"image"
+	"io"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FitContain scales the image down to fit inside the requested box
+	FitContain = "contain"
+	// FitCover scales and crops the image to fill the requested box
+	FitCover = "cover"
+	// FitFill stretches the image to exactly the requested size
+	FitFill = "fill"
+)
+
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatGIF  = "gif"
+)
+
+var ErrInvalidOptions = errors.New("invalid transform options")
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+var ErrTooLarge = errors.New("image is too large")
+
+// Options describe a variant of an image. A zero Width or Height keeps the
+// aspect ratio of the original; an empty Format keeps its format.
+type Options struct {
+	Width   int
+	Height  int
+	Fit     string
+	Quality int
+	Format  string
+}
+
+// Limits bound the work a single transform may cause
+type Limits struct {
+	MaxDimension int
+	MaxPixels    int
+	Quality      int
+}
+
+// ParseOptions reads the w, h, fit, q and format query parameters
+func ParseOptions(q url.Values, limits Limits) (Options, error) {
+	o := Options{Fit: FitContain, Quality: limits.Quality}
+
+	var err error
+	if o.Width, err = dimension(q.Get("w"), limits.MaxDimension); err != nil {
+		return Options{}, err
+	}
+	if o.Height, err = dimension(q.Get("h"), limits.MaxDimension); err != nil {
+		return Options{}, err
+	}
+
+	if fit := strings.ToLower(q.Get("fit")); fit != "" {
+		o.Fit = fit
+	}
+	switch o.Fit {
+	case FitContain:
+	case FitCover, FitFill:
+		if o.Width == 0 || o.Height == 0 {
+			return Options{}, fmt.Errorf("%w: fit=%s needs both w and h", ErrInvalidOptions, o.Fit)
+		}
+	default:
+		return Options{}, fmt.Errorf("%w: unknown fit %q", ErrInvalidOptions, o.Fit)
+	}
+
+	if v := q.Get("q"); v != "" {
+		if o.Quality, err = strconv.Atoi(v); err != nil || o.Quality < 1 || o.Quality > 100 {
+			return Options{}, fmt.Errorf("%w: q must be between 1 and 100", ErrInvalidOptions)
+		}
+	}
+
+	if v := q.Get("format"); v != "" {
+		if o.Format = NormalizeFormat(v); o.Format == "" {
+			return Options{}, fmt.Errorf("%w: %q", ErrUnsupportedFormat, v)
+		}
+	}
+	return o, nil
+}
+
+func dimension(v string, max int) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > max {
+		return 0, fmt.Errorf("%w: dimensions must be between 1 and %d", ErrInvalidOptions, max)
+	}
+	return n, nil
+}
+
+// NormalizeFormat maps a format name or extension to one of the supported
+// formats, returning an empty string for anything else
+func NormalizeFormat(v string) string {
+	switch strings.TrimPrefix(strings.ToLower(v), ".") {
+	case "jpeg", "jpg":
+		return FormatJPEG
+	case "png":
+		return FormatPNG
+	case "gif":
+		return FormatGIF
+	}
+	return ""
+}
+
+// FormatOf returns the format of a detected media type
+func FormatOf(contentType string) string {
+	switch strings.TrimSpace(strings.Split(contentType, ";")[0]) {
+	case "image/jpeg":
+		return FormatJPEG
+	case "image/png":
+		return FormatPNG
+	case "image/gif":
+		return FormatGIF
+	}
+	return ""
+}
+
+// ContentType returns the media type of a supported format
+func ContentType(format string) string {
+	return "image/" + format
+}
+
+// Name identifies a variant in the cache. Quality only matters for JPEG,
+// so it is left out for the other formats.
+func (o Options) Name() string {
+	name := fmt.Sprintf("%dx%d-%s", o.Width, o.Height, o.Fit)
+	if o.Format == FormatJPEG {
+		name += fmt.Sprintf("-q%d", o.Quality)
+	}
+	return name + "." + o.Format
+}
+
+// Transform decodes the image in r, applies o and encodes the result to w.
+// Images with more than maxPixels pixels are rejected before decoding.
+// Animated GIFs are reduced to their first frame.
+func Transform(w io.Writer, r io.Reader, o Options, maxPixels int) error {
+	img, err := Decode(r, maxPixels)
+	if err != nil {
+		return err
+	}
+	return Encode(w, Resize(img, o), o)
+}
+
+// Decode reads an image upright, refusing it when it has more than
+// maxPixels pixels
+func Decode(r io.Reader, maxPixels int) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return decode(data, maxPixels)
+}
+
+// decode is Decode for an image already read into memory
+func decode(data []byte, maxPixels int) (image.Image, error) {
+	conf, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	if maxPixels > 0 && conf.Width*conf.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	// Variants carry no EXIF, so sideways photos have to be turned upright
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	return img, nil
+}
+
+// Resize scales img according to the size and fit of o
+func Resize(img image.Image, o Options) image.Image {
+	if o.Width == 0 && o.Height == 0 {
+		return img
+	}
+
+	switch o.Fit {
+	case FitCover:
+		return imaging.Fill(img, o.Width, o.Height, imaging.Center, imaging.Lanczos)
+	case FitFill:
+		return imaging.Resize(img, o.Width, o.Height, imaging.Lanczos)
+	default:
+		// Fit never enlarges; an unbounded side follows the aspect ratio
+		width, height := o.Width, o.Height
+		if width == 0 {
+			width = math.MaxInt32
+		}
+		if height == 0 {
+			height = math.MaxInt32
+		}
+		return imaging.Fit(img, width, height, imaging.Lanczos)
+	}
+}
+
+// Encode writes img in the format of o
+func Encode(w io.Writer, img image.Image, o Options) error {
+	switch o.Format {
+	case FormatJPEG:
+		return imaging.Encode(w, img, imaging.JPEG, imaging.JPEGQuality(o.Quality))
+	case FormatPNG:
+		return imaging.Encode(w, img, imaging.PNG)
+	case FormatGIF:
+		return imaging.Encode(w, img, imaging.GIF)
+	}
+	return ErrUnsupportedFormat
+}
@@ -0,0 +1,237 @@
+package imgproc
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var limits = Limits{MaxDimension: 1000, MaxPixels: 10_000, Quality: 80}
+
+func testImage(t *testing.T, width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected Options
+		err      error
+	}{
+		{"Defaults", "", Options{Fit: FitContain, Quality: 80}, nil},
+		{"Width Only", "w=200", Options{Width: 200, Fit: FitContain, Quality: 80}, nil},
+		{"Cover", "w=200&h=100&fit=cover&q=60&format=jpg", Options{Width: 200, Height: 100, Fit: FitCover, Quality: 60, Format: FormatJPEG}, nil},
+		{"Cover Without Height", "w=200&fit=cover", Options{}, ErrInvalidOptions},
+		{"Unknown Fit", "w=200&fit=zoom", Options{}, ErrInvalidOptions},
+		{"Too Wide", "w=1001", Options{}, ErrInvalidOptions},
+		{"Negative", "h=-1", Options{}, ErrInvalidOptions},
+		{"Bad Quality", "q=101", Options{}, ErrInvalidOptions},
+		{"Unknown Format", "format=webp", Options{}, ErrUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				q, err := url.ParseQuery(tt.query)
+				require.NoError(t, err)
+
+				o, err := ParseOptions(q, limits)
+				assert.ErrorIs(t, err, tt.err)
+				assert.Equal(t, tt.expected, o)
+			},
+		)
+	}
+}
+
+func TestName(t *testing.T) {
+	assert.Equal(t, "200x0-contain-q80.jpeg", Options{Width: 200, Fit: FitContain, Quality: 80, Format: FormatJPEG}.Name())
+	assert.Equal(t, "20x10-cover.png", Options{Width: 20, Height: 10, Fit: FitCover, Quality: 80, Format: FormatPNG}.Name())
+}
+
+func TestTransform(t *testing.T) {
+	src := testImage(t, 80, 40)
+
+	tests := []struct {
+		name          string
+		opts          Options
+		width, height int
+	}{
+		{"Contain", Options{Width: 20, Height: 20, Fit: FitContain}, 20, 10},
+		{"Contain Height Only", Options{Height: 10, Fit: FitContain}, 20, 10},
+		{"Contain Never Enlarges", Options{Width: 200, Fit: FitContain}, 80, 40},
+		{"Cover", Options{Width: 20, Height: 20, Fit: FitCover}, 20, 20},
+		{"Fill", Options{Width: 30, Height: 30, Fit: FitFill}, 30, 30},
+		{"Original Size", Options{Fit: FitContain}, 80, 40},
+	}
+
+	for _, format := range []string{FormatJPEG, FormatPNG, FormatGIF} {
+		for _, tt := range tests {
+			t.Run(
+				format+" "+tt.name, func(t *testing.T) {
+					tt.opts.Format = format
+					tt.opts.Quality = 80
+
+					var buf bytes.Buffer
+					require.NoError(t, Transform(&buf, bytes.NewReader(src), tt.opts, limits.MaxPixels))
+
+					conf, decoded, err := image.DecodeConfig(&buf)
+					require.NoError(t, err)
+					assert.Equal(t, format, decoded)
+					assert.Equal(t, tt.width, conf.Width)
+					assert.Equal(t, tt.height, conf.Height)
+				},
+			)
+		}
+	}
+
+	t.Run(
+		"Orientation", func(t *testing.T) {
+			rotated := testJPEG(t, 40, 20, exifSegment(testEXIF(6, false)))
+
+			var buf bytes.Buffer
+			require.NoError(t, Transform(&buf, bytes.NewReader(rotated), Options{Fit: FitContain, Format: FormatPNG}, limits.MaxPixels))
+
+			conf, err := png.DecodeConfig(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, 20, conf.Width)
+			assert.Equal(t, 40, conf.Height)
+		},
+	)
+
+	t.Run(
+		"Too Large", func(t *testing.T) {
+			err := Transform(io.Discard, bytes.NewReader(testImage(t, 200, 100)), Options{Format: FormatPNG}, limits.MaxPixels)
+			assert.ErrorIs(t, err, ErrTooLarge)
+		},
+	)
+
+	t.Run(
+		"Not An Image", func(t *testing.T) {
+			err := Transform(io.Discard, bytes.NewReader([]byte("plain text")), Options{Format: FormatPNG}, limits.MaxPixels)
+			assert.ErrorIs(t, err, ErrUnsupportedFormat)
+		},
+	)
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewCache(dir)
+	o := Options{Width: 10, Fit: FitContain, Format: FormatPNG}
+	modTime := time.Now().Add(-time.Minute)
+
+	_, _, err := cache.Open("a/b.png", o, modTime)
+	assert.ErrorIs(t, err, ErrCacheMiss)
+
+	file, _, err := cache.Store(
+		"a/b.png", o, func(w io.Writer) error {
+			_, err := w.Write([]byte("variant"))
+			return err
+		},
+	)
+	require.NoError(t, err)
+	file.Close()
+	assert.FileExists(t, filepath.Join(dir, "a", "b.png", o.Name()))
+
+	t.Run(
+		"Hit", func(t *testing.T) {
+			file, _, err := cache.Open("a/b.png", o, modTime)
+			require.NoError(t, err)
+			defer file.Close()
+
+			data, err := io.ReadAll(file)
+			require.NoError(t, err)
+			assert.Equal(t, "variant", string(data))
+		},
+	)
+
+	t.Run(
+		"Stale", func(t *testing.T) {
+			_, _, err := cache.Open("a/b.png", o, time.Now().Add(time.Minute))
+			assert.ErrorIs(t, err, ErrCacheMiss)
+		},
+	)
+
+	t.Run(
+		"Failed Render", func(t *testing.T) {
+			failed := Options{Width: 20, Fit: FitContain, Format: FormatPNG}
+			_, _, err := cache.Store(
+				"a/b.png", failed, func(w io.Writer) error {
+					w.Write([]byte("partial"))
+					return ErrTooLarge
+				},
+			)
+			assert.ErrorIs(t, err, ErrTooLarge)
+			assert.NoFileExists(t, filepath.Join(dir, "a", "b.png", failed.Name()))
+		},
+	)
+
+	t.Run(
+		"Placeholder", func(t *testing.T) {
+			_, err := cache.Placeholder("a/b.png", modTime)
+			assert.ErrorIs(t, err, ErrCacheMiss)
+
+			p := &Placeholder{BlurHash: "L00000fQfQfQfQfQfQfQfQfQfQfQ", Preview: "data:image/png;base64,", Color: "#000000"}
+			require.NoError(t, cache.StorePlaceholder("a/b.png", p))
+
+			cached, err := cache.Placeholder("a/b.png", modTime)
+			require.NoError(t, err)
+			assert.Equal(t, p, cached)
+
+			_, err = cache.Placeholder("a/b.png", time.Now().Add(time.Minute))
+			assert.ErrorIs(t, err, ErrCacheMiss)
+		},
+	)
+
+	t.Run(
+		"Invalidate", func(t *testing.T) {
+			nested, _, err := cache.Store(
+				"a", o, func(w io.Writer) error {
+					_, err := w.Write([]byte("parent"))
+					return err
+				},
+			)
+			require.NoError(t, err)
+			nested.Close()
+
+			require.NoError(t, cache.Invalidate("a"))
+			_, _, err = cache.Open("a", o, modTime)
+			assert.ErrorIs(t, err, ErrCacheMiss)
+
+			file, _, err := cache.Open("a/b.png", o, modTime)
+			require.NoError(t, err)
+			file.Close()
+
+			require.NoError(t, cache.Invalidate("a/b.png"))
+			_, err = os.Stat(filepath.Join(dir, "a", "b.png"))
+			assert.True(t, os.IsNotExist(err))
+			assert.NoError(t, cache.Invalidate("missing.png"))
+		},
+	)
+
+	t.Run(
+		"Invalid Key", func(t *testing.T) {
+			_, _, err := cache.Open("../escape.png", o, modTime)
+			assert.Error(t, err)
+			assert.Error(t, cache.Invalidate("../escape.png"))
+		},
+	)
+}
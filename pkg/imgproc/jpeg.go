@@ -0,0 +1,178 @@
+package imgproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var errMalformedJPEG = errors.New("malformed jpeg")
+
+const (
+	markerSOI  = 0xD8
+	markerEOI  = 0xD9
+	markerSOS  = 0xDA
+	markerAPP0 = 0xE0
+	markerAPP1 = 0xE1
+	markerAPP2 = 0xE2
+	markerAPPE = 0xEE
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+var iccHeader = []byte("ICC_PROFILE\x00")
+
+// segment is a marker segment of a JPEG file. Raw holds the marker and the
+// length, payload is what follows them.
+type segment struct {
+	marker  byte
+	raw     []byte
+	payload []byte
+}
+
+// jpegFile splits a JPEG file into the marker segments in front of the first
+// scan, the scans up to and including EOI, and whatever trails EOI. Phones
+// append secondary images there, each with its own EXIF block.
+type jpegFile struct {
+	segments []segment
+	scans    []byte
+	trailer  []byte
+}
+
+// parseJPEG splits data into its segments. A truncated file yields the
+// segments read so far, which is enough to inspect its metadata.
+func parseJPEG(data []byte) (*jpegFile, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != markerSOI {
+		return nil, errMalformedJPEG
+	}
+
+	f := &jpegFile{}
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return f, errMalformedJPEG
+		}
+		marker := data[i+1]
+		if marker == 0xFF {
+			i++
+			continue
+		}
+		if marker == markerSOS {
+			end := scanEnd(data, i)
+			f.scans, f.trailer = data[i:end], data[end:]
+			return f, nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return f, errMalformedJPEG
+		}
+		f.segments = append(
+			f.segments, segment{
+				marker:  marker,
+				raw:     data[i : i+2+length],
+				payload: data[i+4 : i+2+length],
+			},
+		)
+		i += 2 + length
+	}
+	return f, errMalformedJPEG
+}
+
+// scanEnd returns the offset just past the EOI marker, skipping the entropy
+// coded data and the table segments between progressive scans
+func scanEnd(data []byte, i int) int {
+	for i+1 < len(data) {
+		if data[i] != 0xFF {
+			i++
+			continue
+		}
+
+		marker := data[i+1]
+		switch {
+		case marker == 0x00, marker == 0xFF, marker >= 0xD0 && marker <= 0xD7:
+			// Stuffed byte, fill byte or restart marker inside a scan
+			i++
+		case marker == markerEOI:
+			return i + 2
+		default:
+			if i+4 > len(data) {
+				return len(data)
+			}
+			i += 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		}
+	}
+	return len(data)
+}
+
+// exif returns the TIFF block of the EXIF segment, if there is one
+func (f *jpegFile) exif() []byte {
+	for _, s := range f.segments {
+		if s.marker == markerAPP1 && bytes.HasPrefix(s.payload, exifHeader) {
+			return s.payload[len(exifHeader):]
+		}
+	}
+	return nil
+}
+
+// keepWhenStripping reports whether a segment survives metadata stripping.
+// Everything but JFIF, the ICC profile and the Adobe colour transform
+// flag among the application segments is dropped, as are comments.
+func keepWhenStripping(s segment) bool {
+	switch {
+	case s.marker == markerAPP0, s.marker == markerAPPE:
+		return true
+	case s.marker == markerAPP2:
+		return bytes.HasPrefix(s.payload, iccHeader)
+	case s.marker > markerAPP0 && s.marker <= 0xEF, s.marker == 0xFE:
+		return false
+	}
+	return true
+}
+
+// orientationTag locates the value of the orientation tag in IFD0 of a
+// TIFF block, returning its offset and the byte order of the block
+func orientationTag(tiff []byte) (int, binary.ByteOrder, bool) {
+	if len(tiff) < 8 {
+		return 0, nil, false
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, nil, false
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0, nil, false
+	}
+
+	entries := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < entries; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		// A SHORT value is stored in the first two bytes of the value field
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			return entry + 8, order, true
+		}
+	}
+	return 0, nil, false
+}
+
+// orientation reads the EXIF orientation of a TIFF block, 1 when it has none
+func orientation(tiff []byte) int {
+	offset, order, ok := orientationTag(tiff)
+	if !ok {
+		return 1
+	}
+	if v := int(order.Uint16(tiff[offset:])); v >= 1 && v <= 8 {
+		return v
+	}
+	return 1
+}
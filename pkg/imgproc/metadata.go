@@ -0,0 +1,109 @@
+package imgproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/rwcarlsen/goexif/exif"
+	"image"
+	"strings"
+	"time"
+)
+
+// MetadataScanLen is how much of a file ReadMetadata needs. EXIF and the
+// image header sit at the start of every supported format.
+const MetadataScanLen = 1 << 20
+
+// Metadata describes a stored image. Width and Height are the displayed
+// size, with the EXIF orientation applied.
+type Metadata struct {
+	Format      string
+	Width       int
+	Height      int
+	Orientation int
+	Make        string
+	Model       string
+	LensModel   string
+	Software    string
+	Taken       time.Time
+	HasLocation bool
+}
+
+// ReadMetadata extracts the dimensions and the EXIF details of the image
+// whose leading bytes are head
+func ReadMetadata(head []byte) (*Metadata, error) {
+	conf, format, err := image.DecodeConfig(bytes.NewReader(head))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Metadata{Format: format, Width: conf.Width, Height: conf.Height, Orientation: 1}
+
+	tiff := exifBlock(head)
+	if tiff == nil {
+		return m, nil
+	}
+
+	m.Orientation = orientation(tiff)
+	if m.Orientation >= 5 {
+		m.Width, m.Height = m.Height, m.Width
+	}
+
+	// Maker notes and GPS blocks are often damaged; whatever parsed is kept
+	x, _ := exif.Decode(bytes.NewReader(tiff))
+	if x == nil {
+		return m, nil
+	}
+	m.Make = exifString(x, exif.Make)
+	m.Model = exifString(x, exif.Model)
+	m.LensModel = exifString(x, exif.LensModel)
+	m.Software = exifString(x, exif.Software)
+	if taken, err := x.DateTime(); err == nil {
+		m.Taken = taken
+	}
+	if _, err := x.Get(exif.GPSLatitude); err == nil {
+		m.HasLocation = true
+	}
+	return m, nil
+}
+
+// exifBlock finds the TIFF block holding the EXIF data of a JPEG or PNG
+func exifBlock(head []byte) []byte {
+	if bytes.HasPrefix(head, []byte{0xFF, markerSOI}) {
+		// The metadata segments come first, so a cut-off scan doesn't matter
+		if f, _ := parseJPEG(head); f != nil {
+			return f.exif()
+		}
+		return nil
+	}
+
+	if !bytes.HasPrefix(head, pngSignature) {
+		return nil
+	}
+	for i := len(pngSignature); i+12 <= len(head); {
+		length := int(binary.BigEndian.Uint32(head[i:]))
+		end := i + 12 + length
+		if end > len(head) {
+			return nil
+		}
+		switch string(head[i+4 : i+8]) {
+		case "eXIf":
+			return head[i+8 : i+8+length]
+		case "IDAT":
+			return nil
+		}
+		i = end
+	}
+	return nil
+}
+
+func exifString(x *exif.Exif, name exif.FieldName) string {
+	tag, err := x.Get(name)
+	if err != nil {
+		return ""
+	}
+	v, err := tag.StringVal()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(v, "\x00"))
+}
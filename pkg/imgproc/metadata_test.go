@@ -0,0 +1,199 @@
+package imgproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+	"time"
+)
+
+type tiffEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	data  []byte
+}
+
+func asciiEntry(tag uint16, v string) tiffEntry {
+	return tiffEntry{tag: tag, typ: 2, count: uint32(len(v) + 1), data: []byte(v + "\x00")}
+}
+
+func shortEntry(tag uint16, v uint16) tiffEntry {
+	return tiffEntry{tag: tag, typ: 3, count: 1, data: binary.BigEndian.AppendUint16(nil, v)}
+}
+
+func longEntry(tag uint16, v uint32) tiffEntry {
+	return tiffEntry{tag: tag, typ: 4, count: 1, data: binary.BigEndian.AppendUint32(nil, v)}
+}
+
+// writeIFD appends a big-endian IFD followed by the values that don't fit
+// into its entries
+func writeIFD(buf *bytes.Buffer, entries []tiffEntry) {
+	dataStart := buf.Len() + 2 + 12*len(entries) + 4
+	var data []byte
+
+	buf.Write(binary.BigEndian.AppendUint16(nil, uint16(len(entries))))
+	for _, e := range entries {
+		buf.Write(binary.BigEndian.AppendUint16(nil, e.tag))
+		buf.Write(binary.BigEndian.AppendUint16(nil, e.typ))
+		buf.Write(binary.BigEndian.AppendUint32(nil, e.count))
+		if len(e.data) <= 4 {
+			buf.Write(append(bytes.Clone(e.data), make([]byte, 4-len(e.data))...))
+			continue
+		}
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(dataStart+len(data))))
+		data = append(data, e.data...)
+		if len(data)%2 == 1 {
+			data = append(data, 0)
+		}
+	}
+	buf.Write([]byte{0, 0, 0, 0})
+	buf.Write(data)
+}
+
+// testEXIF builds the TIFF block of an EXIF segment. Sub-IFDs are written
+// first, so IFD0 can point at them.
+func testEXIF(orientation uint16, gps bool) []byte {
+	buf := bytes.NewBufferString("MM\x00\x2A\x00\x00\x00\x00")
+
+	exifIFD := buf.Len()
+	writeIFD(buf, []tiffEntry{asciiEntry(0x9003, "2023:06:15 14:30:00"), asciiEntry(0xA434, "Wide 26mm")})
+
+	ifd0 := []tiffEntry{
+		asciiEntry(0x010F, "Acme"),
+		asciiEntry(0x0110, "Phone X"),
+		shortEntry(0x0112, orientation),
+		asciiEntry(0x0131, "Camera 1.0"),
+		longEntry(0x8769, uint32(exifIFD)),
+	}
+	if gps {
+		gpsIFD := buf.Len()
+		rational := make([]byte, 0, 24)
+		for _, v := range []uint32{52, 1, 30, 1, 0, 1} {
+			rational = binary.BigEndian.AppendUint32(rational, v)
+		}
+		writeIFD(
+			buf, []tiffEntry{
+				asciiEntry(0x0001, "N"),
+				{tag: 0x0002, typ: 5, count: 3, data: rational},
+				asciiEntry(0x0003, "E"),
+				{tag: 0x0004, typ: 5, count: 3, data: rational},
+			},
+		)
+		ifd0 = append(ifd0, longEntry(0x8825, uint32(gpsIFD)))
+	}
+
+	start := buf.Len()
+	writeIFD(buf, ifd0)
+	tiff := buf.Bytes()
+	binary.BigEndian.PutUint32(tiff[4:], uint32(start))
+	return tiff
+}
+
+func testSegment(marker byte, payload []byte) []byte {
+	seg := []byte{0xFF, marker}
+	seg = binary.BigEndian.AppendUint16(seg, uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+// testJPEG encodes a width x height JPEG whose left half is red, with the
+// given segments inserted after SOI
+func testJPEG(t *testing.T, width, height int, segments ...[]byte) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			c := color.RGBA{B: 255, A: 255}
+			if x < width/2 {
+				c = color.RGBA{R: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}))
+
+	out := []byte{0xFF, markerSOI}
+	for _, seg := range segments {
+		out = append(out, seg...)
+	}
+	return append(out, buf.Bytes()[2:]...)
+}
+
+func exifSegment(tiff []byte) []byte {
+	return testSegment(markerAPP1, append(bytes.Clone(exifHeader), tiff...))
+}
+
+func TestReadMetadata(t *testing.T) {
+	t.Run(
+		"JPEG", func(t *testing.T) {
+			data := testJPEG(t, 40, 20, exifSegment(testEXIF(6, true)))
+
+			meta, err := ReadMetadata(data)
+			require.NoError(t, err)
+			assert.Equal(t, "jpeg", meta.Format)
+			assert.Equal(t, 20, meta.Width, "orientation 6 swaps the sides")
+			assert.Equal(t, 40, meta.Height)
+			assert.Equal(t, 6, meta.Orientation)
+			assert.Equal(t, "Acme", meta.Make)
+			assert.Equal(t, "Phone X", meta.Model)
+			assert.Equal(t, "Wide 26mm", meta.LensModel)
+			assert.Equal(t, "Camera 1.0", meta.Software)
+			assert.Equal(t, time.Date(2023, 6, 15, 14, 30, 0, 0, time.Local), meta.Taken)
+			assert.True(t, meta.HasLocation)
+		},
+	)
+
+	t.Run(
+		"Without EXIF", func(t *testing.T) {
+			meta, err := ReadMetadata(testJPEG(t, 40, 20))
+			require.NoError(t, err)
+			assert.Equal(t, 40, meta.Width)
+			assert.Equal(t, 1, meta.Orientation)
+			assert.Empty(t, meta.Make)
+			assert.False(t, meta.HasLocation)
+		},
+	)
+
+	t.Run(
+		"PNG", func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 7, 3))))
+
+			meta, err := ReadMetadata(insertPNGChunk(buf.Bytes(), "eXIf", testEXIF(1, false)))
+			require.NoError(t, err)
+			assert.Equal(t, "png", meta.Format)
+			assert.Equal(t, 7, meta.Width)
+			assert.Equal(t, 3, meta.Height)
+			assert.Equal(t, "Acme", meta.Make)
+			assert.False(t, meta.HasLocation)
+		},
+	)
+
+	t.Run(
+		"Not An Image", func(t *testing.T) {
+			_, err := ReadMetadata([]byte("plain text"))
+			assert.Error(t, err)
+		},
+	)
+}
+
+// insertPNGChunk adds a chunk right after IHDR
+func insertPNGChunk(data []byte, typ string, payload []byte) []byte {
+	ihdrEnd := len(pngSignature) + 12 + int(binary.BigEndian.Uint32(data[len(pngSignature):]))
+
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, payload...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	out := append(bytes.Clone(data[:ihdrEnd]), chunk...)
+	return append(out, data[ihdrEnd:]...)
+}
@@ -0,0 +1,98 @@
+package imgproc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"github.com/disintegration/imaging"
+	"image"
+)
+
+const (
+	// PreviewSize bounds the longer side of the inline preview in pixels
+	PreviewSize = 16
+	// PreviewQuality is the JPEG quality of opaque previews
+	PreviewQuality = 60
+	// placeholderSample is the size images are reduced to before computing
+	// the blurhash and the dominant colour
+	placeholderSample = 32
+)
+
+// Placeholder is what a client shows while an image loads: a blurhash, a
+// tiny preview as a data URI and the dominant colour as #rrggbb
+type Placeholder struct {
+	BlurHash string `json:"blurHash"`
+	Preview  string `json:"preview"`
+	Color    string `json:"color,omitempty"`
+}
+
+// NewPlaceholder computes the placeholder of a decoded image. The blurhash
+// uses 4x3 components, or 3x4 for portrait images.
+func NewPlaceholder(img image.Image) (*Placeholder, error) {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return nil, ErrUnsupportedFormat
+	}
+
+	sample := imaging.Fit(img, placeholderSample, placeholderSample, imaging.Box)
+	xComponents, yComponents := 4, 3
+	if bounds.Dy() > bounds.Dx() {
+		xComponents, yComponents = 3, 4
+	}
+
+	// Transparency needs PNG; everything else is smaller as a JPEG
+	preview := imaging.Fit(img, PreviewSize, PreviewSize, imaging.Lanczos)
+	o := Options{Format: FormatJPEG, Quality: PreviewQuality}
+	if !preview.Opaque() {
+		o.Format = FormatPNG
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, preview, o); err != nil {
+		return nil, err
+	}
+
+	return &Placeholder{
+		BlurHash: BlurHash(sample, xComponents, yComponents),
+		Preview:  "data:" + ContentType(o.Format) + ";base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+		Color:    dominantColor(sample),
+	}, nil
+}
+
+// dominantColor buckets the pixels of img by their four most significant
+// bits per channel and returns the average colour of the fullest bucket.
+// Mostly transparent pixels are ignored.
+func dominantColor(img *image.NRGBA) string {
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+
+	var best *bucket
+	buckets := make(map[int]*bucket)
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		r, g, b, a := img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3]
+		if a < 128 {
+			continue
+		}
+
+		id := int(r>>4)<<8 | int(g>>4)<<4 | int(b>>4)
+		bk := buckets[id]
+		if bk == nil {
+			bk = &bucket{}
+			buckets[id] = bk
+		}
+		bk.count++
+		bk.r += int(r)
+		bk.g += int(g)
+		bk.b += int(b)
+		if best == nil || bk.count > best.count {
+			best = bk
+		}
+	}
+
+	if best == nil {
+		return ""
+	}
+	return fmt.Sprintf("#%02x%02x%02x", best.r/best.count, best.g/best.count, best.b/best.count)
+}
@@ -0,0 +1,106 @@
+package imgproc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+)
+
+func TestBlurHash(t *testing.T) {
+	t.Run(
+		"Solid Colour", func(t *testing.T) {
+			img := image.NewNRGBA(image.Rect(0, 0, 8, 6))
+			draw.Draw(img, img.Bounds(), image.NewUniform(color.NRGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+
+			// The basis is sampled at the pixel edges as in the reference
+			// encoder, so even a flat image has some AC energy
+			assert.Equal(t, "LsTI:j]9fQ]9|csUfQsUfQfQfQfQ", BlurHash(img, 4, 3))
+			assert.Equal(t, "00TI:j", BlurHash(img, 1, 1))
+		},
+	)
+
+	t.Run(
+		"Gradient", func(t *testing.T) {
+			img := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+			for x := 0; x < 32; x++ {
+				for y := 0; y < 32; y++ {
+					img.Set(x, y, color.NRGBA{R: uint8(x * 8), G: 64, B: uint8(255 - x*8), A: 255})
+				}
+			}
+
+			assert.Equal(t, "T.G~Sq6;w%oMa~jufQfQfQoMa~ju", BlurHash(img, 3, 4))
+		},
+	)
+
+	t.Run(
+		"Empty", func(t *testing.T) {
+			assert.Empty(t, BlurHash(image.NewNRGBA(image.Rect(0, 0, 0, 0)), 4, 3))
+		},
+	)
+}
+
+func TestNewPlaceholder(t *testing.T) {
+	decodePreview := func(t *testing.T, uri, contentType string) image.Image {
+		prefix := "data:" + contentType + ";base64,"
+		require.True(t, strings.HasPrefix(uri, prefix), uri)
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(uri, prefix))
+		require.NoError(t, err)
+		img, _, err := image.Decode(bytes.NewReader(data))
+		require.NoError(t, err)
+		return img
+	}
+
+	t.Run(
+		"Landscape", func(t *testing.T) {
+			// Three quarters blue with a red stripe
+			img := image.NewNRGBA(image.Rect(0, 0, 200, 100))
+			draw.Draw(img, img.Bounds(), image.NewUniform(color.NRGBA{B: 255, A: 255}), image.Point{}, draw.Src)
+			draw.Draw(img, image.Rect(0, 0, 50, 100), image.NewUniform(color.NRGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+
+			p, err := NewPlaceholder(img)
+			require.NoError(t, err)
+			assert.Equal(t, "#0000ff", p.Color)
+			assert.Equal(t, "L", p.BlurHash[:1])
+			assert.Len(t, p.BlurHash, 28)
+
+			preview := decodePreview(t, p.Preview, "image/jpeg")
+			assert.Equal(t, image.Rect(0, 0, 16, 8), preview.Bounds())
+		},
+	)
+
+	t.Run(
+		"Portrait With Transparency", func(t *testing.T) {
+			img := image.NewNRGBA(image.Rect(0, 0, 50, 100))
+			draw.Draw(img, image.Rect(0, 0, 50, 40), image.NewUniform(color.NRGBA{G: 200, A: 255}), image.Point{}, draw.Src)
+
+			p, err := NewPlaceholder(img)
+			require.NoError(t, err)
+			assert.Equal(t, "#00c800", p.Color)
+			assert.Equal(t, "T", p.BlurHash[:1])
+
+			preview := decodePreview(t, p.Preview, "image/png")
+			assert.Equal(t, image.Rect(0, 0, 8, 16), preview.Bounds())
+		},
+	)
+
+	t.Run(
+		"Fully Transparent", func(t *testing.T) {
+			p, err := NewPlaceholder(image.NewNRGBA(image.Rect(0, 0, 4, 4)))
+			require.NoError(t, err)
+			assert.Empty(t, p.Color)
+		},
+	)
+
+	t.Run(
+		"Empty", func(t *testing.T) {
+			_, err := NewPlaceholder(image.NewNRGBA(image.Rect(0, 0, 0, 0)))
+			assert.ErrorIs(t, err, ErrUnsupportedFormat)
+		},
+	)
+}
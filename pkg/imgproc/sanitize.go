@@ -0,0 +1,194 @@
+package imgproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/disintegration/imaging"
+	"regexp"
+)
+
+var errMalformedPNG = errors.New("malformed png")
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadataChunks carry EXIF, XMP and free-form text, which may hold
+// locations, names or software details
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+var xmpOrientation = regexp.MustCompile(`(tiff:Orientation(?:="|>))[2-8]`)
+
+// OriginalQuality is the JPEG quality used when an original has to be
+// re-encoded, high enough that the loss is not visible
+const OriginalQuality = 92
+
+// SanitizeOptions select what Sanitize does to an uploaded image
+type SanitizeOptions struct {
+	// StripMetadata removes EXIF, XMP, IPTC and comments
+	StripMetadata bool
+	// AutoOrient rotates JPEG pixels according to the EXIF orientation
+	AutoOrient bool
+	// Quality is used when auto-orientation re-encodes a JPEG
+	Quality int
+	// MaxPixels refuses to rotate JPEGs with more pixels, like Decode
+	MaxPixels int
+}
+
+// Sanitize applies o to a JPEG or PNG image and returns the result. Other
+// data is returned unchanged. Stripping is lossless; only rotating a JPEG
+// re-encodes it, keeping its ICC profile and, unless stripped, its metadata
+// with the orientation reset.
+func Sanitize(data []byte, o SanitizeOptions) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, markerSOI}):
+		return sanitizeJPEG(data, o)
+	case bytes.HasPrefix(data, pngSignature) && o.StripMetadata:
+		return stripPNG(data)
+	}
+	return data, nil
+}
+
+func sanitizeJPEG(data []byte, o SanitizeOptions) ([]byte, error) {
+	f, err := parseJPEG(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.AutoOrient && orientation(f.exif()) != 1 {
+		return orientJPEG(data, f, o)
+	}
+	if !o.StripMetadata {
+		return data, nil
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write([]byte{0xFF, markerSOI})
+	// A photo that isn't rotated keeps the one tag it needs to display
+	// upright, placed where EXIF belongs: right after JFIF
+	var upright []byte
+	if o := orientation(f.exif()); o != 1 {
+		upright = orientationSegment(o)
+	}
+	for _, s := range f.segments {
+		if upright != nil && s.marker != markerAPP0 {
+			out.Write(upright)
+			upright = nil
+		}
+		if keepWhenStripping(s) {
+			out.Write(s.raw)
+		}
+	}
+	out.Write(upright)
+	out.Write(f.scans)
+	return out.Bytes(), nil
+}
+
+// orientationSegment builds an EXIF segment holding nothing but the
+// orientation tag
+func orientationSegment(orientation int) []byte {
+	tiff := []byte{
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, // header, IFD0 at offset 8
+		0x00, 0x01, // one entry
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, byte(orientation >> 8), byte(orientation), 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, // no next IFD
+	}
+
+	payload := append(bytes.Clone(exifHeader), tiff...)
+	seg := []byte{0xFF, markerAPP1, 0x00, 0x00}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+// orientJPEG re-encodes a JPEG with its pixels rotated upright and carries
+// the metadata of the original over. Segments describing the old encoding
+// and the secondary images trailing it are dropped.
+func orientJPEG(data []byte, f *jpegFile, o SanitizeOptions) ([]byte, error) {
+	img, err := decode(data, o.MaxPixels)
+	if err != nil {
+		return nil, err
+	}
+
+	var encoded bytes.Buffer
+	if err = imaging.Encode(&encoded, img, imaging.JPEG, imaging.JPEGQuality(o.Quality)); err != nil {
+		return nil, err
+	}
+	rotated, err := parseJPEG(encoded.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, encoded.Len()))
+	out.Write([]byte{0xFF, markerSOI})
+	for _, s := range f.segments {
+		switch {
+		case s.marker == markerAPP2 && bytes.HasPrefix(s.payload, iccHeader):
+			out.Write(s.raw)
+		case o.StripMetadata || !isMetadata(s):
+			// Tables and markers of the old encoding don't apply any more
+		case s.marker == markerAPP1:
+			out.Write(uprightMetadata(s))
+		default:
+			out.Write(s.raw)
+		}
+	}
+	for _, s := range rotated.segments {
+		out.Write(s.raw)
+	}
+	out.Write(rotated.scans)
+	return out.Bytes(), nil
+}
+
+// isMetadata reports whether a segment holds metadata rather than data
+// about the encoding. Multi-picture indexes in APP2 point into the trailer,
+// which is never carried over.
+func isMetadata(s segment) bool {
+	switch s.marker {
+	case markerAPP0, markerAPP2, markerAPPE:
+		return false
+	}
+	return s.marker > markerAPP0 && s.marker <= 0xEF || s.marker == 0xFE
+}
+
+// uprightMetadata copies an APP1 segment with its orientation reset to 1
+func uprightMetadata(s segment) []byte {
+	raw := bytes.Clone(s.raw)
+	payload := raw[len(raw)-len(s.payload):]
+
+	if bytes.HasPrefix(payload, exifHeader) {
+		tiff := payload[len(exifHeader):]
+		if offset, order, ok := orientationTag(tiff); ok {
+			order.PutUint16(tiff[offset:], 1)
+		}
+		return raw
+	}
+	return xmpOrientation.ReplaceAll(raw, []byte("${1}1"))
+}
+
+// stripPNG drops the metadata chunks of a PNG file
+func stripPNG(data []byte) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+
+	for i := len(pngSignature); i < len(data); {
+		if i+12 > len(data) {
+			return nil, errMalformedPNG
+		}
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length
+		if end > len(data) {
+			return nil, errMalformedPNG
+		}
+
+		if !pngMetadataChunks[string(data[i+4:i+8])] {
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	return out.Bytes(), nil
+}
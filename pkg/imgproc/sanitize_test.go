@@ -0,0 +1,145 @@
+package imgproc
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	xmp := testSegment(markerAPP1, []byte(`http://ns.adobe.com/xap/1.0/`+"\x00"+`<x:xmpmeta tiff:Orientation="6"/>`))
+	icc := testSegment(markerAPP2, append(bytes.Clone(iccHeader), "profile"...))
+	comment := testSegment(0xFE, []byte("shot at home"))
+	original := testJPEG(t, 40, 20, exifSegment(testEXIF(6, true)), xmp, icc, comment)
+
+	t.Run(
+		"Untouched", func(t *testing.T) {
+			out, err := Sanitize(original, SanitizeOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, original, out)
+		},
+	)
+
+	t.Run(
+		"Strip", func(t *testing.T) {
+			out, err := Sanitize(original, SanitizeOptions{StripMetadata: true})
+			require.NoError(t, err)
+
+			meta, err := ReadMetadata(out)
+			require.NoError(t, err)
+			assert.Empty(t, meta.Make)
+			assert.False(t, meta.HasLocation)
+			assert.Equal(t, 6, meta.Orientation, "the orientation survives so the photo still displays upright")
+			assert.NotContains(t, string(out), "xmpmeta")
+			assert.NotContains(t, string(out), "shot at home")
+			assert.Contains(t, string(out), "ICC_PROFILE")
+
+			// Stripping never touches the pixels
+			f, err := parseJPEG(original)
+			require.NoError(t, err)
+			assert.True(t, bytes.HasSuffix(out, f.scans))
+		},
+	)
+
+	t.Run(
+		"Strip Trailing Images", func(t *testing.T) {
+			secondary := testJPEG(t, 8, 8, exifSegment(testEXIF(1, true)))
+			out, err := Sanitize(append(bytes.Clone(original), secondary...), SanitizeOptions{StripMetadata: true})
+			require.NoError(t, err)
+			assert.NotContains(t, string(out), "Acme")
+		},
+	)
+
+	t.Run(
+		"Auto Orient", func(t *testing.T) {
+			out, err := Sanitize(original, SanitizeOptions{AutoOrient: true, Quality: OriginalQuality})
+			require.NoError(t, err)
+
+			meta, err := ReadMetadata(out)
+			require.NoError(t, err)
+			assert.Equal(t, 1, meta.Orientation)
+			assert.Equal(t, 20, meta.Width)
+			assert.Equal(t, 40, meta.Height)
+			assert.Equal(t, "Acme", meta.Make, "metadata is kept unless stripped")
+			assert.True(t, meta.HasLocation)
+			assert.Contains(t, string(out), `tiff:Orientation="1"`)
+			assert.Contains(t, string(out), "ICC_PROFILE")
+
+			// Orientation 6 turns the red left half into the top half
+			img, _, err := image.Decode(bytes.NewReader(out))
+			require.NoError(t, err)
+			r, _, b, _ := img.At(10, 5).RGBA()
+			assert.Greater(t, r, b)
+			r, _, b, _ = img.At(10, 35).RGBA()
+			assert.Greater(t, b, r)
+		},
+	)
+
+	t.Run(
+		"Auto Orient And Strip", func(t *testing.T) {
+			out, err := Sanitize(original, SanitizeOptions{AutoOrient: true, StripMetadata: true, Quality: OriginalQuality})
+			require.NoError(t, err)
+
+			meta, err := ReadMetadata(out)
+			require.NoError(t, err)
+			assert.Equal(t, 1, meta.Orientation)
+			assert.Equal(t, 20, meta.Width)
+			assert.Empty(t, meta.Make)
+			assert.False(t, meta.HasLocation)
+			assert.NotContains(t, string(out), "xmpmeta")
+		},
+	)
+
+	t.Run(
+		"Upright Photo", func(t *testing.T) {
+			upright := testJPEG(t, 40, 20, exifSegment(testEXIF(1, false)))
+			out, err := Sanitize(upright, SanitizeOptions{AutoOrient: true})
+			require.NoError(t, err)
+			assert.Equal(t, upright, out, "nothing to rotate means nothing is re-encoded")
+		},
+	)
+
+	t.Run(
+		"Too Many Pixels", func(t *testing.T) {
+			_, err := Sanitize(original, SanitizeOptions{AutoOrient: true, MaxPixels: 40*20 - 1})
+			assert.ErrorIs(t, err, ErrTooLarge)
+		},
+	)
+
+	t.Run(
+		"PNG", func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))))
+			data := insertPNGChunk(buf.Bytes(), "eXIf", testEXIF(1, true))
+			data = insertPNGChunk(data, "tEXt", []byte("Author\x00someone"))
+			data = insertPNGChunk(data, "gAMA", []byte{0, 0, 0xB1, 0x8F})
+
+			out, err := Sanitize(data, SanitizeOptions{StripMetadata: true})
+			require.NoError(t, err)
+			assert.NotContains(t, string(out), "eXIf")
+			assert.NotContains(t, string(out), "someone")
+			assert.Contains(t, string(out), "gAMA")
+
+			_, err = png.Decode(bytes.NewReader(out))
+			assert.NoError(t, err)
+		},
+	)
+
+	t.Run(
+		"Other Data", func(t *testing.T) {
+			out, err := Sanitize([]byte("GIF89a"), SanitizeOptions{StripMetadata: true, AutoOrient: true})
+			require.NoError(t, err)
+			assert.Equal(t, []byte("GIF89a"), out)
+		},
+	)
+
+	t.Run(
+		"Truncated", func(t *testing.T) {
+			_, err := Sanitize(original[:30], SanitizeOptions{StripMetadata: true})
+			assert.Error(t, err)
+		},
+	)
+}
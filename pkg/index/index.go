@@ -0,0 +1,348 @@
+// Package index keeps an embedded on-disk record of every stored object so
+// listings and searches never have to walk the storage backend. Objects are
+// kept in a bbolt bucket keyed by object key, which gives every listing the
+// same byte-wise key order.
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	bolt "go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrLocked = errors.New("index is opened by another process")
+
+var objectsBucket = []byte("objects")
+var metaBucket = []byte("meta")
+var builtKey = []byte("built")
+
+// openTimeout bounds how long Open waits for the file lock held by
+// another process, e.g. a running server while the reindex command runs
+const openTimeout = time.Second
+
+type entry struct {
+	Size         int64             `json:"size"`
+	ModTime      int64             `json:"modTime"`
+	ContentType  string            `json:"contentType,omitempty"`
+	ETag         string            `json:"etag,omitempty"`
+	SHA256       string            `json:"sha256,omitempty"`
+	OriginalName string            `json:"originalName,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	Audio        *storage.Audio    `json:"audio,omitempty"`
+}
+
+type Index struct {
+	db *bolt.DB
+
+	// rebuild allows one Rebuild at a time
+	rebuild sync.Mutex
+	// mu orders writes against the start and end of a Rebuild. While one
+	// runs, pending records what was written since it started listing,
+	// nil for deleted keys, so it isn't lost when the bucket is replaced.
+	mu      sync.Mutex
+	pending map[string]*storage.ObjectInfo
+}
+
+func Open(path string) (*Index, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: openTimeout})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, ErrLocked
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(
+		func(tx *bolt.Tx) error {
+			if _, err := tx.CreateBucketIfNotExists(objectsBucket); err != nil {
+				return err
+			}
+			_, err := tx.CreateBucketIfNotExists(metaBucket)
+			return err
+		},
+	)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Index{db: db}, nil
+}
+
+func (i *Index) Close() error {
+	return i.db.Close()
+}
+
+// Built reports whether the index has been filled by Rebuild at least once
+func (i *Index) Built() bool {
+	built := false
+	i.db.View(
+		func(tx *bolt.Tx) error {
+			built = tx.Bucket(metaBucket).Get(builtKey) != nil
+			return nil
+		},
+	)
+	return built
+}
+
+// Rebuild replaces the contents of the index with every object in src.
+// Metadata of objects that haven't changed since they were indexed is kept,
+// everything else is read once to compute it. Puts and Deletes made while
+// it runs are applied on top of the rebuilt index.
+func (i *Index) Rebuild(ctx context.Context, src storage.Storage) (int, error) {
+	i.rebuild.Lock()
+	defer i.rebuild.Unlock()
+
+	i.mu.Lock()
+	i.pending = make(map[string]*storage.ObjectInfo)
+	i.mu.Unlock()
+	defer func() {
+		i.mu.Lock()
+		i.pending = nil
+		i.mu.Unlock()
+	}()
+
+	objects, _, err := src.List(ctx, "", "", 0)
+	if err != nil {
+		return 0, err
+	}
+
+	for j := range objects {
+		obj := &objects[j]
+		if obj.ETag != "" {
+			continue
+		}
+		if old, err := i.Get(obj.Key); err == nil && old.Size == obj.Size && old.ModTime.Equal(obj.ModTime) {
+			obj.Meta = old.Meta
+			continue
+		}
+
+		rc, _, err := src.Get(ctx, obj.Key, 0, -1)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		obj.Meta, err = storage.Digest(ctx, obj.Key, rc)
+		rc.Close()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	n := len(objects)
+	err = i.db.Update(
+		func(tx *bolt.Tx) error {
+			if err := tx.DeleteBucket(objectsBucket); err != nil {
+				return err
+			}
+			b, err := tx.CreateBucket(objectsBucket)
+			if err != nil {
+				return err
+			}
+
+			// Keys arrive sorted, so filling pages completely saves space
+			b.FillPercent = 1
+			for j := range objects {
+				if err = put(b, &objects[j]); err != nil {
+					return err
+				}
+			}
+
+			// The listing may predate writes made while it was digested
+			for key, info := range i.pending {
+				exists := b.Get([]byte(key)) != nil
+				if info == nil {
+					if exists {
+						n--
+					}
+					err = b.Delete([]byte(key))
+				} else {
+					if !exists {
+						n++
+					}
+					err = put(b, info)
+				}
+				if err != nil {
+					return err
+				}
+			}
+
+			built, _ := time.Now().MarshalBinary()
+			return tx.Bucket(metaBucket).Put(builtKey, built)
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (i *Index) Put(info *storage.ObjectInfo) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.pending != nil {
+		i.pending[info.Key] = info
+	}
+	return i.db.Update(
+		func(tx *bolt.Tx) error {
+			return put(tx.Bucket(objectsBucket), info)
+		},
+	)
+}
+
+func (i *Index) Delete(key string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.pending != nil {
+		i.pending[key] = nil
+	}
+	return i.db.Update(
+		func(tx *bolt.Tx) error {
+			return tx.Bucket(objectsBucket).Delete([]byte(key))
+		},
+	)
+}
+
+func (i *Index) Get(key string) (*storage.ObjectInfo, error) {
+	var info *storage.ObjectInfo
+	err := i.db.View(
+		func(tx *bolt.Tx) error {
+			v := tx.Bucket(objectsBucket).Get([]byte(key))
+			if v == nil {
+				return storage.ErrNotFound
+			}
+
+			var err error
+			info, err = decode([]byte(key), v)
+			return err
+		},
+	)
+	return info, err
+}
+
+// List returns up to limit objects below prefix that sort after cursor,
+// with the same semantics as storage.Storage.List
+func (i *Index) List(prefix, cursor string, limit int) ([]storage.ObjectInfo, string, error) {
+	objects := make([]storage.ObjectInfo, 0)
+	next := ""
+	err := i.db.View(
+		func(tx *bolt.Tx) error {
+			c := tx.Bucket(objectsBucket).Cursor()
+			start := []byte(prefix)
+			if cursor > prefix {
+				start = []byte(cursor)
+			}
+
+			for k, v := c.Seek(start); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Next() {
+				if cursor != "" && string(k) <= cursor {
+					continue
+				}
+				if limit > 0 && len(objects) == limit {
+					next = objects[len(objects)-1].Key
+					return nil
+				}
+
+				info, err := decode(k, v)
+				if err != nil {
+					return err
+				}
+				objects = append(objects, *info)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	return objects, next, nil
+}
+
+// Page returns limit objects below prefix starting at offset, together with
+// the number of objects below prefix. A non-empty query only keeps objects
+// whose key contains it, ignoring case.
+func (i *Index) Page(prefix, query string, offset, limit int) ([]storage.ObjectInfo, int, error) {
+	query = strings.ToLower(query)
+	objects := make([]storage.ObjectInfo, 0)
+	total := 0
+	err := i.db.View(
+		func(tx *bolt.Tx) error {
+			c := tx.Bucket(objectsBucket).Cursor()
+			for k, v := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Next() {
+				if query != "" && !strings.Contains(strings.ToLower(string(k)), query) {
+					continue
+				}
+
+				total++
+				if total <= offset || (limit > 0 && len(objects) == limit) {
+					continue
+				}
+
+				info, err := decode(k, v)
+				if err != nil {
+					return err
+				}
+				objects = append(objects, *info)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	return objects, total, nil
+}
+
+func put(b *bolt.Bucket, info *storage.ObjectInfo) error {
+	data, err := json.Marshal(
+		&entry{
+			Size:         info.Size,
+			ModTime:      info.ModTime.UnixNano(),
+			ContentType:  info.ContentType,
+			ETag:         info.ETag,
+			SHA256:       info.SHA256,
+			OriginalName: info.OriginalName,
+			Tags:         info.Tags,
+			Audio:        info.Audio,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(info.Key), data)
+}
+
+func decode(k, v []byte) (*storage.ObjectInfo, error) {
+	var e entry
+	if err := json.Unmarshal(v, &e); err != nil {
+		return nil, err
+	}
+	return &storage.ObjectInfo{
+		Key:     string(k),
+		Size:    e.Size,
+		ModTime: time.Unix(0, e.ModTime),
+		Meta: storage.Meta{
+			ContentType:  e.ContentType,
+			ETag:         e.ETag,
+			SHA256:       e.SHA256,
+			OriginalName: e.OriginalName,
+			Tags:         e.Tags,
+			Audio:        e.Audio,
+		},
+	}, nil
+}
@@ -0,0 +1,262 @@
+package index
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setupIndex(t *testing.T) *Index {
+	idx, err := Open(filepath.Join(t.TempDir(), "index"))
+	require.NoError(t, err)
+	t.Cleanup(func() { idx.Close() })
+	return idx
+}
+
+func keys(objects []storage.ObjectInfo) []string {
+	res := make([]string, 0, len(objects))
+	for _, o := range objects {
+		res = append(res, o.Key)
+	}
+	return res
+}
+
+// writingDuringList is a backend whose listing is overtaken by writes
+// through the index
+type writingDuringList struct {
+	storage.Storage
+	write func()
+}
+
+func (s *writingDuringList) List(ctx context.Context, prefix, cursor string, limit int) ([]storage.ObjectInfo, string, error) {
+	objects, next, err := s.Storage.List(ctx, prefix, cursor, limit)
+	s.write()
+	return objects, next, err
+}
+
+func TestIndex(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run(
+		"Rebuild", func(t *testing.T) {
+			src := storage.NewLocal(t.TempDir())
+			for _, key := range []string{"b.txt", "a/1.txt", "a/2.txt"} {
+				_, err := src.Put(ctx, key, strings.NewReader(key))
+				require.NoError(t, err)
+			}
+
+			idx := setupIndex(t)
+			assert.False(t, idx.Built())
+
+			n, err := idx.Rebuild(ctx, src)
+			require.NoError(t, err)
+			assert.Equal(t, 3, n)
+			assert.True(t, idx.Built())
+
+			objects, next, err := idx.List("", "", 0)
+			require.NoError(t, err)
+			assert.Empty(t, next)
+			assert.Equal(t, []string{"a/1.txt", "a/2.txt", "b.txt"}, keys(objects))
+			assert.Equal(t, int64(7), objects[0].Size)
+
+			assert.Equal(t, `"`+fmt.Sprintf("%x", md5.Sum([]byte("a/1.txt")))+`"`, objects[0].ETag)
+			assert.Equal(t, "text/plain; charset=utf-8", objects[0].ContentType)
+
+			// Unchanged objects keep the metadata they were indexed with
+			require.NoError(t, idx.Put(&storage.ObjectInfo{
+				Key: "a/2.txt", Size: objects[1].Size, ModTime: objects[1].ModTime,
+				Meta: storage.Meta{ETag: `"kept"`, OriginalName: "Two.txt"},
+			}))
+			_, err = idx.Rebuild(ctx, src)
+			require.NoError(t, err)
+			kept, err := idx.Get("a/2.txt")
+			require.NoError(t, err)
+			assert.Equal(t, `"kept"`, kept.ETag)
+			assert.Equal(t, "Two.txt", kept.OriginalName)
+
+			require.NoError(t, src.Delete(ctx, "b.txt"))
+			_, err = idx.Rebuild(ctx, src)
+			require.NoError(t, err)
+			_, err = idx.Get("b.txt")
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+		},
+	)
+
+	t.Run(
+		"Writes During Rebuild", func(t *testing.T) {
+			local := storage.NewLocal(t.TempDir())
+			for _, key := range []string{"a.txt", "b.txt"} {
+				_, err := local.Put(ctx, key, strings.NewReader(key))
+				require.NoError(t, err)
+			}
+
+			idx := setupIndex(t)
+			store := Wrap(local, idx)
+			src := &writingDuringList{
+				Storage: local, write: func() {
+					_, err := store.Put(ctx, "c.txt", strings.NewReader("c.txt"))
+					require.NoError(t, err)
+					require.NoError(t, store.Delete(ctx, "a.txt"))
+				},
+			}
+
+			n, err := idx.Rebuild(ctx, src)
+			require.NoError(t, err)
+			assert.Equal(t, 2, n)
+
+			objects, _, err := idx.List("", "", 0)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"b.txt", "c.txt"}, keys(objects))
+		},
+	)
+
+	t.Run(
+		"List", func(t *testing.T) {
+			idx := setupIndex(t)
+			for i := 0; i < 5; i++ {
+				require.NoError(t, idx.Put(&storage.ObjectInfo{Key: fmt.Sprintf("dir/%d.txt", i)}))
+			}
+			require.NoError(t, idx.Put(&storage.ObjectInfo{Key: "dir2/file.txt"}))
+
+			objects, next, err := idx.List("dir/", "", 2)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"dir/0.txt", "dir/1.txt"}, keys(objects))
+			assert.Equal(t, "dir/1.txt", next)
+
+			objects, next, err = idx.List("dir/", next, 2)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"dir/2.txt", "dir/3.txt"}, keys(objects))
+
+			objects, next, err = idx.List("dir/", next, 2)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"dir/4.txt"}, keys(objects))
+			assert.Empty(t, next)
+
+			objects, _, err = idx.List("dir", "", 0)
+			require.NoError(t, err)
+			assert.Len(t, objects, 6)
+		},
+	)
+
+	t.Run(
+		"Page", func(t *testing.T) {
+			idx := setupIndex(t)
+			for _, key := range []string{"photos/Cat.jpg", "photos/dog.jpg", "photos/cat-2.png", "docs/cat.txt"} {
+				require.NoError(t, idx.Put(&storage.ObjectInfo{Key: key}))
+			}
+
+			objects, total, err := idx.Page("photos/", "", 1, 1)
+			require.NoError(t, err)
+			assert.Equal(t, 3, total)
+			assert.Equal(t, []string{"photos/cat-2.png"}, keys(objects))
+
+			objects, total, err = idx.Page("", "CAT", 0, 10)
+			require.NoError(t, err)
+			assert.Equal(t, 3, total)
+			assert.Equal(t, []string{"docs/cat.txt", "photos/Cat.jpg", "photos/cat-2.png"}, keys(objects))
+
+			objects, total, err = idx.Page("photos/", "cat", 5, 10)
+			require.NoError(t, err)
+			assert.Equal(t, 2, total)
+			assert.Empty(t, objects)
+
+			require.NoError(t, idx.Delete("photos/Cat.jpg"))
+			_, total, err = idx.Page("photos/", "cat", 0, 10)
+			require.NoError(t, err)
+			assert.Equal(t, 1, total)
+		},
+	)
+
+	t.Run(
+		"Locked", func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "index")
+			idx, err := Open(path)
+			require.NoError(t, err)
+			defer idx.Close()
+
+			_, err = Open(path)
+			assert.ErrorIs(t, err, ErrLocked)
+		},
+	)
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	local := storage.NewLocal(t.TempDir())
+	s := Wrap(local, setupIndex(t))
+
+	_, err := s.Put(ctx, "docs/a.txt", strings.NewReader("a"))
+	require.NoError(t, err)
+	_, err = s.Put(ctx, "docs/b.txt", strings.NewReader("bb"))
+	require.NoError(t, err)
+
+	objects, _, err := s.List(ctx, "docs/", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"docs/a.txt", "docs/b.txt"}, keys(objects))
+	assert.NotEmpty(t, objects[1].SHA256)
+
+	info, err := s.Stat(ctx, "docs/b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, objects[1].SHA256, info.SHA256)
+
+	require.NoError(t, s.Move(ctx, "docs/b.txt", "other/b.txt"))
+	objects, total, err := s.Page(ctx, "", "b.txt", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "other/b.txt", objects[0].Key)
+	assert.Equal(t, int64(2), objects[0].Size)
+	assert.Equal(t, info.SHA256, objects[0].SHA256)
+
+	require.NoError(t, s.MoveDir(ctx, "other", "moved/other"))
+	info, err = s.Stat(ctx, "moved/other/b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, objects[0].SHA256, info.SHA256)
+	assert.ErrorIs(t, s.MoveDir(ctx, "docs", "moved"), storage.ErrExists)
+	require.NoError(t, s.MoveDir(ctx, "moved/other", "other"))
+
+	require.NoError(t, s.Delete(ctx, "docs/a.txt"))
+	objects, _, err = s.List(ctx, "", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other/b.txt"}, keys(objects))
+
+	_, _, err = s.List(ctx, "missing/", "", 0)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, _, err = s.Page(ctx, "missing/", "", 0, 10)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	_, err = local.Put(ctx, "outside.txt", strings.NewReader("x"))
+	require.NoError(t, err)
+	_, total, err = s.Page(ctx, "", "outside", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 0, total)
+
+	n, err := s.Reindex(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	_, total, err = s.Page(ctx, "", "outside", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+
+	info, err = s.Stat(ctx, "other/b.txt")
+	require.NoError(t, err)
+	info.Audio = &storage.Audio{Format: "mp3", Title: "B"}
+	require.NoError(t, s.Annotate(ctx, info))
+	info, err = s.Stat(ctx, "other/b.txt")
+	require.NoError(t, err)
+	require.NotNil(t, info.Audio)
+	assert.Equal(t, "B", info.Audio.Title)
+
+	_, err = s.Put(ctx, "other/b.txt", strings.NewReader("replaced"))
+	require.NoError(t, err)
+	stale := *info
+	require.NoError(t, s.Annotate(ctx, &stale))
+	info, err = s.Stat(ctx, "other/b.txt")
+	require.NoError(t, err)
+	assert.Nil(t, info.Audio, "annotations of replaced content are dropped")
+}
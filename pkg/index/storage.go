@@ -0,0 +1,165 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"io"
+	"log"
+	"strings"
+)
+
+// Storage wraps a backend and keeps the index in sync with every write.
+// Listings are served from the index instead of the backend.
+type Storage struct {
+	storage.Storage
+	index *Index
+}
+
+func Wrap(store storage.Storage, index *Index) *Storage {
+	return &Storage{Storage: store, index: index}
+}
+
+// Unwrap returns the wrapped backend
+func (s *Storage) Unwrap() storage.Storage {
+	return s.Storage
+}
+
+// Reindex rebuilds the index from the wrapped backend
+func (s *Storage) Reindex(ctx context.Context) (int, error) {
+	return s.index.Rebuild(ctx, s.Storage)
+}
+
+func (s *Storage) Put(ctx context.Context, key string, r io.Reader) (*storage.ObjectInfo, error) {
+	info, err := s.Storage.Put(ctx, key, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.index.Put(info); err != nil {
+		log.Printf("Error indexing %s: %v\n", key, err)
+	}
+	return info, nil
+}
+
+// Stat adds the metadata recorded in the index, as long as the object
+// hasn't been replaced behind the server's back since it was indexed
+func (s *Storage) Stat(ctx context.Context, key string) (*storage.ObjectInfo, error) {
+	info, err := s.Storage.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if indexed, err := s.index.Get(key); err == nil && indexed.Size == info.Size && indexed.ModTime.Equal(info.ModTime) {
+		info.Meta = indexed.Meta
+	}
+	return info, nil
+}
+
+// Annotate records info.Meta in the index, as long as the object is still
+// the one info describes
+func (s *Storage) Annotate(ctx context.Context, info *storage.ObjectInfo) error {
+	current, err := s.Storage.Stat(ctx, info.Key)
+	if err != nil {
+		return err
+	}
+	if current.Size != info.Size || !current.ModTime.Equal(info.ModTime) {
+		return nil
+	}
+	return s.index.Put(info)
+}
+
+func (s *Storage) Delete(ctx context.Context, key string) error {
+	err := s.Storage.Delete(ctx, key)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	// Objects removed behind the server's back are dropped from the index too
+	if indexErr := s.index.Delete(key); indexErr != nil {
+		log.Printf("Error removing %s from index: %v\n", key, indexErr)
+	}
+	return err
+}
+
+func (s *Storage) Move(ctx context.Context, src, dst string) error {
+	indexed, _ := s.index.Get(src)
+	if err := s.Storage.Move(ctx, src, dst); err != nil {
+		return err
+	}
+
+	if err := s.index.Delete(src); err != nil {
+		log.Printf("Error removing %s from index: %v\n", src, err)
+	}
+	info, err := s.Storage.Stat(ctx, dst)
+	if err != nil {
+		return err
+	}
+	if indexed != nil && indexed.Size == info.Size {
+		info.Meta = indexed.Meta
+	}
+	if err = s.index.Put(info); err != nil {
+		log.Printf("Error indexing %s: %v\n", dst, err)
+	}
+	return nil
+}
+
+// MoveDir renames the directory src in the backend and moves the entries
+// of the files below it along
+func (s *Storage) MoveDir(ctx context.Context, src, dst string) error {
+	mover, ok := storage.As[storage.DirMover](s.Storage)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+
+	indexed, _, err := s.index.List(src+"/", "", 0)
+	if err != nil {
+		return err
+	}
+	if err = mover.MoveDir(ctx, src, dst); err != nil {
+		return err
+	}
+
+	// Renamed files keep their size and modification time, so the entries
+	// still describe them
+	for i := range indexed {
+		info := &indexed[i]
+		if err = s.index.Delete(info.Key); err != nil {
+			log.Printf("Error removing %s from index: %v\n", info.Key, err)
+		}
+		info.Key = dst + strings.TrimPrefix(info.Key, src)
+		if err = s.index.Put(info); err != nil {
+			log.Printf("Error indexing %s: %v\n", info.Key, err)
+		}
+	}
+	return nil
+}
+
+func (s *Storage) List(ctx context.Context, prefix, cursor string, limit int) ([]storage.ObjectInfo, string, error) {
+	objects, next, err := s.index.List(prefix, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Only the backend knows whether an empty directory exists
+	if len(objects) == 0 && cursor == "" {
+		if _, _, err = s.Storage.List(ctx, prefix, "", 1); err != nil {
+			return nil, "", err
+		}
+	}
+	return objects, next, nil
+}
+
+func (s *Storage) Page(ctx context.Context, prefix, query string, offset, limit int) ([]storage.ObjectInfo, int, error) {
+	objects, total, err := s.index.Page(prefix, query, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if total == 0 && prefix != "" {
+		if _, _, err = s.List(ctx, prefix, "", 1); err != nil {
+			return nil, 0, err
+		}
+	}
+	return objects, total, nil
+}
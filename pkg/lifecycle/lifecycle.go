@@ -0,0 +1,224 @@
+// Package lifecycle applies rules that expire stored objects or move them
+// to a compressed archive tier once they match a prefix, an age, a size
+// range and tags. A worker evaluates the rules against every object on a
+// schedule; the same evaluation can be run as a dry run to see what it
+// would do.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"log"
+	"strings"
+	"time"
+)
+
+var ErrInvalidAction = errors.New("action must be expire or archive")
+var ErrNoCondition = errors.New("rule matches every file")
+var ErrInvalidSize = errors.New("minSize is above maxSize")
+var ErrChanged = errors.New("file changed while the rules were applied")
+
+type Action string
+
+const (
+	Expire  Action = "expire"
+	Archive Action = "archive"
+)
+
+// Rule selects the objects below Prefix that were last written at least
+// MinAge ago, whose size lies between MinSize and MaxSize and that carry
+// every one of Tags. Zero values don't restrict.
+type Rule struct {
+	Name    string
+	Prefix  string
+	MinAge  time.Duration
+	MinSize int64
+	MaxSize int64
+	Tags    map[string]string
+	Action  Action
+}
+
+// Validate refuses rules that can't be applied, and rules without any
+// condition, which would empty the whole store
+func (r Rule) Validate() error {
+	if r.Action != Expire && r.Action != Archive {
+		return ErrInvalidAction
+	}
+	if r.MaxSize > 0 && r.MinSize > r.MaxSize {
+		return ErrInvalidSize
+	}
+	if strings.Trim(r.Prefix, "/") == "" && r.MinAge <= 0 && r.MinSize <= 0 && r.MaxSize <= 0 && len(r.Tags) == 0 {
+		return ErrNoCondition
+	}
+	return nil
+}
+
+// Matches reports whether info is selected by r at now
+func (r Rule) Matches(info *storage.ObjectInfo, now time.Time) bool {
+	if p := strings.Trim(r.Prefix, "/"); p != "" && info.Key != p && !strings.HasPrefix(info.Key, p+"/") {
+		return false
+	}
+	if r.MinAge > 0 && now.Sub(info.ModTime) < r.MinAge {
+		return false
+	}
+	if info.Size < r.MinSize || (r.MaxSize > 0 && info.Size > r.MaxSize) {
+		return false
+	}
+	for k, v := range r.Tags {
+		if tag, ok := info.Tags[k]; !ok || tag != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Decision is what the first matching rule does to an object
+type Decision struct {
+	storage.ObjectInfo
+	Rule   string
+	Action Action
+}
+
+// Result counts what a run of the rules did
+type Result struct {
+	Expired  int
+	Archived int
+	Failed   int
+}
+
+// Forget is told about every object a rule removed, so data derived from
+// it can be dropped
+type Forget func(key string)
+
+type Worker struct {
+	store    storage.Storage
+	tier     *Tier
+	rules    []Rule
+	interval time.Duration
+	forget   Forget
+}
+
+// New creates a worker that applies rules to store every interval. The
+// first matching rule decides what happens to an object; archived objects
+// are moved to tier.
+func New(store storage.Storage, tier *Tier, rules []Rule, interval time.Duration, forget Forget) *Worker {
+	return &Worker{store: store, tier: tier, rules: rules, interval: interval, forget: forget}
+}
+
+// Tier returns the archive tier objects are moved to
+func (w *Worker) Tier() *Tier {
+	return w.tier
+}
+
+// Plan evaluates the rules at now without applying them, returning the
+// decisions ordered by key
+func (w *Worker) Plan(ctx context.Context, now time.Time) ([]Decision, error) {
+	objects, _, err := w.store.List(ctx, "", "", 0)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+
+	decisions := make([]Decision, 0)
+	for i := range objects {
+		for _, r := range w.rules {
+			if r.Matches(&objects[i], now) {
+				decisions = append(decisions, Decision{ObjectInfo: objects[i], Rule: r.Name, Action: r.Action})
+				break
+			}
+		}
+	}
+	return decisions, nil
+}
+
+// Apply evaluates the rules at now and applies them. Failures are logged
+// and counted; only a failure to evaluate the rules is returned.
+func (w *Worker) Apply(ctx context.Context, now time.Time) (Result, error) {
+	decisions, err := w.Plan(ctx, now)
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := Result{}
+	for _, d := range decisions {
+		if ctx.Err() != nil {
+			return res, ctx.Err()
+		}
+
+		switch err := w.apply(ctx, d); {
+		case err != nil:
+			log.Printf("Error applying lifecycle rule %s to %s: %v\n", d.Rule, d.Key, err)
+			res.Failed++
+		case d.Action == Expire:
+			res.Expired++
+		default:
+			res.Archived++
+		}
+	}
+	return res, nil
+}
+
+func (w *Worker) apply(ctx context.Context, d Decision) error {
+	// The object may have been replaced since it was listed
+	info, err := w.store.Stat(ctx, d.Key)
+	if err != nil {
+		return err
+	}
+	if info.Size != d.Size || !info.ModTime.Equal(d.ModTime) {
+		return ErrChanged
+	}
+
+	if d.Action == Archive {
+		if err = w.archive(ctx, info); err != nil {
+			return err
+		}
+	} else if err = w.store.Delete(ctx, d.Key); err != nil {
+		return err
+	}
+
+	if w.forget != nil {
+		w.forget(d.Key)
+	}
+	return nil
+}
+
+// archive moves an object into the archive tier
+func (w *Worker) archive(ctx context.Context, info *storage.ObjectInfo) error {
+	rc, _, err := w.store.Get(ctx, info.Key, 0, -1)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if err = w.tier.Put(ctx, info, rc); err != nil {
+		return err
+	}
+
+	// The tier keeps the content, so there is no point in trashing it
+	if err = w.store.Delete(storage.WithContentKept(ctx), info.Key); err != nil {
+		w.tier.Delete(ctx, info.Key)
+		return err
+	}
+	return nil
+}
+
+// Run applies the rules every interval until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			res, err := w.Apply(ctx, now)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Println("Error applying lifecycle rules: ", err)
+			}
+			if res.Expired > 0 || res.Archived > 0 || res.Failed > 0 {
+				log.Printf("Lifecycle rules expired %d, archived %d and failed on %d files\n", res.Expired, res.Archived, res.Failed)
+			}
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package lifecycle
+
+import (
+	"context"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func put(t *testing.T, s storage.Storage, key, content string, tags map[string]string) {
+	ctx := storage.WithTags(storage.WithOriginalName(context.Background(), "Original.txt"), tags)
+	_, err := s.Put(ctx, key, strings.NewReader(content))
+	require.NoError(t, err)
+}
+
+func read(t *testing.T, s storage.Storage, key string) string {
+	rc, _, err := s.Get(context.Background(), key, 0, -1)
+	require.NoError(t, err)
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestRule(t *testing.T) {
+	now := time.Now()
+	info := &storage.ObjectInfo{
+		Key:     "logs/2024/app.log",
+		Size:    100,
+		ModTime: now.Add(-48 * time.Hour),
+		Meta:    storage.Meta{Tags: map[string]string{"env": "dev"}},
+	}
+
+	t.Run(
+		"Validate", func(t *testing.T) {
+			assert.NoError(t, Rule{Prefix: "logs", Action: Expire}.Validate())
+			assert.ErrorIs(t, Rule{Prefix: "logs", Action: "move"}.Validate(), ErrInvalidAction)
+			assert.ErrorIs(t, Rule{MinSize: 10, MaxSize: 5, Action: Archive}.Validate(), ErrInvalidSize)
+			assert.ErrorIs(t, Rule{Prefix: "/", Action: Expire}.Validate(), ErrNoCondition)
+		},
+	)
+
+	t.Run(
+		"Matches", func(t *testing.T) {
+			assert.True(t, Rule{Prefix: "/logs/", MinAge: 24 * time.Hour}.Matches(info, now))
+			assert.False(t, Rule{Prefix: "log"}.Matches(info, now))
+			assert.False(t, Rule{MinAge: 72 * time.Hour}.Matches(info, now))
+			assert.True(t, Rule{MinSize: 100, MaxSize: 100}.Matches(info, now))
+			assert.False(t, Rule{MaxSize: 99}.Matches(info, now))
+			assert.True(t, Rule{Tags: map[string]string{"env": "dev"}}.Matches(info, now))
+			assert.False(t, Rule{Tags: map[string]string{"env": "prod"}}.Matches(info, now))
+		},
+	)
+}
+
+func TestWorker(t *testing.T) {
+	ctx := context.Background()
+	s := storage.NewMemory()
+	tier := NewTier(t.TempDir())
+
+	forgotten := make([]string, 0)
+	w := New(
+		s, tier, []Rule{
+			{Name: "tmp", Prefix: "tmp", Action: Expire},
+			{Name: "cold", Tags: map[string]string{"class": "cold"}, Action: Archive},
+		}, time.Hour, func(key string) { forgotten = append(forgotten, key) },
+	)
+
+	put(t, s, "tmp/a.txt", "a", nil)
+	put(t, s, "docs/b.txt", strings.Repeat("b", 1000), map[string]string{"class": "cold"})
+	put(t, s, "docs/c.txt", "c", map[string]string{"class": "hot"})
+
+	t.Run(
+		"Plan", func(t *testing.T) {
+			decisions, err := w.Plan(ctx, time.Now())
+			require.NoError(t, err)
+			require.Len(t, decisions, 2)
+			assert.Equal(t, "docs/b.txt", decisions[0].Key)
+			assert.Equal(t, "cold", decisions[0].Rule)
+			assert.Equal(t, Archive, decisions[0].Action)
+			assert.Equal(t, "tmp/a.txt", decisions[1].Key)
+			assert.Equal(t, Expire, decisions[1].Action)
+
+			_, err = s.Stat(ctx, "tmp/a.txt")
+			assert.NoError(t, err)
+		},
+	)
+
+	t.Run(
+		"Apply", func(t *testing.T) {
+			res, err := w.Apply(ctx, time.Now())
+			require.NoError(t, err)
+			assert.Equal(t, Result{Expired: 1, Archived: 1}, res)
+			assert.ElementsMatch(t, []string{"docs/b.txt", "tmp/a.txt"}, forgotten)
+
+			_, err = s.Stat(ctx, "tmp/a.txt")
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+			_, err = s.Stat(ctx, "docs/b.txt")
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+			assert.Equal(t, "c", read(t, s, "docs/c.txt"))
+		},
+	)
+
+	t.Run(
+		"List", func(t *testing.T) {
+			archived, err := tier.List(ctx, "docs")
+			require.NoError(t, err)
+			require.Len(t, archived, 1)
+			assert.Equal(t, "docs/b.txt", archived[0].Key)
+			assert.Equal(t, int64(1000), archived[0].Size)
+			assert.Less(t, archived[0].CompressedSize, archived[0].Size)
+			assert.Equal(t, "Original.txt", archived[0].OriginalName)
+			assert.Equal(t, map[string]string{"class": "cold"}, archived[0].Tags)
+
+			archived, err = tier.List(ctx, "tmp")
+			require.NoError(t, err)
+			assert.Empty(t, archived)
+		},
+	)
+
+	t.Run(
+		"Restore", func(t *testing.T) {
+			put(t, s, "docs/b.txt", "new", nil)
+			_, err := tier.Restore(ctx, "docs/b.txt", s)
+			assert.ErrorIs(t, err, ErrConflict)
+			require.NoError(t, s.Delete(ctx, "docs/b.txt"))
+
+			info, err := tier.Restore(ctx, "docs/b.txt", s)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1000), info.Size)
+			assert.Equal(t, map[string]string{"class": "cold"}, info.Tags)
+			assert.Equal(t, strings.Repeat("b", 1000), read(t, s, "docs/b.txt"))
+
+			_, err = tier.Stat(ctx, "docs/b.txt")
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+		},
+	)
+
+	t.Run(
+		"Archive Twice", func(t *testing.T) {
+			require.NoError(t, s.Delete(ctx, "docs/b.txt"))
+			put(t, s, "docs/d.txt", "first", map[string]string{"class": "cold"})
+			res, err := w.Apply(ctx, time.Now())
+			require.NoError(t, err)
+			assert.Equal(t, Result{Archived: 1}, res)
+
+			put(t, s, "docs/d.txt", "second", map[string]string{"class": "cold"})
+			res, err = w.Apply(ctx, time.Now())
+			require.NoError(t, err)
+			assert.Equal(t, Result{Failed: 1}, res)
+			assert.Equal(t, "second", read(t, s, "docs/d.txt"))
+
+			err = tier.Put(ctx, &storage.ObjectInfo{Key: "docs/d.txt"}, strings.NewReader("third"))
+			assert.ErrorIs(t, err, ErrArchived)
+
+			require.NoError(t, s.Delete(ctx, "docs/d.txt"))
+			_, err = tier.Restore(ctx, "docs/d.txt", s)
+			require.NoError(t, err)
+			assert.Equal(t, "first", read(t, s, "docs/d.txt"))
+		},
+	)
+}
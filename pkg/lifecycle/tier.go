@@ -0,0 +1,241 @@
+package lifecycle
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+var ErrConflict = errors.New("a file already exists at the original path")
+var ErrInvalidArchive = errors.New("archived file has invalid metadata")
+var ErrArchived = errors.New("an archived file already exists at this path")
+
+// archiveExt is appended to the key of every archived object
+const archiveExt = ".gz"
+
+// extraID identifies the gzip extra subfield that holds the metadata of
+// an archived object
+var extraID = [2]byte{'S', '3'}
+
+// maxExtra is the most a gzip extra subfield holds
+const maxExtra = 1<<16 - 1 - 4
+
+type header struct {
+	Size         int64             `json:"size"`
+	ModTime      int64             `json:"modTime"`
+	ContentType  string            `json:"contentType,omitempty"`
+	ETag         string            `json:"etag,omitempty"`
+	SHA256       string            `json:"sha256,omitempty"`
+	OriginalName string            `json:"originalName,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	Audio        *storage.Audio    `json:"audio,omitempty"`
+}
+
+// Archived describes an object in the archive tier. Size and ModTime are
+// those of the object; CompressedSize is what it takes in the tier.
+type Archived struct {
+	Key            string
+	Size           int64
+	CompressedSize int64
+	ModTime        time.Time
+	ArchivedAt     time.Time
+	storage.Meta
+}
+
+// Tier is the archive tier: objects are kept gzip-compressed under their
+// own key, with their metadata in the gzip header, until they are restored
+type Tier struct {
+	store storage.Storage
+}
+
+func NewTier(dir string) *Tier {
+	return &Tier{store: storage.NewLocal(dir)}
+}
+
+// Put compresses the content of the object info into the tier. An object
+// archived earlier under the same key is never replaced: Put fails with
+// ErrArchived until that one is restored.
+func (t *Tier) Put(ctx context.Context, info *storage.ObjectInfo, r io.Reader) error {
+	if _, err := t.store.Stat(ctx, info.Key+archiveExt); err == nil {
+		return ErrArchived
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	data, err := json.Marshal(
+		&header{
+			Size:         info.Size,
+			ModTime:      info.ModTime.UnixNano(),
+			ContentType:  info.ContentType,
+			ETag:         info.ETag,
+			SHA256:       info.SHA256,
+			OriginalName: info.OriginalName,
+			Tags:         info.Tags,
+			Audio:        info.Audio,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxExtra {
+		return ErrInvalidArchive
+	}
+
+	extra := make([]byte, 4, 4+len(data))
+	copy(extra, extraID[:])
+	binary.LittleEndian.PutUint16(extra[2:], uint16(len(data)))
+	extra = append(extra, data...)
+
+	pr, pw := io.Pipe()
+	go func() {
+		zw, _ := gzip.NewWriterLevel(pw, gzip.BestCompression)
+		zw.Name = path.Base(info.Key)
+		zw.ModTime = info.ModTime
+		zw.Extra = extra
+
+		_, err := io.Copy(zw, r)
+		if err == nil {
+			err = zw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	_, err = t.store.Put(ctx, info.Key+archiveExt, pr)
+	pr.CloseWithError(err)
+	return err
+}
+
+// open returns the decompressed content of the archived object key
+func (t *Tier) open(ctx context.Context, key string) (io.ReadCloser, *Archived, error) {
+	rc, info, err := t.store.Get(ctx, key+archiveExt, 0, -1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	zr, err := gzip.NewReader(rc)
+	if err != nil {
+		rc.Close()
+		return nil, nil, err
+	}
+
+	h, ok := parseExtra(zr.Extra)
+	if !ok {
+		rc.Close()
+		return nil, nil, ErrInvalidArchive
+	}
+
+	a := &Archived{
+		Key:            key,
+		Size:           h.Size,
+		CompressedSize: info.Size,
+		ModTime:        time.Unix(0, h.ModTime),
+		ArchivedAt:     info.ModTime,
+		Meta: storage.Meta{
+			ContentType:  h.ContentType,
+			ETag:         h.ETag,
+			SHA256:       h.SHA256,
+			OriginalName: h.OriginalName,
+			Tags:         h.Tags,
+			Audio:        h.Audio,
+		},
+	}
+	return readCloser{Reader: zr, Closer: rc}, a, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Stat describes the archived object key
+func (t *Tier) Stat(ctx context.Context, key string) (*Archived, error) {
+	rc, a, err := t.open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	rc.Close()
+	return a, nil
+}
+
+// List describes the archived objects below dir, ordered by key. An empty
+// dir lists the whole tier.
+func (t *Tier) List(ctx context.Context, dir string) ([]Archived, error) {
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+
+	objects, _, err := t.store.List(ctx, prefix, "", 0)
+	if errors.Is(err, storage.ErrNotFound) {
+		return []Archived{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	archived := make([]Archived, 0, len(objects))
+	for _, obj := range objects {
+		key, ok := strings.CutSuffix(obj.Key, archiveExt)
+		if !ok {
+			continue
+		}
+
+		a, err := t.Stat(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		archived = append(archived, *a)
+	}
+	return archived, nil
+}
+
+// Restore decompresses the archived object key back into store and takes
+// it out of the tier. A file stored at the key since is not replaced:
+// Restore fails with ErrConflict unless store keeps a version of it.
+func (t *Tier) Restore(ctx context.Context, key string, store storage.Storage) (*storage.ObjectInfo, error) {
+	rc, a, err := t.open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	if _, err = store.Stat(ctx, key); err == nil && !storage.Overwritable(store, key) {
+		return nil, ErrConflict
+	}
+
+	info, err := store.Put(storage.WithMetaOf(ctx, a.Meta), key, rc)
+	if err != nil {
+		return nil, err
+	}
+	return info, t.Delete(ctx, key)
+}
+
+// Delete permanently deletes the archived object key
+func (t *Tier) Delete(ctx context.Context, key string) error {
+	return t.store.Delete(ctx, key+archiveExt)
+}
+
+// parseExtra finds the metadata among the subfields of a gzip extra field
+func parseExtra(extra []byte) (*header, bool) {
+	for len(extra) >= 4 {
+		n := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if len(extra) < 4+n {
+			return nil, false
+		}
+		if extra[0] == extraID[0] && extra[1] == extraID[1] {
+			h := &header{}
+			if err := json.Unmarshal(extra[4:4+n], h); err != nil {
+				return nil, false
+			}
+			return h, true
+		}
+		extra = extra[4+n:]
+	}
+	return nil, false
+}
@@ -0,0 +1,307 @@
+package mediaprobe
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+var ebmlMagic = []byte{0x1A, 0x45, 0xDF, 0xA3}
+
+// Element IDs, with their length marker bits kept as the specification
+// writes them
+const (
+	idEBML           = 0x1A45DFA3
+	idDocType        = 0x4282
+	idSegment        = 0x18538067
+	idInfo           = 0x1549A966
+	idTimestampScale = 0x2AD7B1
+	idDuration       = 0x4489
+	idTracks         = 0x1654AE6B
+	idTrackEntry     = 0xAE
+	idTrackType      = 0x83
+	idCodecID        = 0x86
+	idVideo          = 0xE0
+	idPixelWidth     = 0xB0
+	idPixelHeight    = 0xBA
+	idDisplayWidth   = 0x54B0
+	idDisplayHeight  = 0x54BA
+	idDisplayUnit    = 0x54B2
+	idCluster        = 0x1F43B675
+)
+
+const (
+	trackTypeVideo = 1
+	trackTypeAudio = 2
+)
+
+// unknownSize marks an element whose size isn't known up front, as live
+// recordings write their segment and clusters
+const unknownSize = -1
+
+// matroskaCodecs maps codec IDs to codec names
+var matroskaCodecs = map[string]string{
+	"V_MPEG4/ISO/AVC":  "h264",
+	"V_MPEGH/ISO/HEVC": "hevc",
+	"V_AV1":            "av1",
+	"V_VP8":            "vp8",
+	"V_VP9":            "vp9",
+	"V_THEORA":         "theora",
+	"A_OPUS":           "opus",
+	"A_VORBIS":         "vorbis",
+	"A_AAC":            "aac",
+	"A_FLAC":           "flac",
+	"A_MPEG/L3":        "mp3",
+	"A_AC3":            "ac3",
+	"A_EAC3":           "eac3",
+}
+
+// vint decodes a variable-length integer at the start of data, returning
+// its value and length. IDs keep their marker bit; sizes drop it.
+func vint(data []byte, keepMarker bool) (int64, int) {
+	if len(data) == 0 || data[0] == 0 {
+		return 0, 0
+	}
+
+	length := 1
+	for mask := byte(0x80); data[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if len(data) < length {
+		return 0, 0
+	}
+
+	v := int64(data[0])
+	if !keepMarker {
+		v &= int64(0xFF >> length)
+	}
+	allOnes := v == int64(0xFF>>length)
+	for _, b := range data[1:length] {
+		v = v<<8 | int64(b)
+		allOnes = allOnes && b == 0xFF
+	}
+	if !keepMarker && allOnes {
+		return unknownSize, length
+	}
+	return v, length
+}
+
+type element struct {
+	id     int64
+	offset int64
+	header int64
+	size   int64
+}
+
+// readElement reads the header of the element at offset
+func readElement(r io.ReadSeeker, offset, end int64) (element, error) {
+	head, err := readAt(r, offset, min(12, end-offset))
+	if err != nil {
+		return element{}, err
+	}
+
+	id, idLen := vint(head, true)
+	if idLen == 0 || idLen > 4 {
+		return element{}, ErrMalformed
+	}
+	size, sizeLen := vint(head[idLen:], false)
+	if sizeLen == 0 {
+		return element{}, ErrMalformed
+	}
+	return element{id: id, offset: offset, header: int64(idLen + sizeLen), size: size}, nil
+}
+
+// elements iterates over the elements packed into data. An element of
+// unknown size takes up the rest of data.
+func elements(data []byte, fn func(id int64, payload []byte)) {
+	for len(data) > 0 {
+		id, idLen := vint(data, true)
+		if idLen == 0 {
+			return
+		}
+		size, sizeLen := vint(data[idLen:], false)
+		if sizeLen == 0 {
+			return
+		}
+
+		start := idLen + sizeLen
+		end := len(data)
+		if size != unknownSize {
+			if size > int64(len(data)-start) {
+				return
+			}
+			end = start + int(size)
+		}
+		fn(id, data[start:end])
+		data = data[end:]
+	}
+}
+
+func uintValue(b []byte) int64 {
+	var v int64
+	for _, c := range b {
+		v = v<<8 | int64(c)
+	}
+	return v
+}
+
+func floatValue(b []byte) float64 {
+	switch len(b) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// probeMatroska reads the EBML header and the top-level elements of the
+// segment up to the first cluster, which is where muxers put Info and
+// Tracks
+func probeMatroska(r io.ReadSeeker, size int64) (*Info, error) {
+	header, err := readElement(r, 0, size)
+	if err != nil || header.id != idEBML || header.size == unknownSize {
+		return nil, ErrMalformed
+	}
+	data, err := readAt(r, header.header, header.size)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{Container: ContainerMatroska}
+	elements(
+		data, func(id int64, payload []byte) {
+			if id == idDocType && strings.TrimRight(string(payload), "\x00") == "webm" {
+				info.Container = ContainerWebM
+			}
+		},
+	)
+
+	segment, err := readElement(r, header.header+header.size, size)
+	if err != nil || segment.id != idSegment {
+		return nil, ErrMalformed
+	}
+	end := size
+	if segment.size != unknownSize {
+		end = min(size, segment.offset+segment.header+segment.size)
+	}
+
+	var seenInfo, seenTracks bool
+	for offset := segment.offset + segment.header; offset < end && !(seenInfo && seenTracks); {
+		el, err := readElement(r, offset, end)
+		if err != nil {
+			return nil, err
+		}
+		if el.id == idCluster || el.size == unknownSize {
+			break
+		}
+
+		switch el.id {
+		case idInfo:
+			payload, err := readAt(r, el.offset+el.header, el.size)
+			if err != nil {
+				return nil, err
+			}
+			parseSegmentInfo(payload, info)
+			seenInfo = true
+		case idTracks:
+			payload, err := readAt(r, el.offset+el.header, el.size)
+			if err != nil {
+				return nil, err
+			}
+			parseTracks(payload, info)
+			seenTracks = true
+		}
+		offset = el.offset + el.header + el.size
+	}
+
+	if !seenInfo && !seenTracks {
+		return nil, ErrMalformed
+	}
+	return info, nil
+}
+
+func parseSegmentInfo(data []byte, info *Info) {
+	scale := int64(time.Millisecond)
+	var duration float64
+	elements(
+		data, func(id int64, payload []byte) {
+			switch id {
+			case idTimestampScale:
+				if v := uintValue(payload); v > 0 {
+					scale = v
+				}
+			case idDuration:
+				duration = floatValue(payload)
+			}
+		},
+	)
+	if duration > 0 {
+		info.Duration = time.Duration(duration * float64(scale))
+	}
+}
+
+func parseTracks(data []byte, info *Info) {
+	elements(
+		data, func(id int64, entry []byte) {
+			if id != idTrackEntry {
+				return
+			}
+
+			var trackType int64
+			var codec string
+			var video []byte
+			elements(
+				entry, func(id int64, payload []byte) {
+					switch id {
+					case idTrackType:
+						trackType = uintValue(payload)
+					case idCodecID:
+						codec = strings.TrimRight(string(payload), "\x00")
+					case idVideo:
+						video = payload
+					}
+				},
+			)
+			if name, ok := matroskaCodecs[codec]; ok {
+				codec = name
+			}
+
+			switch {
+			case trackType == trackTypeVideo && info.VideoCodec == "":
+				info.VideoCodec = codec
+				info.Width, info.Height = videoSize(video)
+			case trackType == trackTypeAudio && info.AudioCodec == "":
+				info.AudioCodec = codec
+			}
+		},
+	)
+}
+
+// videoSize prefers the display size over the coded size when it is set
+// in pixels rather than as an aspect ratio or a physical size
+func videoSize(video []byte) (int, int) {
+	var width, height, displayWidth, displayHeight, displayUnit int64
+	elements(
+		video, func(id int64, payload []byte) {
+			switch id {
+			case idPixelWidth:
+				width = uintValue(payload)
+			case idPixelHeight:
+				height = uintValue(payload)
+			case idDisplayWidth:
+				displayWidth = uintValue(payload)
+			case idDisplayHeight:
+				displayHeight = uintValue(payload)
+			case idDisplayUnit:
+				displayUnit = uintValue(payload)
+			}
+		},
+	)
+	if displayUnit == 0 && displayWidth > 0 && displayHeight > 0 {
+		return int(displayWidth), int(displayHeight)
+	}
+	return int(width), int(height)
+}
@@ -0,0 +1,229 @@
+package mediaprobe
+
+import (
+	"encoding/binary"
+	"io"
+	"strings"
+	"time"
+)
+
+// mp4Codecs maps sample entry types to codec names
+var mp4Codecs = map[string]string{
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "hevc",
+	"hev1": "hevc",
+	"av01": "av1",
+	"vp08": "vp8",
+	"vp09": "vp9",
+	"mp4v": "mpeg4",
+	"mp4a": "aac",
+	"Opus": "opus",
+	"fLaC": "flac",
+	"ac-3": "ac3",
+	"ec-3": "eac3",
+	".mp3": "mp3",
+}
+
+type box struct {
+	typ    string
+	offset int64
+	// header is the size of the size and type fields
+	header int64
+	size   int64
+}
+
+// nextBox reads the header of the box at offset. A box whose size is 0
+// extends to the end of the file.
+func nextBox(r io.ReadSeeker, offset, end int64) (box, error) {
+	head, err := readAt(r, offset, min(16, end-offset))
+	if err != nil || len(head) < 8 {
+		return box{}, ErrMalformed
+	}
+
+	b := box{typ: string(head[4:8]), offset: offset, header: 8, size: int64(binary.BigEndian.Uint32(head))}
+	switch b.size {
+	case 0:
+		b.size = end - offset
+	case 1:
+		if len(head) < 16 {
+			return box{}, ErrMalformed
+		}
+		b.header, b.size = 16, int64(binary.BigEndian.Uint64(head[8:]))
+	}
+	if b.size < b.header || offset+b.size > end {
+		return box{}, ErrMalformed
+	}
+	return b, nil
+}
+
+// probeMP4 walks the top-level boxes, noting whether moov comes before
+// mdat, and parses moov without reading the media data
+func probeMP4(r io.ReadSeeker, size int64) (*Info, error) {
+	info := &Info{Container: ContainerMP4}
+	var moov []byte
+	seenMdat := false
+
+	for offset := int64(0); offset < size && moov == nil; {
+		b, err := nextBox(r, offset, size)
+		if err != nil {
+			return nil, err
+		}
+
+		switch b.typ {
+		case "ftyp":
+			brand, err := readAt(r, b.offset+b.header, min(4, b.size-b.header))
+			if err != nil {
+				return nil, err
+			}
+			if string(brand) == "qt  " {
+				info.Container = ContainerMOV
+			}
+		case "mdat":
+			seenMdat = true
+		case "moov":
+			if moov, err = readAt(r, b.offset+b.header, b.size-b.header); err != nil {
+				return nil, err
+			}
+			info.FastStart = !seenMdat
+		}
+		offset += b.size
+	}
+
+	if moov == nil {
+		return nil, ErrMalformed
+	}
+	parseMoov(moov, info)
+	return info, nil
+}
+
+// children iterates over the boxes packed into data
+func children(data []byte, fn func(typ string, payload []byte)) {
+	for len(data) >= 8 {
+		size := int(binary.BigEndian.Uint32(data))
+		header := 8
+		switch {
+		case size == 0:
+			size = len(data)
+		case size == 1 && len(data) >= 16:
+			size, header = int(binary.BigEndian.Uint64(data[8:])), 16
+		}
+		if size < header || size > len(data) {
+			return
+		}
+		fn(string(data[4:8]), data[header:size])
+		data = data[size:]
+	}
+}
+
+func child(data []byte, path ...string) []byte {
+	for _, typ := range path {
+		var found []byte
+		children(
+			data, func(t string, payload []byte) {
+				if found == nil && t == typ {
+					found = payload
+				}
+			},
+		)
+		if found == nil {
+			return nil
+		}
+		data = found
+	}
+	return data
+}
+
+func parseMoov(moov []byte, info *Info) {
+	if mvhd := child(moov, "mvhd"); len(mvhd) > 0 {
+		info.Duration = mediaDuration(mvhd)
+	}
+
+	children(
+		moov, func(typ string, trak []byte) {
+			if typ != "trak" {
+				return
+			}
+
+			mdia := child(trak, "mdia")
+			hdlr := child(mdia, "hdlr")
+			if len(hdlr) < 12 {
+				return
+			}
+			if info.Duration == 0 {
+				info.Duration = mediaDuration(child(mdia, "mdhd"))
+			}
+
+			entry := child(mdia, "minf", "stbl", "stsd")
+			if len(entry) < 16 {
+				return
+			}
+			// Skip version, flags and the entry count to the first entry
+			entry = entry[8:]
+			codec := string(entry[4:8])
+			if name, ok := mp4Codecs[codec]; ok {
+				codec = name
+			}
+
+			switch string(hdlr[8:12]) {
+			case "vide":
+				if info.VideoCodec != "" {
+					return
+				}
+				info.VideoCodec = strings.TrimSpace(codec)
+				info.Width, info.Height = trackSize(child(trak, "tkhd"))
+				if (info.Width == 0 || info.Height == 0) && len(entry) >= 36 {
+					info.Width = int(binary.BigEndian.Uint16(entry[32:]))
+					info.Height = int(binary.BigEndian.Uint16(entry[34:]))
+				}
+			case "soun":
+				if info.AudioCodec == "" {
+					info.AudioCodec = strings.TrimSpace(codec)
+				}
+			}
+		},
+	)
+}
+
+// mediaDuration reads the timescale and duration of an mvhd or mdhd box,
+// which share their layout up to the duration
+func mediaDuration(b []byte) time.Duration {
+	if len(b) < 20 {
+		return 0
+	}
+
+	var timescale, duration uint64
+	unknown := uint64(1<<32 - 1)
+	switch {
+	case b[0] == 1 && len(b) >= 32:
+		timescale, duration = uint64(binary.BigEndian.Uint32(b[20:])), binary.BigEndian.Uint64(b[24:])
+		unknown = 1<<64 - 1
+	case b[0] == 0:
+		timescale, duration = uint64(binary.BigEndian.Uint32(b[12:])), uint64(binary.BigEndian.Uint32(b[16:]))
+	}
+	if timescale == 0 || duration == 0 || duration == unknown {
+		return 0
+	}
+	return time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+}
+
+// trackSize reads the presentation size from a tkhd box, in 16.16 fixed
+// point at its end. Tracks rotated by a quarter turn through the matrix in
+// front of it are displayed with the sides swapped.
+func trackSize(tkhd []byte) (int, int) {
+	offset := 76
+	if len(tkhd) > 0 && tkhd[0] == 1 {
+		offset = 88
+	}
+	if len(tkhd) < offset+8 {
+		return 0, 0
+	}
+
+	width := int(binary.BigEndian.Uint32(tkhd[offset:]) >> 16)
+	height := int(binary.BigEndian.Uint32(tkhd[offset+4:]) >> 16)
+	matrix := tkhd[offset-36:]
+	if binary.BigEndian.Uint32(matrix) == 0 && binary.BigEndian.Uint32(matrix[16:]) == 0 {
+		width, height = height, width
+	}
+	return width, height
+}
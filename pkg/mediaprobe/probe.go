@@ -0,0 +1,91 @@
+// Package mediaprobe reads the container headers of video files without
+// decoding them. ISO-BMFF (MP4, MOV) and Matroska (MKV, WebM) are supported.
+package mediaprobe
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+var ErrUnsupported = errors.New("unsupported container")
+var ErrMalformed = errors.New("malformed container")
+
+const (
+	ContainerMP4      = "mp4"
+	ContainerMOV      = "mov"
+	ContainerWebM     = "webm"
+	ContainerMatroska = "matroska"
+)
+
+// maxHeaderSize bounds how much of a file is read into memory to parse a
+// single header, such as the moov box or the Tracks element
+const maxHeaderSize = 64 << 20
+
+// Info describes a video file. Width and Height are those of the first
+// video track; codecs are reported by their common names, e.g. "h264".
+type Info struct {
+	Container  string
+	Duration   time.Duration
+	Width      int
+	Height     int
+	VideoCodec string
+	AudioCodec string
+	// FastStart reports for ISO-BMFF files whether the moov box precedes the
+	// media data, so playback can start before the whole file has loaded
+	FastStart bool
+}
+
+// HasFastStart reports whether FastStart applies to the container
+func (i *Info) HasFastStart() bool {
+	return i.Container == ContainerMP4 || i.Container == ContainerMOV
+}
+
+// Probe parses the headers of the video in r, which is size bytes long
+func Probe(r io.ReadSeeker, size int64) (*Info, error) {
+	head := make([]byte, 12)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	head = head[:n]
+
+	switch {
+	case len(head) >= 8 && string(head[4:8]) == "ftyp":
+		return probeMP4(r, size)
+	case bytes.HasPrefix(head, ebmlMagic):
+		return probeMatroska(r, size)
+	}
+	return nil, ErrUnsupported
+}
+
+// readAt reads length bytes at offset, refusing headers that are too large
+// to hold in memory
+func readAt(r io.ReadSeeker, offset, length int64) ([]byte, error) {
+	if length < 0 || length > maxHeaderSize {
+		return nil, ErrMalformed
+	}
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return nil, ErrMalformed
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// Supports reports whether Probe understands files of a detected media type
+func Supports(contentType string) bool {
+	switch strings.TrimSpace(strings.Split(contentType, ";")[0]) {
+	case "video/mp4", "video/quicktime", "video/x-m4v", "video/3gpp", "video/3gpp2", "video/webm", "video/x-matroska":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+package mediaprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+	"time"
+)
+
+func mp4Box(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	out := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(out, typ...), body...)
+}
+
+// fullBox prefixes payload with a version and zero flags
+func fullBox(typ string, version byte, payload ...[]byte) []byte {
+	return mp4Box(typ, append([]byte{version, 0, 0, 0}, bytes.Join(payload, nil)...))
+}
+
+func u32(v ...uint32) []byte {
+	var out []byte
+	for _, n := range v {
+		out = binary.BigEndian.AppendUint32(out, n)
+	}
+	return out
+}
+
+// tkhd builds a version 0 track header; rotated turns the matrix a quarter
+func tkhd(width, height uint32, rotated bool) []byte {
+	matrix := u32(0x10000, 0, 0, 0, 0x10000, 0, 0, 0, 0x40000000)
+	if rotated {
+		matrix = u32(0, 0x10000, 0, 0xFFFF0000, 0, 0, 0, 0, 0x40000000)
+	}
+	return fullBox("tkhd", 0, make([]byte, 20), make([]byte, 16), matrix, u32(width<<16, height<<16))
+}
+
+func trak(handler, codec string, header []byte, entry []byte) []byte {
+	stsd := fullBox("stsd", 0, u32(1), mp4Box(codec, entry))
+	return mp4Box(
+		"trak", header, mp4Box(
+			"mdia",
+			fullBox("mdhd", 0, u32(0, 0, 48000, 48000*12)),
+			fullBox("hdlr", 0, u32(0), []byte(handler), make([]byte, 12)),
+			mp4Box("minf", mp4Box("stbl", stsd)),
+		),
+	)
+}
+
+func testMoov(mvhd []byte, video []byte) []byte {
+	visual := append(make([]byte, 24), 0x02, 0x80, 0x01, 0x68) // 640x360
+	return mp4Box(
+		"moov", mvhd,
+		trak("vide", "avc1", video, visual),
+		trak("soun", "mp4a", tkhd(0, 0, false), make([]byte, 20)),
+	)
+}
+
+func TestProbeMP4(t *testing.T) {
+	ftyp := mp4Box("ftyp", []byte("isom"), u32(0x200), []byte("isomavc1"))
+	mvhd := fullBox("mvhd", 0, u32(0, 0, 1000, 12500), make([]byte, 80))
+	moov := testMoov(mvhd, tkhd(1920, 1080, false))
+	mdat := mp4Box("mdat", make([]byte, 64))
+
+	probe := func(t *testing.T, parts ...[]byte) *Info {
+		data := bytes.Join(parts, nil)
+		info, err := Probe(bytes.NewReader(data), int64(len(data)))
+		require.NoError(t, err)
+		return info
+	}
+
+	t.Run(
+		"Faststart", func(t *testing.T) {
+			info := probe(t, ftyp, moov, mdat)
+			assert.Equal(t, ContainerMP4, info.Container)
+			assert.Equal(t, 12500*time.Millisecond, info.Duration)
+			assert.Equal(t, 1920, info.Width)
+			assert.Equal(t, 1080, info.Height)
+			assert.Equal(t, "h264", info.VideoCodec)
+			assert.Equal(t, "aac", info.AudioCodec)
+			assert.True(t, info.HasFastStart())
+			assert.True(t, info.FastStart)
+		},
+	)
+
+	t.Run(
+		"Moov At The End", func(t *testing.T) {
+			// A 64-bit size on mdat, as large recordings have
+			large := append(u32(1), "mdat"...)
+			large = binary.BigEndian.AppendUint64(large, 16+32)
+			large = append(large, make([]byte, 32)...)
+
+			info := probe(t, ftyp, large, moov)
+			assert.False(t, info.FastStart)
+			assert.Equal(t, "h264", info.VideoCodec)
+		},
+	)
+
+	t.Run(
+		"Rotated", func(t *testing.T) {
+			info := probe(t, ftyp, testMoov(mvhd, tkhd(1920, 1080, true)), mdat)
+			assert.Equal(t, 1080, info.Width)
+			assert.Equal(t, 1920, info.Height)
+		},
+	)
+
+	t.Run(
+		"Fallbacks", func(t *testing.T) {
+			// No movie duration and no track size: the media header and
+			// the sample entry are used instead
+			mov := mp4Box("ftyp", []byte("qt  "), u32(0))
+			empty := fullBox("mvhd", 1, make([]byte, 16), u32(600), make([]byte, 8), make([]byte, 80))
+
+			info := probe(t, mov, testMoov(empty, tkhd(0, 0, false)), mdat)
+			assert.Equal(t, ContainerMOV, info.Container)
+			assert.Equal(t, 12*time.Second, info.Duration)
+			assert.Equal(t, 640, info.Width)
+			assert.Equal(t, 360, info.Height)
+		},
+	)
+
+	t.Run(
+		"Malformed", func(t *testing.T) {
+			data := append(bytes.Clone(ftyp), moov[:len(moov)/2]...)
+			_, err := Probe(bytes.NewReader(data), int64(len(data)))
+			assert.ErrorIs(t, err, ErrMalformed)
+
+			data = append(bytes.Clone(ftyp), mdat...)
+			_, err = Probe(bytes.NewReader(data), int64(len(data)))
+			assert.ErrorIs(t, err, ErrMalformed)
+		},
+	)
+}
+
+// ebml encodes an element with an eight byte size
+func ebml(id uint32, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	var out []byte
+	for shift := 24; shift >= 0; shift -= 8 {
+		if b := byte(id >> shift); b != 0 || len(out) > 0 {
+			out = append(out, b)
+		}
+	}
+	out = binary.BigEndian.AppendUint64(out, uint64(len(body))|1<<56)
+	return append(out, body...)
+}
+
+func ebmlUint(id uint32, v uint64) []byte {
+	return ebml(id, binary.BigEndian.AppendUint64(nil, v))
+}
+
+func ebmlFloat(id uint32, v float64) []byte {
+	return ebml(id, binary.BigEndian.AppendUint64(nil, math.Float64bits(v)))
+}
+
+func TestProbeMatroska(t *testing.T) {
+	header := ebml(idEBML, ebml(idDocType, []byte("webm")))
+	info := ebml(idInfo, ebmlUint(idTimestampScale, 1_000_000), ebmlFloat(idDuration, 12500))
+	tracks := ebml(
+		idTracks,
+		ebml(
+			idTrackEntry, ebmlUint(idTrackType, trackTypeVideo), ebml(idCodecID, []byte("V_VP9")),
+			ebml(idVideo, ebmlUint(idPixelWidth, 640), ebmlUint(idPixelHeight, 360)),
+		),
+		ebml(idTrackEntry, ebmlUint(idTrackType, trackTypeAudio), ebml(idCodecID, []byte("A_OPUS"))),
+	)
+	seekHead := ebml(0x114D9B74, make([]byte, 40))
+	cluster := ebml(idCluster, make([]byte, 128))
+
+	// A segment of unknown size, as live recorders write it
+	segment := func(children ...[]byte) []byte {
+		return append([]byte{0x18, 0x53, 0x80, 0x67, 0xFF}, bytes.Join(children, nil)...)
+	}
+
+	probe := func(t *testing.T, parts ...[]byte) *Info {
+		data := bytes.Join(parts, nil)
+		info, err := Probe(bytes.NewReader(data), int64(len(data)))
+		require.NoError(t, err)
+		return info
+	}
+
+	t.Run(
+		"WebM", func(t *testing.T) {
+			res := probe(t, header, segment(seekHead, info, tracks, cluster))
+			assert.Equal(t, ContainerWebM, res.Container)
+			assert.Equal(t, 12500*time.Millisecond, res.Duration)
+			assert.Equal(t, 640, res.Width)
+			assert.Equal(t, 360, res.Height)
+			assert.Equal(t, "vp9", res.VideoCodec)
+			assert.Equal(t, "opus", res.AudioCodec)
+			assert.False(t, res.HasFastStart())
+		},
+	)
+
+	t.Run(
+		"Matroska With Display Size", func(t *testing.T) {
+			mkv := ebml(idEBML, ebml(idDocType, []byte("matroska")))
+			anamorphic := ebml(
+				idTracks, ebml(
+					idTrackEntry, ebmlUint(idTrackType, trackTypeVideo), ebml(idCodecID, []byte("V_MPEG4/ISO/AVC")),
+					ebml(
+						idVideo, ebmlUint(idPixelWidth, 720), ebmlUint(idPixelHeight, 576),
+						ebmlUint(idDisplayWidth, 1024), ebmlUint(idDisplayHeight, 576),
+					),
+				),
+			)
+			scaled := ebml(idInfo, ebmlUint(idTimestampScale, 1000), ebmlFloat(idDuration, 3_000_000))
+
+			res := probe(t, mkv, ebml(idSegment, scaled, anamorphic))
+			assert.Equal(t, ContainerMatroska, res.Container)
+			assert.Equal(t, 3*time.Second, res.Duration)
+			assert.Equal(t, 1024, res.Width)
+			assert.Equal(t, 576, res.Height)
+			assert.Equal(t, "h264", res.VideoCodec)
+			assert.Empty(t, res.AudioCodec)
+		},
+	)
+
+	t.Run(
+		"Tracks After Clusters", func(t *testing.T) {
+			res := probe(t, header, segment(info, cluster, tracks))
+			assert.Equal(t, 12500*time.Millisecond, res.Duration)
+			assert.Empty(t, res.VideoCodec)
+		},
+	)
+
+	t.Run(
+		"Malformed", func(t *testing.T) {
+			data := append(bytes.Clone(header), segment(cluster)...)
+			_, err := Probe(bytes.NewReader(data), int64(len(data)))
+			assert.ErrorIs(t, err, ErrMalformed)
+
+			_, err = Probe(bytes.NewReader(header[:10]), 10)
+			assert.ErrorIs(t, err, ErrMalformed)
+		},
+	)
+}
+
+func TestProbeUnsupported(t *testing.T) {
+	data := []byte("just some text")
+	_, err := Probe(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
+
+func TestSupports(t *testing.T) {
+	assert.True(t, Supports("video/mp4"))
+	assert.True(t, Supports("video/webm; codecs=vp9"))
+	assert.True(t, Supports("video/x-matroska"))
+	assert.False(t, Supports("video/x-msvideo"))
+	assert.False(t, Supports("image/png"))
+}
@@ -0,0 +1,48 @@
+package model
+
+type ArchiveReq struct {
+	Paths  []string `json:"paths"`
+	Format string   `json:"format"`
+}
+
+// ExtractRes reports what unpacking an uploaded archive did with each of
+// its files
+type ExtractRes struct {
+	Extracted int               `json:"extracted"`
+	Rejected  int               `json:"rejected"`
+	Failed    int               `json:"failed"`
+	Entries   []ExtractEntryRes `json:"entries"`
+}
+
+type ExtractEntryRes struct {
+	// Name is the path of the entry inside the archive
+	Name string `json:"name"`
+	// Status is extracted, rejected for entries that are unsafe or not
+	// regular files, or failed when storing the file did not work
+	Status string   `json:"status"`
+	Error  string   `json:"error,omitempty"`
+	File   *FileRes `json:"file,omitempty"`
+}
+
+// ArchiveEntryRes describes a file inside a stored ZIP archive
+type ArchiveEntryRes struct {
+	// Path is the virtual path of the entry: the path of the archive, !
+	// and the name of the entry
+	Path           string `json:"path"`
+	Name           string `json:"name"`
+	Size           int64  `json:"size"`
+	CompressedSize int64  `json:"compressedSize"`
+	ModTime        int64  `json:"modTime"`
+	// Stored entries are not compressed, so they can be read in ranges
+	Stored bool `json:"stored"`
+}
+
+// ArchiveListRes is a page of the files inside a stored archive, shaped
+// like the pages of /list
+type ArchiveListRes struct {
+	Data        []ArchiveEntryRes `json:"data"`
+	Count       int               `json:"count"`
+	TotalPages  int               `json:"total_pages"`
+	CurrentPage int               `json:"current_page"`
+	HasNextPage bool              `json:"has_next_page"`
+}
@@ -0,0 +1,19 @@
+package model
+
+// DirRes summarises a directory. Files and Size count the files directly
+// in it, TotalFiles and TotalSize everything below it.
+type DirRes struct {
+	Path       string   `json:"path"`
+	Files      int      `json:"files"`
+	Size       int64    `json:"size"`
+	TotalFiles int      `json:"totalFiles"`
+	TotalSize  int64    `json:"totalSize"`
+	Dirs       []DirRes `json:"dirs,omitempty"`
+}
+
+// RmDirRes reports what removing a directory deleted
+type RmDirRes struct {
+	Path  string `json:"path"`
+	Files int    `json:"files"`
+	Dirs  int    `json:"dirs"`
+}
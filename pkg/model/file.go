@@ -1,6 +1,43 @@
 package model
 
 type FileRes struct {
-	Path    string `json:"path"`
-	ModTime int64  `json:"modTime"`
+	Path         string `json:"path"`
+	ModTime      int64  `json:"modTime"`
+	Size         int64  `json:"size"`
+	ContentType  string `json:"contentType,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	SHA256       string `json:"sha256,omitempty"`
+	OriginalName string `json:"originalName,omitempty"`
+
+	// Tags label the file for lifecycle rules, as sent with its upload
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Thumbnails maps preset names to their URLs. Only upload responses
+	// carry them; they are rendered in the background.
+	Thumbnails map[string]string `json:"thumbnails,omitempty"`
+
+	// Placeholder is set for images once it has been computed, which
+	// happens on upload or the first time the image is described by /stat
+	Placeholder *PlaceholderRes `json:"placeholder,omitempty"`
+
+	// Video is set for MP4, MOV, WebM and Matroska files once they have
+	// been probed, in the same way as Placeholder
+	Video *VideoMetaRes `json:"video,omitempty"`
+
+	// Audio is set for MP3, FLAC, Ogg Vorbis and Opus files once their tags
+	// have been read, in the same way as Placeholder
+	Audio *AudioMetaRes `json:"audio,omitempty"`
+
+	// Warnings point out problems with an upload that didn't stop it from
+	// being stored. Only upload responses carry them.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// PlaceholderRes is what a client shows while an image loads
+type PlaceholderRes struct {
+	BlurHash string `json:"blurHash"`
+	// Preview is a data URI of the image scaled down to 16 pixels
+	Preview string `json:"preview"`
+	// Color is the dominant colour as #rrggbb
+	Color string `json:"color,omitempty"`
 }
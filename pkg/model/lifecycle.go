@@ -0,0 +1,39 @@
+package model
+
+// LifecycleFileRes is a file a lifecycle rule selects, and what the rule
+// does to it
+type LifecycleFileRes struct {
+	Path    string `json:"path"`
+	Rule    string `json:"rule"`
+	Action  string `json:"action"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modTime"`
+}
+
+// LifecycleReportRes is what applying the lifecycle rules would do
+type LifecycleReportRes struct {
+	At      int64              `json:"at"`
+	Expire  int                `json:"expire"`
+	Archive int                `json:"archive"`
+	Size    int64              `json:"size"`
+	Files   []LifecycleFileRes `json:"files"`
+}
+
+// ArchivedRes describes a file in the archive tier. Size is that of the
+// file and CompressedSize what it takes in the tier.
+type ArchivedRes struct {
+	Path           string            `json:"path"`
+	Size           int64             `json:"size"`
+	CompressedSize int64             `json:"compressedSize"`
+	ModTime        int64             `json:"modTime"`
+	ArchivedAt     int64             `json:"archivedAt"`
+	ContentType    string            `json:"contentType,omitempty"`
+	ETag           string            `json:"etag,omitempty"`
+	SHA256         string            `json:"sha256,omitempty"`
+	OriginalName   string            `json:"originalName,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`
+}
+
+type ArchivedListRes struct {
+	Files []ArchivedRes `json:"files"`
+}
@@ -0,0 +1,27 @@
+package model
+
+// VideoMetaRes describes the container of a video. Duration is in seconds;
+// FastStart is only reported for MP4 and MOV files.
+type VideoMetaRes struct {
+	Container  string  `json:"container"`
+	Duration   float64 `json:"duration"`
+	Width      int     `json:"width,omitempty"`
+	Height     int     `json:"height,omitempty"`
+	VideoCodec string  `json:"videoCodec,omitempty"`
+	AudioCodec string  `json:"audioCodec,omitempty"`
+	FastStart  *bool   `json:"faststart,omitempty"`
+}
+
+// AudioMetaRes holds the tags of an audio file. Duration is in seconds and
+// Cover is the URL of the embedded cover art, if there is any.
+type AudioMetaRes struct {
+	Format   string  `json:"format"`
+	Title    string  `json:"title,omitempty"`
+	Artist   string  `json:"artist,omitempty"`
+	Album    string  `json:"album,omitempty"`
+	Genre    string  `json:"genre,omitempty"`
+	Year     string  `json:"year,omitempty"`
+	Track    string  `json:"track,omitempty"`
+	Duration float64 `json:"duration"`
+	Cover    string  `json:"cover,omitempty"`
+}
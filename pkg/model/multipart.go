@@ -0,0 +1,17 @@
+package model
+
+type MultipartUploadRes struct {
+	UploadID string `json:"uploadId"`
+	Path     string `json:"path"`
+}
+
+type PartRes struct {
+	PartNumber int    `json:"partNumber"`
+	ETag       string `json:"etag"`
+	Size       int64  `json:"size,omitempty"`
+	ModTime    int64  `json:"modTime,omitempty"`
+}
+
+type CompleteMultipartReq struct {
+	Parts []PartRes `json:"parts"`
+}
@@ -0,0 +1,14 @@
+package model
+
+type PresignReq struct {
+	URL       string `json:"url"`
+	Method    string `json:"method"`
+	ExpiresIn int64  `json:"expiresIn"`
+	MaxLength int64  `json:"maxLength"`
+}
+
+type PresignRes struct {
+	URL       string `json:"url"`
+	Method    string `json:"method"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
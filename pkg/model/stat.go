@@ -0,0 +1,23 @@
+package model
+
+// StatRes describes a single file. Image is only set for JPEG, PNG and GIF
+// files.
+type StatRes struct {
+	FileRes
+	Image *ImageMetaRes `json:"image,omitempty"`
+}
+
+// ImageMetaRes holds the dimensions and EXIF details of an image. Width and
+// Height are the displayed size, with Orientation already applied.
+type ImageMetaRes struct {
+	Format      string `json:"format"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Orientation int    `json:"orientation"`
+	Make        string `json:"make,omitempty"`
+	Model       string `json:"model,omitempty"`
+	LensModel   string `json:"lensModel,omitempty"`
+	Software    string `json:"software,omitempty"`
+	TakenAt     string `json:"takenAt,omitempty"`
+	HasLocation bool   `json:"hasLocation"`
+}
@@ -0,0 +1,16 @@
+package model
+
+type ThumbnailJobRes struct {
+	Path    string `json:"path"`
+	Preset  string `json:"preset"`
+	State   string `json:"state"`
+	Error   string `json:"error,omitempty"`
+	Queued  int64  `json:"queued"`
+	Updated int64  `json:"updated"`
+}
+
+type ThumbnailStatusRes struct {
+	Pending int               `json:"pending"`
+	Failed  int               `json:"failed"`
+	Jobs    []ThumbnailJobRes `json:"jobs"`
+}
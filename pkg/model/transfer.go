@@ -0,0 +1,29 @@
+package model
+
+// TransferReq moves or copies the file at Src, or every file below the
+// directory Src, to Dst
+type TransferReq struct {
+	Src string `json:"src"`
+	Dst string `json:"dst"`
+	// Overwrite is fail (default), skip or replace
+	Overwrite string `json:"overwrite"`
+}
+
+// TransferRes reports what a move or copy did with each file
+type TransferRes struct {
+	Done    int                `json:"done"`
+	Skipped int                `json:"skipped"`
+	Failed  int                `json:"failed"`
+	Entries []TransferEntryRes `json:"entries"`
+}
+
+type TransferEntryRes struct {
+	Src string `json:"src"`
+	Dst string `json:"dst"`
+	// Status is moved or copied, skipped when the destination exists and
+	// overwrite is skip, conflict when it exists and overwrite is fail, or
+	// failed
+	Status string   `json:"status"`
+	Error  string   `json:"error,omitempty"`
+	File   *FileRes `json:"file,omitempty"`
+}
@@ -0,0 +1,25 @@
+package model
+
+// TrashItemRes describes a deleted file waiting in the trash. Path is where
+// it is restored to, and ExpiresAt when it is purged for good.
+type TrashItemRes struct {
+	ID           string `json:"id"`
+	Path         string `json:"path"`
+	DeletedAt    int64  `json:"deletedAt"`
+	ExpiresAt    int64  `json:"expiresAt"`
+	Size         int64  `json:"size"`
+	ModTime      int64  `json:"modTime"`
+	ContentType  string `json:"contentType,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	SHA256       string `json:"sha256,omitempty"`
+	OriginalName string `json:"originalName,omitempty"`
+}
+
+// TrashListRes is a page of the trash, shaped like the pages of /list
+type TrashListRes struct {
+	Data        []TrashItemRes `json:"data"`
+	Count       int            `json:"count"`
+	TotalPages  int            `json:"total_pages"`
+	CurrentPage int            `json:"current_page"`
+	HasNextPage bool           `json:"has_next_page"`
+}
@@ -0,0 +1,24 @@
+package model
+
+// VersionRes describes one version of a file. VersionID is "null" for a
+// file stored before versioning applied to it.
+type VersionRes struct {
+	Path         string `json:"path"`
+	VersionID    string `json:"versionId"`
+	Latest       bool   `json:"latest"`
+	DeleteMarker bool   `json:"deleteMarker"`
+	Size         int64  `json:"size"`
+	ModTime      int64  `json:"modTime"`
+	ContentType  string `json:"contentType,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	SHA256       string `json:"sha256,omitempty"`
+	OriginalName string `json:"originalName,omitempty"`
+}
+
+type VersionsRes struct {
+	Versions []VersionRes `json:"versions"`
+}
+
+type PurgeRes struct {
+	Purged int `json:"purged"`
+}
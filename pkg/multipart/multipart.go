@@ -0,0 +1,354 @@
+// Package multipart implements S3-style multipart uploads. Parts are staged
+// as files in a local directory and only assembled into the storage backend
+// once the upload is completed. Uploads that are never completed are purged
+// once they expire.
+package multipart
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const MinPartNumber = 1
+const MaxPartNumber = 10000
+
+var ErrNoSuchUpload = errors.New("upload does not exist")
+var ErrInvalidPartNumber = errors.New("part number must be between 1 and 10000")
+var ErrInvalidPart = errors.New("part is missing or its etag does not match")
+var ErrInvalidPartOrder = errors.New("parts must be listed in ascending order")
+var ErrPartTooSmall = errors.New("part is smaller than the minimum part size")
+var ErrPartTooLarge = errors.New("part is larger than the maximum part size")
+
+const uploadFile = "upload.json"
+const partExt = ".part"
+const metaExt = ".json"
+
+type Upload struct {
+	ID           string    `json:"id"`
+	Key          string    `json:"key"`
+	OriginalName string    `json:"originalName,omitempty"`
+	Initiated    time.Time `json:"initiated"`
+}
+
+type Part struct {
+	Number  int       `json:"partNumber"`
+	ETag    string    `json:"etag"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// CompletedPart is one entry of the manifest sent to complete an upload
+type CompletedPart struct {
+	Number int    `json:"partNumber"`
+	ETag   string `json:"etag"`
+}
+
+type Manager struct {
+	dir         string
+	store       storage.Storage
+	minPartSize int64
+	maxPartSize int64
+	expiry      time.Duration
+}
+
+// New creates a manager staging parts under dir. Uploads not completed
+// within expiry of being initiated are removed by Purge.
+func New(dir string, store storage.Storage, minPartSize, maxPartSize int64, expiry time.Duration) *Manager {
+	return &Manager{
+		dir:         dir,
+		store:       store,
+		minPartSize: minPartSize,
+		maxPartSize: maxPartSize,
+		expiry:      expiry,
+	}
+}
+
+// Initiate starts a new upload that will be stored under key. The
+// original file name, if known, is recorded with the finished object.
+func (m *Manager) Initiate(key, originalName string) (*Upload, error) {
+	if !storage.ValidKey(key) {
+		return nil, storage.ErrInvalidKey
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	upload := &Upload{
+		ID:           hex.EncodeToString(id),
+		Key:          key,
+		OriginalName: originalName,
+		Initiated:    time.Now(),
+	}
+	if err := os.MkdirAll(m.uploadDir(upload.ID), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return nil, err
+	}
+	if err = writeFile(filepath.Join(m.uploadDir(upload.ID), uploadFile), bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+func (m *Manager) Get(id string) (*Upload, error) {
+	if !validID(id) {
+		return nil, ErrNoSuchUpload
+	}
+
+	data, err := os.ReadFile(filepath.Join(m.uploadDir(id), uploadFile))
+	if err != nil {
+		return nil, ErrNoSuchUpload
+	}
+
+	upload := &Upload{}
+	if err = json.Unmarshal(data, upload); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// PutPart stores part number n of an upload. Uploading the same number
+// again replaces the previous part, so failed parts can simply be retried.
+func (m *Manager) PutPart(id string, n int, r io.Reader) (*Part, error) {
+	if n < MinPartNumber || n > MaxPartNumber {
+		return nil, ErrInvalidPartNumber
+	}
+	if _, err := m.Get(id); err != nil {
+		return nil, err
+	}
+
+	hash := md5.New()
+	counter := &countingReader{r: io.TeeReader(r, hash), max: m.maxPartSize}
+	if err := writeFile(m.partPath(id, n, partExt), counter); err != nil {
+		return nil, err
+	}
+
+	part := &Part{
+		Number:  n,
+		ETag:    `"` + hex.EncodeToString(hash.Sum(nil)) + `"`,
+		Size:    counter.n,
+		ModTime: time.Now(),
+	}
+
+	data, err := json.Marshal(part)
+	if err != nil {
+		return nil, err
+	}
+	if err = writeFile(m.partPath(id, n, metaExt), bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return part, nil
+}
+
+// ListParts returns the uploaded parts ordered by part number
+func (m *Manager) ListParts(id string) ([]Part, error) {
+	if _, err := m.Get(id); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(m.uploadDir(id))
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([]Part, 0, len(entries))
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), metaExt)
+		if !ok || name == strings.TrimSuffix(uploadFile, metaExt) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(m.uploadDir(id), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var part Part
+		if err = json.Unmarshal(data, &part); err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+
+	sort.Slice(
+		parts, func(i, j int) bool {
+			return parts[i].Number < parts[j].Number
+		},
+	)
+	return parts, nil
+}
+
+// Complete assembles the parts named in the manifest into the final object.
+// The object only appears once every part has been copied, and the upload
+// is removed afterwards. The returned ETag follows the S3 convention of
+// hashing the part digests and appending the part count.
+func (m *Manager) Complete(ctx context.Context, id string, manifest []CompletedPart) (*storage.ObjectInfo, string, error) {
+	upload, err := m.Get(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(manifest) == 0 {
+		return nil, "", ErrInvalidPart
+	}
+
+	uploaded, err := m.ListParts(id)
+	if err != nil {
+		return nil, "", err
+	}
+	byNumber := make(map[int]Part, len(uploaded))
+	for _, part := range uploaded {
+		byNumber[part.Number] = part
+	}
+
+	digests := md5.New()
+	readers := make([]io.Reader, 0, len(manifest))
+	for i, entry := range manifest {
+		if i > 0 && entry.Number <= manifest[i-1].Number {
+			return nil, "", ErrInvalidPartOrder
+		}
+
+		part, ok := byNumber[entry.Number]
+		if !ok || strings.Trim(entry.ETag, `"`) != strings.Trim(part.ETag, `"`) {
+			return nil, "", ErrInvalidPart
+		}
+		if i < len(manifest)-1 && part.Size < m.minPartSize {
+			return nil, "", ErrPartTooSmall
+		}
+
+		sum, _ := hex.DecodeString(strings.Trim(part.ETag, `"`))
+		digests.Write(sum)
+
+		file, err := os.Open(m.partPath(id, part.Number, partExt))
+		if err != nil {
+			return nil, "", ErrInvalidPart
+		}
+		defer file.Close()
+		readers = append(readers, file)
+	}
+
+	if upload.OriginalName != "" {
+		ctx = storage.WithOriginalName(ctx, upload.OriginalName)
+	}
+	info, err := m.store.Put(ctx, upload.Key, io.MultiReader(readers...))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err = os.RemoveAll(m.uploadDir(id)); err != nil {
+		return nil, "", err
+	}
+	return info, fmt.Sprintf(`"%x-%d"`, digests.Sum(nil), len(manifest)), nil
+}
+
+// Abort discards an upload and every part staged for it
+func (m *Manager) Abort(id string) error {
+	if _, err := m.Get(id); err != nil {
+		return err
+	}
+	return os.RemoveAll(m.uploadDir(id))
+}
+
+// Purge removes the uploads that expired by now along with their parts and
+// returns their ids
+func (m *Manager) Purge(now time.Time) ([]string, error) {
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	purged := make([]string, 0)
+	for _, entry := range entries {
+		if !entry.IsDir() || !validID(entry.Name()) {
+			continue
+		}
+
+		upload, err := m.Get(entry.Name())
+		if err != nil || now.Before(upload.Initiated.Add(m.expiry)) {
+			continue
+		}
+
+		if err = os.RemoveAll(m.uploadDir(upload.ID)); err != nil {
+			return purged, err
+		}
+		purged = append(purged, upload.ID)
+	}
+
+	sort.Strings(purged)
+	return purged, nil
+}
+
+func (m *Manager) uploadDir(id string) string {
+	return filepath.Join(m.dir, id)
+}
+
+func (m *Manager) partPath(id string, n int, ext string) string {
+	return filepath.Join(m.uploadDir(id), fmt.Sprintf("%05d", n)+ext)
+}
+
+func validID(id string) bool {
+	_, err := hex.DecodeString(id)
+	return err == nil && len(id) == 32
+}
+
+// writeFile atomically replaces path with the contents of r
+func writeFile(path string, r io.Reader) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+type countingReader struct {
+	r   io.Reader
+	n   int64
+	max int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.max > 0 && c.n > c.max {
+		return n, ErrPartTooLarge
+	}
+	return n, err
+}
+
+// PartNumber parses a part number query value
+func PartNumber(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < MinPartNumber || n > MaxPartNumber {
+		return 0, ErrInvalidPartNumber
+	}
+	return n, nil
+}
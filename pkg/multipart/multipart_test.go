@@ -0,0 +1,202 @@
+package multipart
+
+import (
+	"bytes"
+	"context"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func setupManager(t *testing.T) (*Manager, storage.Storage) {
+	store := storage.NewMemory()
+	return New(t.TempDir(), store, 4, 16, time.Minute), store
+}
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run(
+		"Complete", func(t *testing.T) {
+			m, store := setupManager(t)
+			upload, err := m.Initiate("docs/file.txt", "File.txt")
+			require.NoError(t, err)
+
+			var wg sync.WaitGroup
+			for i, chunk := range []string{"aaaa", "bbbb", "cc"} {
+				wg.Add(1)
+				go func(n int, chunk string) {
+					defer wg.Done()
+					_, err := m.PutPart(upload.ID, n, strings.NewReader(chunk))
+					assert.NoError(t, err)
+				}(i+1, chunk)
+			}
+			wg.Wait()
+
+			parts, err := m.ListParts(upload.ID)
+			require.NoError(t, err)
+			require.Len(t, parts, 3)
+
+			manifest := make([]CompletedPart, 0, len(parts))
+			for i, part := range parts {
+				assert.Equal(t, i+1, part.Number)
+				manifest = append(manifest, CompletedPart{Number: part.Number, ETag: part.ETag})
+			}
+
+			info, etag, err := m.Complete(ctx, upload.ID, manifest)
+			require.NoError(t, err)
+			assert.Equal(t, int64(10), info.Size)
+			assert.Equal(t, "File.txt", info.OriginalName)
+			assert.True(t, strings.HasSuffix(etag, `-3"`))
+
+			rc, _, err := store.Get(ctx, "docs/file.txt", 0, -1)
+			require.NoError(t, err)
+			defer rc.Close()
+			data, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			assert.Equal(t, "aaaabbbbcc", string(data))
+
+			_, err = m.Get(upload.ID)
+			assert.ErrorIs(t, err, ErrNoSuchUpload)
+		},
+	)
+
+	t.Run(
+		"Retry Part", func(t *testing.T) {
+			m, _ := setupManager(t)
+			upload, err := m.Initiate("file.txt", "")
+			require.NoError(t, err)
+
+			_, err = m.PutPart(upload.ID, 1, strings.NewReader("first"))
+			require.NoError(t, err)
+			part, err := m.PutPart(upload.ID, 1, strings.NewReader("second"))
+			require.NoError(t, err)
+
+			parts, err := m.ListParts(upload.ID)
+			require.NoError(t, err)
+			require.Len(t, parts, 1)
+			assert.Equal(t, part.ETag, parts[0].ETag)
+			assert.Equal(t, int64(6), parts[0].Size)
+		},
+	)
+
+	t.Run(
+		"Invalid Manifest", func(t *testing.T) {
+			m, store := setupManager(t)
+			upload, err := m.Initiate("file.txt", "")
+			require.NoError(t, err)
+
+			small, err := m.PutPart(upload.ID, 1, strings.NewReader("a"))
+			require.NoError(t, err)
+			last, err := m.PutPart(upload.ID, 2, strings.NewReader("bbbb"))
+			require.NoError(t, err)
+
+			_, _, err = m.Complete(ctx, upload.ID, nil)
+			assert.ErrorIs(t, err, ErrInvalidPart)
+
+			_, _, err = m.Complete(
+				ctx, upload.ID, []CompletedPart{{Number: 2, ETag: last.ETag}, {Number: 1, ETag: small.ETag}},
+			)
+			assert.ErrorIs(t, err, ErrInvalidPartOrder)
+
+			_, _, err = m.Complete(ctx, upload.ID, []CompletedPart{{Number: 1, ETag: `"deadbeef"`}})
+			assert.ErrorIs(t, err, ErrInvalidPart)
+
+			_, _, err = m.Complete(ctx, upload.ID, []CompletedPart{{Number: 3, ETag: last.ETag}})
+			assert.ErrorIs(t, err, ErrInvalidPart)
+
+			_, _, err = m.Complete(
+				ctx, upload.ID, []CompletedPart{{Number: 1, ETag: small.ETag}, {Number: 2, ETag: last.ETag}},
+			)
+			assert.ErrorIs(t, err, ErrPartTooSmall)
+
+			_, err = store.Stat(ctx, "file.txt")
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+
+			_, err = m.Get(upload.ID)
+			assert.NoError(t, err)
+		},
+	)
+
+	t.Run(
+		"Invalid Part", func(t *testing.T) {
+			m, _ := setupManager(t)
+			upload, err := m.Initiate("file.txt", "")
+			require.NoError(t, err)
+
+			_, err = m.PutPart(upload.ID, 0, strings.NewReader("data"))
+			assert.ErrorIs(t, err, ErrInvalidPartNumber)
+
+			_, err = m.PutPart(upload.ID, MaxPartNumber+1, strings.NewReader("data"))
+			assert.ErrorIs(t, err, ErrInvalidPartNumber)
+
+			_, err = m.PutPart(upload.ID, 1, bytes.NewReader(make([]byte, 17)))
+			assert.ErrorIs(t, err, ErrPartTooLarge)
+
+			parts, err := m.ListParts(upload.ID)
+			require.NoError(t, err)
+			assert.Empty(t, parts)
+
+			_, err = m.PutPart("0123456789abcdef0123456789abcdef", 1, strings.NewReader("data"))
+			assert.ErrorIs(t, err, ErrNoSuchUpload)
+
+			_, err = m.PutPart("../../etc", 1, strings.NewReader("data"))
+			assert.ErrorIs(t, err, ErrNoSuchUpload)
+		},
+	)
+
+	t.Run(
+		"Abort", func(t *testing.T) {
+			m, _ := setupManager(t)
+			upload, err := m.Initiate("file.txt", "")
+			require.NoError(t, err)
+
+			_, err = m.PutPart(upload.ID, 1, strings.NewReader("data"))
+			require.NoError(t, err)
+
+			require.NoError(t, m.Abort(upload.ID))
+			_, err = os.Stat(filepath.Join(m.dir, upload.ID))
+			assert.True(t, os.IsNotExist(err))
+
+			assert.ErrorIs(t, m.Abort(upload.ID), ErrNoSuchUpload)
+		},
+	)
+
+	t.Run(
+		"Purge", func(t *testing.T) {
+			m, _ := setupManager(t)
+			expired, err := m.Initiate("old.txt", "")
+			require.NoError(t, err)
+			_, err = m.PutPart(expired.ID, 1, strings.NewReader("data"))
+			require.NoError(t, err)
+
+			purged, err := m.Purge(time.Now())
+			require.NoError(t, err)
+			assert.Empty(t, purged)
+
+			purged, err = m.Purge(time.Now().Add(2 * time.Minute))
+			require.NoError(t, err)
+			assert.Equal(t, []string{expired.ID}, purged)
+
+			_, err = m.Get(expired.ID)
+			assert.ErrorIs(t, err, ErrNoSuchUpload)
+			_, err = os.Stat(filepath.Join(m.dir, expired.ID))
+			assert.True(t, os.IsNotExist(err))
+		},
+	)
+
+	t.Run(
+		"Invalid Key", func(t *testing.T) {
+			m, _ := setupManager(t)
+			_, err := m.Initiate("../escape.txt", "")
+			assert.ErrorIs(t, err, storage.ErrInvalidKey)
+		},
+	)
+}
@@ -0,0 +1,72 @@
+package presign
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	s := New("secret")
+
+	signed, expires, err := s.URL(http.MethodPost, "/upload?path=avatars", time.Minute, 1024)
+	require.NoError(t, err)
+	assert.True(t, expires.After(time.Now()))
+
+	t.Run(
+		"Valid", func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, signed, nil)
+			assert.True(t, IsSigned(r))
+
+			grant, err := s.Verify(r, time.Now())
+			require.NoError(t, err)
+			assert.Equal(t, http.MethodPost, grant.Method)
+			assert.Equal(t, "/upload", grant.Path)
+			assert.Equal(t, int64(1024), grant.MaxLength)
+		},
+	)
+
+	t.Run(
+		"Tampered Query", func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, strings.Replace(signed, "avatars", "admin", 1), nil)
+			_, err := s.Verify(r, time.Now())
+			assert.ErrorIs(t, err, ErrInvalidSignature)
+		},
+	)
+
+	t.Run(
+		"Tampered Length", func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, strings.Replace(signed, "X-Max-Length=1024", "X-Max-Length=4096", 1), nil)
+			_, err := s.Verify(r, time.Now())
+			assert.ErrorIs(t, err, ErrInvalidSignature)
+		},
+	)
+
+	t.Run(
+		"Other Secret", func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, signed, nil)
+			_, err := New("other").Verify(r, time.Now())
+			assert.ErrorIs(t, err, ErrInvalidSignature)
+		},
+	)
+
+	t.Run(
+		"Expired", func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, signed, nil)
+			_, err := s.Verify(r, time.Now().Add(time.Hour))
+			assert.ErrorIs(t, err, ErrExpired)
+		},
+	)
+
+	t.Run(
+		"Wrong Method", func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, signed, nil)
+			_, err := s.Verify(r, time.Now())
+			assert.ErrorIs(t, err, ErrMethodNotAllowed)
+		},
+	)
+}
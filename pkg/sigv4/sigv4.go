@@ -0,0 +1,207 @@
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const Algorithm = "AWS4-HMAC-SHA256"
+const Service = "s3"
+const TimeFormat = "20060102T150405Z"
+const DateFormat = "20060102"
+
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+const StreamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+const StreamingPayloadTrailer = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+const StreamingUnsignedPayloadTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+// EmptySHA256 is the hex SHA-256 of an empty payload
+const EmptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// SigningKey derives the per-day, per-region signing key from a secret
+func SigningKey(secret, date, region string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, Service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func Scope(date, region string) string {
+	return date + "/" + region + "/" + Service + "/aws4_request"
+}
+
+// uriEncode encodes s the way AWS expects: every byte except the unreserved
+// characters A-Z, a-z, 0-9, '-', '.', '_' and '~' is percent-encoded, and '/'
+// is kept as is only when encodeSlash is false
+func uriEncode(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&15])
+		}
+	}
+	return b.String()
+}
+
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for k, vs := range query {
+		if k == "X-Amz-Signature" {
+			continue
+		}
+		for _, v := range vs {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func canonicalHeaders(r *http.Request, signed []string) string {
+	var b strings.Builder
+	for _, name := range signed {
+		var value string
+		switch name {
+		case "host":
+			value = r.Host
+		case "content-length":
+			value = r.Header.Get("Content-Length")
+			if value == "" && r.ContentLength > 0 {
+				value = strconv.FormatInt(r.ContentLength, 10)
+			}
+		default:
+			values := r.Header.Values(name)
+			for i := range values {
+				values[i] = strings.Join(strings.Fields(values[i]), " ")
+			}
+			value = strings.Join(values, ",")
+		}
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.TrimSpace(value))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// CanonicalRequest builds the canonical form of r covering the given
+// lowercase header names and payload hash
+func CanonicalRequest(r *http.Request, signed []string, payloadHash string) string {
+	path := r.URL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	return strings.Join(
+		[]string{
+			r.Method,
+			uriEncode(path, false),
+			canonicalQuery(r.URL.Query()),
+			canonicalHeaders(r, signed),
+			strings.Join(signed, ";"),
+			payloadHash,
+		}, "\n",
+	)
+}
+
+func StringToSign(t time.Time, scope, canonicalRequest string) string {
+	return strings.Join(
+		[]string{
+			Algorithm,
+			t.UTC().Format(TimeFormat),
+			scope,
+			sha256Hex([]byte(canonicalRequest)),
+		}, "\n",
+	)
+}
+
+func signature(key []byte, stringToSign string) string {
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func signedHeaderNames(r *http.Request) []string {
+	names := []string{"host"}
+	for name := range r.Header {
+		lower := strings.ToLower(name)
+		if lower == "authorization" || lower == "user-agent" {
+			continue
+		}
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" || lower == "content-md5" || lower == "range" {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Sign adds an Authorization header to r. The payload hash is taken from
+// the x-amz-content-sha256 header, which is set to UNSIGNED-PAYLOAD when absent.
+func Sign(r *http.Request, accessKey, secret, region string, t time.Time) {
+	t = t.UTC()
+	r.Header.Set("X-Amz-Date", t.Format(TimeFormat))
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = UnsignedPayload
+		r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	signed := signedHeaderNames(r)
+	scope := Scope(t.Format(DateFormat), region)
+	sts := StringToSign(t, scope, CanonicalRequest(r, signed, payloadHash))
+	sig := signature(SigningKey(secret, t.Format(DateFormat), region), sts)
+
+	r.Header.Set(
+		"Authorization",
+		Algorithm+" Credential="+accessKey+"/"+scope+
+			", SignedHeaders="+strings.Join(signed, ";")+
+			", Signature="+sig,
+	)
+}
+
+// Presign adds query-string authentication to r that stays valid for expires
+func Presign(r *http.Request, accessKey, secret, region string, t time.Time, expires time.Duration) {
+	t = t.UTC()
+	scope := Scope(t.Format(DateFormat), region)
+
+	q := r.URL.Query()
+	q.Set("X-Amz-Algorithm", Algorithm)
+	q.Set("X-Amz-Credential", accessKey+"/"+scope)
+	q.Set("X-Amz-Date", t.Format(TimeFormat))
+	q.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	q.Set("X-Amz-SignedHeaders", "host")
+	q.Del("X-Amz-Signature")
+	r.URL.RawQuery = q.Encode()
+
+	sts := StringToSign(t, scope, CanonicalRequest(r, []string{"host"}, UnsignedPayload))
+	q.Set("X-Amz-Signature", signature(SigningKey(secret, t.Format(DateFormat), region), sts))
+	r.URL.RawQuery = q.Encode()
+}
@@ -0,0 +1,451 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// tmpPrefix marks files that are still being written. They are never listed.
+const tmpPrefix = ".tmp-"
+
+// Local stores objects as files below a root directory
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) *Local {
+	return &Local{root: root}
+}
+
+type moveToKey struct{}
+type moveFromKey struct{}
+
+// move names a file of another Local that the Put or Delete of object
+// renames rather than copies
+type move struct {
+	object string
+	store  *Local
+	key    string
+	meta   Meta
+}
+
+// WithMoveTo makes Local.Delete of object keep the file as key of dst,
+// renaming it when both are on the same filesystem and copying it otherwise
+func WithMoveTo(ctx context.Context, object string, dst *Local, key string) context.Context {
+	return context.WithValue(ctx, moveToKey{}, &move{object: object, store: dst, key: key})
+}
+
+// WithMoveFrom makes Local.Put of object rename the file stored as key of
+// src, whose metadata is meta, rather than copy r, which must hold the same
+// content. r is still read when the two are on different filesystems.
+func WithMoveFrom(ctx context.Context, object string, src *Local, key string, meta Meta) context.Context {
+	return context.WithValue(ctx, moveFromKey{}, &move{object: object, store: src, key: key, meta: meta})
+}
+
+func (l *Local) path(key string) (string, error) {
+	if !ValidKey(key) {
+		return "", ErrInvalidKey
+	}
+
+	p := filepath.Join(l.root, filepath.FromSlash(key))
+	if !l.contains(p) {
+		return "", ErrInvalidKey
+	}
+	return p, nil
+}
+
+// contains reports whether p stays below the root once symlinks are
+// followed. Parts of p that don't exist yet can't lead anywhere, so only
+// the longest existing one is resolved.
+func (l *Local) contains(p string) bool {
+	root, err := filepath.EvalSymlinks(l.root)
+	if err != nil {
+		// Nothing can lead out of a root that doesn't exist yet
+		return true
+	}
+
+	existing := p
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			rel, err := filepath.Rel(root, resolved)
+			return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+		}
+		if existing == filepath.Dir(existing) {
+			return true
+		}
+		existing = filepath.Dir(existing)
+	}
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader) (*ObjectInfo, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if info, err := os.Stat(p); err == nil && info.IsDir() {
+		return nil, ErrInvalidKey
+	}
+
+	dir := filepath.Dir(p)
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	if m, ok := ctx.Value(moveFromKey{}).(*move); ok && m.object == key && m.store != l {
+		info, err := l.moveFrom(ctx, key, p, m)
+		if !errors.Is(err, syscall.EXDEV) {
+			return info, err
+		}
+	}
+
+	tmp, err := os.CreateTemp(dir, tmpPrefix+"*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	digester := NewDigester(key, r)
+	_, err = io.Copy(tmp, digester)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.Rename(tmp.Name(), p); err != nil {
+		return nil, err
+	}
+
+	info, err := l.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	info.Meta = digester.Meta(ctx)
+	return info, nil
+}
+
+// moveFrom renames the file named by m to p. It fails with syscall.EXDEV
+// when m is on another filesystem.
+func (l *Local) moveFrom(ctx context.Context, key, p string, m *move) (*ObjectInfo, error) {
+	src, err := m.store.path(m.key)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Rename(src, p); err != nil {
+		return nil, notFound(err)
+	}
+
+	// The file counts as written now, just as a copy would
+	now := time.Now()
+	if err = os.Chtimes(p, now, now); err != nil {
+		return nil, err
+	}
+
+	info, err := l.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	info.Meta = m.meta
+	return info, nil
+}
+
+func (l *Local) Get(_ context.Context, key string, offset, length int64) (io.ReadCloser, *ObjectInfo, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(p)
+	if err != nil {
+		return nil, nil, notFound(err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil || stat.IsDir() {
+		file.Close()
+		return nil, nil, ErrNotFound
+	}
+
+	if err = checkRange(stat.Size(), offset, length); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	info := &ObjectInfo{Key: key, Size: stat.Size(), ModTime: stat.ModTime()}
+	if length < 0 {
+		return file, info, nil
+	}
+	return readCloser{io.LimitReader(file, length), file}, info, nil
+}
+
+func (l *Local) Stat(_ context.Context, key string) (*ObjectInfo, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(p)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if stat.IsDir() {
+		return nil, ErrNotFound
+	}
+	return &ObjectInfo{Key: key, Size: stat.Size(), ModTime: stat.ModTime()}, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if _, err := l.Stat(ctx, key); err != nil {
+		return err
+	}
+
+	p, _ := l.path(key)
+	if m, ok := ctx.Value(moveToKey{}).(*move); ok && m.object == key && m.store != l {
+		return l.moveTo(ctx, p, m)
+	}
+	return notFound(os.Remove(p))
+}
+
+// moveTo renames the file at p to the one named by m, or copies it there
+// and removes it when m is on another filesystem
+func (l *Local) moveTo(ctx context.Context, p string, m *move) error {
+	dst, err := m.store.path(m.key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	if err = os.Rename(p, dst); !errors.Is(err, syscall.EXDEV) {
+		return notFound(err)
+	}
+
+	file, err := os.Open(p)
+	if err != nil {
+		return notFound(err)
+	}
+	_, err = m.store.Put(ctx, m.key, file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	return notFound(os.Remove(p))
+}
+
+func (l *Local) List(_ context.Context, prefix, cursor string, limit int) ([]ObjectInfo, string, error) {
+	dir := path.Dir("/" + prefix)
+	if strings.HasSuffix(prefix, "/") {
+		dir = "/" + strings.TrimSuffix(prefix, "/")
+	}
+	if dir != "/" && !ValidKey(dir[1:]) {
+		return nil, "", ErrInvalidKey
+	}
+
+	root := filepath.Join(l.root, filepath.FromSlash(dir))
+	if !l.contains(root) {
+		return nil, "", ErrInvalidKey
+	}
+
+	objects := make([]ObjectInfo, 0, 50)
+	err := filepath.WalkDir(
+		root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if p == root && errors.Is(err, fs.ErrNotExist) && !strings.HasSuffix(prefix, "/") {
+					return fs.SkipAll
+				}
+				return err
+			}
+			if d.IsDir() || strings.HasPrefix(d.Name(), tmpPrefix) {
+				return nil
+			}
+
+			rel, err := filepath.Rel(l.root, p)
+			if err != nil {
+				return err
+			}
+
+			key := filepath.ToSlash(rel)
+			if !strings.HasPrefix(key, prefix) || (cursor != "" && key <= cursor) {
+				return nil
+			}
+
+			info, err := d.Info()
+			if d.Type()&fs.ModeSymlink != 0 {
+				// Links are listed like the files they point to, as long
+				// as those are below the root
+				if !l.contains(p) {
+					return nil
+				}
+				info, err = os.Stat(p)
+			}
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			objects = append(objects, ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, "", notFound(err)
+	}
+
+	sort.Slice(
+		objects, func(i, j int) bool {
+			return objects[i].Key < objects[j].Key
+		},
+	)
+
+	res, next := page(objects, cursor, limit)
+	return res, next, nil
+}
+
+func (l *Local) Move(ctx context.Context, src, dst string) error {
+	srcPath, err := l.path(src)
+	if err != nil {
+		return err
+	}
+	dstPath, err := l.path(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err = l.Stat(ctx, src); err != nil {
+		return err
+	}
+	if info, err := os.Stat(dstPath); err == nil && info.IsDir() {
+		return ErrInvalidKey
+	}
+
+	if err = os.MkdirAll(filepath.Dir(dstPath), os.ModePerm); err != nil {
+		return err
+	}
+	return os.Rename(srcPath, dstPath)
+}
+
+func (l *Local) MoveDir(_ context.Context, src, dst string) error {
+	srcPath, err := l.path(src)
+	if err != nil {
+		return err
+	}
+	dstPath, err := l.path(dst)
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(srcPath); err != nil {
+		return notFound(err)
+	} else if !info.IsDir() {
+		return ErrNotFound
+	}
+	if _, err = os.Lstat(dstPath); err == nil {
+		return ErrExists
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(dstPath), os.ModePerm); errors.Is(err, syscall.ENOTDIR) {
+		return ErrInvalidKey
+	} else if err != nil {
+		return err
+	}
+	return os.Rename(srcPath, dstPath)
+}
+
+func (l *Local) MkDir(_ context.Context, dir string) error {
+	p, err := l.path(dir)
+	if err != nil {
+		return err
+	}
+
+	// A file anywhere along the way can't become a directory
+	if err = os.MkdirAll(p, os.ModePerm); errors.Is(err, syscall.ENOTDIR) {
+		return ErrInvalidKey
+	}
+	return err
+}
+
+func (l *Local) RemoveDir(_ context.Context, dir string) error {
+	p, err := l.path(dir)
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(p); err != nil {
+		return notFound(err)
+	} else if !info.IsDir() {
+		return ErrNotFound
+	}
+
+	// Unfinished uploads count as content too
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return ErrNotEmpty
+	}
+	return notFound(os.Remove(p))
+}
+
+func (l *Local) ListDirs(_ context.Context, dir string) ([]string, error) {
+	root := l.root
+	if dir != "" {
+		p, err := l.path(dir)
+		if err != nil {
+			return nil, err
+		}
+		root = p
+	}
+
+	dirs := make([]string, 0, 16)
+	err := filepath.WalkDir(
+		root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if p == root && !d.IsDir() {
+				return ErrNotFound
+			}
+			if !d.IsDir() || p == root {
+				return nil
+			}
+
+			rel, err := filepath.Rel(l.root, p)
+			if err != nil {
+				return err
+			}
+			dirs = append(dirs, filepath.ToSlash(rel))
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+func notFound(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memObject struct {
+	data    []byte
+	modTime time.Time
+	meta    Meta
+}
+
+// Memory keeps objects in memory. It is meant for tests and ephemeral setups.
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string]*memObject
+}
+
+func NewMemory() *Memory {
+	return &Memory{objects: make(map[string]*memObject)}
+}
+
+func (m *Memory) Put(ctx context.Context, key string, r io.Reader) (*ObjectInfo, error) {
+	if !ValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+
+	digester := NewDigester(key, r)
+	data, err := io.ReadAll(digester)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj := &memObject{data: data, modTime: time.Now(), meta: digester.Meta(ctx)}
+	m.objects[key] = obj
+	return obj.info(key), nil
+}
+
+func (m *Memory) Get(_ context.Context, key string, offset, length int64) (io.ReadCloser, *ObjectInfo, error) {
+	m.mu.RLock()
+	obj, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+
+	size := int64(len(obj.data))
+	if err := checkRange(size, offset, length); err != nil {
+		return nil, nil, err
+	}
+
+	end := size
+	if length >= 0 {
+		end = offset + length
+	}
+	return io.NopCloser(bytes.NewReader(obj.data[offset:end])), obj.info(key), nil
+}
+
+func (m *Memory) Stat(_ context.Context, key string) (*ObjectInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	obj, ok := m.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return obj.info(key), nil
+}
+
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.objects[key]; !ok {
+		return ErrNotFound
+	}
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *Memory) List(_ context.Context, prefix, cursor string, limit int) ([]ObjectInfo, string, error) {
+	m.mu.RLock()
+	objects := make([]ObjectInfo, 0, len(m.objects))
+	for key, obj := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, *obj.info(key))
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(
+		objects, func(i, j int) bool {
+			return objects[i].Key < objects[j].Key
+		},
+	)
+
+	res, next := page(objects, cursor, limit)
+	return res, next, nil
+}
+
+func (m *Memory) Move(_ context.Context, src, dst string) error {
+	if !ValidKey(dst) {
+		return ErrInvalidKey
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	obj, ok := m.objects[src]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(m.objects, src)
+	m.objects[dst] = obj
+	return nil
+}
+
+func (o *memObject) info(key string) *ObjectInfo {
+	return &ObjectInfo{Key: key, Size: int64(len(o.data)), ModTime: o.modTime, Meta: o.meta}
+}
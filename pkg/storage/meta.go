@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/JMURv/simple-s3/pkg/utils/mediatype"
+	"hash"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+// sniffLen is how many leading bytes are used to detect the content type
+const sniffLen = mediatype.SniffLen
+
+// Meta describes the contents of an object. It is computed while the object
+// is written, so backends that can't keep it return it from Put only.
+type Meta struct {
+	ContentType string
+	// ETag is the quoted hex MD5 of the contents, as S3 reports it
+	ETag   string
+	SHA256 string
+	// OriginalName is the file name the client uploaded, before slugify
+	OriginalName string
+	// Tags are the key-value pairs the client labelled the object with
+	Tags map[string]string
+	// Audio holds the tags read from an audio file once it was stored
+	Audio *Audio
+}
+
+// Audio describes an audio file as its tags do. Cover reports whether it
+// embeds cover art; the picture itself is not part of the metadata.
+type Audio struct {
+	Format   string        `json:"format"`
+	Title    string        `json:"title,omitempty"`
+	Artist   string        `json:"artist,omitempty"`
+	Album    string        `json:"album,omitempty"`
+	Genre    string        `json:"genre,omitempty"`
+	Year     string        `json:"year,omitempty"`
+	Track    string        `json:"track,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Cover    bool          `json:"cover,omitempty"`
+}
+
+type originalNameKey struct{}
+type tagsKey struct{}
+type audioKey struct{}
+
+// WithOriginalName makes Put record name as the original file name
+func WithOriginalName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, originalNameKey{}, name)
+}
+
+func OriginalName(ctx context.Context) string {
+	name, _ := ctx.Value(originalNameKey{}).(string)
+	return name
+}
+
+// WithTags makes Put record tags on the object
+func WithTags(ctx context.Context, tags map[string]string) context.Context {
+	return context.WithValue(ctx, tagsKey{}, tags)
+}
+
+func Tags(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(tagsKey{}).(map[string]string)
+	return tags
+}
+
+// WithMetaOf makes Put keep what the client told about an object stored
+// before, for content that is stored again elsewhere
+func WithMetaOf(ctx context.Context, meta Meta) context.Context {
+	if meta.OriginalName != "" {
+		ctx = WithOriginalName(ctx, meta.OriginalName)
+	}
+	if len(meta.Tags) > 0 {
+		ctx = WithTags(ctx, meta.Tags)
+	}
+	// Read from the content, so only carried over with it
+	if meta.Audio != nil {
+		ctx = context.WithValue(ctx, audioKey{}, meta.Audio)
+	}
+	return ctx
+}
+
+// Digester computes the Meta of an object while it is read
+type Digester struct {
+	r    io.Reader
+	key  string
+	md5  hash.Hash
+	sha  hash.Hash
+	head []byte
+}
+
+func NewDigester(key string, r io.Reader) *Digester {
+	return &Digester{
+		r:    r,
+		key:  key,
+		md5:  md5.New(),
+		sha:  sha256.New(),
+		head: make([]byte, 0, sniffLen),
+	}
+}
+
+func (d *Digester) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.md5.Write(p[:n])
+	d.sha.Write(p[:n])
+	if rest := sniffLen - len(d.head); rest > 0 {
+		d.head = append(d.head, p[:min(n, rest)]...)
+	}
+	return n, err
+}
+
+// Meta returns the metadata of everything read so far
+func (d *Digester) Meta(ctx context.Context) Meta {
+	return Meta{
+		ContentType:  DetectContentType(d.key, d.head),
+		ETag:         `"` + hex.EncodeToString(d.md5.Sum(nil)) + `"`,
+		SHA256:       hex.EncodeToString(d.sha.Sum(nil)),
+		OriginalName: OriginalName(ctx),
+		Tags:         Tags(ctx),
+		Audio:        audio(ctx),
+	}
+}
+
+func audio(ctx context.Context) *Audio {
+	a, _ := ctx.Value(audioKey{}).(*Audio)
+	return a
+}
+
+// Digest reads r to the end and returns its metadata
+func Digest(ctx context.Context, key string, r io.Reader) (Meta, error) {
+	d := NewDigester(key, r)
+	if _, err := io.Copy(io.Discard, d); err != nil {
+		return Meta{}, err
+	}
+	return d.Meta(ctx), nil
+}
+
+// DetectContentType sniffs the leading bytes of an object and falls back to
+// the extension of key when sniffing only finds generic binary or text
+func DetectContentType(key string, head []byte) string {
+	sniffed := mediatype.Detect(head)
+	if sniffed == mediatype.Unknown || strings.HasPrefix(sniffed, "text/plain") {
+		if byExt := mime.TypeByExtension(path.Ext(key)); byExt != "" {
+			return byExt
+		}
+	}
+	return sniffed
+}
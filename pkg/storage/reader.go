@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ReadSeeker adapts a stored object to io.ReadSeekCloser by reopening it at
+// the current offset after every seek. It lets http.ServeContent serve
+// ranges from any backend. ReadAt makes it an io.ReaderAt for readers of
+// formats such as ZIP that jump around a file.
+type ReadSeeker struct {
+	ctx    context.Context
+	store  Storage
+	key    string
+	size   int64
+	offset int64
+	rc     io.ReadCloser
+}
+
+func NewReadSeeker(ctx context.Context, store Storage, info *ObjectInfo) *ReadSeeker {
+	return &ReadSeeker{
+		ctx:   ctx,
+		store: store,
+		key:   info.Key,
+		size:  info.Size,
+	}
+}
+
+func (r *ReadSeeker) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.rc == nil {
+		rc, _, err := r.store.Get(r.ctx, r.key, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.rc = rc
+	}
+
+	n, err := r.rc.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *ReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if offset != r.offset {
+		r.Close()
+		r.offset = offset
+	}
+	return offset, nil
+}
+
+// ReadAt seeks to off and reads len(p) bytes from there. Unlike most
+// readers it moves the offset, which keeps consecutive reads on a single
+// open object; it must not be called concurrently.
+func (r *ReadSeeker) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	n, err := io.ReadFull(r, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r *ReadSeeker) Close() error {
+	if r.rc == nil {
+		return nil
+	}
+	err := r.rc.Close()
+	r.rc = nil
+	return err
+}
@@ -0,0 +1,204 @@
+// Package storage abstracts where objects live so handlers never touch the
+// filesystem directly. Objects are addressed by slash-separated keys
+// relative to the root of the backend, e.g. "photos/2024/cat.jpg".
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("object not found")
+var ErrInvalidKey = errors.New("invalid object key")
+var ErrInvalidRange = errors.New("invalid range")
+var ErrNotEmpty = errors.New("directory is not empty")
+var ErrExists = errors.New("object already exists")
+
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+	Meta
+}
+
+type Storage interface {
+	// Put stores the contents of r under key, replacing any existing object.
+	// The object only becomes visible once r has been read to the end
+	// without error.
+	Put(ctx context.Context, key string, r io.Reader) (*ObjectInfo, error)
+
+	// Get returns length bytes of the object starting at offset. A negative
+	// length reads until the end of the object.
+	Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, *ObjectInfo, error)
+
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+
+	Delete(ctx context.Context, key string) error
+
+	// List returns up to limit objects whose keys start with prefix and sort
+	// after cursor, ordered by key. The returned cursor continues the listing
+	// and is empty once every object has been returned. A limit <= 0 returns
+	// everything. Backends with real directories return ErrNotFound when a
+	// prefix ending with "/" names a directory that does not exist.
+	List(ctx context.Context, prefix, cursor string, limit int) ([]ObjectInfo, string, error)
+
+	// Move renames src to dst, replacing dst if it exists
+	Move(ctx context.Context, src, dst string) error
+}
+
+// Pager is implemented by backends that can page through and search their
+// objects without listing all of them, such as the metadata index
+type Pager interface {
+	// Page returns limit objects below prefix starting at offset, together
+	// with the number of objects below prefix. A non-empty query only keeps
+	// objects whose key contains it, ignoring case.
+	Page(ctx context.Context, prefix, query string, offset, limit int) ([]ObjectInfo, int, error)
+}
+
+// Dirs is implemented by backends with real directories, which exist on
+// their own and may be empty. Elsewhere a directory only exists through
+// the keys below it.
+type Dirs interface {
+	// MkDir creates the directory dir along with its parents
+	MkDir(ctx context.Context, dir string) error
+
+	// RemoveDir removes the directory dir, failing with ErrNotEmpty when
+	// anything is left in it
+	RemoveDir(ctx context.Context, dir string) error
+
+	// ListDirs returns every directory below dir, empty ones included,
+	// ordered by key. An empty dir lists from the root.
+	ListDirs(ctx context.Context, dir string) ([]string, error)
+}
+
+// DirMover is implemented by backends that can rename a whole directory in
+// one step rather than file by file
+type DirMover interface {
+	// MoveDir renames the directory src to dst, failing with ErrExists when
+	// anything exists at dst
+	MoveDir(ctx context.Context, src, dst string) error
+}
+
+// Versioner is implemented by decorators that keep what a write replaces
+// as a previous version of the key
+type Versioner interface {
+	Versioned(key string) bool
+}
+
+// Overwritable reports whether writing over key in s keeps the content it
+// replaces, so there is no reason to refuse the write
+func Overwritable(s Storage, key string) bool {
+	v, ok := As[Versioner](s)
+	return ok && v.Versioned(key)
+}
+
+// Annotator is implemented by backends that keep metadata, such as the
+// metadata index, so what is learned about an object after it was stored
+// can be kept with it
+type Annotator interface {
+	// Annotate records info.Meta for the object, unless it changed since
+	// info was read
+	Annotate(ctx context.Context, info *ObjectInfo) error
+}
+
+type contentKeptKey struct{}
+
+// WithContentKept tells decorators that hold on to deleted content, such
+// as the trash, that Delete needn't: the caller has stored it elsewhere
+func WithContentKept(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contentKeptKey{}, true)
+}
+
+func ContentKept(ctx context.Context) bool {
+	kept, _ := ctx.Value(contentKeptKey{}).(bool)
+	return kept
+}
+
+// Unwrapper is implemented by decorators, such as the metadata index, that
+// add to another backend
+type Unwrapper interface {
+	Unwrap() Storage
+}
+
+// As finds the first of s and the backends it decorates that implements
+// T, for capabilities that decorators pass through without changing them
+func As[T any](s Storage) (T, bool) {
+	for {
+		if t, ok := s.(T); ok {
+			return t, true
+		}
+		u, ok := s.(Unwrapper)
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		s = u.Unwrap()
+	}
+}
+
+// ValidKey reports whether key is a canonical relative object key
+func ValidKey(key string) bool {
+	if key == "" || strings.ContainsRune(key, 0) || strings.HasPrefix(key, "/") {
+		return false
+	}
+	return path.Clean(key) == key && key != ".." && !strings.HasPrefix(key, "../")
+}
+
+// CleanKey canonicalises a relative path into a key: backslashes become
+// slashes and empty and . segments are dropped. Paths that could point
+// anywhere but below the root, because they are absolute, carry a drive
+// letter, a .. segment or a NUL byte, are refused with ErrInvalidKey
+// rather than repaired. The root itself cleans to "".
+func CleanKey(p string) (string, error) {
+	p = strings.ReplaceAll(p, "\\", "/")
+	if strings.ContainsRune(p, 0) || strings.HasPrefix(p, "/") {
+		return "", ErrInvalidKey
+	}
+	if len(p) >= 2 && p[1] == ':' {
+		return "", ErrInvalidKey
+	}
+
+	segments := make([]string, 0, strings.Count(p, "/")+1)
+	for _, s := range strings.Split(p, "/") {
+		switch s {
+		case "", ".":
+			continue
+		case "..":
+			return "", ErrInvalidKey
+		}
+		segments = append(segments, s)
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// page applies cursor and limit to objects sorted by key
+func page(objects []ObjectInfo, cursor string, limit int) ([]ObjectInfo, string) {
+	start := 0
+	if cursor != "" {
+		for start < len(objects) && objects[start].Key <= cursor {
+			start++
+		}
+	}
+	objects = objects[start:]
+
+	if limit <= 0 || len(objects) <= limit {
+		return objects, ""
+	}
+	return objects[:limit], objects[limit-1].Key
+}
+
+func checkRange(size, offset, length int64) error {
+	if offset < 0 || offset > size || (length >= 0 && offset+length > size) {
+		return ErrInvalidRange
+	}
+	return nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,435 @@
+package storage
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected bool
+	}{
+		{"file.txt", true},
+		{"dir/file.txt", true},
+		{"", false},
+		{"/abs.txt", false},
+		{"../escape.txt", false},
+		{"dir/../../escape.txt", false},
+		{"..", false},
+		{"dir//file.txt", false},
+		{"dir/./file.txt", false},
+		{"dir/", false},
+		{"nul\x00byte", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.key, func(t *testing.T) {
+				assert.Equal(t, tt.expected, ValidKey(tt.key))
+			},
+		)
+	}
+}
+
+func TestCleanKey(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+		err      error
+	}{
+		{"", "", nil},
+		{".", "", nil},
+		{"dir/file.txt", "dir/file.txt", nil},
+		{"./dir//file.txt", "dir/file.txt", nil},
+		{"dir/", "dir", nil},
+		{"dir\\file.txt", "dir/file.txt", nil},
+		{"..", "", ErrInvalidKey},
+		{"dir/../file.txt", "", ErrInvalidKey},
+		{"..\\escape.txt", "", ErrInvalidKey},
+		{"/abs.txt", "", ErrInvalidKey},
+		{"\\\\server\\share", "", ErrInvalidKey},
+		{"C:\\file.txt", "", ErrInvalidKey},
+		{"nul\x00byte", "", ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.path, func(t *testing.T) {
+				key, err := CleanKey(tt.path)
+				assert.ErrorIs(t, err, tt.err)
+				assert.Equal(t, tt.expected, key)
+			},
+		)
+	}
+}
+
+func TestBackends(t *testing.T) {
+	backends := map[string]func(t *testing.T) Storage{
+		"Local": func(t *testing.T) Storage {
+			return NewLocal(t.TempDir())
+		},
+		"Memory": func(t *testing.T) Storage {
+			return NewMemory()
+		},
+	}
+
+	for name, newStorage := range backends {
+		t.Run(
+			name, func(t *testing.T) {
+				testStorage(t, newStorage(t))
+			},
+		)
+	}
+}
+
+func testStorage(t *testing.T, s Storage) {
+	ctx := context.Background()
+	read := func(t *testing.T, key string, offset, length int64) string {
+		rc, _, err := s.Get(ctx, key, offset, length)
+		require.NoError(t, err)
+		defer rc.Close()
+
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	for _, key := range []string{"b.txt", "dir/c.txt", "a.txt", "dir/sub/d.txt"} {
+		info, err := s.Put(ctx, key, strings.NewReader("content of "+key))
+		require.NoError(t, err)
+		assert.Equal(t, key, info.Key)
+		assert.Equal(t, int64(len("content of "+key)), info.Size)
+	}
+
+	t.Run(
+		"Get", func(t *testing.T) {
+			assert.Equal(t, "content of a.txt", read(t, "a.txt", 0, -1))
+			assert.Equal(t, "content", read(t, "a.txt", 0, 7))
+			assert.Equal(t, "a.txt", read(t, "a.txt", 11, -1))
+
+			_, _, err := s.Get(ctx, "a.txt", 100, -1)
+			assert.ErrorIs(t, err, ErrInvalidRange)
+
+			_, _, err = s.Get(ctx, "missing.txt", 0, -1)
+			assert.ErrorIs(t, err, ErrNotFound)
+		},
+	)
+
+	t.Run(
+		"Meta", func(t *testing.T) {
+			info, err := s.Put(WithOriginalName(ctx, "Hello World.txt"), "hello.txt", strings.NewReader("hello"))
+			require.NoError(t, err)
+			assert.Equal(t, "text/plain; charset=utf-8", info.ContentType)
+			assert.Equal(t, `"5d41402abc4b2a76b9719d911017c592"`, info.ETag)
+			assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", info.SHA256)
+			assert.Equal(t, "Hello World.txt", info.OriginalName)
+			require.NoError(t, s.Delete(ctx, "hello.txt"))
+		},
+	)
+
+	t.Run(
+		"Overwrite", func(t *testing.T) {
+			_, err := s.Put(ctx, "b.txt", strings.NewReader("new"))
+			require.NoError(t, err)
+			assert.Equal(t, "new", read(t, "b.txt", 0, -1))
+		},
+	)
+
+	t.Run(
+		"Failed Put Is Not Committed", func(t *testing.T) {
+			_, err := s.Put(ctx, "broken.txt", io.MultiReader(strings.NewReader("partial"), errReader{}))
+			assert.Error(t, err)
+
+			_, err = s.Stat(ctx, "broken.txt")
+			assert.ErrorIs(t, err, ErrNotFound)
+		},
+	)
+
+	t.Run(
+		"List", func(t *testing.T) {
+			objects, next, err := s.List(ctx, "", "", 0)
+			require.NoError(t, err)
+			assert.Empty(t, next)
+			assert.Equal(t, []string{"a.txt", "b.txt", "dir/c.txt", "dir/sub/d.txt"}, keys(objects))
+
+			objects, _, err = s.List(ctx, "dir/", "", 0)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"dir/c.txt", "dir/sub/d.txt"}, keys(objects))
+
+			objects, _, err = s.List(ctx, "dir/s", "", 0)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"dir/sub/d.txt"}, keys(objects))
+		},
+	)
+
+	t.Run(
+		"List Cursor", func(t *testing.T) {
+			objects, next, err := s.List(ctx, "", "", 3)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"a.txt", "b.txt", "dir/c.txt"}, keys(objects))
+			assert.Equal(t, "dir/c.txt", next)
+
+			objects, next, err = s.List(ctx, "", next, 3)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"dir/sub/d.txt"}, keys(objects))
+			assert.Empty(t, next)
+		},
+	)
+
+	t.Run(
+		"ReadAt", func(t *testing.T) {
+			info, err := s.Stat(ctx, "dir/c.txt")
+			require.NoError(t, err)
+			r := NewReadSeeker(ctx, s, info)
+			defer r.Close()
+
+			buf := make([]byte, 2)
+			for _, off := range []int64{8, 10, 0} {
+				n, err := r.ReadAt(buf, off)
+				require.NoError(t, err)
+				assert.Equal(t, "content of dir/c.txt"[off:off+2], string(buf[:n]))
+			}
+
+			n, err := r.ReadAt(make([]byte, 10), info.Size-3)
+			assert.ErrorIs(t, err, io.EOF)
+			assert.Equal(t, 3, n)
+		},
+	)
+
+	t.Run(
+		"Move", func(t *testing.T) {
+			require.NoError(t, s.Move(ctx, "a.txt", "moved/a.txt"))
+			assert.Equal(t, "content of a.txt", read(t, "moved/a.txt", 0, -1))
+
+			_, err := s.Stat(ctx, "a.txt")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			assert.ErrorIs(t, s.Move(ctx, "a.txt", "other.txt"), ErrNotFound)
+		},
+	)
+
+	t.Run(
+		"Delete", func(t *testing.T) {
+			require.NoError(t, s.Delete(ctx, "b.txt"))
+			_, err := s.Stat(ctx, "b.txt")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			assert.ErrorIs(t, s.Delete(ctx, "b.txt"), ErrNotFound)
+		},
+	)
+
+	t.Run(
+		"Invalid Keys", func(t *testing.T) {
+			_, err := s.Put(ctx, "../escape.txt", strings.NewReader("x"))
+			assert.ErrorIs(t, err, ErrInvalidKey)
+
+			assert.ErrorIs(t, s.Move(ctx, "dir/c.txt", "/abs.txt"), ErrInvalidKey)
+		},
+	)
+}
+
+func TestLocalDirs(t *testing.T) {
+	ctx := context.Background()
+	s := NewLocal(t.TempDir())
+	_, err := s.Put(ctx, "docs/a.txt", strings.NewReader("a"))
+	require.NoError(t, err)
+
+	dirs, ok := As[Dirs](s)
+	require.True(t, ok)
+	_, ok = As[Dirs](NewMemory())
+	assert.False(t, ok)
+
+	t.Run(
+		"MkDir", func(t *testing.T) {
+			require.NoError(t, dirs.MkDir(ctx, "empty/nested"))
+			require.NoError(t, dirs.MkDir(ctx, "empty/nested"))
+			assert.ErrorIs(t, dirs.MkDir(ctx, "docs/a.txt/sub"), ErrInvalidKey)
+			assert.ErrorIs(t, dirs.MkDir(ctx, "../outside"), ErrInvalidKey)
+		},
+	)
+
+	t.Run(
+		"ListDirs", func(t *testing.T) {
+			all, err := dirs.ListDirs(ctx, "")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"docs", "empty", "empty/nested"}, all)
+
+			below, err := dirs.ListDirs(ctx, "empty")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"empty/nested"}, below)
+
+			_, err = dirs.ListDirs(ctx, "missing")
+			assert.ErrorIs(t, err, ErrNotFound)
+			_, err = dirs.ListDirs(ctx, "docs/a.txt")
+			assert.ErrorIs(t, err, ErrNotFound)
+		},
+	)
+
+	t.Run(
+		"MoveDir", func(t *testing.T) {
+			mover, ok := As[DirMover](s)
+			require.True(t, ok)
+			require.NoError(t, mover.MoveDir(ctx, "docs", "moved/docs"))
+			_, err := s.Stat(ctx, "moved/docs/a.txt")
+			require.NoError(t, err)
+
+			require.NoError(t, dirs.MkDir(ctx, "docs"))
+			assert.ErrorIs(t, mover.MoveDir(ctx, "moved/docs", "docs"), ErrExists)
+			assert.ErrorIs(t, mover.MoveDir(ctx, "missing", "elsewhere"), ErrNotFound)
+			assert.ErrorIs(t, mover.MoveDir(ctx, "moved/docs/a.txt", "elsewhere"), ErrNotFound)
+			require.NoError(t, dirs.RemoveDir(ctx, "docs"))
+			require.NoError(t, mover.MoveDir(ctx, "moved/docs", "docs"))
+			require.NoError(t, dirs.RemoveDir(ctx, "moved"))
+		},
+	)
+
+	t.Run(
+		"RemoveDir", func(t *testing.T) {
+			assert.ErrorIs(t, dirs.RemoveDir(ctx, "empty"), ErrNotEmpty)
+			assert.ErrorIs(t, dirs.RemoveDir(ctx, "docs"), ErrNotEmpty)
+			assert.ErrorIs(t, dirs.RemoveDir(ctx, "docs/a.txt"), ErrNotFound)
+			assert.ErrorIs(t, dirs.RemoveDir(ctx, "missing"), ErrNotFound)
+
+			require.NoError(t, dirs.RemoveDir(ctx, "empty/nested"))
+			require.NoError(t, dirs.RemoveDir(ctx, "empty"))
+			_, err := dirs.ListDirs(ctx, "empty")
+			assert.ErrorIs(t, err, ErrNotFound)
+		},
+	)
+}
+
+func TestLocalMoves(t *testing.T) {
+	ctx := context.Background()
+	src, dst := NewLocal(t.TempDir()), NewLocal(t.TempDir())
+	_, err := src.Put(ctx, "docs/a.txt", strings.NewReader("a"))
+	require.NoError(t, err)
+	before, err := os.Stat(filepath.Join(src.root, "docs", "a.txt"))
+	require.NoError(t, err)
+
+	t.Run(
+		"Delete", func(t *testing.T) {
+			require.NoError(t, src.Delete(WithMoveTo(ctx, "docs/a.txt", dst, "kept/a"), "docs/a.txt"))
+			_, err := src.Stat(ctx, "docs/a.txt")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			after, err := os.Stat(filepath.Join(dst.root, "kept", "a"))
+			require.NoError(t, err)
+			assert.True(t, os.SameFile(before, after))
+		},
+	)
+
+	t.Run(
+		"Put", func(t *testing.T) {
+			meta := Meta{ContentType: "text/plain", OriginalName: "A.txt"}
+			moved := WithMoveFrom(ctx, "docs/a.txt", dst, "kept/a", meta)
+
+			// Only the object it names is moved
+			info, err := src.Put(moved, "docs/b.txt", strings.NewReader("b"))
+			require.NoError(t, err)
+			assert.NotEqual(t, meta, info.Meta)
+
+			info, err = src.Put(moved, "docs/a.txt", strings.NewReader("a"))
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), info.Size)
+			assert.Equal(t, meta, info.Meta)
+			assert.WithinDuration(t, time.Now(), info.ModTime, time.Minute)
+
+			after, err := os.Stat(filepath.Join(src.root, "docs", "a.txt"))
+			require.NoError(t, err)
+			assert.True(t, os.SameFile(before, after))
+			_, err = dst.Stat(ctx, "kept/a")
+			assert.ErrorIs(t, err, ErrNotFound)
+		},
+	)
+}
+
+func TestLocalSymlinks(t *testing.T) {
+	ctx := context.Background()
+	root, outside := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "out")))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "secret.txt")))
+
+	s := NewLocal(root)
+	_, err := s.Put(ctx, "in/a.txt", strings.NewReader("a"))
+	require.NoError(t, err)
+	require.NoError(t, os.Symlink("in", filepath.Join(root, "alias")))
+
+	t.Run(
+		"Escaping", func(t *testing.T) {
+			for _, key := range []string{"out/secret.txt", "secret.txt"} {
+				_, err := s.Stat(ctx, key)
+				assert.ErrorIs(t, err, ErrInvalidKey, key)
+				_, _, err = s.Get(ctx, key, 0, -1)
+				assert.ErrorIs(t, err, ErrInvalidKey, key)
+				assert.ErrorIs(t, s.Delete(ctx, key), ErrInvalidKey, key)
+				assert.ErrorIs(t, s.Move(ctx, "in/a.txt", key), ErrInvalidKey, key)
+			}
+
+			_, err := s.Put(ctx, "out/new.txt", strings.NewReader("x"))
+			assert.ErrorIs(t, err, ErrInvalidKey)
+			assert.NoFileExists(t, filepath.Join(outside, "new.txt"))
+
+			_, _, err = s.List(ctx, "out/", "", 0)
+			assert.ErrorIs(t, err, ErrInvalidKey)
+			assert.FileExists(t, filepath.Join(outside, "secret.txt"))
+		},
+	)
+
+	t.Run(
+		"Inside", func(t *testing.T) {
+			info, err := s.Stat(ctx, "alias/a.txt")
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), info.Size)
+
+			objects, _, err := s.List(ctx, "", "", 0)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"in/a.txt"}, keys(objects))
+		},
+	)
+}
+
+func keys(objects []ObjectInfo) []string {
+	res := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		res = append(res, obj.Key)
+	}
+	return res
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		key      string
+		head     []byte
+		expected string
+	}{
+		{"image.bin", []byte("\x89PNG\r\n\x1a\n"), "image/png"},
+		{"song.bin", []byte("ID3\x04\x00\x00\x00\x00\x00\x00"), "audio/mpeg"},
+		{"data.json", []byte(`{"a": 1}`), "application/json"},
+		{"notes.txt", []byte("plain text"), "text/plain; charset=utf-8"},
+		{"unknown", []byte{0x00, 0x01, 0x02}, "application/octet-stream"},
+		{"archive.zip", []byte{0x00, 0x01, 0x02}, "application/zip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.key, func(t *testing.T) {
+				assert.Equal(t, tt.expected, DetectContentType(tt.key, tt.head))
+			},
+		)
+	}
+}
@@ -0,0 +1,220 @@
+// Package thumbnail renders the configured thumbnail presets of uploaded
+// images in the background. A fixed number of workers drains a bounded
+// queue; jobs that are waiting, running or have failed can be inspected
+// while finished jobs are forgotten, and so are the oldest failed ones once
+// too many have piled up.
+package thumbnail
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrQueueFull = errors.New("thumbnail queue is full")
+
+// maxFailed is how many failed jobs are kept for inspection
+const maxFailed = 1000
+
+type State string
+
+const (
+	Pending State = "pending"
+	Running State = "running"
+	Failed  State = "failed"
+)
+
+type Job struct {
+	Key     string
+	Preset  string
+	State   State
+	Error   string
+	Queued  time.Time
+	Updated time.Time
+}
+
+// Renderer produces one preset of the object stored under key
+type Renderer func(ctx context.Context, key, preset string) error
+
+type jobID struct {
+	key    string
+	preset string
+}
+
+type Pool struct {
+	workers int
+	render  Renderer
+	queue   chan jobID
+
+	mu        sync.Mutex
+	jobs      map[jobID]*Job
+	maxFailed int
+}
+
+// NewPool creates a pool of workers that render jobs through render. At most
+// queueSize jobs wait for a worker; Enqueue fails the rest right away.
+func NewPool(workers, queueSize int, render Renderer) *Pool {
+	return &Pool{
+		workers:   max(workers, 1),
+		render:    render,
+		queue:     make(chan jobID, max(queueSize, 1)),
+		jobs:      make(map[jobID]*Job),
+		maxFailed: maxFailed,
+	}
+}
+
+// Enqueue schedules presets of key and returns the jobs as queued
+func (p *Pool) Enqueue(key string, presets []string) []Job {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	queued := make([]Job, 0, len(presets))
+	for _, preset := range presets {
+		id := jobID{key: key, preset: preset}
+		job := &Job{Key: key, Preset: preset, State: Pending, Queued: now, Updated: now}
+
+		p.jobs[id] = job
+		select {
+		case p.queue <- id:
+		default:
+			p.fail(job, ErrQueueFull)
+		}
+		queued = append(queued, *job)
+	}
+	return queued
+}
+
+// Run starts the workers and blocks until ctx is cancelled. Jobs still
+// queued at that point stay pending.
+func (p *Pool) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-p.queue:
+					p.process(ctx, id)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *Pool) process(ctx context.Context, id jobID) {
+	if !p.start(id) {
+		return
+	}
+
+	err := p.render(ctx, id.key, id.preset)
+	if err != nil {
+		log.Printf("Error rendering %s thumbnail of %s: %v\n", id.preset, id.key, err)
+	}
+	p.finish(id, err)
+}
+
+// start marks a job as running. It reports false when the job was
+// forgotten while it was queued.
+func (p *Pool) start(id jobID) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	job, ok := p.jobs[id]
+	if !ok {
+		return false
+	}
+	job.State = Running
+	job.Updated = time.Now()
+	return true
+}
+
+// finish drops a successful job and records the error of a failed one
+func (p *Pool) finish(id jobID, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	job, ok := p.jobs[id]
+	if !ok {
+		return
+	}
+	if err == nil {
+		delete(p.jobs, id)
+		return
+	}
+	p.fail(job, err)
+}
+
+// fail records the error of a job and forgets the failed jobs that were
+// updated longest ago, so only maxFailed of them are kept. p.mu must be held.
+func (p *Pool) fail(job *Job, err error) {
+	job.State = Failed
+	job.Error = err.Error()
+	job.Updated = time.Now()
+
+	failed := make([]jobID, 0)
+	for id, j := range p.jobs {
+		if j.State == Failed {
+			failed = append(failed, id)
+		}
+	}
+	if len(failed) <= p.maxFailed {
+		return
+	}
+
+	sort.Slice(
+		failed, func(i, j int) bool {
+			return p.jobs[failed[i]].Updated.Before(p.jobs[failed[j]].Updated)
+		},
+	)
+	for _, id := range failed[:len(failed)-p.maxFailed] {
+		delete(p.jobs, id)
+	}
+}
+
+// Jobs returns the unfinished and failed jobs of key, or of every object
+// when key is empty, oldest first
+func (p *Pool) Jobs(key string) []Job {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	jobs := make([]Job, 0, len(p.jobs))
+	for id, job := range p.jobs {
+		if key == "" || id.key == key {
+			jobs = append(jobs, *job)
+		}
+	}
+
+	sort.Slice(
+		jobs, func(i, j int) bool {
+			if !jobs[i].Queued.Equal(jobs[j].Queued) {
+				return jobs[i].Queued.Before(jobs[j].Queued)
+			}
+			if jobs[i].Key != jobs[j].Key {
+				return jobs[i].Key < jobs[j].Key
+			}
+			return jobs[i].Preset < jobs[j].Preset
+		},
+	)
+	return jobs
+}
+
+// Forget drops every job of key, so a queued job of a deleted object is
+// skipped instead of rendered
+func (p *Pool) Forget(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for id := range p.jobs {
+		if id.key == key {
+			delete(p.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package thumbnail
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	var mu sync.Mutex
+	rendered := make(map[string]int)
+	release := make(chan struct{})
+
+	pool := NewPool(
+		2, 4, func(ctx context.Context, key, preset string) error {
+			<-release
+			mu.Lock()
+			defer mu.Unlock()
+			rendered[key+":"+preset]++
+			if preset == "broken" {
+				return errors.New("cannot render")
+			}
+			return nil
+		},
+	)
+
+	t.Run(
+		"Enqueue", func(t *testing.T) {
+			jobs := pool.Enqueue("a.png", []string{"small", "broken"})
+			require.Len(t, jobs, 2)
+			assert.Equal(t, Pending, jobs[0].State)
+			assert.Len(t, pool.Jobs(""), 2)
+			assert.Len(t, pool.Jobs("a.png"), 2)
+			assert.Empty(t, pool.Jobs("b.png"))
+		},
+	)
+
+	t.Run(
+		"Queue Full", func(t *testing.T) {
+			jobs := pool.Enqueue("b.png", []string{"small", "large", "huge"})
+			assert.Equal(t, Pending, jobs[0].State)
+			assert.Equal(t, Pending, jobs[1].State)
+			assert.Equal(t, Failed, jobs[2].State)
+			assert.Equal(t, ErrQueueFull.Error(), jobs[2].Error)
+		},
+	)
+
+	t.Run(
+		"Forget", func(t *testing.T) {
+			pool.Forget("b.png")
+			assert.Empty(t, pool.Jobs("b.png"))
+		},
+	)
+
+	t.Run(
+		"Run", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				pool.Run(ctx)
+				close(done)
+			}()
+			close(release)
+
+			require.Eventually(
+				t, func() bool {
+					jobs := pool.Jobs("")
+					return len(jobs) == 1 && jobs[0].State == Failed
+				}, time.Second, 10*time.Millisecond,
+			)
+
+			failed := pool.Jobs("a.png")[0]
+			assert.Equal(t, "broken", failed.Preset)
+			assert.Equal(t, "cannot render", failed.Error)
+
+			mu.Lock()
+			assert.Equal(t, 1, rendered["a.png:small"])
+			assert.Zero(t, rendered["b.png:small"], "forgotten jobs are skipped")
+			mu.Unlock()
+
+			cancel()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("workers did not stop")
+			}
+		},
+	)
+}
+
+func TestPoolFailedCap(t *testing.T) {
+	pool := NewPool(
+		1, 1, func(ctx context.Context, key, preset string) error {
+			return nil
+		},
+	)
+	pool.maxFailed = 2
+
+	pool.Enqueue("a.png", []string{"small"})
+	for _, key := range []string{"b.png", "c.png", "d.png"} {
+		jobs := pool.Enqueue(key, []string{"small"})
+		require.Equal(t, Failed, jobs[0].State)
+	}
+
+	assert.Len(t, pool.Jobs(""), 3)
+	assert.Len(t, pool.Jobs("a.png"), 1, "pending jobs are kept")
+	assert.Empty(t, pool.Jobs("b.png"), "the oldest failed job is forgotten")
+	assert.Len(t, pool.Jobs("d.png"), 1)
+}
@@ -0,0 +1,398 @@
+// Package trash turns deletes into moves to a trash area. The content of a
+// deleted object is moved into a store of its own, renamed when the backend
+// keeps files on the same filesystem and copied otherwise, and an item
+// recording its key and deletion time is kept in a bbolt bucket, keyed by
+// an ID that sorts by deletion time. Items can be restored to their key
+// until they are emptied or expire.
+package trash
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	bolt "go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var ErrConflict = errors.New("a file already exists at the original path")
+
+var itemsBucket = []byte("items")
+
+// openTimeout bounds how long Open waits for the file lock held by
+// another process
+const openTimeout = time.Second
+
+type record struct {
+	Key          string            `json:"key"`
+	DeletedAt    int64             `json:"deletedAt"`
+	Size         int64             `json:"size"`
+	ModTime      int64             `json:"modTime"`
+	ContentType  string            `json:"contentType,omitempty"`
+	ETag         string            `json:"etag,omitempty"`
+	SHA256       string            `json:"sha256,omitempty"`
+	OriginalName string            `json:"originalName,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	Audio        *storage.Audio    `json:"audio,omitempty"`
+}
+
+// Item is a deleted object waiting in the trash. ModTime is when the
+// object was last written and Expires when the purger removes it.
+type Item struct {
+	ID        string
+	Key       string
+	DeletedAt time.Time
+	Expires   time.Time
+	Size      int64
+	ModTime   time.Time
+	storage.Meta
+}
+
+// Storage wraps a backend so that deleted objects are moved to the trash
+// instead of being lost
+type Storage struct {
+	storage.Storage
+	db        *bolt.DB
+	blobs     *storage.Local
+	retention time.Duration
+
+	// mu keeps an item from being restored and removed at the same time
+	mu sync.Mutex
+}
+
+// Open wraps store with the trash kept in dir. Items are kept for
+// retention before Purge removes them.
+func Open(dir string, store storage.Storage, retention time.Duration) (*Storage, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, "trash.db"), 0o600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(
+		func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists(itemsBucket)
+			return err
+		},
+	)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Storage{
+		Storage:   store,
+		db:        db,
+		blobs:     storage.NewLocal(filepath.Join(dir, "files")),
+		retention: retention,
+	}, nil
+}
+
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// Unwrap returns the wrapped backend
+func (s *Storage) Unwrap() storage.Storage {
+	return s.Storage
+}
+
+// newID returns an item ID that sorts after every ID handed out before
+func newID() string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%016x%s", time.Now().UnixNano(), hex.EncodeToString(suffix))
+}
+
+// validID keeps IDs sent by clients from naming anything but an item
+func validID(id string) bool {
+	if len(id) != 24 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// blobKey spreads the content of items over directories by the random end
+// of their IDs
+func blobKey(id string) string {
+	return id[len(id)-2:] + "/" + id
+}
+
+// Delete moves key to the trash, unless the caller keeps its content. So
+// does versioning: a versioned object keeps it as its previous version.
+func (s *Storage) Delete(ctx context.Context, key string) error {
+	if storage.ContentKept(ctx) || storage.Overwritable(s.Storage, key) {
+		return s.Storage.Delete(ctx, key)
+	}
+
+	info, err := s.Storage.Stat(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	id := newID()
+	item := &Item{
+		ID:        id,
+		Key:       key,
+		DeletedAt: time.Now(),
+		Size:      info.Size,
+		ModTime:   info.ModTime,
+		Meta:      info.Meta,
+	}
+
+	// Local files are renamed into the trash by the delete itself
+	if _, ok := storage.As[*storage.Local](s.Storage); ok {
+		if err = s.put(item); err != nil {
+			return err
+		}
+		if err = s.Storage.Delete(storage.WithMoveTo(ctx, key, s.blobs, blobKey(id)), key); err != nil {
+			s.remove(ctx, id)
+			return err
+		}
+		return nil
+	}
+
+	rc, _, err := s.Storage.Get(ctx, key, 0, -1)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if _, err = s.blobs.Put(ctx, blobKey(id), rc); err != nil {
+		return err
+	}
+	if err = s.put(item); err != nil {
+		s.blobs.Delete(ctx, blobKey(id))
+		return err
+	}
+
+	if err = s.Storage.Delete(ctx, key); err != nil {
+		s.remove(ctx, id)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) put(item *Item) error {
+	data, err := json.Marshal(
+		&record{
+			Key:          item.Key,
+			DeletedAt:    item.DeletedAt.UnixNano(),
+			Size:         item.Size,
+			ModTime:      item.ModTime.UnixNano(),
+			ContentType:  item.ContentType,
+			ETag:         item.ETag,
+			SHA256:       item.SHA256,
+			OriginalName: item.OriginalName,
+			Tags:         item.Tags,
+			Audio:        item.Audio,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(
+		func(tx *bolt.Tx) error {
+			return tx.Bucket(itemsBucket).Put([]byte(item.ID), data)
+		},
+	)
+}
+
+func (s *Storage) item(id string, data []byte) (Item, error) {
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Item{}, err
+	}
+
+	deletedAt := time.Unix(0, r.DeletedAt)
+	return Item{
+		ID:        id,
+		Key:       r.Key,
+		DeletedAt: deletedAt,
+		Expires:   deletedAt.Add(s.retention),
+		Size:      r.Size,
+		ModTime:   time.Unix(0, r.ModTime),
+		Meta: storage.Meta{
+			ContentType:  r.ContentType,
+			ETag:         r.ETag,
+			SHA256:       r.SHA256,
+			OriginalName: r.OriginalName,
+			Tags:         r.Tags,
+			Audio:        r.Audio,
+		},
+	}, nil
+}
+
+// Items returns up to limit items, most recently deleted first, skipping
+// the first offset, together with the number of items in the trash. A
+// limit of 0 returns every item.
+func (s *Storage) Items(offset, limit int) ([]Item, int, error) {
+	items := make([]Item, 0)
+	count := 0
+	err := s.db.View(
+		func(tx *bolt.Tx) error {
+			b := tx.Bucket(itemsBucket)
+			count = b.Stats().KeyN
+
+			c := b.Cursor()
+			skipped := 0
+			for k, v := c.Last(); k != nil; k, v = c.Prev() {
+				if skipped < offset {
+					skipped++
+					continue
+				}
+				if limit > 0 && len(items) == limit {
+					break
+				}
+
+				item, err := s.item(string(k), v)
+				if err != nil {
+					return err
+				}
+				items = append(items, item)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, count, nil
+}
+
+// Item returns the item id, or storage.ErrNotFound
+func (s *Storage) Item(id string) (*Item, error) {
+	if !validID(id) {
+		return nil, storage.ErrNotFound
+	}
+
+	var item *Item
+	err := s.db.View(
+		func(tx *bolt.Tx) error {
+			data := tx.Bucket(itemsBucket).Get([]byte(id))
+			if data == nil {
+				return storage.ErrNotFound
+			}
+
+			i, err := s.item(id, data)
+			item = &i
+			return err
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Restore puts item id back at its key and takes it out of the trash. A
+// file stored at the key since is not replaced: Restore fails with
+// ErrConflict unless the backend keeps a version of it.
+func (s *Storage) Restore(ctx context.Context, id string) (*storage.ObjectInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, err := s.Item(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = s.Storage.Stat(ctx, item.Key); err == nil && !storage.Overwritable(s.Storage, item.Key) {
+		return nil, ErrConflict
+	}
+
+	rc, _, err := s.blobs.Get(ctx, blobKey(id), 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	// Local backends rename the file back rather than read rc
+	ctx = storage.WithMoveFrom(storage.WithMetaOf(ctx, item.Meta), item.Key, s.blobs, blobKey(id), item.Meta)
+	info, err := s.Storage.Put(ctx, item.Key, rc)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.remove(ctx, id); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Remove permanently deletes item id
+func (s *Storage) Remove(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.Item(id); err != nil {
+		return err
+	}
+	return s.remove(ctx, id)
+}
+
+// Empty permanently deletes every item, returning how many there were
+func (s *Storage) Empty(ctx context.Context) (int, error) {
+	return s.Purge(ctx, time.Time{})
+}
+
+// Purge permanently deletes the items that expired by now, or every item
+// for a zero now. Returns the number of items deleted.
+func (s *Storage) Purge(ctx context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	err := s.db.View(
+		func(tx *bolt.Tx) error {
+			c := tx.Bucket(itemsBucket).Cursor()
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				item, err := s.item(string(k), v)
+				if err != nil {
+					return err
+				}
+				// IDs sort by deletion time, so the rest expire later
+				if !now.IsZero() && item.Expires.After(now) {
+					break
+				}
+				ids = append(ids, item.ID)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, id := range ids {
+		if err = s.remove(ctx, id); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
+// remove deletes the content and then the record of item id
+func (s *Storage) remove(ctx context.Context, id string) error {
+	err := s.blobs.Delete(ctx, blobKey(id))
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	return s.db.Update(
+		func(tx *bolt.Tx) error {
+			return tx.Bucket(itemsBucket).Delete([]byte(id))
+		},
+	)
+}
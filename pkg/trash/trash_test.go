@@ -0,0 +1,201 @@
+package trash
+
+import (
+	"context"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/JMURv/simple-s3/pkg/versions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setupStorage(t *testing.T) *Storage {
+	s, err := Open(t.TempDir(), storage.NewMemory(), time.Hour)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func put(t *testing.T, s storage.Storage, key, content string) {
+	_, err := s.Put(storage.WithOriginalName(context.Background(), "Original.txt"), key, strings.NewReader(content))
+	require.NoError(t, err)
+}
+
+func read(t *testing.T, s storage.Storage, key string) string {
+	rc, _, err := s.Get(context.Background(), key, 0, -1)
+	require.NoError(t, err)
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	s := setupStorage(t)
+
+	put(t, s, "docs/a.txt", "a")
+	put(t, s, "docs/b.txt", "bb")
+	put(t, s, "c.txt", "ccc")
+
+	t.Run(
+		"Delete", func(t *testing.T) {
+			require.NoError(t, s.Delete(ctx, "docs/a.txt"))
+			require.NoError(t, s.Delete(ctx, "docs/b.txt"))
+			_, err := s.Stat(ctx, "docs/a.txt")
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+			assert.ErrorIs(t, s.Delete(ctx, "docs/a.txt"), storage.ErrNotFound)
+
+			items, count, err := s.Items(0, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 2, count)
+			require.Len(t, items, 2)
+			assert.Equal(t, "docs/b.txt", items[0].Key)
+			assert.Equal(t, "docs/a.txt", items[1].Key)
+			assert.Equal(t, int64(2), items[0].Size)
+			assert.Equal(t, "Original.txt", items[0].OriginalName)
+			assert.Equal(t, items[0].DeletedAt.Add(time.Hour), items[0].Expires)
+
+			items, count, err = s.Items(1, 1)
+			require.NoError(t, err)
+			assert.Equal(t, 2, count)
+			require.Len(t, items, 1)
+			assert.Equal(t, "docs/a.txt", items[0].Key)
+		},
+	)
+
+	t.Run(
+		"Restore", func(t *testing.T) {
+			items, _, err := s.Items(0, 0)
+			require.NoError(t, err)
+
+			info, err := s.Restore(ctx, items[1].ID)
+			require.NoError(t, err)
+			assert.Equal(t, "docs/a.txt", info.Key)
+			assert.Equal(t, "Original.txt", info.OriginalName)
+			assert.Equal(t, "a", read(t, s, "docs/a.txt"))
+
+			_, err = s.Restore(ctx, items[1].ID)
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+			_, err = s.Restore(ctx, "../../etc/passwd")
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+
+			put(t, s, "docs/b.txt", "new")
+			_, err = s.Restore(ctx, items[0].ID)
+			assert.ErrorIs(t, err, ErrConflict)
+			assert.Equal(t, "new", read(t, s, "docs/b.txt"))
+		},
+	)
+
+	t.Run(
+		"Remove", func(t *testing.T) {
+			items, _, err := s.Items(0, 0)
+			require.NoError(t, err)
+			require.Len(t, items, 1)
+
+			require.NoError(t, s.Remove(ctx, items[0].ID))
+			assert.ErrorIs(t, s.Remove(ctx, items[0].ID), storage.ErrNotFound)
+		},
+	)
+
+	t.Run(
+		"Purge", func(t *testing.T) {
+			require.NoError(t, s.Delete(ctx, "c.txt"))
+			require.NoError(t, s.Delete(ctx, "docs/a.txt"))
+
+			n, err := s.Purge(ctx, time.Now())
+			require.NoError(t, err)
+			assert.Equal(t, 0, n)
+
+			n, err = s.Purge(ctx, time.Now().Add(2*time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, 2, n)
+
+			_, count, err := s.Items(0, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 0, count)
+		},
+	)
+
+	t.Run(
+		"Empty", func(t *testing.T) {
+			require.NoError(t, s.Delete(ctx, "docs/b.txt"))
+			n, err := s.Empty(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 1, n)
+		},
+	)
+}
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	s, err := Open(t.TempDir(), storage.NewLocal(root), time.Hour)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	put(t, s, "docs/a.txt", "a")
+	before, err := os.Stat(filepath.Join(root, "docs", "a.txt"))
+	require.NoError(t, err)
+
+	t.Run(
+		"Delete Renames", func(t *testing.T) {
+			require.NoError(t, s.Delete(ctx, "docs/a.txt"))
+			items, _, err := s.Items(0, 0)
+			require.NoError(t, err)
+			require.Len(t, items, 1)
+
+			info, err := s.blobs.Stat(ctx, blobKey(items[0].ID))
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), info.Size)
+		},
+	)
+
+	t.Run(
+		"Restore Renames", func(t *testing.T) {
+			items, _, err := s.Items(0, 0)
+			require.NoError(t, err)
+
+			info, err := s.Restore(ctx, items[0].ID)
+			require.NoError(t, err)
+			assert.Equal(t, items[0].Meta, info.Meta)
+			assert.Equal(t, "a", read(t, s, "docs/a.txt"))
+
+			after, err := os.Stat(filepath.Join(root, "docs", "a.txt"))
+			require.NoError(t, err)
+			assert.True(t, os.SameFile(before, after))
+
+			_, count, err := s.Items(0, 0)
+			require.NoError(t, err)
+			assert.Equal(t, 0, count)
+		},
+	)
+}
+
+func TestVersioned(t *testing.T) {
+	ctx := context.Background()
+	vs, err := versions.Open(t.TempDir(), []string{"docs"})
+	require.NoError(t, err)
+	t.Cleanup(func() { vs.Close() })
+
+	s, err := Open(t.TempDir(), versions.Wrap(storage.NewMemory(), vs), time.Hour)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	put(t, s, "docs/a.txt", "a")
+	put(t, s, "c.txt", "c")
+	require.NoError(t, s.Delete(ctx, "docs/a.txt"))
+	require.NoError(t, s.Delete(ctx, "c.txt"))
+
+	// The previous version of docs/a.txt keeps its content instead
+	items, _, err := s.Items(0, 0)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "c.txt", items[0].Key)
+}
@@ -0,0 +1,366 @@
+// Package tus stores resumable uploads for the tus 1.0 protocol. Every upload
+// keeps its bytes in a data file and its state in a JSON sidecar, so an
+// upload survives restarts and can be resumed from the last written byte.
+package tus
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const Version = "1.0.0"
+const Extensions = "creation,termination,expiration"
+
+var ErrNotFound = errors.New("upload does not exist")
+var ErrExpired = errors.New("upload has expired")
+var ErrOffsetMismatch = errors.New("upload offset does not match")
+var ErrSizeExceeded = errors.New("upload is larger than its declared length")
+var ErrLocked = errors.New("upload is being written by another request")
+var ErrInvalidMetadata = errors.New("invalid upload metadata")
+var ErrConflict = errors.New("destination already exists")
+
+const infoExt = ".json"
+const dataExt = ".bin"
+
+type Upload struct {
+	ID        string            `json:"id"`
+	Key       string            `json:"key"`
+	Length    int64             `json:"length"`
+	Offset    int64             `json:"-"`
+	Metadata  map[string]string `json:"metadata"`
+	Expires   time.Time         `json:"expires"`
+	Completed bool              `json:"completed"`
+	// Info describes the stored file, on the write that completed the upload
+	Info *storage.ObjectInfo `json:"-"`
+}
+
+// Filename returns the file name the client sent in the upload metadata
+func (u *Upload) Filename() string {
+	if name := u.Metadata["filename"]; name != "" {
+		return name
+	}
+	return u.Metadata["name"]
+}
+
+type Store struct {
+	dir    string
+	store  storage.Storage
+	expiry time.Duration
+
+	mu    sync.Mutex
+	locks map[string]struct{}
+}
+
+func New(dir string, store storage.Storage, expiry time.Duration) *Store {
+	return &Store{
+		dir:    dir,
+		store:  store,
+		expiry: expiry,
+		locks:  make(map[string]struct{}),
+	}
+}
+
+// Create registers a new upload of length bytes that will be stored under key
+func (s *Store) Create(key string, length int64, metadata map[string]string) (*Upload, error) {
+	if !storage.ValidKey(key) {
+		return nil, storage.ErrInvalidKey
+	}
+	if err := os.MkdirAll(s.dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	upload := &Upload{
+		ID:       hex.EncodeToString(id),
+		Key:      key,
+		Length:   length,
+		Metadata: metadata,
+		Expires:  time.Now().Add(s.expiry),
+	}
+	file, err := os.Create(s.path(upload.ID, dataExt))
+	if err != nil {
+		return nil, err
+	}
+	if err = file.Close(); err != nil {
+		return nil, err
+	}
+
+	if err = s.save(upload); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// Get returns the state of an upload. The offset is taken from the data
+// file, so bytes written before a crash are never lost or counted twice.
+func (s *Store) Get(id string) (*Upload, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+
+	data, err := os.ReadFile(s.path(id, infoExt))
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	upload := &Upload{}
+	if err = json.Unmarshal(data, upload); err != nil {
+		return nil, err
+	}
+	if time.Now().After(upload.Expires) {
+		return nil, ErrExpired
+	}
+
+	if upload.Completed {
+		upload.Offset = upload.Length
+		return upload, nil
+	}
+
+	info, err := os.Stat(s.path(id, dataExt))
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	upload.Offset = info.Size()
+	return upload, nil
+}
+
+// Write appends r to the upload, which must currently be at offset. Bytes
+// received before r fails are kept so the client can resume from them.
+// Once the last byte arrives the upload is moved into the storage backend.
+func (s *Store) Write(ctx context.Context, id string, offset int64, r io.Reader) (*Upload, error) {
+	unlock, err := s.lock(id)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	upload, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Completed || offset != upload.Offset {
+		return upload, ErrOffsetMismatch
+	}
+
+	file, err := os.OpenFile(s.path(id, dataExt), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	n, copyErr := io.Copy(file, io.LimitReader(r, upload.Length-upload.Offset))
+	upload.Offset += n
+	if err = file.Close(); err != nil {
+		return nil, err
+	}
+	if copyErr != nil {
+		return upload, copyErr
+	}
+
+	if upload.Offset == upload.Length {
+		// A body longer than the upload is rejected as a whole
+		if extra, _ := r.Read(make([]byte, 1)); extra > 0 {
+			if err = os.Truncate(s.path(id, dataExt), offset); err != nil {
+				return nil, err
+			}
+			upload.Offset = offset
+			return upload, ErrSizeExceeded
+		}
+		if err = s.finish(ctx, upload); err != nil {
+			return upload, err
+		}
+	}
+
+	upload.Expires = time.Now().Add(s.expiry)
+	if err = s.save(upload); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// Terminate discards an upload and everything received for it
+func (s *Store) Terminate(id string) error {
+	unlock, err := s.lock(id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if !validID(id) {
+		return ErrNotFound
+	}
+	if _, err = os.Stat(s.path(id, infoExt)); err != nil {
+		return ErrNotFound
+	}
+	return s.remove(id)
+}
+
+// Purge removes every upload that expired before now and returns their ids
+func (s *Store) Purge(now time.Time) ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	purged := make([]string, 0)
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), infoExt)
+		if !ok || !validID(id) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		upload := &Upload{}
+		if err = json.Unmarshal(data, upload); err != nil || now.Before(upload.Expires) {
+			continue
+		}
+
+		unlock, err := s.lock(id)
+		if err != nil {
+			continue
+		}
+		err = s.remove(id)
+		unlock()
+		if err != nil {
+			return purged, err
+		}
+		purged = append(purged, id)
+	}
+
+	sort.Strings(purged)
+	return purged, nil
+}
+
+func (s *Store) finish(ctx context.Context, upload *Upload) error {
+	_, err := s.store.Stat(ctx, upload.Key)
+	if err == nil && !storage.Overwritable(s.store, upload.Key) {
+		return ErrConflict
+	}
+
+	file, err := os.Open(s.path(upload.ID, dataExt))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if name := upload.Filename(); name != "" {
+		ctx = storage.WithOriginalName(ctx, name)
+	}
+	if upload.Info, err = s.store.Put(ctx, upload.Key, file); err != nil {
+		return err
+	}
+
+	// The record is kept until it expires so a client that lost the final
+	// response can still see that the upload is complete
+	upload.Completed = true
+	return os.Remove(s.path(upload.ID, dataExt))
+}
+
+func (s *Store) save(upload *Upload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "."+upload.ID+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, bytes.NewReader(data))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(upload.ID, infoExt))
+}
+
+func (s *Store) remove(id string) error {
+	if err := os.Remove(s.path(id, dataExt)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Remove(s.path(id, infoExt))
+}
+
+// lock gives a request exclusive access to an upload. Concurrent requests
+// for the same upload fail instead of waiting, as the tus spec suggests.
+func (s *Store) lock(id string) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.locks[id]; ok {
+		return nil, ErrLocked
+	}
+	s.locks[id] = struct{}{}
+	return func() {
+		s.mu.Lock()
+		delete(s.locks, id)
+		s.mu.Unlock()
+	}, nil
+}
+
+func (s *Store) path(id, ext string) string {
+	return filepath.Join(s.dir, id+ext)
+}
+
+func validID(id string) bool {
+	_, err := hex.DecodeString(id)
+	return err == nil && len(id) == 32
+}
+
+// ParseMetadata decodes an Upload-Metadata header: comma separated pairs of
+// a key and an optional base64 encoded value
+func ParseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, ErrInvalidMetadata
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, ErrInvalidMetadata
+		}
+		metadata[key] = string(decoded)
+	}
+	return metadata, nil
+}
+
+// FormatMetadata encodes metadata for the Upload-Metadata header
+func FormatMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for key, value := range metadata {
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(value)))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
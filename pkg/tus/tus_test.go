@@ -0,0 +1,156 @@
+package tus
+
+import (
+	"context"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run(
+		"Resume", func(t *testing.T) {
+			store := storage.NewMemory()
+			s := New(t.TempDir(), store, time.Hour)
+
+			upload, err := s.Create("docs/file.txt", 11, map[string]string{"filename": "file.txt"})
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), upload.Offset)
+
+			_, err = s.Write(ctx, upload.ID, 0, io.MultiReader(strings.NewReader("hello"), &failingReader{}))
+			assert.Error(t, err)
+
+			upload, err = s.Get(upload.ID)
+			require.NoError(t, err)
+			assert.Equal(t, int64(5), upload.Offset)
+			assert.False(t, upload.Completed)
+
+			_, err = s.Write(ctx, upload.ID, 0, strings.NewReader("hello world"))
+			assert.ErrorIs(t, err, ErrOffsetMismatch)
+
+			upload, err = s.Write(ctx, upload.ID, 5, strings.NewReader(" world"))
+			require.NoError(t, err)
+			assert.True(t, upload.Completed)
+			assert.Equal(t, int64(11), upload.Offset)
+			require.NotNil(t, upload.Info)
+			assert.Equal(t, int64(11), upload.Info.Size)
+
+			rc, _, err := store.Get(ctx, "docs/file.txt", 0, -1)
+			require.NoError(t, err)
+			defer rc.Close()
+			data, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			assert.Equal(t, "hello world", string(data))
+
+			upload, err = s.Get(upload.ID)
+			require.NoError(t, err)
+			assert.True(t, upload.Completed)
+			assert.Equal(t, int64(11), upload.Offset)
+		},
+	)
+
+	t.Run(
+		"Size Exceeded", func(t *testing.T) {
+			s := New(t.TempDir(), storage.NewMemory(), time.Hour)
+			upload, err := s.Create("file.txt", 3, nil)
+			require.NoError(t, err)
+
+			_, err = s.Write(ctx, upload.ID, 0, strings.NewReader("abcd"))
+			assert.ErrorIs(t, err, ErrSizeExceeded)
+
+			upload, err = s.Get(upload.ID)
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), upload.Offset)
+		},
+	)
+
+	t.Run(
+		"Conflict", func(t *testing.T) {
+			store := storage.NewMemory()
+			s := New(t.TempDir(), store, time.Hour)
+			upload, err := s.Create("file.txt", 3, nil)
+			require.NoError(t, err)
+
+			_, err = store.Put(ctx, "file.txt", strings.NewReader("old"))
+			require.NoError(t, err)
+
+			_, err = s.Write(ctx, upload.ID, 0, strings.NewReader("new"))
+			assert.ErrorIs(t, err, ErrConflict)
+		},
+	)
+
+	t.Run(
+		"Terminate", func(t *testing.T) {
+			s := New(t.TempDir(), storage.NewMemory(), time.Hour)
+			upload, err := s.Create("file.txt", 3, nil)
+			require.NoError(t, err)
+
+			require.NoError(t, s.Terminate(upload.ID))
+			_, err = s.Get(upload.ID)
+			assert.ErrorIs(t, err, ErrNotFound)
+			assert.ErrorIs(t, s.Terminate(upload.ID), ErrNotFound)
+			assert.ErrorIs(t, s.Terminate("../../etc/passwd"), ErrNotFound)
+		},
+	)
+
+	t.Run(
+		"Expiration", func(t *testing.T) {
+			s := New(t.TempDir(), storage.NewMemory(), time.Minute)
+			expired, err := s.Create("old.txt", 3, nil)
+			require.NoError(t, err)
+
+			purged, err := s.Purge(time.Now())
+			require.NoError(t, err)
+			assert.Empty(t, purged)
+
+			purged, err = s.Purge(time.Now().Add(2 * time.Minute))
+			require.NoError(t, err)
+			assert.Equal(t, []string{expired.ID}, purged)
+
+			_, err = s.Get(expired.ID)
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			s = New(t.TempDir(), storage.NewMemory(), -time.Minute)
+			upload, err := s.Create("new.txt", 3, nil)
+			require.NoError(t, err)
+			_, err = s.Get(upload.ID)
+			assert.ErrorIs(t, err, ErrExpired)
+		},
+	)
+
+	t.Run(
+		"Invalid Key", func(t *testing.T) {
+			s := New(t.TempDir(), storage.NewMemory(), time.Hour)
+			_, err := s.Create("../escape.txt", 3, nil)
+			assert.ErrorIs(t, err, storage.ErrInvalidKey)
+		},
+	)
+}
+
+func TestMetadata(t *testing.T) {
+	metadata, err := ParseMetadata("filename d29ybGRfZG9taW5hdGlvbl9wbGFuLnBkZg==,is_confidential")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"filename": "world_domination_plan.pdf", "is_confidential": ""}, metadata)
+
+	parsed, err := ParseMetadata(FormatMetadata(metadata))
+	require.NoError(t, err)
+	assert.Equal(t, metadata, parsed)
+
+	_, err = ParseMetadata("filename not-base64!")
+	assert.ErrorIs(t, err, ErrInvalidMetadata)
+
+	_, err = ParseMetadata("filename d29ybGQ=, ,")
+	assert.ErrorIs(t, err, ErrInvalidMetadata)
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
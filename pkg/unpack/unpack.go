@@ -0,0 +1,329 @@
+// Package unpack reads ZIP, TAR and gzipped TAR archives entry by entry.
+// Entry names are cleaned into relative, slash separated paths; entries
+// that could escape the directory they are unpacked to, links and special
+// files are reported as refused instead of being handed out. The files of
+// a ZIP can also be read in place, without going through the archive.
+package unpack
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+var ErrUnsupported = errors.New("unsupported archive format")
+var ErrMalformed = errors.New("malformed archive")
+var ErrTooManyEntries = errors.New("archive has too many entries")
+var ErrTooLarge = errors.New("archive is too large when unpacked")
+var ErrNotSeekable = errors.New("archive entries can't be read in place")
+
+// Reasons an entry is refused
+var ErrUnsafePath = errors.New("entry path escapes the archive")
+var ErrLink = errors.New("entry is a link")
+var ErrSpecial = errors.New("entry is not a regular file")
+var ErrEncrypted = errors.New("entry is encrypted")
+
+const (
+	FormatZip   = "zip"
+	FormatTar   = "tar"
+	FormatTarGz = "tar.gz"
+)
+
+// headerLen covers the magic of every supported format, the TAR one
+// sitting at tarMagicOffset
+const headerLen = 512
+const tarMagicOffset = 257
+
+const zipFlagEncrypted = 0x1
+
+// Limits bound what unpacking a single archive may produce. Every entry
+// that isn't a directory counts towards MaxEntries, refused ones included;
+// MaxSize bounds the total unpacked size of the accepted ones.
+type Limits struct {
+	MaxEntries int
+	MaxSize    int64
+}
+
+// Entry describes a file in an archive
+type Entry struct {
+	// Name is the cleaned path of the entry
+	Name string
+	// RawName is the path as stored in the archive
+	RawName string
+	Size    int64
+	ModTime time.Time
+	// Err tells why the entry is refused
+	Err error
+}
+
+type Archive struct {
+	Format string
+
+	r    io.ReaderAt
+	size int64
+	zip  *zip.Reader
+}
+
+// Open detects the format of the archive in r from its content
+func Open(r io.ReaderAt, size int64) (*Archive, error) {
+	head := make([]byte, headerLen)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	head = head[:n]
+
+	a := &Archive{r: r, size: size}
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")) || bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		a.Format = FormatZip
+		if a.zip, err = zip.NewReader(r, size); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+	case bytes.HasPrefix(head, []byte{0x1F, 0x8B}):
+		gz, err := gzip.NewReader(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		// A compressed file is only an archive when a TAR is inside
+		inner := make([]byte, headerLen)
+		n, _ := io.ReadFull(gz, inner)
+		if !isTar(inner[:n]) {
+			return nil, ErrUnsupported
+		}
+		a.Format = FormatTarGz
+	case isTar(head):
+		a.Format = FormatTar
+	default:
+		return nil, ErrUnsupported
+	}
+	return a, nil
+}
+
+func isTar(head []byte) bool {
+	return len(head) >= tarMagicOffset+5 && string(head[tarMagicOffset:tarMagicOffset+5]) == "ustar"
+}
+
+// Check lists the entries of the archive without reading their content and
+// fails as soon as they exceed limits
+func (a *Archive) Check(limits Limits) ([]Entry, error) {
+	entries := make([]Entry, 0, 64)
+	var total int64
+	err := a.walk(
+		func(e *Entry, _ func() (io.ReadCloser, error)) error {
+			entries = append(entries, *e)
+			if limits.MaxEntries > 0 && len(entries) > limits.MaxEntries {
+				return ErrTooManyEntries
+			}
+			if e.Err == nil {
+				total += e.Size
+			}
+			if limits.MaxSize > 0 && total > limits.MaxSize {
+				return ErrTooLarge
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Walk calls fn for every entry of the archive in the order they are
+// stored. Directories are left out since they follow from the paths of
+// the files. content is nil for refused entries and only valid during the
+// call. An error returned by fn stops the walk and is returned.
+func (a *Archive) Walk(fn func(e *Entry, content io.Reader) error) error {
+	return a.walk(
+		func(e *Entry, open func() (io.ReadCloser, error)) error {
+			if e.Err != nil {
+				return fn(e, nil)
+			}
+
+			rc, err := open()
+			if err != nil {
+				e.Err = err
+				return fn(e, nil)
+			}
+			defer rc.Close()
+			return fn(e, rc)
+		},
+	)
+}
+
+// File is a file of a ZIP archive, which can be read without going
+// through the rest of the archive
+type File struct {
+	Entry
+	// Stored files are kept uncompressed, so any part of them can be read
+	// straight from the archive
+	Stored         bool
+	CompressedSize int64
+	CRC32          uint32
+
+	archive *Archive
+	zip     *zip.File
+}
+
+// Files lists the files Walk would hand out without refusing them. Only
+// ZIP archives have a directory to find them by; the files of a TAR are
+// reported as ErrNotSeekable.
+func (a *Archive) Files() ([]File, error) {
+	if a.Format != FormatZip {
+		return nil, ErrNotSeekable
+	}
+
+	files := make([]File, 0, len(a.zip.File))
+	for _, f := range a.zip.File {
+		if e := zipEntry(f); e != nil && e.Err == nil {
+			files = append(
+				files, File{
+					Entry:          *e,
+					Stored:         f.Method == zip.Store && f.CompressedSize64 == f.UncompressedSize64,
+					CompressedSize: int64(f.CompressedSize64),
+					CRC32:          f.CRC32,
+					archive:        a,
+					zip:            f,
+				},
+			)
+		}
+	}
+	return files, nil
+}
+
+// Open returns the content of the file, decompressing it if needed
+func (f *File) Open() (io.ReadCloser, error) {
+	return f.zip.Open()
+}
+
+// Section returns the content of a stored file as the part of the archive
+// holding it, so it can be read from any offset
+func (f *File) Section() (*io.SectionReader, error) {
+	if !f.Stored {
+		return nil, ErrNotSeekable
+	}
+
+	offset, err := f.zip.DataOffset()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return io.NewSectionReader(f.archive.r, offset, f.Size), nil
+}
+
+func (a *Archive) walk(fn func(e *Entry, open func() (io.ReadCloser, error)) error) error {
+	if a.Format == FormatZip {
+		return a.walkZip(fn)
+	}
+	return a.walkTar(fn)
+}
+
+func (a *Archive) walkZip(fn func(e *Entry, open func() (io.ReadCloser, error)) error) error {
+	for _, f := range a.zip.File {
+		if e := zipEntry(f); e != nil {
+			if err := fn(e, f.Open); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// zipEntry describes a file of a ZIP archive, returning nil for directories
+func zipEntry(f *zip.File) *Entry {
+	mode := f.Mode()
+	if mode.IsDir() || strings.HasSuffix(f.Name, "/") {
+		return nil
+	}
+
+	e := &Entry{RawName: f.Name, Size: int64(f.UncompressedSize64), ModTime: f.Modified}
+	e.Name, e.Err = CleanName(f.Name)
+	switch {
+	case e.Err != nil:
+	case mode&fs.ModeSymlink != 0:
+		e.Err = ErrLink
+	case !mode.IsRegular():
+		e.Err = ErrSpecial
+	case f.Flags&zipFlagEncrypted != 0:
+		e.Err = ErrEncrypted
+	}
+	return e
+}
+
+func (a *Archive) walkTar(fn func(e *Entry, open func() (io.ReadCloser, error)) error) error {
+	var r io.Reader = io.NewSectionReader(a.r, 0, a.size)
+	if a.Format == FormatTarGz {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			continue
+		}
+
+		e := &Entry{RawName: hdr.Name, Size: hdr.Size, ModTime: hdr.ModTime}
+		e.Name, e.Err = CleanName(hdr.Name)
+		switch {
+		case e.Err != nil:
+		case hdr.Typeflag == tar.TypeSymlink || hdr.Typeflag == tar.TypeLink:
+			e.Err = ErrLink
+		case hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA:
+			e.Err = ErrSpecial
+		}
+
+		open := func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }
+		if err = fn(e, open); err != nil {
+			return err
+		}
+	}
+}
+
+// CleanName turns the path of an entry into a relative, slash separated
+// path. Absolute paths, drive letters, NUL bytes and .. segments are
+// refused with ErrUnsafePath rather than stripped, since an archive
+// carrying them was not made for unpacking in place.
+func CleanName(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if name == "" || strings.ContainsRune(name, 0) || strings.HasPrefix(name, "/") {
+		return "", ErrUnsafePath
+	}
+	if len(name) >= 2 && name[1] == ':' {
+		return "", ErrUnsafePath
+	}
+
+	segments := make([]string, 0, strings.Count(name, "/")+1)
+	for _, s := range strings.Split(name, "/") {
+		switch s {
+		case "", ".":
+			continue
+		case "..":
+			return "", ErrUnsafePath
+		}
+		segments = append(segments, s)
+	}
+	if len(segments) == 0 {
+		return "", ErrUnsafePath
+	}
+	return strings.Join(segments, "/"), nil
+}
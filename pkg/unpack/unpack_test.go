@@ -0,0 +1,261 @@
+package unpack
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/fs"
+	"testing"
+)
+
+type testEntry struct {
+	name    string
+	content string
+	mode    fs.FileMode
+}
+
+var testEntries = []testEntry{
+	{"pack/", "", fs.ModeDir | 0755},
+	{"pack/readme.txt", "hello", 0644},
+	{"pack/img/logo.png", "png", 0644},
+	{"../escape.txt", "evil", 0644},
+	{"pack/link", "/etc/passwd", fs.ModeSymlink | 0777},
+}
+
+func testZip(t *testing.T, entries []testEntry) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		hdr.SetMode(e.mode)
+		w, err := zw.CreateHeader(hdr)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(e.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func testTar(t *testing.T, entries []testEntry, compress bool) []byte {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	gz := gzip.NewWriter(&buf)
+	if compress {
+		w = gz
+	}
+
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: int64(e.mode.Perm()), Typeflag: tar.TypeReg, Size: int64(len(e.content))}
+		switch {
+		case e.mode.IsDir():
+			hdr.Typeflag, hdr.Size = tar.TypeDir, 0
+		case e.mode&fs.ModeSymlink != 0:
+			hdr.Typeflag, hdr.Size, hdr.Linkname = tar.TypeSymlink, 0, e.content
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		if hdr.Typeflag == tar.TypeReg {
+			_, err := tw.Write([]byte(e.content))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	if compress {
+		require.NoError(t, gz.Close())
+	}
+	return buf.Bytes()
+}
+
+func TestWalk(t *testing.T) {
+	archives := map[string][]byte{
+		FormatZip:   testZip(t, testEntries),
+		FormatTar:   testTar(t, testEntries, false),
+		FormatTarGz: testTar(t, testEntries, true),
+	}
+
+	for format, data := range archives {
+		t.Run(
+			format, func(t *testing.T) {
+				a, err := Open(bytes.NewReader(data), int64(len(data)))
+				require.NoError(t, err)
+				assert.Equal(t, format, a.Format)
+
+				contents := make(map[string]string)
+				refused := make(map[string]error)
+				err = a.Walk(
+					func(e *Entry, content io.Reader) error {
+						if e.Err != nil {
+							assert.Nil(t, content)
+							refused[e.RawName] = e.Err
+							return nil
+						}
+						data, err := io.ReadAll(content)
+						require.NoError(t, err)
+						contents[e.Name] = string(data)
+						return nil
+					},
+				)
+				require.NoError(t, err)
+
+				assert.Equal(t, map[string]string{"pack/readme.txt": "hello", "pack/img/logo.png": "png"}, contents)
+				assert.Equal(t, map[string]error{"../escape.txt": ErrUnsafePath, "pack/link": ErrLink}, refused)
+			},
+		)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	data := testZip(t, testEntries)
+	a, err := Open(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	t.Run(
+		"Within Limits", func(t *testing.T) {
+			entries, err := a.Check(Limits{MaxEntries: 4, MaxSize: 8})
+			require.NoError(t, err)
+			assert.Len(t, entries, 4)
+		},
+	)
+
+	t.Run(
+		"Too Many Entries", func(t *testing.T) {
+			_, err := a.Check(Limits{MaxEntries: 3})
+			assert.ErrorIs(t, err, ErrTooManyEntries)
+		},
+	)
+
+	t.Run(
+		"Too Large", func(t *testing.T) {
+			// Refused entries don't count towards the size
+			_, err := a.Check(Limits{MaxSize: 7})
+			assert.ErrorIs(t, err, ErrTooLarge)
+		},
+	)
+}
+
+func TestOpen(t *testing.T) {
+	t.Run(
+		"Not An Archive", func(t *testing.T) {
+			_, err := Open(bytes.NewReader([]byte("plain text")), 10)
+			assert.ErrorIs(t, err, ErrUnsupported)
+		},
+	)
+
+	t.Run(
+		"Compressed File", func(t *testing.T) {
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			gz.Write([]byte("plain text"))
+			gz.Close()
+
+			_, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			assert.ErrorIs(t, err, ErrUnsupported)
+		},
+	)
+
+	t.Run(
+		"Truncated Zip", func(t *testing.T) {
+			data := testZip(t, testEntries)
+			_, err := Open(bytes.NewReader(data[:40]), 40)
+			assert.ErrorIs(t, err, ErrMalformed)
+		},
+	)
+}
+
+func TestCleanName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		err      error
+	}{
+		{"a/b.txt", "a/b.txt", nil},
+		{"./a//b.txt", "a/b.txt", nil},
+		{"a\\b.txt", "a/b.txt", nil},
+		{"a/./b/", "a/b", nil},
+		{"../b.txt", "", ErrUnsafePath},
+		{"a/../../b.txt", "", ErrUnsafePath},
+		{"a\\..\\b.txt", "", ErrUnsafePath},
+		{"/etc/passwd", "", ErrUnsafePath},
+		{"C:\\Windows\\win.ini", "", ErrUnsafePath},
+		{"a\x00.txt", "", ErrUnsafePath},
+		{"./", "", ErrUnsafePath},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				name, err := CleanName(tt.name)
+				assert.ErrorIs(t, err, tt.err)
+				assert.Equal(t, tt.expected, name)
+			},
+		)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range []struct {
+		name   string
+		method uint16
+	}{
+		{"stored.txt", zip.Store},
+		{"deflated.txt", zip.Deflate},
+		{"../escape.txt", zip.Store},
+	} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: e.name, Method: e.method})
+		require.NoError(t, err)
+		w.Write([]byte("content of " + e.name))
+	}
+	require.NoError(t, zw.Close())
+
+	a, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	files, err := a.Files()
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	t.Run(
+		"Stored", func(t *testing.T) {
+			assert.Equal(t, "stored.txt", files[0].Name)
+			assert.True(t, files[0].Stored)
+
+			section, err := files[0].Section()
+			require.NoError(t, err)
+			part := make([]byte, 6)
+			_, err = section.ReadAt(part, 11)
+			require.NoError(t, err)
+			assert.Equal(t, "stored", string(part))
+		},
+	)
+
+	t.Run(
+		"Deflated", func(t *testing.T) {
+			assert.False(t, files[1].Stored)
+			_, err := files[1].Section()
+			assert.ErrorIs(t, err, ErrNotSeekable)
+
+			rc, err := files[1].Open()
+			require.NoError(t, err)
+			defer rc.Close()
+			data, err := io.ReadAll(rc)
+			require.NoError(t, err)
+			assert.Equal(t, "content of deflated.txt", string(data))
+		},
+	)
+
+	t.Run(
+		"Tar", func(t *testing.T) {
+			data := testTar(t, testEntries, false)
+			a, err := Open(bytes.NewReader(data), int64(len(data)))
+			require.NoError(t, err)
+			_, err = a.Files()
+			assert.ErrorIs(t, err, ErrNotSeekable)
+		},
+	)
+}
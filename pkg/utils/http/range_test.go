@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected []ByteRange
+		err      error
+	}{
+		{"", nil, nil},
+		{"bytes=0-4", []ByteRange{{0, 5}}, nil},
+		{"bytes=5-", []ByteRange{{5, 5}}, nil},
+		{"bytes=-3", []ByteRange{{7, 3}}, nil},
+		{"bytes=-30", []ByteRange{{0, 10}}, nil},
+		{"bytes=8-100", []ByteRange{{8, 2}}, nil},
+		{"bytes=0-1, 4-5", []ByteRange{{0, 2}, {4, 2}}, nil},
+		{"bytes=20-30, 0-0", []ByteRange{{0, 1}}, nil},
+		{"bytes=10-", nil, ErrUnsatisfiableRange},
+		{"bytes=20-30", nil, ErrUnsatisfiableRange},
+		{"bytes=-0", nil, ErrUnsatisfiableRange},
+		{"items=0-4", nil, nil},
+		{"bytes=4-2", nil, nil},
+		{"bytes=a-b", nil, nil},
+		{"bytes=0-9,0-9", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.header, func(t *testing.T) {
+				ranges, err := ParseRange(tt.header, 10)
+				assert.Equal(t, tt.err, err)
+				assert.Equal(t, tt.expected, ranges)
+			},
+		)
+	}
+
+	assert.Equal(t, "bytes 2-4/10", ByteRange{2, 3}.ContentRange(10))
+}
+
+func TestPreconditions(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 500, time.UTC)
+	etag := `"abc"`
+
+	tests := []struct {
+		name     string
+		method   string
+		headers  map[string]string
+		expected int
+	}{
+		{"None", http.MethodGet, nil, 0},
+		{"If-None-Match Hit", http.MethodGet, map[string]string{"If-None-Match": `"x", "abc"`}, http.StatusNotModified},
+		{"If-None-Match Weak", http.MethodGet, map[string]string{"If-None-Match": `W/"abc"`}, http.StatusNotModified},
+		{"If-None-Match Star", http.MethodHead, map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{"If-None-Match Miss", http.MethodGet, map[string]string{"If-None-Match": `"other"`}, 0},
+		{"If-None-Match Unsafe", http.MethodPut, map[string]string{"If-None-Match": `"abc"`}, http.StatusPreconditionFailed},
+		{"If-Modified-Since Same", http.MethodGet, map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)}, http.StatusNotModified},
+		{"If-Modified-Since Older", http.MethodGet, map[string]string{"If-Modified-Since": modTime.Add(-time.Hour).Format(http.TimeFormat)}, 0},
+		{
+			"If-None-Match Wins", http.MethodGet, map[string]string{
+				"If-None-Match":     `"other"`,
+				"If-Modified-Since": modTime.Format(http.TimeFormat),
+			}, 0,
+		},
+		{"If-Match Miss", http.MethodGet, map[string]string{"If-Match": `"other"`}, http.StatusPreconditionFailed},
+		{"If-Match Weak", http.MethodGet, map[string]string{"If-Match": `W/"abc"`}, http.StatusPreconditionFailed},
+		{"If-Unmodified-Since Older", http.MethodGet, map[string]string{"If-Unmodified-Since": modTime.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(tt.method, "/", nil)
+				for k, v := range tt.headers {
+					req.Header.Set(k, v)
+				}
+				assert.Equal(t, tt.expected, CheckPreconditions(req, etag, modTime))
+			},
+		)
+	}
+}
+
+func TestRangeApplies(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		ifRange  string
+		etag     string
+		expected bool
+	}{
+		{"", `"abc"`, true},
+		{`"abc"`, `"abc"`, true},
+		{`"old"`, `"abc"`, false},
+		{`W/"abc"`, `W/"abc"`, false},
+		{modTime.Format(http.TimeFormat), `"abc"`, true},
+		{modTime.Add(time.Second).Format(http.TimeFormat), `"abc"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.ifRange, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				if tt.ifRange != "" {
+					req.Header.Set("If-Range", tt.ifRange)
+				}
+				assert.Equal(t, tt.expected, RangeApplies(req, tt.etag, modTime))
+			},
+		)
+	}
+}
@@ -0,0 +1,225 @@
+// Package mediatype detects the media type of a file from its leading bytes
+// instead of trusting its extension.
+package mediatype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"strings"
+)
+
+// SniffLen is how many leading bytes Detect looks at
+const SniffLen = 4096
+
+const Unknown = "application/octet-stream"
+
+type signature struct {
+	offset int
+	magic  []byte
+	typ    string
+}
+
+// signatures are checked in order, so more specific ones come first
+var signatures = []signature{
+	{0, []byte("\xFF\xD8\xFF"), "image/jpeg"},
+	{0, []byte("\x89PNG\r\n\x1A\n"), "image/png"},
+	{0, []byte("GIF87a"), "image/gif"},
+	{0, []byte("GIF89a"), "image/gif"},
+	{0, []byte("BM"), "image/bmp"},
+	{0, []byte("II*\x00"), "image/tiff"},
+	{0, []byte("MM\x00*"), "image/tiff"},
+	{0, []byte("\x00\x00\x01\x00"), "image/x-icon"},
+	{0, []byte("8BPS"), "image/vnd.adobe.photoshop"},
+
+	{0, []byte("fLaC"), "audio/flac"},
+	{0, []byte("ID3"), "audio/mpeg"},
+	{0, []byte("#!AMR"), "audio/amr"},
+	{0, []byte("MThd"), "audio/midi"},
+	{0, []byte("FORM"), "audio/aiff"},
+
+	{0, []byte("FLV\x01"), "video/x-flv"},
+	{0, []byte("\x00\x00\x01\xBA"), "video/mpeg"},
+	{0, []byte("\x00\x00\x01\xB3"), "video/mpeg"},
+
+	{0, []byte("%PDF-"), "application/pdf"},
+	{0, []byte("{\\rtf"), "application/rtf"},
+	{0, []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1"), "application/x-ole-storage"},
+	{0, []byte("%!PS"), "application/postscript"},
+	{0, []byte("\x1F\x8B"), "application/gzip"},
+	{0, []byte("7z\xBC\xAF\x27\x1C"), "application/x-7z-compressed"},
+	{0, []byte("Rar!\x1A\x07"), "application/vnd.rar"},
+	{257, []byte("ustar"), "application/x-tar"},
+}
+
+// ftypBrands maps ISO base media major brands to media types
+var ftypBrands = map[string]string{
+	"qt  ": "video/quicktime",
+	"M4A ": "audio/mp4",
+	"M4B ": "audio/mp4",
+	"M4V ": "video/x-m4v",
+	"3gp4": "video/3gpp",
+	"3gp5": "video/3gpp",
+	"3g2a": "video/3gpp2",
+	"avif": "image/avif",
+	"avis": "image/avif",
+	"heic": "image/heic",
+	"heix": "image/heic",
+	"mif1": "image/heif",
+	"msf1": "image/heif",
+}
+
+// Detect returns the media type of a file starting with head, or Unknown.
+// Text formats without a signature are reported as text/plain.
+func Detect(head []byte) string {
+	switch {
+	case riff(head, "WEBP"):
+		return "image/webp"
+	case riff(head, "WAVE"):
+		return "audio/wav"
+	case riff(head, "AVI "):
+		return "video/x-msvideo"
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		if typ, ok := ftypBrands[string(head[8:12])]; ok {
+			return typ
+		}
+		return "video/mp4"
+	case len(head) >= 8 && isQuickTimeAtom(string(head[4:8])):
+		return "video/quicktime"
+	case bytes.HasPrefix(head, []byte("\x1A\x45\xDF\xA3")):
+		return ebml(head)
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return ogg(head)
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return zip(head)
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xF6 == 0xF0:
+		return "audio/aac"
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		return "audio/mpeg"
+	case len(head) > 188 && head[0] == 0x47 && head[188] == 0x47:
+		return "video/mp2t"
+	}
+
+	for _, sig := range signatures {
+		if len(head) >= sig.offset+len(sig.magic) && bytes.Equal(head[sig.offset:sig.offset+len(sig.magic)], sig.magic) {
+			return sig.typ
+		}
+	}
+
+	if svg(head) {
+		return "image/svg+xml"
+	}
+
+	// Leave text and markup to the standard library
+	if sniffed := http.DetectContentType(head); strings.HasPrefix(sniffed, "text/") {
+		return sniffed
+	}
+	return Unknown
+}
+
+// Allowed reports whether typ matches one of patterns. A pattern is either
+// a full media type or a top level type followed by "/*", e.g. "video/*".
+func Allowed(typ string, patterns []string) bool {
+	typ, _, _ = strings.Cut(typ, ";")
+	typ = strings.TrimSpace(typ)
+	for _, p := range patterns {
+		if p == "*/*" || strings.EqualFold(p, typ) {
+			return true
+		}
+		if top, ok := strings.CutSuffix(p, "/*"); ok && strings.HasPrefix(typ, top+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func riff(head []byte, format string) bool {
+	return len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == format
+}
+
+// isQuickTimeAtom recognises old QuickTime files that don't start with ftyp
+func isQuickTimeAtom(atom string) bool {
+	switch atom {
+	case "moov", "mdat", "wide", "free", "skip", "pnot":
+		return true
+	}
+	return false
+}
+
+// ebml tells Matroska and WebM apart by the EBML DocType element
+func ebml(head []byte) string {
+	i := bytes.Index(head, []byte("\x42\x82"))
+	if i < 0 || i+3 > len(head) {
+		return "video/x-matroska"
+	}
+
+	size := int(head[i+2] & 0x7F)
+	doc := head[i+3 : min(i+3+size, len(head))]
+	if bytes.HasPrefix(doc, []byte("webm")) {
+		return "video/webm"
+	}
+	return "video/x-matroska"
+}
+
+// ogg inspects the first packet to tell audio from video streams
+func ogg(head []byte) string {
+	switch {
+	case bytes.Contains(head, []byte("\x80theora")):
+		return "video/ogg"
+	case bytes.Contains(head, []byte("OpusHead")), bytes.Contains(head, []byte("\x01vorbis")),
+		bytes.Contains(head, []byte("\x7FFLAC")), bytes.Contains(head, []byte("Speex   ")):
+		return "audio/ogg"
+	}
+	return "application/ogg"
+}
+
+// zip recognises the container formats built on top of ZIP by their
+// mimetype entry or by the names of the first entries
+func zip(head []byte) string {
+	// Open Document and EPUB store their media type uncompressed first
+	if name := zipEntryName(head); name == "mimetype" {
+		start := 30 + len(name) + int(binary.LittleEndian.Uint16(head[28:30]))
+		if start < len(head) {
+			rest := head[start:]
+			for _, typ := range []string{
+				"application/epub+zip",
+				"application/vnd.oasis.opendocument.text",
+				"application/vnd.oasis.opendocument.spreadsheet",
+				"application/vnd.oasis.opendocument.presentation",
+			} {
+				if bytes.HasPrefix(rest, []byte(typ)) {
+					return typ
+				}
+			}
+		}
+	}
+
+	switch {
+	case bytes.Contains(head, []byte("word/")):
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	case bytes.Contains(head, []byte("xl/")):
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case bytes.Contains(head, []byte("ppt/")):
+		return "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	}
+	return "application/zip"
+}
+
+func zipEntryName(head []byte) string {
+	if len(head) < 30 {
+		return ""
+	}
+	n := int(binary.LittleEndian.Uint16(head[26:28]))
+	if 30+n > len(head) {
+		return ""
+	}
+	return string(head[30 : 30+n])
+}
+
+func svg(head []byte) bool {
+	text := bytes.TrimSpace(head)
+	if !bytes.HasPrefix(text, []byte("<")) {
+		return false
+	}
+	return bytes.Contains(text, []byte("<svg"))
+}
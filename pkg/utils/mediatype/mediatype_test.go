@@ -0,0 +1,85 @@
+package mediatype
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func ftyp(brand string) []byte {
+	return []byte("\x00\x00\x00\x18ftyp" + brand + "\x00\x00\x02\x00isomiso2")
+}
+
+func ebmlHeader(docType string) []byte {
+	header := []byte("\x1A\x45\xDF\xA3\x9F\x42\x86\x81\x01\x42\xF7\x81\x01\x42\x82")
+	return append(append(header, byte(0x80|len(docType))), docType...)
+}
+
+func zipEntry(name, data string) []byte {
+	header := []byte("PK\x03\x04\x14\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+	header = append(header, byte(len(name)), 0, 0, 0)
+	return append(append(header, name...), data...)
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		head     []byte
+		expected string
+	}{
+		{"JPEG", []byte("\xFF\xD8\xFF\xE0\x00\x10JFIF"), "image/jpeg"},
+		{"PNG", []byte("\x89PNG\r\n\x1A\n\x00\x00\x00\x0DIHDR"), "image/png"},
+		{"GIF", []byte("GIF89a\x01\x00"), "image/gif"},
+		{"WebP", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), "image/webp"},
+		{"AVIF", ftyp("avif"), "image/avif"},
+		{"HEIC", ftyp("heic"), "image/heic"},
+		{"SVG", []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"></svg>`), "image/svg+xml"},
+		{"MP3 ID3", []byte("ID3\x04\x00\x00\x00\x00\x00\x00"), "audio/mpeg"},
+		{"MP3 Frame", []byte("\xFF\xFB\x90\x64\x00"), "audio/mpeg"},
+		{"AAC", []byte("\xFF\xF1\x50\x80\x00"), "audio/aac"},
+		{"OGG Vorbis", []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00\x01vorbis"), "audio/ogg"},
+		{"OGG Opus", []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00OpusHead"), "audio/ogg"},
+		{"OGG Theora", []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00\x80theora"), "video/ogg"},
+		{"FLAC", []byte("fLaC\x00\x00\x00\x22"), "audio/flac"},
+		{"WAV", []byte("RIFF\x24\x08\x00\x00WAVEfmt "), "audio/wav"},
+		{"M4A", ftyp("M4A "), "audio/mp4"},
+		{"MP4", ftyp("isom"), "video/mp4"},
+		{"MOV", ftyp("qt  "), "video/quicktime"},
+		{"MOV Legacy", []byte("\x00\x00\x00\x08wide\x00\x00\x00\x00mdat"), "video/quicktime"},
+		{"MKV", ebmlHeader("matroska"), "video/x-matroska"},
+		{"WebM", ebmlHeader("webm"), "video/webm"},
+		{"AVI", []byte("RIFF\x24\x08\x00\x00AVI LIST"), "video/x-msvideo"},
+		{"PDF", []byte("%PDF-1.7\n"), "application/pdf"},
+		{"RTF", []byte(`{\rtf1\ansi`), "application/rtf"},
+		{"DOC", []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1\x00"), "application/x-ole-storage"},
+		{"DOCX", zipEntry("[Content_Types].xml", "...word/document.xml"), "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"XLSX", zipEntry("xl/workbook.xml", ""), "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+		{"ODT", zipEntry("mimetype", "application/vnd.oasis.opendocument.text"), "application/vnd.oasis.opendocument.text"},
+		{"EPUB", zipEntry("mimetype", "application/epub+zip"), "application/epub+zip"},
+		{"ZIP", zipEntry("notes.txt", "hello"), "application/zip"},
+		{"Text", []byte("just some text"), "text/plain; charset=utf-8"},
+		{"HTML", []byte("<!DOCTYPE html><html></html>"), "text/html; charset=utf-8"},
+		{"Binary", []byte("\x00\x01\x02\x03\x04"), Unknown},
+		{"Empty", nil, "text/plain; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.expected, Detect(tt.head))
+			},
+		)
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	patterns := []string{"image/*", "video/mp4", "audio/ogg"}
+
+	assert.True(t, Allowed("image/png", patterns))
+	assert.True(t, Allowed("video/mp4", patterns))
+	assert.True(t, Allowed("audio/ogg; codecs=opus", patterns))
+	assert.False(t, Allowed("video/webm", patterns))
+	assert.False(t, Allowed("imagex/png", patterns))
+	assert.False(t, Allowed("text/plain; charset=utf-8", patterns))
+	assert.True(t, Allowed("application/pdf", []string{"*/*"}))
+	assert.False(t, Allowed("image/png", nil))
+}
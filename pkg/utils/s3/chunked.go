@@ -0,0 +1,145 @@
+package s3
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MaxChunkSize bounds a single aws-chunked frame so a malformed header
+// can't make the server allocate arbitrary amounts of memory
+const MaxChunkSize = 16 << 20
+
+var ErrMalformedChunk = errors.New("malformed aws-chunked body")
+var ErrMissingChunkSignature = errors.New("aws-chunked frame is not signed")
+
+// IsChunked reports whether the request payload uses the aws-chunked
+// content encoding that SDKs emit for streaming uploads
+func IsChunked(r *http.Request) bool {
+	if strings.HasPrefix(r.Header.Get("x-amz-content-sha256"), "STREAMING-") {
+		return true
+	}
+	for _, enc := range strings.Split(r.Header.Get("Content-Encoding"), ",") {
+		if strings.TrimSpace(enc) == "aws-chunked" {
+			return true
+		}
+	}
+	return false
+}
+
+// ChunkedReader strips aws-chunked framing from a request body:
+//
+//	hex-size[;chunk-signature=sig]\r\n
+//	data\r\n
+//	...
+//	0[;chunk-signature=sig]\r\n
+//	[trailer-name:value\r\n]...
+//	\r\n
+//
+// When verify is not nil every frame, including the terminating empty one,
+// must carry a chunk-signature that verify accepts.
+type ChunkedReader struct {
+	r      *bufio.Reader
+	verify func(sig string, chunk []byte) error
+	buf    []byte
+	off    int
+	done   bool
+	err    error
+}
+
+func NewChunkedReader(r io.Reader, verify func(sig string, chunk []byte) error) *ChunkedReader {
+	return &ChunkedReader{
+		r:      bufio.NewReader(r),
+		verify: verify,
+	}
+}
+
+func (c *ChunkedReader) Read(p []byte) (int, error) {
+	for c.off >= len(c.buf) {
+		if c.err != nil {
+			return 0, c.err
+		}
+		if c.done {
+			return 0, io.EOF
+		}
+		if c.err = c.next(); c.err != nil {
+			c.buf, c.off = nil, 0
+		}
+	}
+
+	n := copy(p, c.buf[c.off:])
+	c.off += n
+	return n, nil
+}
+
+func (c *ChunkedReader) next() error {
+	line, err := c.readLine()
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+
+	sizeStr, ext, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeStr), 16, 64)
+	if err != nil || size < 0 || size > MaxChunkSize {
+		return ErrMalformedChunk
+	}
+
+	sig, signed := strings.CutPrefix(strings.TrimSpace(ext), "chunk-signature=")
+	if c.verify != nil && !signed {
+		return ErrMissingChunkSignature
+	}
+
+	if size == 0 {
+		c.done = true
+		c.buf, c.off = nil, 0
+		if c.verify != nil {
+			if err = c.verify(sig, nil); err != nil {
+				return err
+			}
+		}
+		return c.readTrailers()
+	}
+
+	if int64(cap(c.buf)) < size {
+		c.buf = make([]byte, size)
+	}
+	c.buf, c.off = c.buf[:size], 0
+	if _, err = io.ReadFull(c.r, c.buf); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+
+	if crlf, err := c.readLine(); err != nil || crlf != "" {
+		return ErrMalformedChunk
+	}
+
+	if c.verify != nil {
+		return c.verify(sig, c.buf)
+	}
+	return nil
+}
+
+func (c *ChunkedReader) readTrailers() error {
+	for {
+		line, err := c.readLine()
+		if err == io.EOF || (err == nil && line == "") {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *ChunkedReader) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && line == "" {
+			return "", io.EOF
+		}
+		return "", io.ErrUnexpectedEOF
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
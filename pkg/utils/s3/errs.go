@@ -0,0 +1,40 @@
+package s3
+
+import "net/http"
+
+// Error is an S3 API error code together with the HTTP status it maps to
+type Error struct {
+	Code       string
+	Message    string
+	StatusCode int
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+var ErrNoSuchBucket = &Error{"NoSuchBucket", "The specified bucket does not exist.", http.StatusNotFound}
+var ErrNoSuchKey = &Error{"NoSuchKey", "The specified key does not exist.", http.StatusNotFound}
+var ErrInvalidArgument = &Error{"InvalidArgument", "Invalid Argument.", http.StatusBadRequest}
+var ErrInvalidObjectName = &Error{"InvalidObjectName", "The specified object name is not valid.", http.StatusBadRequest}
+var ErrInvalidTag = &Error{"InvalidTag", "The tag provided was not a valid tag.", http.StatusBadRequest}
+var ErrInvalidDigest = &Error{"InvalidDigest", "The Content-MD5 you specified is not valid.", http.StatusBadRequest}
+var ErrBadDigest = &Error{"BadDigest", "The Content-MD5 you specified did not match what we received.", http.StatusBadRequest}
+var ErrEntityTooLarge = &Error{"EntityTooLarge", "Your proposed upload exceeds the maximum allowed object size.", http.StatusBadRequest}
+var ErrIncompleteBody = &Error{"IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header.", http.StatusBadRequest}
+var ErrInvalidRange = &Error{"InvalidRange", "The requested range is not satisfiable.", http.StatusRequestedRangeNotSatisfiable}
+var ErrMethodNotAllowed = &Error{"MethodNotAllowed", "The specified method is not allowed against this resource.", http.StatusMethodNotAllowed}
+var ErrNotImplemented = &Error{"NotImplemented", "A header you provided implies functionality that is not implemented.", http.StatusNotImplemented}
+var ErrAccessDenied = &Error{"AccessDenied", "Access Denied.", http.StatusForbidden}
+var ErrExpiredRequest = &Error{"AccessDenied", "Request has expired.", http.StatusForbidden}
+var ErrInvalidAccessKeyID = &Error{"InvalidAccessKeyId", "The AWS access key ID you provided does not exist in our records.", http.StatusForbidden}
+var ErrSignatureDoesNotMatch = &Error{"SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.", http.StatusForbidden}
+var ErrRequestTimeTooSkewed = &Error{"RequestTimeTooSkewed", "The difference between the request time and the server's time is too large.", http.StatusForbidden}
+var ErrAuthorizationMalformed = &Error{"AuthorizationHeaderMalformed", "The authorization header or query parameters you provided are not valid.", http.StatusBadRequest}
+var ErrContentSHA256Mismatch = &Error{"XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.", http.StatusBadRequest}
+var ErrNoSuchUpload = &Error{"NoSuchUpload", "The specified multipart upload does not exist.", http.StatusNotFound}
+var ErrInvalidPart = &Error{"InvalidPart", "One or more of the specified parts could not be found or its entity tag did not match.", http.StatusBadRequest}
+var ErrInvalidPartOrder = &Error{"InvalidPartOrder", "The list of parts was not in ascending order.", http.StatusBadRequest}
+var ErrEntityTooSmall = &Error{"EntityTooSmall", "Your proposed upload is smaller than the minimum allowed object size.", http.StatusBadRequest}
+var ErrMalformedXML = &Error{"MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema.", http.StatusBadRequest}
+var ErrInternalError = &Error{"InternalError", "We encountered an internal error. Please try again.", http.StatusInternalServerError}
@@ -7,6 +7,8 @@ import (
 	"strings"
 )
 
+// IsValidPath reports whether p is free of the characters Windows refuses
+// in file names. It says nothing about where p leads.
 func IsValidPath(p string) bool {
 	return !strings.ContainsAny(p, `<>:"|?*`)
 }
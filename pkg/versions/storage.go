@@ -0,0 +1,391 @@
+package versions
+
+import (
+	"context"
+	"errors"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Storage wraps a backend and versions the objects below the prefixes of
+// its store. Objects elsewhere are passed through untouched.
+type Storage struct {
+	storage.Storage
+	store *Store
+
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock serialises the writes to one key, so a version is never
+// replaced before it has been copied aside
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+func Wrap(store storage.Storage, versions *Store) *Storage {
+	return &Storage{Storage: store, store: versions, locks: make(map[string]*keyLock)}
+}
+
+// Unwrap returns the wrapped backend
+func (s *Storage) Unwrap() storage.Storage {
+	return s.Storage
+}
+
+// Versioned reports whether writes to key are versioned
+func (s *Storage) Versioned(key string) bool {
+	return s.store.Versioned(key)
+}
+
+// lock locks keys, in order so two writes can't wait for each other
+func (s *Storage) lock(keys ...string) func() {
+	sort.Strings(keys)
+	held := make(map[string]*keyLock, len(keys))
+	for _, key := range keys {
+		if _, ok := held[key]; ok {
+			continue
+		}
+
+		s.mu.Lock()
+		l, ok := s.locks[key]
+		if !ok {
+			l = &keyLock{}
+			s.locks[key] = l
+		}
+		l.refs++
+		s.mu.Unlock()
+
+		l.Lock()
+		held[key] = l
+	}
+
+	return func() {
+		for key, l := range held {
+			l.Unlock()
+			s.mu.Lock()
+			if l.refs--; l.refs == 0 {
+				delete(s.locks, key)
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *Storage) Put(ctx context.Context, key string, r io.Reader) (*storage.ObjectInfo, error) {
+	if !s.store.Versioned(key) {
+		return s.Storage.Put(ctx, key, r)
+	}
+	defer s.lock(key)()
+
+	if err := s.archive(ctx, key); err != nil {
+		return nil, err
+	}
+	info, err := s.Storage.Put(ctx, key, r)
+	if err != nil {
+		return nil, err
+	}
+	s.record(info)
+	return info, nil
+}
+
+// Delete leaves a delete marker behind a versioned object, keeping its
+// content as the previous version
+func (s *Storage) Delete(ctx context.Context, key string) error {
+	if !s.store.Versioned(key) {
+		return s.Storage.Delete(ctx, key)
+	}
+	defer s.lock(key)()
+
+	if err := s.archive(ctx, key); err != nil {
+		return err
+	}
+	if err := s.Storage.Delete(ctx, key); err != nil {
+		return err
+	}
+	s.mark(key)
+	return nil
+}
+
+// Move versions a move like a delete of src followed by a write of dst
+func (s *Storage) Move(ctx context.Context, src, dst string) error {
+	srcVersioned, dstVersioned := s.store.Versioned(src), s.store.Versioned(dst)
+	if !srcVersioned && !dstVersioned {
+		return s.Storage.Move(ctx, src, dst)
+	}
+	defer s.lock(src, dst)()
+
+	if srcVersioned {
+		if err := s.archive(ctx, src); err != nil {
+			return err
+		}
+	}
+	if dstVersioned {
+		if err := s.archive(ctx, dst); err != nil {
+			return err
+		}
+	}
+	if err := s.Storage.Move(ctx, src, dst); err != nil {
+		return err
+	}
+
+	if srcVersioned {
+		s.mark(src)
+	}
+	if dstVersioned {
+		info, err := s.Storage.Stat(ctx, dst)
+		if err != nil {
+			return err
+		}
+		s.record(info)
+	}
+	return nil
+}
+
+// MoveDir renames a directory in one step as long as nothing in or below
+// either directory is versioned. Otherwise the files have to be moved one
+// by one, so each of them is versioned.
+func (s *Storage) MoveDir(ctx context.Context, src, dst string) error {
+	if s.store.overlaps(src) || s.store.overlaps(dst) {
+		return errors.ErrUnsupported
+	}
+
+	mover, ok := storage.As[storage.DirMover](s.Storage)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	return mover.MoveDir(ctx, src, dst)
+}
+
+// archive copies the current content of key aside, so it survives as a
+// previous version once the object is replaced or deleted
+func (s *Storage) archive(ctx context.Context, key string) error {
+	info, err := s.Storage.Stat(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	versions, err := s.store.list(key, false)
+	if err != nil {
+		return err
+	}
+
+	v := current(versions, info)
+	untracked := v == nil
+	if untracked {
+		v = &Version{Key: key, ID: newID(), Size: info.Size, ModTime: info.ModTime, Meta: info.Meta}
+	} else if _, err = s.store.blobs.Stat(ctx, blobKey(v.ID)); err == nil {
+		// Already copied by a write that failed afterwards
+		return nil
+	}
+
+	rc, _, err := s.Storage.Get(ctx, key, 0, -1)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if _, err = s.store.blobs.Put(ctx, blobKey(v.ID), rc); err != nil {
+		return err
+	}
+	if untracked {
+		return s.store.put(v)
+	}
+	return nil
+}
+
+// record adds a version for an object that was just written
+func (s *Storage) record(info *storage.ObjectInfo) {
+	v := &Version{Key: info.Key, ID: newID(), Size: info.Size, ModTime: info.ModTime, Meta: info.Meta}
+	if err := s.store.put(v); err != nil {
+		log.Printf("Error recording version of %s: %v\n", info.Key, err)
+	}
+}
+
+// mark adds a delete marker for an object that was just deleted
+func (s *Storage) mark(key string) {
+	v := &Version{Key: key, ID: newID(), ModTime: time.Now(), DeleteMarker: true}
+	if err := s.store.put(v); err != nil {
+		log.Printf("Error recording deletion of %s: %v\n", key, err)
+	}
+}
+
+// Versions lists the versions of key, newest first. With dir set, key is
+// a directory and the versions of every versioned object below it are
+// listed, ordered by key. Objects stored without versioning are reported
+// as their only version, with NullID.
+func (s *Storage) Versions(ctx context.Context, key string, dir bool) ([]Version, error) {
+	recorded, err := s.store.list(key, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string][]Version)
+	for _, v := range recorded {
+		byKey[v.Key] = append(byKey[v.Key], v)
+	}
+
+	live := make(map[string]*storage.ObjectInfo)
+	if dir {
+		prefix := ""
+		if key != "" {
+			prefix = key + "/"
+		}
+		objects, _, err := s.Storage.List(ctx, prefix, "", 0)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
+		for i := range objects {
+			if s.store.Versioned(objects[i].Key) {
+				live[objects[i].Key] = &objects[i]
+			}
+		}
+	} else {
+		info, err := s.Storage.Stat(ctx, key)
+		if err == nil {
+			live[key] = info
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	keys := make([]string, 0, len(byKey)+len(live))
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	for k := range live {
+		if _, ok := byKey[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 && !dir {
+		return nil, storage.ErrNotFound
+	}
+	sort.Strings(keys)
+
+	res := make([]Version, 0, len(recorded)+len(live))
+	for _, k := range keys {
+		versions := byKey[k]
+		info := live[k]
+		if v := current(versions, info); v != nil {
+			v.Latest = true
+		} else if info != nil {
+			versions = append(
+				versions, Version{Key: k, ID: NullID, Size: info.Size, ModTime: info.ModTime, Latest: true, Meta: info.Meta},
+			)
+		} else if n := len(versions); n > 0 && versions[n-1].DeleteMarker {
+			versions[n-1].Latest = true
+		}
+
+		for i := len(versions) - 1; i >= 0; i-- {
+			res = append(res, versions[i])
+		}
+	}
+	return res, nil
+}
+
+// Version describes one version of key
+func (s *Storage) Version(ctx context.Context, key, id string) (*Version, error) {
+	versions, err := s.Versions(ctx, key, false)
+	if err != nil {
+		return nil, err
+	}
+	for i := range versions {
+		if versions[i].ID == id {
+			return &versions[i], nil
+		}
+	}
+	return nil, storage.ErrNotFound
+}
+
+// GetVersion reads the content of a version like storage.Storage.Get
+func (s *Storage) GetVersion(ctx context.Context, key, id string, offset, length int64) (io.ReadCloser, *Version, error) {
+	v, err := s.Version(ctx, key, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if v.DeleteMarker {
+		return nil, nil, ErrDeleteMarker
+	}
+
+	var rc io.ReadCloser
+	if v.Latest {
+		rc, _, err = s.Storage.Get(ctx, key, offset, length)
+	} else {
+		rc, _, err = s.store.blobs.Get(ctx, blobKey(v.ID), offset, length)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return rc, v, nil
+}
+
+// Restore makes a copy of a previous version the current one. The version
+// it replaces becomes a previous version in turn.
+func (s *Storage) Restore(ctx context.Context, key, id string) (*storage.ObjectInfo, error) {
+	v, err := s.Version(ctx, key, id)
+	if err != nil {
+		return nil, err
+	}
+	if v.DeleteMarker {
+		return nil, ErrDeleteMarker
+	}
+	if v.Latest {
+		return nil, ErrCurrent
+	}
+
+	rc, _, err := s.store.blobs.Get(ctx, blobKey(v.ID), 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return s.Put(storage.WithMetaOf(ctx, v.Meta), key, rc)
+}
+
+// Purge permanently deletes a previous version or a delete marker of key.
+// An empty id purges all of them. The current version is left alone; it
+// is deleted like any object. Returns the number of versions purged.
+func (s *Storage) Purge(ctx context.Context, key, id string) (int, error) {
+	defer s.lock(key)()
+
+	versions, err := s.Versions(ctx, key, false)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, v := range versions {
+		if id != "" && v.ID != id {
+			continue
+		}
+		if v.Latest && !v.DeleteMarker {
+			if id != "" {
+				return 0, ErrCurrent
+			}
+			continue
+		}
+
+		if !v.DeleteMarker {
+			err := s.store.blobs.Delete(ctx, blobKey(v.ID))
+			if err != nil && !errors.Is(err, storage.ErrNotFound) {
+				return purged, err
+			}
+		}
+		if err = s.store.delete(key, v.ID); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	if id != "" && purged == 0 {
+		return 0, storage.ErrNotFound
+	}
+	return purged, nil
+}
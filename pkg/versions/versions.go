@@ -0,0 +1,240 @@
+// Package versions keeps the previous versions of objects below configured
+// prefixes. Every write records a version and every delete a delete
+// marker in a bbolt bucket keyed by object key and version ID, so the
+// versions of a key sort oldest first. The current version stays where it
+// is in the backend; the content of older ones is copied aside into a
+// separate store when they are replaced.
+package versions
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	bolt "go.etcd.io/bbolt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrDeleteMarker = errors.New("version is a delete marker")
+var ErrCurrent = errors.New("version is the current one")
+
+// NullID stands for a current object written without versioning, e.g.
+// before its prefix was versioned or behind the server's back. It gets an
+// ID of its own once it is replaced.
+const NullID = "null"
+
+var versionsBucket = []byte("versions")
+
+// openTimeout bounds how long Open waits for the file lock held by
+// another process
+const openTimeout = time.Second
+
+type record struct {
+	Size         int64             `json:"size"`
+	ModTime      int64             `json:"modTime"`
+	ContentType  string            `json:"contentType,omitempty"`
+	ETag         string            `json:"etag,omitempty"`
+	SHA256       string            `json:"sha256,omitempty"`
+	OriginalName string            `json:"originalName,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	Audio        *storage.Audio    `json:"audio,omitempty"`
+	DeleteMarker bool              `json:"deleteMarker,omitempty"`
+}
+
+// Version describes one version of an object. ModTime is when a delete
+// marker was placed.
+type Version struct {
+	Key          string
+	ID           string
+	Size         int64
+	ModTime      time.Time
+	DeleteMarker bool
+	// Latest is the current version, or the delete marker hiding the object
+	Latest bool
+	storage.Meta
+}
+
+type Store struct {
+	db       *bolt.DB
+	blobs    storage.Storage
+	prefixes []string
+}
+
+// Open opens the versions kept in dir for the objects below prefixes
+func Open(dir string, prefixes []string) (*Store, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, "versions.db"), 0o600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(
+		func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists(versionsBucket)
+			return err
+		},
+	)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	prefixes = append([]string(nil), prefixes...)
+	for i, p := range prefixes {
+		prefixes[i] = strings.Trim(p, "/")
+	}
+	return &Store{db: db, blobs: storage.NewLocal(filepath.Join(dir, "blobs")), prefixes: prefixes}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Versioned reports whether key lies below one of the versioned prefixes
+func (s *Store) Versioned(key string) bool {
+	for _, p := range s.prefixes {
+		if p == "" || key == p || strings.HasPrefix(key, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// overlaps reports whether anything in or below the directory dir is
+// versioned
+func (s *Store) overlaps(dir string) bool {
+	for _, p := range s.prefixes {
+		if p == "" || dir == p || strings.HasPrefix(dir, p+"/") || strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// newID returns a version ID that sorts after every ID handed out before
+func newID() string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%016x%s", time.Now().UnixNano(), hex.EncodeToString(suffix))
+}
+
+// blobKey spreads the content of versions over directories by the random
+// end of their IDs
+func blobKey(id string) string {
+	return id[len(id)-2:] + "/" + id
+}
+
+// dbKey orders versions by key and then by ID. Keys never hold NUL bytes.
+func dbKey(key, id string) []byte {
+	return []byte(key + "\x00" + id)
+}
+
+func (s *Store) put(v *Version) error {
+	data, err := json.Marshal(
+		&record{
+			Size:         v.Size,
+			ModTime:      v.ModTime.UnixNano(),
+			ContentType:  v.ContentType,
+			ETag:         v.ETag,
+			SHA256:       v.SHA256,
+			OriginalName: v.OriginalName,
+			Tags:         v.Tags,
+			Audio:        v.Audio,
+			DeleteMarker: v.DeleteMarker,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(
+		func(tx *bolt.Tx) error {
+			return tx.Bucket(versionsBucket).Put(dbKey(v.Key, v.ID), data)
+		},
+	)
+}
+
+func (s *Store) delete(key, id string) error {
+	return s.db.Update(
+		func(tx *bolt.Tx) error {
+			return tx.Bucket(versionsBucket).Delete(dbKey(key, id))
+		},
+	)
+}
+
+// list returns the recorded versions of key, oldest first. With dir set,
+// key is a directory and the versions of every key below it are returned,
+// ordered by key.
+func (s *Store) list(key string, dir bool) ([]Version, error) {
+	prefix := []byte(key + "\x00")
+	if dir {
+		prefix = []byte("")
+		if key != "" {
+			prefix = []byte(key + "/")
+		}
+	}
+
+	versions := make([]Version, 0)
+	err := s.db.View(
+		func(tx *bolt.Tx) error {
+			c := tx.Bucket(versionsBucket).Cursor()
+			for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+				key, id, ok := strings.Cut(string(k), "\x00")
+				if !ok {
+					continue
+				}
+
+				var r record
+				if err := json.Unmarshal(v, &r); err != nil {
+					return err
+				}
+				versions = append(
+					versions, Version{
+						Key:          key,
+						ID:           id,
+						Size:         r.Size,
+						ModTime:      time.Unix(0, r.ModTime),
+						DeleteMarker: r.DeleteMarker,
+						Meta: storage.Meta{
+							ContentType:  r.ContentType,
+							ETag:         r.ETag,
+							SHA256:       r.SHA256,
+							OriginalName: r.OriginalName,
+							Tags:         r.Tags,
+							Audio:        r.Audio,
+						},
+					},
+				)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// current picks the version of key that is the object info currently
+// stored: the latest version, as long as the object hasn't been replaced
+// behind the server's back since
+func current(versions []Version, info *storage.ObjectInfo) *Version {
+	if info == nil || len(versions) == 0 {
+		return nil
+	}
+
+	latest := &versions[len(versions)-1]
+	if latest.DeleteMarker || latest.Size != info.Size || !latest.ModTime.Equal(info.ModTime) {
+		return nil
+	}
+	return latest
+}
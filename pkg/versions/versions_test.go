@@ -0,0 +1,205 @@
+package versions
+
+import (
+	"context"
+	"github.com/JMURv/simple-s3/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+)
+
+func setupStorage(t *testing.T) (*Storage, storage.Storage) {
+	store, err := Open(t.TempDir(), []string{"docs/"})
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+
+	backend := storage.NewMemory()
+	return Wrap(backend, store), backend
+}
+
+func put(t *testing.T, s storage.Storage, key, content string) *storage.ObjectInfo {
+	info, err := s.Put(context.Background(), key, strings.NewReader(content))
+	require.NoError(t, err)
+	return info
+}
+
+func read(t *testing.T, s *Storage, key, id string) string {
+	rc, _, err := s.GetVersion(context.Background(), key, id, 0, -1)
+	require.NoError(t, err)
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestVersioned(t *testing.T) {
+	store, err := Open(t.TempDir(), []string{"/docs/", "reports"})
+	require.NoError(t, err)
+	defer store.Close()
+
+	assert.True(t, store.Versioned("docs/a.txt"))
+	assert.True(t, store.Versioned("reports/2024/q1.txt"))
+	assert.False(t, store.Versioned("docsx/a.txt"))
+	assert.False(t, store.Versioned("a.txt"))
+	assert.True(t, store.overlaps("reports/2024"))
+	assert.True(t, store.overlaps("reports"))
+	assert.False(t, store.overlaps("docsx"))
+
+	all, err := Open(t.TempDir(), []string{""})
+	require.NoError(t, err)
+	defer all.Close()
+	assert.True(t, all.Versioned("a.txt"))
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	s, backend := setupStorage(t)
+
+	put(t, s, "docs/a.txt", "one")
+	put(t, s, "docs/a.txt", "two")
+	put(t, s, "other.txt", "plain")
+	put(t, s, "other.txt", "replaced")
+
+	t.Run(
+		"Versions", func(t *testing.T) {
+			versions, err := s.Versions(ctx, "docs/a.txt", false)
+			require.NoError(t, err)
+			require.Len(t, versions, 2)
+			assert.True(t, versions[0].Latest)
+			assert.False(t, versions[1].Latest)
+			assert.Greater(t, versions[0].ID, versions[1].ID)
+
+			assert.Equal(t, "two", read(t, s, "docs/a.txt", versions[0].ID))
+			assert.Equal(t, "one", read(t, s, "docs/a.txt", versions[1].ID))
+
+			_, err = s.Versions(ctx, "missing.txt", false)
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+		},
+	)
+
+	t.Run(
+		"Unversioned", func(t *testing.T) {
+			versions, err := s.Versions(ctx, "other.txt", false)
+			require.NoError(t, err)
+			require.Len(t, versions, 1)
+			assert.Equal(t, NullID, versions[0].ID)
+			assert.Equal(t, "replaced", read(t, s, "other.txt", NullID))
+		},
+	)
+
+	t.Run(
+		"Written Behind The Server's Back", func(t *testing.T) {
+			put(t, backend, "docs/a.txt", "three")
+			versions, err := s.Versions(ctx, "docs/a.txt", false)
+			require.NoError(t, err)
+			require.Len(t, versions, 3)
+			assert.Equal(t, NullID, versions[0].ID)
+
+			// The unknown content is kept once it is replaced
+			put(t, s, "docs/a.txt", "four")
+			versions, err = s.Versions(ctx, "docs/a.txt", false)
+			require.NoError(t, err)
+			require.Len(t, versions, 4)
+			assert.Equal(t, "three", read(t, s, "docs/a.txt", versions[1].ID))
+		},
+	)
+
+	t.Run(
+		"Delete", func(t *testing.T) {
+			require.NoError(t, s.Delete(ctx, "docs/a.txt"))
+			_, err := s.Stat(ctx, "docs/a.txt")
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+
+			versions, err := s.Versions(ctx, "docs/a.txt", false)
+			require.NoError(t, err)
+			require.Len(t, versions, 5)
+			assert.True(t, versions[0].DeleteMarker)
+			assert.True(t, versions[0].Latest)
+			assert.Equal(t, "four", read(t, s, "docs/a.txt", versions[1].ID))
+
+			_, _, err = s.GetVersion(ctx, "docs/a.txt", versions[0].ID, 0, -1)
+			assert.ErrorIs(t, err, ErrDeleteMarker)
+			assert.ErrorIs(t, s.Delete(ctx, "docs/a.txt"), storage.ErrNotFound)
+		},
+	)
+
+	t.Run(
+		"Restore", func(t *testing.T) {
+			versions, err := s.Versions(ctx, "docs/a.txt", false)
+			require.NoError(t, err)
+			oldest := versions[len(versions)-1]
+
+			info, err := s.Restore(ctx, "docs/a.txt", oldest.ID)
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), info.Size)
+
+			versions, err = s.Versions(ctx, "docs/a.txt", false)
+			require.NoError(t, err)
+			require.Len(t, versions, 6)
+			assert.True(t, versions[0].Latest)
+			assert.Equal(t, "one", read(t, s, "docs/a.txt", versions[0].ID))
+
+			_, err = s.Restore(ctx, "docs/a.txt", versions[0].ID)
+			assert.ErrorIs(t, err, ErrCurrent)
+			_, err = s.Restore(ctx, "docs/a.txt", versions[1].ID)
+			assert.ErrorIs(t, err, ErrDeleteMarker)
+			_, err = s.Restore(ctx, "docs/a.txt", "missing")
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+		},
+	)
+
+	t.Run(
+		"Move", func(t *testing.T) {
+			require.NoError(t, s.Move(ctx, "docs/a.txt", "docs/b.txt"))
+
+			versions, err := s.Versions(ctx, "docs/a.txt", false)
+			require.NoError(t, err)
+			assert.True(t, versions[0].DeleteMarker)
+			assert.Equal(t, "one", read(t, s, "docs/a.txt", versions[1].ID))
+
+			versions, err = s.Versions(ctx, "docs/b.txt", false)
+			require.NoError(t, err)
+			require.Len(t, versions, 1)
+			assert.True(t, versions[0].Latest)
+		},
+	)
+
+	t.Run(
+		"Directory", func(t *testing.T) {
+			versions, err := s.Versions(ctx, "docs", true)
+			require.NoError(t, err)
+			assert.Len(t, versions, 8)
+			assert.Equal(t, "docs/a.txt", versions[0].Key)
+			assert.Equal(t, "docs/b.txt", versions[7].Key)
+		},
+	)
+
+	t.Run(
+		"Purge", func(t *testing.T) {
+			versions, err := s.Versions(ctx, "docs/a.txt", false)
+			require.NoError(t, err)
+
+			n, err := s.Purge(ctx, "docs/a.txt", versions[1].ID)
+			require.NoError(t, err)
+			assert.Equal(t, 1, n)
+			_, _, err = s.GetVersion(ctx, "docs/a.txt", versions[1].ID, 0, -1)
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+
+			_, err = s.Purge(ctx, "docs/b.txt", NullID)
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+			current, err := s.Versions(ctx, "docs/b.txt", false)
+			require.NoError(t, err)
+			_, err = s.Purge(ctx, "docs/b.txt", current[0].ID)
+			assert.ErrorIs(t, err, ErrCurrent)
+
+			n, err = s.Purge(ctx, "docs/a.txt", "")
+			require.NoError(t, err)
+			assert.Equal(t, len(versions)-1, n)
+			_, err = s.Versions(ctx, "docs/a.txt", false)
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+		},
+	)
+}